@@ -32,7 +32,7 @@ func InitDB() *gorm.DB {
 	}
 
 	// Auto Migrate the models
-	err = db.AutoMigrate(&models.Course{})
+	err = db.AutoMigrate(&models.Course{}, &models.CourseInvitation{})
 	if err != nil {
 		log.Fatalf("Failed to auto migrate models: %v", err)
 	}
@@ -1,6 +1,13 @@
 package firebase
 
-import "fmt"
+import (
+	"fmt"
+
+	googleStorage "cloud.google.com/go/storage"
+)
+
+// ErrFileNotFound is the error, wrapped, of operations on files that don't exist.
+var ErrFileNotFound = googleStorage.ErrObjectNotExist
 
 type BucketError struct {
 	err error
@@ -25,3 +32,7 @@ func NewDeleteFileError(err error) *DeleteFileError {
 func (e *DeleteFileError) Error() string {
 	return fmt.Errorf("error deleting file: %v", e.err).Error()
 }
+
+func (e *DeleteFileError) Unwrap() error {
+	return e.err
+}
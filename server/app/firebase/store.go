@@ -157,6 +157,70 @@ func (s *CloudStorage) DeleteFile(path string) error {
 	return nil
 }
 
+// WriteFile stores data as a publicly readable file in Firebase Storage, replacing any file at the path.
+//
+// Parameters:
+//   - path: The path of the file in the storage bucket.
+//   - data: The content of the file.
+//   - contentType: The MIME type of the content.
+//
+// Returns:
+//   - string: The public URL of the file.
+//   - error: An error if the upload fails.
+func (s *CloudStorage) WriteFile(path string, data []byte, contentType string) (string, error) {
+	ctx := context.Background()
+
+	bucket, err := s.client.Bucket(s.bucket)
+	if err != nil {
+		return "", InvalidBucket(err)
+	}
+
+	obj := bucket.Object(path)
+	writer := obj.NewWriter(ctx)
+	writer.ContentType = contentType
+	if _, err := writer.Write(data); err != nil {
+		_ = writer.Close()
+		return "", fmt.Errorf("error writing file to firebase: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("error closing writer: %v", err)
+	}
+
+	if err := obj.ACL().Set(ctx, googleStorage.AllUsers, googleStorage.RoleReader); err != nil {
+		return "", fmt.Errorf("error setting file to public: %v", err)
+	}
+
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error getting file attributes: %v", err)
+	}
+	return attrs.MediaLink, nil
+}
+
+// OpenFile opens a file in Firebase Storage for reading. Close it when done.
+//
+// Parameters:
+//   - path: The path of the file in the storage bucket.
+//
+// Returns:
+//   - io.ReadCloser: The content of the file.
+//   - error: An error wrapping ErrFileNotFound if the file doesn't exist, or another error if it cannot be read.
+func (s *CloudStorage) OpenFile(path string) (io.ReadCloser, error) {
+	ctx := context.Background()
+
+	bucket, err := s.client.Bucket(s.bucket)
+	if err != nil {
+		return nil, InvalidBucket(err)
+	}
+
+	reader, err := bucket.Object(path).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	return reader, nil
+}
+
 // GetFileURL retrieves the public URL of a file in Firebase Storage.
 //
 // Parameters:
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccommodationHandler handles HTTP requests for per-student quiz accommodations.
+type AccommodationHandler struct {
+	serv *services.AccommodationService
+}
+
+// NewAccommodationHandler creates a new AccommodationHandler with the given AccommodationService.
+func NewAccommodationHandler(serv *services.AccommodationService) *AccommodationHandler {
+	return &AccommodationHandler{serv: serv}
+}
+
+// ListAccommodations lists the accommodations of a quiz.
+//
+// Method: GET
+// Route: /api/quizzes/:id/accommodations
+//
+// Returns:
+//   - 200 OK: Returns the accommodations as JSON.
+//   - 400 Bad Request: If the quiz ID is invalid.
+//   - 403 Forbidden: If the user may not manage the quiz.
+func (h *AccommodationHandler) ListAccommodations(c *gin.Context) {
+	quizID, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	accommodations, err := h.serv.ListAccommodations(quizID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accommodations": accommodations})
+}
+
+// SetAccommodation creates or replaces the accommodation of a student for a quiz.
+//
+// Method: PUT
+// Route: /api/quizzes/:id/accommodations/:enrollmentId
+//
+// Request Body:
+//   - durationMultiplier: Multiplies the duration of the quiz, at least 1 (optional)
+//   - extraMinutes: Minutes added to the duration (optional)
+//   - startTime, endTime: A window replacing the one of the quiz (optional)
+//   - extraAttempts: Attempts allowed on top of the quiz's maximum (optional)
+//
+// Returns:
+//   - 200 OK: Returns the accommodation as JSON.
+//   - 400 Bad Request: If an ID or the accommodation is invalid.
+//   - 403 Forbidden: If the user may not manage the quiz.
+//   - 404 Not Found: If the quiz or enrollment doesn't exist.
+func (h *AccommodationHandler) SetAccommodation(c *gin.Context) {
+	quizID, enrollmentID, ok := accommodationParams(c)
+	if !ok {
+		return
+	}
+
+	var input models.QuizAccommodation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	accommodation, err := h.serv.SetAccommodation(quizID, enrollmentID, &input)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accommodation": accommodation})
+}
+
+// DeleteAccommodation removes the accommodation of a student for a quiz.
+//
+// Method: DELETE
+// Route: /api/quizzes/:id/accommodations/:enrollmentId
+//
+// Returns:
+//   - 204 No Content: If the accommodation was removed.
+//   - 400 Bad Request: If an ID is invalid.
+//   - 403 Forbidden: If the user may not manage the quiz.
+//   - 404 Not Found: If the accommodation doesn't exist.
+func (h *AccommodationHandler) DeleteAccommodation(c *gin.Context) {
+	quizID, enrollmentID, ok := accommodationParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.serv.DeleteAccommodation(quizID, enrollmentID); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleDeleted(c, "accommodation deleted successfully")
+}
+
+// accommodationParams parses the quiz and enrollment IDs from the URL,
+// responding with 400 Bad Request if either is invalid.
+func accommodationParams(c *gin.Context) (quizID, enrollmentID uint, ok bool) {
+	quizID, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return 0, 0, false
+	}
+
+	enrollmentID, err = GetParamUint(c, EnrollmentIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidEnrollmentID)
+		return 0, 0, false
+	}
+
+	return quizID, enrollmentID, true
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// RequestVerificationEmail emails the authenticated user a new link to verify
+// their email address.
+//
+// Method: POST
+// Route: /users/verify-email/request
+//
+// Returns:
+//   - 200 OK: If the email was sent.
+//   - 401 Unauthorized: If the email address is already verified.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *UserHandler) RequestVerificationEmail(c *gin.Context) {
+	userID := GetUserID(c)
+	if err := h.accounts.SendVerificationEmail(userID); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Verification email sent")
+}
+
+// VerifyEmail verifies the email address of the user a verification link was sent to.
+//
+// Method: POST
+// Route: /users/verify-email
+//
+// Request Body:
+//   - token: The token from the verification link.
+//
+// Returns:
+//   - 200 OK: If the email address was verified.
+//   - 400 Bad Request: If the token is missing.
+//   - 401 Unauthorized: If the token is invalid, already used or expired.
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var verifyData struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&verifyData); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.accounts.VerifyEmail(verifyData.Token); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Email verified successfully")
+}
+
+// RequestPasswordReset emails a password reset link to the given address. The
+// response is the same whether or not the address has an account.
+//
+// Method: POST
+// Route: /users/password-reset/request
+//
+// Request Body:
+//   - email: The email address of the account.
+//
+// Returns:
+//   - 200 OK: If the request was accepted.
+//   - 400 Bad Request: If the email address is missing.
+func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
+	var resetData struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&resetData); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.accounts.RequestPasswordReset(resetData.Email); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "If the address has an account, a reset link was sent to it")
+}
+
+// ResetPassword sets a new password with the token from a reset link, and
+// signs the user out of every device.
+//
+// Method: POST
+// Route: /users/password-reset
+//
+// Request Body:
+//   - token: The token from the reset link.
+//   - password: The new password, at least 8 characters long.
+//
+// Returns:
+//   - 200 OK: If the password was reset.
+//   - 400 Bad Request: If the token or password is missing or the password is too short.
+//   - 401 Unauthorized: If the token is invalid, already used or expired.
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var resetData struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+
+	if err := c.ShouldBindJSON(&resetData); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.accounts.ResetPassword(resetData.Token, resetData.Password); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Password reset successfully")
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"server/app/models"
+	"server/app/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles the account administration endpoints of global admins
+type AdminHandler struct {
+	admin        *services.AdminService
+	provisioning *services.ProvisioningService
+	throttle     *services.LoginThrottleService
+	audit        *services.AuditService
+}
+
+func NewAdminHandler(admin *services.AdminService, provisioning *services.ProvisioningService, throttle *services.LoginThrottleService, audit *services.AuditService) *AdminHandler {
+	return &AdminHandler{
+		admin:        admin,
+		provisioning: provisioning,
+		throttle:     throttle,
+		audit:        audit,
+	}
+}
+
+// maxProvisionSize caps the size of an uploaded provisioning file.
+const maxProvisionSize = 5 << 20
+
+// ProvisionUsers creates or updates users in bulk from an uploaded CSV file,
+// enrolling them in the courses of their invitation codes.
+//
+// Method: POST
+// Route: /admin/users/import?dryRun=<true|false>
+//
+// Request Body (multipart form):
+//   - file: The CSV file, with a header naming the columns first_name, last_name,
+//     email, and optionally invitation_code and role ("student" or "teacher").
+//
+// Returns:
+//   - 200 OK: Returns the report of the rows: created, updated, skipped or in error, and why.
+//     With dryRun=true, nothing is saved and the report tells what would happen.
+//   - 400 Bad Request: If the file is missing, too large, unreadable or lacks a required column.
+func (h *AdminHandler) ProvisionUsers(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		HandleBadRequest(c, NoFilesProvided)
+		return
+	}
+
+	if header.Size > maxProvisionSize {
+		HandleBadRequest(c, fmt.Sprintf("file must be at most %d MB", maxProvisionSize>>20))
+		return
+	}
+
+	dryRun := false
+	if value := c.Query(DryRunQuery); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			HandleBadRequest(c, "Invalid "+DryRunQuery)
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	report, err := h.provisioning.Provision(GetUserID(c), io.LimitReader(file, maxProvisionSize), dryRun)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ListUsers searches the users, a page at a time.
+//
+// Method: GET
+// Route: /admin/users?q=<search>&role=<role>&active=<true|false>&page=<page>&pageSize=<pageSize>
+//
+// Returns:
+//   - 200 OK: Returns the page of users, ordered by email, with the number of users matching in total.
+//   - 400 Bad Request: If active, page or pageSize is invalid.
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	filter := services.UserFilter{
+		Query: c.Query(SearchQuery),
+		Role:  models.Role(c.Query(RoleQuery)),
+	}
+
+	if value := c.Query(ActiveQuery); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			HandleBadRequest(c, "Invalid "+ActiveQuery)
+			return
+		}
+		filter.Active = &active
+	}
+
+	for key, target := range map[string]*int{PageQuery: &filter.Page, PageSizeQuery: &filter.PageSize} {
+		if value := c.Query(key); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				HandleBadRequest(c, "Invalid "+key)
+				return
+			}
+			*target = n
+		}
+	}
+
+	page, err := h.admin.ListUsers(filter)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// DeactivateUser deactivates a user, signing them out everywhere.
+//
+// Method: POST
+// Route: /admin/users/:userId/deactivate
+//
+// Returns:
+//   - 200 OK: If the user was deactivated.
+//   - 400 Bad Request: If the user ID is invalid.
+//   - 401 Unauthorized: If the admin deactivates themselves.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *AdminHandler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false, "User deactivated successfully")
+}
+
+// ReactivateUser reactivates a deactivated user.
+//
+// Method: POST
+// Route: /admin/users/:userId/reactivate
+//
+// Returns:
+//   - 200 OK: If the user was reactivated.
+//   - 400 Bad Request: If the user ID is invalid.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *AdminHandler) ReactivateUser(c *gin.Context) {
+	h.setActive(c, true, "User reactivated successfully")
+}
+
+func (h *AdminHandler) setActive(c *gin.Context, active bool, message string) {
+	userID, err := GetParamUint(c, UserIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidUserID)
+		return
+	}
+
+	if err := h.admin.SetActive(GetUserID(c), userID, active); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, message)
+}
+
+// SetRole assigns the global role of a user.
+//
+// Method: PUT
+// Route: /admin/users/:userId/role
+//
+// Request Body:
+//   - role: The new role, "user" or "admin".
+//
+// Returns:
+//   - 200 OK: If the role was assigned. The sessions of the user are revoked for it to take effect.
+//   - 400 Bad Request: If the user ID or role is invalid.
+//   - 401 Unauthorized: If the admin changes their own role.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	userID, err := GetParamUint(c, UserIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidUserID)
+		return
+	}
+
+	var input struct {
+		Role models.Role `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.admin.SetRole(GetUserID(c), userID, input.Role); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Role updated successfully")
+}
+
+// ForcePasswordReset makes a user choose a new password, emailing them a reset link.
+//
+// Method: POST
+// Route: /admin/users/:userId/password-reset
+//
+// Returns:
+//   - 200 OK: If the password was reset and the link sent.
+//   - 400 Bad Request: If the user ID is invalid.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	userID, err := GetParamUint(c, UserIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidUserID)
+		return
+	}
+
+	if err := h.admin.ForcePasswordReset(GetUserID(c), userID); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Password reset email sent")
+}
+
+// ImpersonateUser starts a session in which the admin acts as a user. The
+// access token carries the ID of the admin in its "imp" claim, lasts an hour
+// and cannot be refreshed; it cannot change the credentials or profile of the user.
+//
+// Method: POST
+// Route: /admin/users/:userId/impersonate
+//
+// Returns:
+//   - 200 OK: Returns the access token and its expiry.
+//   - 400 Bad Request: If the user ID is invalid.
+//   - 401 Unauthorized: If the user is the admin, another admin or inactive.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	userID, err := GetParamUint(c, UserIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidUserID)
+		return
+	}
+
+	tokens, err := h.admin.Impersonate(GetUserID(c), userID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// UnlockUser lifts the lockout of an account after repeated failed logins.
+//
+// Method: POST
+// Route: /admin/users/:userId/unlock
+//
+// Returns:
+//   - 200 OK: If the account was unlocked.
+//   - 400 Bad Request: If the user ID is invalid.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	userID, err := GetParamUint(c, UserIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidUserID)
+		return
+	}
+
+	if err := h.throttle.Unlock(GetUserID(c), userID); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Account unlocked successfully")
+}
+
+// ListAuditLogs returns the most recent entries of the audit trail, newest first.
+//
+// Method: GET
+// Route: /admin/audit-logs?action=<action>&userId=<userId>&before=<entryId>
+//
+// Returns:
+//   - 200 OK: Returns up to 100 entries; pass the ID of the last one as before for the next page.
+//   - 400 Bad Request: If the user ID or entry ID is invalid.
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	filter := services.AuditFilter{Action: models.AuditAction(c.Query(ActionQuery))}
+
+	for key, target := range map[string]*uint{UserIDQuery: &filter.UserID, BeforeQuery: &filter.Before} {
+		if value := c.Query(key); value != "" {
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				HandleBadRequest(c, "Invalid "+key)
+				return
+			}
+			*target = uint(id)
+		}
+	}
+
+	entries, err := h.audit.List(filter)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
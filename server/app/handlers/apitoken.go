@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenHandler handles the personal access tokens of the authenticated user
+type APITokenHandler struct {
+	serv *services.APITokenService
+}
+
+func NewAPITokenHandler(serv *services.APITokenService) *APITokenHandler {
+	return &APITokenHandler{serv: serv}
+}
+
+// Create creates a personal access token for the authenticated user.
+//
+// Method: POST
+// Route: /users/tokens
+//
+// Request Body:
+//   - name: A name to recognize the token by.
+//   - scopes: The permissions of the token, such as ["grade:read"].
+//   - courseId: Optional. Limits the token to one course.
+//   - expiresInDays: Optional. The lifetime of the token, 30 days by default and 365 at most.
+//
+// Returns:
+//   - 201 Created: Returns the token, with its secret in the token field. The secret is shown only this once.
+//   - 400 Bad Request: If the name, a scope or the lifetime is invalid.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *APITokenHandler) Create(c *gin.Context) {
+	var input services.NewAPIToken
+	if err := c.ShouldBindJSON(&input); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	token, err := h.serv.Create(GetUserID(c), input)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// List returns the personal access tokens of the authenticated user, with when each was last used.
+//
+// Method: GET
+// Route: /users/tokens
+//
+// Returns:
+//   - 200 OK: Returns the tokens that are not revoked. Their secrets are never shown again.
+func (h *APITokenHandler) List(c *gin.Context) {
+	tokens, err := h.serv.List(GetUserID(c))
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Revoke revokes a personal access token of the authenticated user.
+//
+// Method: DELETE
+// Route: /users/tokens/:tokenId
+//
+// Returns:
+//   - 200 OK: If the token was revoked.
+//   - 400 Bad Request: If the token ID is invalid.
+//   - 404 Not Found: If the user has no such token.
+func (h *APITokenHandler) Revoke(c *gin.Context) {
+	tokenID, err := GetParamUint(c, TokenIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidTokenID)
+		return
+	}
+
+	if err := h.serv.Revoke(GetUserID(c), tokenID); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Token revoked successfully")
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttemptHandler handles HTTP requests for students taking a quiz.
+type AttemptHandler struct {
+	serv *services.AttemptService
+}
+
+// NewAttemptHandler creates a new AttemptHandler with the given AttemptService.
+func NewAttemptHandler(serv *services.AttemptService) *AttemptHandler {
+	return &AttemptHandler{serv: serv}
+}
+
+// StartAttempt starts a new attempt at a quiz, or resumes the one in progress.
+// It expects the quiz ID as a URL parameter.
+//
+// Method: POST
+// Route: /api/quizzes/:id/attempts
+//
+// Returns:
+//   - 201 Created: Returns the attempt, including its deadline, as JSON.
+//   - 400 Bad Request: If the quiz ID is invalid.
+//   - 401 Unauthorized: If the user cannot take the quiz or the quiz is not open.
+//   - 404 Not Found: If the quiz doesn't exist.
+func (h *AttemptHandler) StartAttempt(c *gin.Context) {
+	quizID, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	attempt, err := h.serv.StartAttempt(GetUserID(c), quizID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"attempt": attempt})
+}
+
+// GetAttempt retrieves an attempt of the authenticated user with its saved answers.
+//
+// Method: GET
+// Route: /api/quizzes/:id/attempts/:attemptId
+//
+// Returns:
+//   - 200 OK: Returns the attempt as JSON.
+//   - 400 Bad Request: If the quiz ID or attempt ID is invalid.
+//   - 401 Unauthorized: If the attempt belongs to another user.
+//   - 404 Not Found: If the attempt doesn't exist.
+func (h *AttemptHandler) GetAttempt(c *gin.Context) {
+	quizID, attemptID, ok := attemptParams(c)
+	if !ok {
+		return
+	}
+
+	attempt, err := h.serv.GetAttempt(GetUserID(c), quizID, attemptID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attempt": attempt})
+}
+
+// SaveAnswers autosaves answers for an in-progress attempt.
+// It expects a JSON payload with the list of answers.
+//
+// Method: PUT
+// Route: /api/quizzes/:id/attempts/:attemptId/answers
+//
+// Request Body:
+//   - answers: A list of {questionId, selectedOptions, textAnswer, numericAnswer, timeSpentSeconds} objects (required)
+//
+// Returns:
+//   - 200 OK: Returns the attempt with all of its saved answers.
+//   - 400 Bad Request: If the payload is invalid or an answer doesn't match the quiz.
+//   - 401 Unauthorized: If the attempt belongs to another user, is submitted or has expired.
+//   - 404 Not Found: If the attempt doesn't exist.
+func (h *AttemptHandler) SaveAnswers(c *gin.Context) {
+	quizID, attemptID, ok := attemptParams(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Answers []services.AnswerInput `json:"answers" binding:"required,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	attempt, err := h.serv.SaveAnswers(GetUserID(c), quizID, attemptID, input.Answers)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attempt": attempt})
+}
+
+// SubmitAttempt finalizes an attempt.
+//
+// Method: POST
+// Route: /api/quizzes/:id/attempts/:attemptId/submit
+//
+// Returns:
+//   - 200 OK: Returns the submitted attempt as JSON.
+//   - 400 Bad Request: If the quiz ID or attempt ID is invalid.
+//   - 401 Unauthorized: If the attempt belongs to another user or is already submitted.
+//   - 404 Not Found: If the attempt doesn't exist.
+func (h *AttemptHandler) SubmitAttempt(c *gin.Context) {
+	quizID, attemptID, ok := attemptParams(c)
+	if !ok {
+		return
+	}
+
+	attempt, err := h.serv.SubmitAttempt(GetUserID(c), quizID, attemptID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attempt": attempt})
+}
+
+// attemptParams parses the quiz and attempt IDs from the URL, responding with
+// 400 Bad Request if either is invalid.
+func attemptParams(c *gin.Context) (quizID, attemptID uint, ok bool) {
+	quizID, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return 0, 0, false
+	}
+
+	attemptID, err = GetParamUint(c, AttemptIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidAttemptID)
+		return 0, 0, false
+	}
+
+	return quizID, attemptID, true
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAvatarSize caps the size of an uploaded avatar.
+const maxAvatarSize = 5 << 20
+
+// AvatarHandler handles the avatars of users
+type AvatarHandler struct {
+	serv *services.AvatarService
+}
+
+func NewAvatarHandler(serv *services.AvatarService) *AvatarHandler {
+	return &AvatarHandler{serv: serv}
+}
+
+// UploadAvatar replaces the avatar of the authenticated user.
+//
+// Method: POST
+// Route: /users/avatar
+//
+// Request Body (multipart form):
+//   - file: The image, a PNG or JPEG.
+//
+// Returns:
+//   - 200 OK: Returns the URL of the profile picture and the thumbnails of the avatar in each size.
+//   - 400 Bad Request: If the file is missing, too large, or not a PNG or JPEG image.
+func (h *AvatarHandler) UploadAvatar(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		HandleBadRequest(c, NoFilesProvided)
+		return
+	}
+
+	if header.Size > maxAvatarSize {
+		HandleBadRequest(c, fmt.Sprintf("file must be at most %d MB", maxAvatarSize>>20))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarSize))
+	if err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	images, err := h.serv.Upload(GetUserID(c), data)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profilePic": images[0].URL, "images": images})
+}
+
+// DeleteAvatar removes the avatar of the authenticated user.
+//
+// Method: DELETE
+// Route: /users/avatar
+//
+// Returns:
+//   - 200 OK: If the avatar was removed.
+func (h *AvatarHandler) DeleteAvatar(c *gin.Context) {
+	if err := h.serv.Delete(GetUserID(c)); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Avatar removed successfully")
+}
@@ -4,12 +4,56 @@ const (
 	SubmissionIDKey = "submissionID"
 	AssignmentIDKey = "assignmentID"
 	MaterialIDKey   = "materialID"
+	QuizIDKey       = "id"
+	AttemptIDKey    = "attemptId"
+	AnswerIDKey     = "answerId"
+	CourseIDKey     = "id"
+	QuestionIDKey   = "questionId"
+	EnrollmentIDKey = "enrollmentId"
+	CourseIDQuery   = "courseId"
+	TagQuery        = "tag"
+	FormatQuery     = "format"
+	ProviderKey     = "provider"
+	UserIDKey       = "userId"
+	TokenIDKey      = "tokenId"
+	JobIDKey        = "jobId"
+	RoleKey         = "role"
+	ActionQuery     = "action"
+	UserIDQuery     = "userId"
+	BeforeQuery     = "before"
+	SearchQuery     = "q"
+	RoleQuery       = "role"
+	ActiveQuery     = "active"
+	PageQuery       = "page"
+	PageSizeQuery   = "pageSize"
+	DryRunQuery     = "dryRun"
+	CategoryQuery   = "category"
+	DifficultyQuery = "difficulty"
+	ArchivedQuery   = "archived"
+	StartFromQuery  = "startFrom"
+	StartToQuery    = "startTo"
+	EndFromQuery    = "endFrom"
+	EndToQuery      = "endTo"
+	SortQuery       = "sort"
+	OrderQuery      = "order"
+	CursorQuery     = "cursor"
+	LimitQuery      = "limit"
+
+	CanManageQuizKey = "canManageQuiz"
 
 	InvalidSubmissionID = "Invalid submission ID"
 	InvalidAssignmentID = "Invalid assignment ID"
 	InvalidEnrollmentID = "Invalid enrollment ID"
 	InvalidUserID       = "Invalid user ID"
+	InvalidCourseID     = "Invalid course ID"
 	InvalidMaterialID   = "Invalid material ID"
+	InvalidQuizID       = "Invalid quiz ID"
+	InvalidAttemptID    = "Invalid attempt ID"
+	InvalidAnswerID     = "Invalid answer ID"
+	InvalidQuestionID   = "Invalid question ID"
+	InvalidTokenID      = "Invalid token ID"
+	InvalidJobID        = "Invalid job ID"
+	InvalidFormat       = "Format must be gift or qti"
 
 	NoFilesProvided            = "No files provided"
 	FailedToParseMultipartForm = "Failed to parse multipart form"
@@ -18,7 +18,7 @@ func NewCourseHandler(courseService *services.CourseService) *CourseHandler {
 	return &CourseHandler{courseService: courseService}
 }
 
-// CreateCourse handles the creation of a new course
+// CreateCourse handles the creation of a new course, making the authenticated user its creator
 func (h *CourseHandler) CreateCourse(c *gin.Context) {
 	var course models.Course
 	if err := c.ShouldBindJSON(&course); err != nil {
@@ -26,6 +26,9 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 		return
 	}
 
+	course.CreatorID = GetUserID(c)
+	course.ArchivedAt, course.ClonedFromID = nil, nil
+
 	if err := h.courseService.CreateCourse(&course); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create course"})
 		return
@@ -34,15 +37,74 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 	c.JSON(http.StatusCreated, course)
 }
 
-// GetCourses handles retrieving all courses
+// GetCourses searches the course catalog, a page at a time.
+//
+// Method: GET
+// Route: /courses?q=<search>&category=<category>&difficulty=<difficulty>&active=<true|false>&archived=<true|false>
+// &startFrom=<date>&startTo=<date>&endFrom=<date>&endTo=<date>&sort=<sort>&order=<asc|desc>&cursor=<cursor>&limit=<limit>
+//
+// The sort is one of relevance, name, start_date, created or enrollments; dates are such as 2025-09-01.
+//
+// Returns:
+//   - 200 OK: Returns the page of courses with their enrollment counts, and the cursor of the next page if there is one.
+//   - 400 Bad Request: If a filter, the sort, the order, the cursor or the limit is invalid.
 func (h *CourseHandler) GetCourses(c *gin.Context) {
-	courses, err := h.courseService.GetCourse()
+	filter := services.CourseFilter{
+		Query:      c.Query(SearchQuery),
+		Category:   c.Query(CategoryQuery),
+		Difficulty: c.Query(DifficultyQuery),
+		StartFrom:  c.Query(StartFromQuery),
+		StartTo:    c.Query(StartToQuery),
+		EndFrom:    c.Query(EndFromQuery),
+		EndTo:      c.Query(EndToQuery),
+		Sort:       services.CourseSort(c.Query(SortQuery)),
+		Cursor:     c.Query(CursorQuery),
+	}
+
+	if value := c.Query(ActiveQuery); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			HandleBadRequest(c, "Invalid "+ActiveQuery)
+			return
+		}
+		filter.Active = &active
+	}
+
+	if value := c.Query(ArchivedQuery); value != "" {
+		archived, err := strconv.ParseBool(value)
+		if err != nil {
+			HandleBadRequest(c, "Invalid "+ArchivedQuery)
+			return
+		}
+		filter.Archived = archived
+	}
+
+	switch order := c.Query(OrderQuery); order {
+	case "":
+	case "asc", "desc":
+		descending := order == "desc"
+		filter.Descending = &descending
+	default:
+		HandleBadRequest(c, "Invalid "+OrderQuery)
+		return
+	}
+
+	if value := c.Query(LimitQuery); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			HandleBadRequest(c, "Invalid "+LimitQuery)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	page, err := h.courseService.SearchCourses(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve courses"})
+		SendError(err, c)
 		return
 	}
 
-	c.JSON(http.StatusOK, courses)
+	c.JSON(http.StatusOK, page)
 }
 
 // GetCourseByID handles retrieving a specific course by ID
@@ -100,3 +162,56 @@ func (h *CourseHandler) DeleteCourse(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Course deleted successfully"})
 }
+
+// ArchiveCourse archives a course, making it read-only for all but global admins.
+//
+// Method: POST
+// Route: /courses/:id/archive
+//
+// Returns:
+//   - 200 OK: Returns the archived course.
+//   - 400 Bad Request: If the course ID is invalid.
+//   - 401 Unauthorized: If the course is already archived.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *CourseHandler) ArchiveCourse(c *gin.Context) {
+	id, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	course, err := h.courseService.ArchiveCourse(id)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, course)
+}
+
+// RestoreCourse restores an archived course. Since archived courses are
+// read-only, only global admins can.
+//
+// Method: POST
+// Route: /courses/:id/restore
+//
+// Returns:
+//   - 200 OK: Returns the restored course.
+//   - 400 Bad Request: If the course ID is invalid.
+//   - 401 Unauthorized: If the course is not archived.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *CourseHandler) RestoreCourse(c *gin.Context) {
+	id, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	course, err := h.courseService.RestoreCourse(id)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, course)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CourseCloneHandler handles the cloning of courses into new terms
+type CourseCloneHandler struct {
+	serv *services.CourseCloneService
+}
+
+func NewCourseCloneHandler(serv *services.CourseCloneService) *CourseCloneHandler {
+	return &CourseCloneHandler{serv: serv}
+}
+
+// CloneCourse creates a copy of a course for a new term, made by the
+// authenticated user. The content is copied in the background.
+//
+// Method: POST
+// Route: /courses/:id/clone
+//
+// Request Body:
+//   - name: The name of the new course.
+//   - start_date: The start date of the new course, such as 2025-09-01.
+//   - end_date: The end date of the new course (optional).
+//   - shift_days: How many days the dates of assignments and quizzes move (optional),
+//     by default the gap between the start dates of the courses.
+//   - archive_source: Whether to archive the course once it is copied (optional).
+//
+// Returns:
+//   - 202 Accepted: Returns the new course, still empty and inactive, and the job copying
+//     its materials, assignments, quizzes and question bank.
+//   - 400 Bad Request: If the course ID, the name or a date is invalid.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *CourseCloneHandler) CloneCourse(c *gin.Context) {
+	id, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	var req services.CloneCourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	course, job, err := h.serv.CloneCourse(GetUserID(c), id, req)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"course": course, "job": job})
+}
+
+// GetCloneStatus returns the progress of the copy of content into a cloned course.
+//
+// Method: GET
+// Route: /courses/:id/clone
+//
+// Returns:
+//   - 200 OK: Returns the job copying the content, with its status and progress in percent.
+//   - 400 Bad Request: If the course ID is invalid.
+//   - 404 Not Found: If the course doesn't exist or is not a clone.
+func (h *CourseCloneHandler) GetCloneStatus(c *gin.Context) {
+	id, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	job, err := h.serv.CloneStatus(id)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
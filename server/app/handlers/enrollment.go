@@ -85,11 +85,13 @@ func (h *EnrollmentHandler) RejectEnrollment(c *gin.Context) {
 //
 // Request Body:
 //   - code: The invitation code for the course (string, required)
-//   - role: The role of the user in the course (string, required)
+//   - role: The role of the user in the course, which the code must be for (string, required)
 //
 // Returns:
 //   - 200 OK: If the user successfully joins the course
-//   - 400 Bad Request: If the request body is invalid or the role is invalid
+//   - 400 Bad Request: If the request body is invalid, the role is invalid or the code is unknown or for another role
+//   - 401 Unauthorized: If the code is disabled, expired or used up, the course is archived or full,
+//     or the user is already enrolled
 //   - 500 Internal Server Error: If there's an error during the join process
 func (h *EnrollmentHandler) JoinCourseByCode(c *gin.Context) {
 	userId := GetUserID(c)
@@ -122,34 +124,23 @@ func (h *EnrollmentHandler) JoinCourseByCode(c *gin.Context) {
 // It expects the course ID as a URL parameter.
 //
 // Method: GET
-// Route: /courses/:id/pending-enrollments
+// Route: /api/enrollments/course/:courseId
 //
 // Parameters:
-//   - id: The ID of the course to get pending enrollments for (from URL)
+//   - courseId: The ID of the course to get pending enrollments for (from URL)
 //
 // Returns:
 //   - 200 OK: Returns a list of pending enrollments
 //   - 400 Bad Request: If the course ID is invalid
-//   - 403 Forbidden: If the user is not the admin of the course
+//   - 403 Forbidden: If the user may not manage the enrollments of the course
 //   - 500 Internal Server Error: If there's an error retrieving the enrollments
 func (h *EnrollmentHandler) GetPendingEnrollments(c *gin.Context) {
-	adminId := GetUserID(c)
-	courseId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	courseId, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
 	if err != nil {
 		HandleBadRequest(c, err.Error())
 		return
 	}
 
-	ok, err := h.serv.IsAdmin(adminId, uint(courseId))
-	if err != nil {
-		SendError(err, c)
-		return
-	}
-
-	if !ok {
-		HandleForbidden(c, "Only course creator can get pending enrollments")
-		return
-	}
 	enrollments, err := h.serv.GetPendingEnrollments(uint(courseId))
 	if err != nil {
 		SendError(err, c)
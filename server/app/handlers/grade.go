@@ -32,7 +32,7 @@ func (h *GradeHandler) Create(c *gin.Context) {
 		return
 	}
 
-	gradedBy := c.GetUint("userID")
+	gradedBy := GetUserID(c)
 
 	grade, err := h.serv.Create(input.SubmissionID, gradedBy, input.PointsEarned, input.Feedback)
 	if err != nil {
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GradingHandler handles HTTP requests for teachers grading quiz answers by hand.
+type GradingHandler struct {
+	serv *services.GradingService
+}
+
+// NewGradingHandler creates a new GradingHandler with the given GradingService.
+func NewGradingHandler(serv *services.GradingService) *GradingHandler {
+	return &GradingHandler{serv: serv}
+}
+
+// GetGradingQueue lists the answers of a quiz that are waiting to be graded.
+//
+// Method: GET
+// Route: /api/quizzes/:id/grading
+//
+// Returns:
+//   - 200 OK: Returns the ungraded answers as JSON.
+//   - 400 Bad Request: If the quiz ID is invalid.
+//   - 403 Forbidden: If the user may not manage the quiz.
+//   - 404 Not Found: If the quiz doesn't exist.
+func (h *GradingHandler) GetGradingQueue(c *gin.Context) {
+	quizID, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	queue, err := h.serv.GetGradingQueue(GetUserID(c), quizID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"answers": queue})
+}
+
+// GradeAnswer awards points and feedback to an answer of an Essay question.
+// It expects a JSON payload with the points and optional feedback.
+//
+// Method: PUT
+// Route: /api/quizzes/:id/grading/:answerId
+//
+// Request Body:
+//   - points: The points to award, between 0 and the points of the question (required)
+//   - feedback: Feedback for the student (optional)
+//
+// Returns:
+//   - 200 OK: Returns the graded answer as JSON.
+//   - 400 Bad Request: If the payload is invalid or the points are out of range.
+//   - 401 Unauthorized: If the answer cannot be graded.
+//   - 403 Forbidden: If the user may not manage the quiz.
+//   - 404 Not Found: If the quiz or answer doesn't exist.
+func (h *GradingHandler) GradeAnswer(c *gin.Context) {
+	quizID, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	answerID, err := GetParamUint(c, AnswerIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidAnswerID)
+		return
+	}
+
+	var input struct {
+		Points   *float64 `json:"points" binding:"required"`
+		Feedback string   `json:"feedback"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	answer, err := h.serv.GradeAnswer(GetUserID(c), quizID, answerID, services.GradeInput{
+		Points:   *input.Points,
+		Feedback: input.Feedback,
+	})
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"answer": answer})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvitationHandler handles the invitation codes of courses
+type InvitationHandler struct {
+	serv *services.InvitationService
+}
+
+func NewInvitationHandler(serv *services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{serv: serv}
+}
+
+// GetInvitations returns the invitation codes of a course, with their rules and uses.
+//
+// Method: GET
+// Route: /courses/:id/invitations
+//
+// Returns:
+//   - 200 OK: Returns the invitations of the course, one per role.
+//   - 400 Bad Request: If the course ID is invalid.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *InvitationHandler) GetInvitations(c *gin.Context) {
+	id, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	invitations, err := h.serv.ListInvitations(id)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// RotateInvitation replaces the invitation code of a course for a role, issuing
+// one if there is none. The previous code stops working at once.
+//
+// Method: POST
+// Route: /courses/:id/invitations/:role/rotate
+//
+// Returns:
+//   - 200 OK: Returns the invitation with its new code.
+//   - 400 Bad Request: If the course ID or the role is invalid.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *InvitationHandler) RotateInvitation(c *gin.Context) {
+	id, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	invitation, err := h.serv.RotateInvitation(id, models.Role(c.Param(RoleKey)))
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitation)
+}
+
+// UpdateInvitation sets the rules of the invitation code of a course for a role.
+//
+// Method: PUT
+// Route: /courses/:id/invitations/:role
+//
+// Request Body:
+//   - expires_at: When the code stops working (optional), never if omitted.
+//   - max_uses: How many users can join with the code (optional), no limit if omitted or 0.
+//   - disabled: Whether the code is disabled (optional).
+//
+// Returns:
+//   - 200 OK: Returns the updated invitation.
+//   - 400 Bad Request: If the course ID, the role or a setting is invalid.
+//   - 404 Not Found: If the course has no code for the role.
+func (h *InvitationHandler) UpdateInvitation(c *gin.Context) {
+	id, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	var settings services.InvitationSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	invitation, err := h.serv.UpdateInvitation(id, models.Role(c.Param(RoleKey)), settings)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitation)
+}
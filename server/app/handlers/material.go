@@ -67,10 +67,11 @@ func (h *MaterialHandler) DeleteMaterial(c *gin.Context) {
 // Parameters:
 //   - c: The Gin context for the current request.
 //
-// The function expects a multipart form with title, description, and files.
+// The function expects a multipart form with the course ID, title, description, and files.
 // It returns the created material as JSON if successful, or an appropriate error response.
 func (h *MaterialHandler) CreateMaterial(c *gin.Context) {
 	var material struct {
+		CourseID    uint     `form:"courseId" binding:"required"`
 		Title       string   `form:"title" binding:"required"`
 		Description string   `form:"description" binding:"required"`
 		Files       []string `form:"files" binding:"required"`
@@ -93,6 +94,7 @@ func (h *MaterialHandler) CreateMaterial(c *gin.Context) {
 	}
 
 	mat := &models.Material{
+		CourseId:    material.CourseID,
 		Title:       material.Title,
 		Description: material.Description,
 	}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCHandler handles signing in with OpenID Connect providers
+type OIDCHandler struct {
+	serv *services.OIDCService
+}
+
+func NewOIDCHandler(serv *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{serv: serv}
+}
+
+// Login sends the user to the provider to sign in.
+//
+// Method: GET
+// Route: /users/oidc/:provider/login
+//
+// Returns:
+//   - 302 Found: Redirects to the sign-in page of the provider.
+//   - 404 Not Found: If the provider is unknown.
+//   - 500 Internal Server Error: If the provider cannot be reached.
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, err := h.serv.StartLogin(c.Request.Context(), c.Param(ProviderKey))
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes signing in when the provider sends the user back, and
+// returns the same tokens as a login with a password.
+//
+// Method: GET
+// Route: /users/oidc/:provider/callback?state=<state>&code=<code>
+//
+// Returns:
+//   - 200 OK: Returns the tokens as JSON.
+//   - 400 Bad Request: If the state or code is missing.
+//   - 401 Unauthorized: If the user cancelled, the login expired, or the provider doesn't vouch for the user.
+//   - 404 Not Found: If the provider is unknown.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		HandleUnauthorized(c, "sign-in was not completed: "+reason)
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		HandleBadRequest(c, "state and code are required")
+		return
+	}
+
+	tokens, err := h.serv.FinishLogin(c.Request.Context(), c.Param(ProviderKey), state, code)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
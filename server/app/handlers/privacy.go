@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyHandler handles the export and erasure of the personal data of users
+type PrivacyHandler struct {
+	serv *services.PrivacyService
+	jobs *services.JobService
+}
+
+func NewPrivacyHandler(serv *services.PrivacyService, jobs *services.JobService) *PrivacyHandler {
+	return &PrivacyHandler{serv: serv, jobs: jobs}
+}
+
+// ExportData downloads the personal data of the authenticated user.
+//
+// Method: GET
+// Route: /users/export
+//
+// Returns:
+//   - 200 OK: Returns a ZIP archive of the profile, enrollments, submissions with
+//     their files, grades and quiz attempts of the user.
+func (h *PrivacyHandler) ExportData(c *gin.Context) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="personal-data.zip"`)
+	c.Status(http.StatusOK)
+
+	if err := h.serv.Export(GetUserID(c), c.Writer); err != nil {
+		// Once the archive has started, the status cannot change; the client gets a truncated archive.
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			SendError(err, c)
+			return
+		}
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
+
+// RequestErasure erases the personal data of the authenticated user. The account
+// is deactivated and signed out at once, and erased in the background.
+//
+// Method: POST
+// Route: /users/erasure
+//
+// Returns:
+//   - 202 Accepted: Returns the erasure job.
+//   - 401 Unauthorized: If the account is already erased.
+func (h *PrivacyHandler) RequestErasure(c *gin.Context) {
+	userID := GetUserID(c)
+	h.requestErasure(c, userID, userID)
+}
+
+// EraseUser erases the personal data of a user, on behalf of a global admin.
+//
+// Method: POST
+// Route: /admin/users/:userId/erasure
+//
+// Returns:
+//   - 202 Accepted: Returns the erasure job.
+//   - 400 Bad Request: If the user ID is invalid.
+//   - 401 Unauthorized: If the account is already erased.
+//   - 404 Not Found: If the user doesn't exist.
+func (h *PrivacyHandler) EraseUser(c *gin.Context) {
+	userID, err := GetParamUint(c, UserIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidUserID)
+		return
+	}
+
+	h.requestErasure(c, GetUserID(c), userID)
+}
+
+func (h *PrivacyHandler) requestErasure(c *gin.Context, actorID, userID uint) {
+	job, err := h.serv.RequestErasure(actorID, userID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetJob returns the state of a background job, for global admins.
+//
+// Method: GET
+// Route: /admin/jobs/:jobId
+//
+// Returns:
+//   - 200 OK: Returns the job, with its status, attempts, last error and result.
+//   - 400 Bad Request: If the job ID is invalid.
+//   - 404 Not Found: If the job doesn't exist.
+func (h *PrivacyHandler) GetJob(c *gin.Context) {
+	jobID, err := GetParamUint(c, JobIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidJobID)
+		return
+	}
+
+	job, err := h.jobs.Get(jobID)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
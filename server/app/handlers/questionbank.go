@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionBankHandler handles HTTP requests for the question bank of a course.
+type QuestionBankHandler struct {
+	serv *services.QuestionBankService
+}
+
+// NewQuestionBankHandler creates a new QuestionBankHandler with the given QuestionBankService.
+func NewQuestionBankHandler(serv *services.QuestionBankService) *QuestionBankHandler {
+	return &QuestionBankHandler{serv: serv}
+}
+
+// ListQuestions lists the questions in the bank of a course, optionally
+// filtered by the "tag" query parameter.
+//
+// Method: GET
+// Route: /api/courses/:id/questions
+//
+// Returns:
+//   - 200 OK: Returns the questions as JSON.
+//   - 400 Bad Request: If the course ID is invalid.
+//   - 403 Forbidden: If the user doesn't teach the course.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *QuestionBankHandler) ListQuestions(c *gin.Context) {
+	courseID, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	questions, err := h.serv.ListQuestions(GetUserID(c), courseID, c.Query(TagQuery))
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"questions": questions})
+}
+
+// CreateQuestion adds a question to the bank of a course.
+// It expects a JSON payload with the question, its options and its tags.
+//
+// Method: POST
+// Route: /api/courses/:id/questions
+//
+// Returns:
+//   - 201 Created: Returns the created question as JSON.
+//   - 400 Bad Request: If the course ID or the question is invalid.
+//   - 403 Forbidden: If the user doesn't teach the course.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *QuestionBankHandler) CreateQuestion(c *gin.Context) {
+	courseID, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	var question models.Question
+	if err := c.ShouldBindJSON(&question); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.serv.CreateQuestion(GetUserID(c), courseID, &question); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"question": question})
+}
+
+// UpdateQuestion replaces a question in the bank of a course.
+// Questions already drawn in an attempt cannot be changed.
+//
+// Method: PUT
+// Route: /api/courses/:id/questions/:questionId
+//
+// Returns:
+//   - 200 OK: Returns the updated question as JSON.
+//   - 400 Bad Request: If an ID or the question is invalid.
+//   - 401 Unauthorized: If the question was already drawn.
+//   - 403 Forbidden: If the user doesn't teach the course.
+//   - 404 Not Found: If the question doesn't exist.
+func (h *QuestionBankHandler) UpdateQuestion(c *gin.Context) {
+	courseID, questionID, ok := bankQuestionParams(c)
+	if !ok {
+		return
+	}
+
+	var update models.Question
+	if err := c.ShouldBindJSON(&update); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	question, err := h.serv.UpdateQuestion(GetUserID(c), courseID, questionID, &update)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"question": question})
+}
+
+// DeleteQuestion removes a question from the bank of a course.
+//
+// Method: DELETE
+// Route: /api/courses/:id/questions/:questionId
+//
+// Returns:
+//   - 204 No Content: If the question was deleted.
+//   - 400 Bad Request: If an ID is invalid.
+//   - 403 Forbidden: If the user doesn't teach the course.
+//   - 404 Not Found: If the question doesn't exist.
+func (h *QuestionBankHandler) DeleteQuestion(c *gin.Context) {
+	courseID, questionID, ok := bankQuestionParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.serv.DeleteQuestion(GetUserID(c), courseID, questionID); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleDeleted(c, "question deleted successfully")
+}
+
+// bankQuestionParams parses the course and question IDs from the URL,
+// responding with 400 Bad Request if either is invalid.
+func bankQuestionParams(c *gin.Context) (courseID, questionID uint, ok bool) {
+	courseID, err := GetParamUint(c, CourseIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return 0, 0, false
+	}
+
+	questionID, err = GetParamUint(c, QuestionIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuestionID)
+		return 0, 0, false
+	}
+
+	return courseID, questionID, true
+}
@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"fmt"
+	"net/http"
 	"server/app/models"
 	"server/app/services"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,29 +25,166 @@ func (h *QuizHandler) CreateQuiz(c *gin.Context) {
 	}
 
 	quiz.CreatorID = GetUserID(c)
-	for _, question := range quiz.Questions {
-		queType := question.Type
-		if queType != models.SingleCorrect && queType != models.MultiCorrect {
-			HandleBadRequest(c, "Invalid question type")
+	if err := h.serv.CreateQuiz(&quiz); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleCreated(c, "quiz created successfully")
+}
+
+// GetQuiz retrieves a quiz by its ID.
+// Users who may manage the quiz get the full quiz, answer key included, while
+// everyone else gets the student-facing view without it; readers who are not
+// students of the course see the questions of the quiz there. It expects
+// CheckPermission to have set CanManageQuizKey before it.
+//
+// Method: GET
+// Route: /api/quizzes/:id
+//
+// Returns:
+//   - 200 OK: Returns the quiz as JSON.
+//   - 400 Bad Request: If the quiz ID is invalid.
+//   - 401 Unauthorized: If the user may not read the quizzes of the course.
+//   - 404 Not Found: If the quiz doesn't exist.
+func (h *QuizHandler) GetQuiz(c *gin.Context) {
+	id, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	if c.GetBool(CanManageQuizKey) {
+		quiz, err := h.serv.GetQuiz(id)
+		if err != nil {
+			SendError(err, c)
 			return
 		}
+
+		c.JSON(http.StatusOK, gin.H{"quiz": quiz})
+		return
 	}
 
-	if err := h.serv.CreateQuiz(&quiz); err != nil {
+	view, err := h.serv.GetQuizView(GetUserID(c), id)
+	if err != nil {
 		SendError(err, c)
 		return
 	}
 
-	HandleCreated(c, "quiz created successfully")
+	c.JSON(http.StatusOK, gin.H{"quiz": view})
 }
 
-func (h *QuizHandler) GetQuiz(c *gin.Context) {
-	id, err := GetParamUint(c, "id")
+// ListQuizzes lists the quizzes of a course, without their questions.
+// It expects the course ID as a query parameter.
+//
+// Method: GET
+// Route: /api/quizzes?courseId=<id>
+//
+// Returns:
+//   - 200 OK: Returns the quizzes as JSON.
+//   - 400 Bad Request: If the course ID is missing or invalid.
+//   - 401 Unauthorized: If the user is not part of the course.
+//   - 404 Not Found: If the course doesn't exist.
+func (h *QuizHandler) ListQuizzes(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Query(CourseIDQuery), 10, 32)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	quizzes, err := h.serv.ListQuizzes(GetUserID(c), uint(courseID))
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quizzes": quizzes})
+}
+
+// UpdateQuiz replaces the settings of a quiz, and its questions if the payload
+// has any. Questions and the scoring policy cannot change once the quiz has attempts.
+// It needs the quiz:manage permission.
+//
+// Method: PUT
+// Route: /api/quizzes/:id
+//
+// Request Body:
+//   - The quiz settings, as for creation. Leave out "questions" or "scoringPolicy" to keep the current ones.
+//
+// Returns:
+//   - 200 OK: Returns the updated quiz as JSON.
+//   - 400 Bad Request: If the quiz ID or payload is invalid.
+//   - 401 Unauthorized: If the quiz has attempts and the update changes its questions or scoring.
+//   - 404 Not Found: If the quiz doesn't exist.
+func (h *QuizHandler) UpdateQuiz(c *gin.Context) {
+	id, err := GetParamUint(c, QuizIDKey)
 	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	var update models.Quiz
+	if err := c.ShouldBindJSON(&update); err != nil {
 		HandleBadRequest(c, err.Error())
 		return
 	}
 
-	fmt.Println("id", id)
+	quiz, err := h.serv.UpdateQuiz(id, &update)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quiz": quiz})
+}
+
+// DeleteQuiz deletes a quiz. It needs the quiz:manage permission.
+//
+// Method: DELETE
+// Route: /api/quizzes/:id
+//
+// Returns:
+//   - 204 No Content: If the quiz was deleted.
+//   - 400 Bad Request: If the quiz ID is invalid.
+//   - 404 Not Found: If the quiz doesn't exist.
+func (h *QuizHandler) DeleteQuiz(c *gin.Context) {
+	id, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	if err := h.serv.DeleteQuiz(id); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleDeleted(c, "quiz deleted successfully")
+}
+
+// GetItemAnalysis reports how students fared on each question of a quiz, once
+// the quiz has closed. It needs the quiz:manage permission.
+//
+// Method: GET
+// Route: /api/quizzes/:id/analysis
+//
+// Returns:
+//   - 200 OK: Returns the item analysis as JSON.
+//   - 400 Bad Request: If the quiz ID is invalid.
+//   - 401 Unauthorized: If the quiz is still open.
+//   - 404 Not Found: If the quiz doesn't exist.
+func (h *QuizHandler) GetItemAnalysis(c *gin.Context) {
+	id, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	analysis, err := h.serv.GetItemAnalysis(id)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{"analysis": analysis})
 }
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"server/app/models"
+	"server/app/quizio"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize caps the size of an uploaded quiz document.
+const maxImportSize = 10 << 20
+
+// ImportQuiz creates a quiz from an uploaded GIFT or QTI 2.1 document.
+// Items that cannot be imported are skipped and listed in the response.
+//
+// Method: POST
+// Route: /api/quizzes/import
+//
+// Request Body (multipart form):
+//   - file: The GIFT text, QTI item XML or QTI content package ZIP.
+//   - courseId: The ID of the course to create the quiz in.
+//   - quiz: The quiz settings as JSON, as for creation, without questions and course.
+//   - format: "gift" or "qti". Inferred from the file extension when left out.
+//
+// Returns:
+//   - 201 Created: Returns the quiz and the skipped items as JSON.
+//   - 400 Bad Request: If the form is invalid or the document holds no question that can be imported.
+func (h *QuizHandler) ImportQuiz(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		HandleBadRequest(c, NoFilesProvided)
+		return
+	}
+
+	if header.Size > maxImportSize {
+		HandleBadRequest(c, fmt.Sprintf("file must be at most %d MB", maxImportSize>>20))
+		return
+	}
+
+	var quiz models.Quiz
+	if err := json.Unmarshal([]byte(c.PostForm("quiz")), &quiz); err != nil {
+		HandleBadRequest(c, "invalid quiz settings: "+err.Error())
+		return
+	}
+
+	format := quizio.Format(strings.ToLower(c.PostForm("format")))
+	if format == "" {
+		format = formatFromFilename(header.Filename)
+	}
+	if !format.IsValid() {
+		HandleBadRequest(c, InvalidFormat)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImportSize))
+	if err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	courseID, err := strconv.ParseUint(c.PostForm(CourseIDQuery), 10, 32)
+	if err != nil {
+		HandleBadRequest(c, InvalidCourseID)
+		return
+	}
+
+	quiz.CourseID = uint(courseID)
+	quiz.CreatorID = GetUserID(c)
+	issues, err := h.serv.ImportQuiz(&quiz, format, data)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	if issues == nil {
+		issues = []quizio.Issue{}
+	}
+	c.JSON(http.StatusCreated, gin.H{"quiz": quiz, "issues": issues})
+}
+
+// ExportQuiz downloads the questions of a quiz as a GIFT document or a QTI 2.1
+// content package. It needs the quiz:manage permission. Questions that
+// could not be exported are listed in the X-Export-Issues header.
+//
+// Method: GET
+// Route: /api/quizzes/:id/export?format=gift|qti
+//
+// Returns:
+//   - 200 OK: Returns the document as an attachment.
+//   - 400 Bad Request: If the quiz ID or format is invalid.
+//   - 404 Not Found: If the quiz doesn't exist.
+func (h *QuizHandler) ExportQuiz(c *gin.Context) {
+	id, err := GetParamUint(c, QuizIDKey)
+	if err != nil {
+		HandleBadRequest(c, InvalidQuizID)
+		return
+	}
+
+	format := quizio.Format(strings.ToLower(c.DefaultQuery(FormatQuery, string(quizio.FormatGIFT))))
+	if !format.IsValid() {
+		HandleBadRequest(c, InvalidFormat)
+		return
+	}
+
+	data, issues, err := h.serv.ExportQuiz(id, format)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	filename, contentType := fmt.Sprintf("quiz-%d.gift", id), "text/plain; charset=utf-8"
+	if format == quizio.FormatQTI {
+		filename, contentType = fmt.Sprintf("quiz-%d-qti.zip", id), "application/zip"
+	}
+
+	if len(issues) > 0 {
+		reasons := make([]string, len(issues))
+		for i, issue := range issues {
+			reasons[i] = issue.String()
+		}
+		c.Header("X-Export-Issues", strings.Join(reasons, "; "))
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// formatFromFilename infers the format of a quiz document from its extension.
+func formatFromFilename(filename string) quizio.Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gift", ".txt":
+		return quizio.FormatGIFT
+	case ".xml", ".zip":
+		return quizio.FormatQTI
+	}
+	return ""
+}
@@ -53,41 +53,8 @@ func (h *SubmissionHandler) CreateSubmission(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"submission": sub})
 }
 
-// CheckCanSeeSubmissionMiddleware returns a Gin middleware function that checks if the current user
-// has permission to view a specific submission. It should be used before handlers that retrieve or
-// manipulate submission data.
-//
-// Returns:
-//   - A Gin HandlerFunc that performs the permission check.
-func (h *SubmissionHandler) CheckCanSeeSubmissionMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userId := GetUserID(c)
-		submissionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-		if err != nil {
-			HandleBadRequest(c, InvalidSubmissionID)
-			c.Abort()
-			return
-		}
-
-		canSee, err := h.serv.CanSeeSubmission(userId, uint(submissionID))
-		if err != nil {
-			SendError(err, c)
-			c.Abort()
-			return
-		}
-
-		if !canSee {
-			HandleUnauthorized(c, "You are not allowed to see this submission")
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
-}
-
 // GetSubmission retrieves a specific submission by its ID.
-// This handler should be called after the CheckCanSeeSubmissionMiddleware to ensure proper authorization.
+// This handler should be called after RequirePermission to ensure proper authorization.
 //
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response information.
@@ -95,7 +62,7 @@ func (h *SubmissionHandler) CheckCanSeeSubmissionMiddleware() gin.HandlerFunc {
 // Returns:
 //   - Responds with a JSON object containing the requested submission or an error message.
 func (h *SubmissionHandler) GetSubmission(c *gin.Context) {
-	submissionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	submissionID, err := strconv.ParseUint(c.Param(SubmissionIDKey), 10, 32)
 	if err != nil {
 		HandleBadRequest(c, InvalidSubmissionID)
 		return
@@ -133,7 +100,7 @@ func (h *SubmissionHandler) DeleteSubmission(c *gin.Context) {
 }
 
 // GetSubmissionsForAssignment retrieves all submissions for a specific assignment.
-// This handler should be called after the CheckCanSeeSubmissionMiddleware to ensure proper authorization.
+// This handler should be called after RequirePermission to ensure proper authorization.
 //
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response information.
@@ -164,7 +131,7 @@ func (h *SubmissionHandler) GetSubmissionsForAssignment(c *gin.Context) {
 // Returns:
 //   - Responds with a JSON object containing the updated submission or an error message.
 func (h *SubmissionHandler) UpdateSubmission(c *gin.Context) {
-	submissionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	submissionID, err := strconv.ParseUint(c.Param(SubmissionIDKey), 10, 32)
 	if err != nil {
 		HandleBadRequest(c, InvalidSubmissionID)
 		return
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler handles setting up and turning off two-factor authentication
+type TwoFactorHandler struct {
+	serv *services.TwoFactorService
+}
+
+func NewTwoFactorHandler(serv *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{serv: serv}
+}
+
+// twoFactorCode is the request body of the endpoints that take a code.
+type twoFactorCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// Setup generates a TOTP secret for the authenticated user to add to their
+// authenticator app. Two-factor authentication stays off until Enable.
+//
+// Method: POST
+// Route: /users/2fa/setup
+//
+// Returns:
+//   - 200 OK: Returns the secret and its otpauth:// provisioning URI.
+//   - 401 Unauthorized: If two-factor authentication is already enabled.
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	setup, err := h.serv.Setup(GetUserID(c))
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Enable turns on two-factor authentication for the authenticated user, once
+// they enter a code from their app, and returns their recovery codes.
+//
+// Method: POST
+// Route: /users/2fa/enable
+//
+// Request Body:
+//   - code: A code from the authenticator app.
+//
+// Returns:
+//   - 200 OK: Returns the recovery codes, which are shown only this once.
+//   - 400 Bad Request: If the code is missing.
+//   - 401 Unauthorized: If the code is wrong, or two-factor authentication was not set up or is already enabled.
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	var body twoFactorCode
+	if err := c.ShouldBindJSON(&body); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	codes, err := h.serv.Enable(GetUserID(c), body.Code)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// Disable turns off two-factor authentication for the authenticated user.
+//
+// Method: POST
+// Route: /users/2fa/disable
+//
+// Request Body:
+//   - code: A code from the authenticator app, or a recovery code.
+//
+// Returns:
+//   - 200 OK: If two-factor authentication was turned off.
+//   - 400 Bad Request: If the code is missing.
+//   - 401 Unauthorized: If the code is wrong or two-factor authentication is not enabled.
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var body twoFactorCode
+	if err := c.ShouldBindJSON(&body); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	if err := h.serv.Disable(GetUserID(c), body.Code); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Two-factor authentication disabled")
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated user.
+//
+// Method: POST
+// Route: /users/2fa/recovery-codes
+//
+// Request Body:
+//   - code: A code from the authenticator app, or a recovery code.
+//
+// Returns:
+//   - 200 OK: Returns the new recovery codes.
+//   - 400 Bad Request: If the code is missing.
+//   - 401 Unauthorized: If the code is wrong or two-factor authentication is not enabled.
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var body twoFactorCode
+	if err := c.ShouldBindJSON(&body); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	codes, err := h.serv.RegenerateRecoveryCodes(GetUserID(c), body.Code)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
@@ -1,44 +1,75 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	apperror "server/app/error"
 	"server/app/models"
 	"server/app/services"
-	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // UserHandler handles HTTP requests related to user operations
 type UserHandler struct {
-	serv *services.UserService
+	serv     *services.UserService
+	accounts *services.AccountService
+	throttle *services.LoginThrottleService
 }
 
-func NewUserHandler(serv *services.UserService) *UserHandler {
+func NewUserHandler(serv *services.UserService, accounts *services.AccountService, throttle *services.LoginThrottleService) *UserHandler {
 	return &UserHandler{
-		serv: serv,
+		serv:     serv,
+		accounts: accounts,
+		throttle: throttle,
 	}
 }
 
 // Register handles user registration
-// It binds the JSON request to a User model and creates a new user
+// It binds the JSON request to the fields of a new account, creates the user and emails them a link to verify their address.
+// A failure to send the email doesn't undo the registration, as the user can ask for another link.
 func (h *UserHandler) Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var input struct {
+		FirstName   string    `json:"firstName"`
+		LastName    string    `json:"lastName"`
+		Email       string    `json:"email" binding:"required,email"`
+		Password    string    `json:"password" binding:"required,min=8"`
+		DateOfBirth time.Time `json:"dateOfBirth"`
+		Bio         string    `json:"bio"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		HandleBadRequest(c, err.Error())
 		return
 	}
 
-	if err := h.serv.CreateUser(&user); err != nil {
+	user := models.User{
+		FirstName:   input.FirstName,
+		LastName:    input.LastName,
+		Email:       input.Email,
+		DateOfBirth: input.DateOfBirth,
+		Bio:         input.Bio,
+		Role:        models.RoleUser,
+		Active:      true,
+	}
+	if err := h.serv.CreateUser(&user, input.Password); err != nil {
 		SendError(err, c)
 		return
 	}
 
+	if err := h.accounts.SendVerificationEmail(user.ID); err != nil {
+		HandleCreated(c, "User created successfully, but the verification email could not be sent")
+		return
+	}
+
 	HandleCreated(c, "User created successfully")
 }
 
 // Login handles user authentication
-// It validates user credentials and returns a JWT token upon successful login
+// It validates user credentials and returns a JWT token upon successful login.
+// Users with two-factor authentication get a twoFactorToken instead, for VerifyTwoFactor.
+// Failed logins slow down, then lock out, further attempts on the account and from the IP address,
+// answered with 429 Too Many Requests.
 func (h *UserHandler) Login(c *gin.Context) {
 	var loginData struct {
 		Email    string `json:"email" binding:"required"`
@@ -50,19 +81,166 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.serv.AuthenticateUser(loginData.Email, loginData.Password)
+	ip := throttledIP(c)
+	if err := h.throttle.Check(loginData.Email, ip); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	tokens, err := h.serv.AuthenticateUser(loginData.Email, loginData.Password)
 	if err != nil {
+		h.recordFailure(c, err, loginData.Email, ip)
+		return
+	}
+
+	// With two-factor authentication, the login only succeeds once the code is verified.
+	if tokens.TwoFactorToken == "" {
+		if err := h.throttle.RecordSuccess(loginData.Email); err != nil {
+			SendError(err, c)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// recordFailure counts a login that failed on wrong credentials against the
+// account and the IP address, then sends the error.
+func (h *UserHandler) recordFailure(c *gin.Context, err error, email, ip string) {
+	var invalidCredential apperror.InvalidCredential
+	if errors.As(err, &invalidCredential) {
+		if recordErr := h.throttle.RecordFailure(email, ip); recordErr != nil {
+			SendError(recordErr, c)
+			return
+		}
+	}
+	SendError(err, c)
+}
+
+// VerifyTwoFactor completes the login of a user with two-factor authentication,
+// exchanging the two-factor token from the login and a code for the tokens of a session.
+//
+// Method: POST
+// Route: /users/2fa/verify
+//
+// Request Body:
+//   - twoFactorToken: The two-factor token the login returned.
+//   - code: A code from the authenticator app, or an unused recovery code.
+//
+// Returns:
+//   - 200 OK: Returns the tokens as JSON.
+//   - 400 Bad Request: If the token or code is missing.
+//   - 401 Unauthorized: If the two-factor token is invalid or expired, or the code is wrong.
+//   - 429 Too Many Requests: If the account or the IP address is throttled after failed attempts.
+func (h *UserHandler) VerifyTwoFactor(c *gin.Context) {
+	var verifyData struct {
+		TwoFactorToken string `json:"twoFactorToken" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&verifyData); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	user, err := h.serv.TwoFactorTokenUser(verifyData.TwoFactorToken)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	// Wrong codes count against the account like wrong passwords.
+	ip := throttledIP(c)
+	if err := h.throttle.Check(user.Email, ip); err != nil {
 		SendError(err, c)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	tokens, err := h.serv.VerifyTwoFactor(verifyData.TwoFactorToken, verifyData.Code)
+	if err != nil {
+		h.recordFailure(c, err, user.Email, ip)
+		return
+	}
+
+	if err := h.throttle.RecordSuccess(user.Email); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh exchanges a refresh token for a new access token and the next
+// refresh token of the session. Each refresh token can be used only once.
+//
+// Method: POST
+// Route: /users/refresh
+//
+// Request Body:
+//   - refreshToken: The refresh token received at login or at the last refresh.
+//
+// Returns:
+//   - 200 OK: Returns the new tokens as JSON.
+//   - 400 Bad Request: If the refresh token is missing.
+//   - 401 Unauthorized: If the refresh token is invalid, already used, or its session was revoked.
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var refreshData struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&refreshData); err != nil {
+		HandleBadRequest(c, err.Error())
+		return
+	}
+
+	tokens, err := h.serv.RefreshTokens(refreshData.RefreshToken)
+	if err != nil {
+		SendError(err, c)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the session of the access token used for the request.
+//
+// Method: POST
+// Route: /users/logout
+//
+// Returns:
+//   - 200 OK: If the session was revoked.
+//   - 404 Not Found: If the session was already revoked.
+func (h *UserHandler) Logout(c *gin.Context) {
+	userID := GetUserID(c)
+	if err := h.serv.Logout(userID, GetSessionID(c)); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Logged out successfully")
+}
+
+// LogoutAll revokes every session of the authenticated user, signing them out
+// on all devices.
+//
+// Method: POST
+// Route: /users/logout-all
+//
+// Returns:
+//   - 200 OK: If the sessions were revoked.
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	userID := GetUserID(c)
+	if err := h.serv.LogoutAll(userID); err != nil {
+		SendError(err, c)
+		return
+	}
+
+	HandleOk(c, "Logged out of all devices successfully")
 }
 
 // GetProfile retrieves the user profile for the authenticated user
 func (h *UserHandler) GetProfile(c *gin.Context) {
-	userID, _ := c.Get("userID")
-	user, err := h.serv.GetUserByID(userID.(uint))
+	user, err := h.serv.GetUserByID(GetUserID(c))
 	if err != nil {
 		SendError(err, c)
 		return
@@ -72,6 +250,8 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 }
 
 // UpdateProfile updates the user profile for the authenticated user
+// Only the name, email, date of birth and bio can change. A new email address is
+// unverified until the user follows the link emailed to it.
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID := GetUserID(c)
 	user, err := h.serv.GetUserByID(userID)
@@ -80,33 +260,34 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(user); err != nil {
+	var update services.ProfileUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
 		HandleBadRequest(c, err.Error())
 		return
 	}
 
-	if err := h.serv.UpdateUser(user); err != nil {
+	email := user.Email
+	if user, err = h.serv.UpdateProfile(userID, update); err != nil {
 		SendError(err, c)
 		return
 	}
 
+	// As at registration, a failure to send the email doesn't undo the change.
+	if user.Email != email {
+		_ = h.accounts.SendVerificationEmail(user.ID)
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
-// DeleteUser deletes a user by their ID
+// DeleteUser deletes the account of the authenticated user
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		HandleBadRequest(c, InvalidUserID)
-		return
-	}
-
-	if err := h.serv.DeleteUser(uint(userID)); err != nil {
+	if err := h.serv.DeleteUser(GetUserID(c)); err != nil {
 		SendError(err, c)
 		return
 	}
 
-	HandleDeleted(c, "User deleted successfully")
+	HandleOk(c, "User deleted successfully")
 }
 
 // ChangePassword changes the password for the authenticated user
@@ -131,3 +312,11 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 	HandleOk(c, "Password changed successfully")
 }
+
+// throttledIP returns the IP address failed logins count against: the address
+// the request came from. The X-Forwarded-For header is left alone, since the
+// server runs without a proxy in front of it and any client could set it to
+// escape the throttle.
+func throttledIP(c *gin.Context) string {
+	return c.RemoteIP()
+}
@@ -2,16 +2,32 @@ package handlers
 
 import (
 	"errors"
+	"math"
 	"mime/multipart"
 	"net/http"
+	apperror "server/app/error"
+	"server/app/identity"
 	"server/app/services"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+// GetIdentity returns the identity of the user making the request, as stored by AuthMiddleware.
+// It panics if there is none, so a route missing AuthMiddleware fails loudly instead of acting as user 0.
+func GetIdentity(c *gin.Context) identity.Identity {
+	return identity.MustGet(c)
+}
+
+// GetUserID returns the ID of the user making the request. It panics if there is no identity.
 func GetUserID(c *gin.Context) uint {
-	return c.GetUint("userId")
+	return GetIdentity(c).UserID
+}
+
+// GetSessionID returns the ID of the session of the access token used for the
+// request. It panics if there is no identity.
+func GetSessionID(c *gin.Context) uint {
+	return GetIdentity(c).SessionID
 }
 
 // HandleOk sends a JSON response with a 200 OK status and the given message.
@@ -100,6 +116,10 @@ func SendError(err error, c *gin.Context) {
 	var createEntityFailureError services.CreateEntityFailureError
 	var permissionDeniedError services.PermissionDeniedError
 	var cannotPerformActionError services.CannotPerformActionError
+	var invalidInputError services.InvalidInputError
+	var invalidCredential apperror.InvalidCredential
+	var invalidToken apperror.InvalidToken
+	var tooManyAttempts services.TooManyAttemptsError
 
 	switch {
 	case errors.As(err, &entityNotFoundError):
@@ -110,6 +130,20 @@ func SendError(err error, c *gin.Context) {
 		HandleUnauthorized(c, err.Error())
 		return
 
+	case errors.As(err, &invalidCredential) || errors.As(err, &invalidToken):
+		HandleUnauthorized(c, err.Error())
+		return
+
+	case errors.As(err, &tooManyAttempts):
+		seconds := int(math.Ceil(tooManyAttempts.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		HandleError(c, http.StatusTooManyRequests, err.Error())
+		return
+
+	case errors.As(err, &invalidInputError):
+		HandleBadRequest(c, err.Error())
+		return
+
 	case errors.As(err, &createEntityFailureError):
 		HandleError(c, http.StatusInternalServerError, err.Error())
 
@@ -0,0 +1,66 @@
+// Package identity carries who is making a request through the gin context.
+// AuthMiddleware stores the identity once the access token is verified, and
+// handlers and middlewares further down the chain read it back.
+package identity
+
+import (
+	"server/app/models"
+	"server/app/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextKey is the gin context key the identity is stored under.
+const contextKey = "identity"
+
+// Identity is the authenticated user of a request.
+type Identity struct {
+	UserID    uint
+	Role      models.Role // Global role of the user, RoleUser or RoleAdmin
+	SessionID uint        // 0 when the token was not checked against a session
+
+	// TokenID and Scope are set for requests made with a personal access token,
+	// which may only do what its scope allows.
+	TokenID uint
+	Scope   *policy.TokenScope
+
+	// ImpersonatorID is the ID of the admin acting as the user, for sessions
+	// an admin started with the impersonation of the user.
+	ImpersonatorID uint
+}
+
+// IsAPIToken reports whether the request was made with a personal access token.
+func (i Identity) IsAPIToken() bool {
+	return i.Scope != nil
+}
+
+// IsImpersonated reports whether an admin is acting as the user.
+func (i Identity) IsImpersonated() bool {
+	return i.ImpersonatorID != 0
+}
+
+// Set stores the identity of the request.
+func Set(c *gin.Context, id Identity) {
+	c.Set(contextKey, id)
+}
+
+// Get returns the identity of the request, and whether there is one.
+func Get(c *gin.Context) (Identity, bool) {
+	value, ok := c.Get(contextKey)
+	if !ok {
+		return Identity{}, false
+	}
+
+	id, ok := value.(Identity)
+	return id, ok
+}
+
+// MustGet returns the identity of the request. It panics if there is none,
+// which means the route was set up without AuthMiddleware in front of it.
+func MustGet(c *gin.Context) Identity {
+	id, ok := Get(c)
+	if !ok {
+		panic("identity: no identity in context for " + c.FullPath() + "; is AuthMiddleware in front of the route?")
+	}
+	return id
+}
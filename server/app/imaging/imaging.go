@@ -0,0 +1,156 @@
+// Package imaging reads uploaded PNG and JPEG images and makes square
+// thumbnails of them. Thumbnails are encoded afresh, so they carry none of the
+// metadata of the upload, such as the EXIF location of a photo.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+// Format is an image format accepted for uploads.
+type Format string
+
+const (
+	FormatPNG  Format = "png"
+	FormatJPEG Format = "jpeg"
+)
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Extension returns the file extension of the format, with its dot.
+func (f Format) Extension() string {
+	if f == FormatJPEG {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// ErrUnsupportedFormat is returned for data that is not a PNG or JPEG image,
+// whatever the name of its file says.
+var ErrUnsupportedFormat = errors.New("image must be a PNG or JPEG")
+
+// jpegQuality is the quality of encoded JPEG thumbnails.
+const jpegQuality = 85
+
+// Decode reads a PNG or JPEG image, telling the format from the content rather
+// than a file name. JPEG images are turned upright according to their EXIF
+// orientation, since the orientation is lost with the rest of the metadata.
+//
+// Parameters:
+//   - data: The image file.
+//   - maxPixels: The largest number of pixels accepted, guarding against images
+//     that take far more memory decoded than their file size suggests.
+//
+// Returns:
+//   - image.Image: The decoded image.
+//   - Format: The format of the image.
+//   - error: ErrUnsupportedFormat if the data is not a PNG or JPEG image, or an error if
+//     it is too large or cannot be decoded, nil otherwise.
+func Decode(data []byte, maxPixels int) (image.Image, Format, error) {
+	var format Format
+	switch http.DetectContentType(data) {
+	case "image/png":
+		format = FormatPNG
+	case "image/jpeg":
+		format = FormatJPEG
+	default:
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %v", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, "", fmt.Errorf("image must be at most %d pixels", maxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %v", err)
+	}
+
+	if format == FormatJPEG {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, format, nil
+}
+
+// Encode writes an image in a format.
+func Encode(w io.Writer, img image.Image, format Format) error {
+	if format == FormatJPEG {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return png.Encode(w, img)
+}
+
+// Square crops the middle square out of an image and scales it to size by size pixels.
+// Each pixel of the result is the average of the pixels it covers in the source.
+func Square(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	scale := float64(side) / float64(size)
+	for y := 0; y < size; y++ {
+		y0, y1 := float64(y)*scale, float64(y+1)*scale
+		for x := 0; x < size; x++ {
+			x0, x1 := float64(x)*scale, float64(x+1)*scale
+			dst.SetNRGBA(x, y, average(img, crop.Min, x0, y0, x1, y1))
+		}
+	}
+	return dst
+}
+
+// average returns the average color of the area [x0, x1) × [y0, y1) of the
+// image, relative to origin, weighting each pixel by how much of it the area covers.
+func average(img image.Image, origin image.Point, x0, y0, x1, y1 float64) color.NRGBA {
+	var r, g, b, a, total float64
+	for sy := int(y0); float64(sy) < y1; sy++ {
+		wy := overlap(float64(sy), y0, y1)
+		for sx := int(x0); float64(sx) < x1; sx++ {
+			w := wy * overlap(float64(sx), x0, x1)
+			if w <= 0 {
+				continue
+			}
+
+			// Premultiplied colors, so transparent pixels don't tint their neighbours.
+			pr, pg, pb, pa := img.At(origin.X+sx, origin.Y+sy).RGBA()
+			r += w * float64(pr)
+			g += w * float64(pg)
+			b += w * float64(pb)
+			a += w * float64(pa)
+			total += w
+		}
+	}
+
+	if total == 0 || a == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: uint8(r / a * 0xff),
+		G: uint8(g / a * 0xff),
+		B: uint8(b / a * 0xff),
+		A: uint8(a / total / 0x101),
+	}
+}
+
+// overlap returns how much of the pixel starting at p the span [from, to) covers.
+func overlap(p, from, to float64) float64 {
+	return max(0, min(p+1, to)-max(p, from))
+}
@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes each email to a .eml file in a directory instead of
+// sending it, so flows that send emails can be followed locally.
+type FileMailer struct {
+	dir   string
+	from  string
+	count atomic.Uint64
+}
+
+// NewFileMailer creates a mailer writing to the given directory, creating it if needed.
+//
+// Parameters:
+//   - dir: The directory to write the emails to.
+//   - from: The sender of the emails.
+//
+// Returns:
+//   - *FileMailer: The mailer.
+//   - error: An error if the directory is missing or cannot be created, nil otherwise.
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, errors.New("mail directory is required")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating mail directory: %v", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Send writes the email to a file named after the time, a counter and the recipient.
+func (m *FileMailer) Send(msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%04d-%s.eml",
+		time.Now().Format("20060102T150405"),
+		m.count.Add(1),
+		unsafeFileChars.ReplaceAllString(msg.To, "_"))
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg), 0o644); err != nil {
+		return fmt.Errorf("error writing email: %v", err)
+	}
+	return nil
+}
+
+// LogMailer writes each email to a logger instead of sending it.
+type LogMailer struct {
+	logger *log.Logger
+	from   string
+}
+
+// NewLogMailer creates a mailer writing to the given logger, or to the
+// standard logger if it is nil.
+func NewLogMailer(logger *log.Logger, from string) *LogMailer {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LogMailer{logger: logger, from: from}
+}
+
+// Send logs the email.
+func (m *LogMailer) Send(msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	m.logger.Printf("email from %s to %s\nSubject: %s\n\n%s", m.from, msg.To, msg.Subject, msg.Body)
+	return nil
+}
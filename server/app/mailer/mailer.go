@@ -0,0 +1,75 @@
+// Package mailer sends the emails of the application, such as password reset
+// and email verification links, through SMTP or, for local development,
+// to files or the log.
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// FromEnv builds the mailer selected by the MAIL_DRIVER environment variable:
+//   - "smtp": sends through SMTP_HOST and SMTP_PORT, authenticating with
+//     SMTP_USERNAME and SMTP_PASSWORD when set.
+//   - "file": writes each email to a file in MAIL_DIR.
+//   - "log" or unset: writes each email to the standard logger.
+//
+// MAIL_FROM sets the sender of the emails.
+//
+// Returns:
+//   - Mailer: The configured mailer.
+//   - error: An error if the driver is unknown or its settings are invalid, nil otherwise.
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT: %v", err)
+		}
+
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+
+	case "file":
+		return NewFileMailer(os.Getenv("MAIL_DIR"), from)
+
+	case "log", "":
+		return NewLogMailer(nil, from), nil
+
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
+
+// validate refuses messages whose recipient could inject extra headers.
+func validate(msg Message) error {
+	if msg.To == "" || strings.ContainsAny(msg.To, "\r\n") {
+		return errors.New("invalid recipient address")
+	}
+	return nil
+}
@@ -3,49 +3,164 @@ package middlewares
 import (
 	"errors"
 	"net/http"
+	"server/app/identity"
+	"server/app/models"
+	"server/app/services"
 	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
+// SessionValidator checks that the session an access token was issued for is
+// still live. It lets AuthMiddleware turn away tokens of revoked sessions and of
+// deleted or deactivated users before they expire.
+type SessionValidator interface {
+	ValidateSession(userID, sessionID uint) error
+}
+
+// APITokenValidator checks personal access tokens, returning the identity a
+// token acts as, limited to its scope.
+type APITokenValidator interface {
+	ValidateAPIToken(token string) (identity.Identity, error)
+}
+
 // AuthMiddleware is a middleware that checks for a valid JWT token in the authorization header of an incoming request,
-// and sets the user ID from the token to the gin context.
+// and stores the identity of the user from the token in the gin context, for identity.MustGet to read.
+//
+// It takes a secret key to validate the token, and optionally a SessionValidator. With a validator, the token must
+// carry the ID of its session in the "sid" claim, and the session must still be live; the session ID is then part of
+// the identity as well.
 //
-// It takes a secret key to validate the token.
+// If a validator also implements APITokenValidator, as services.SessionService does, personal access tokens are
+// accepted in place of a JWT. Their identity carries the scope of the token, which RequirePermission enforces.
 //
 // If the request does not have a valid token, it returns a 401 Unauthorized status. If the token is not valid, it returns
-// a 401 Unauthorized status with a JSON response containing the error message. If the token is valid, it sets the
-// user ID to the gin context and calls the next handler in the chain.
+// a 401 Unauthorized status with a JSON response containing the error message. If the token is valid, it stores the
+// identity in the gin context and calls the next handler in the chain.
 //
 // Example usage:
 //
-// router.GET("/api/protected", AuthMiddleware("secretKey"), protectedHandler)
-func AuthMiddleware(secretKey string) gin.HandlerFunc {
+// router.GET("/api/protected", AuthMiddleware("secretKey", sessionService), protectedHandler)
+func AuthMiddleware(secretKey string, sessions ...SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token, err := extractToken(c)
-		if err != nil {
-			handleAuthError(c, http.StatusUnauthorized, err.Error())
+		if !authenticate(c, secretKey, sessions) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		claims, err := validateToken(token, secretKey)
-		if err != nil {
-			handleAuthError(c, http.StatusUnauthorized, "invalid token")
+// SessionAuthMiddleware is AuthMiddleware for routes that manage the account
+// itself, such as changing the password or creating personal access tokens.
+// They need a signed-in session, so personal access tokens get a 403 Forbidden status.
+func SessionAuthMiddleware(secretKey string, sessions ...SessionValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c, secretKey, sessions) {
 			return
 		}
 
-		userID, err := extractUserID(claims)
-		if err != nil {
-			handleAuthError(c, http.StatusUnauthorized, "invalid token claims")
+		if identity.MustGet(c).IsAPIToken() {
+			handleAuthError(c, http.StatusForbidden, "personal access tokens cannot be used here")
 			return
 		}
+		c.Next()
+	}
+}
 
-		c.Set("userID", userID)
+// RejectImpersonation turns away requests of admins impersonating a user with a
+// 403 Forbidden status. It guards the routes that change the credentials or the
+// profile of the account, which are for the user alone. It must come after AuthMiddleware.
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if identity.MustGet(c).IsImpersonated() {
+			handleAuthError(c, http.StatusForbidden, "not available while impersonating a user")
+			return
+		}
 		c.Next()
 	}
 }
 
+// authenticate verifies the token of the request and stores its identity. It
+// aborts the request and returns false if the token is missing or invalid.
+func authenticate(c *gin.Context, secretKey string, sessions []SessionValidator) bool {
+	token, err := extractToken(c)
+	if err != nil {
+		handleAuthError(c, http.StatusUnauthorized, err.Error())
+		return false
+	}
+
+	if strings.HasPrefix(token, services.APITokenPrefix) {
+		return authenticateAPIToken(c, token, sessions)
+	}
+
+	claims, err := validateToken(token, secretKey)
+	if err != nil {
+		handleAuthError(c, http.StatusUnauthorized, "invalid token")
+		return false
+	}
+
+	// Tokens with a purpose, such as the partial token of a login awaiting its
+	// second factor, are only good at their own endpoint.
+	if _, ok := claims["purpose"]; ok {
+		handleAuthError(c, http.StatusUnauthorized, "invalid token")
+		return false
+	}
+
+	userID, err := extractUserID(claims)
+	if err != nil {
+		handleAuthError(c, http.StatusUnauthorized, "invalid token claims")
+		return false
+	}
+
+	id := identity.Identity{UserID: userID, Role: extractRole(claims)}
+	if impersonator, ok := claims["imp"].(float64); ok {
+		id.ImpersonatorID = uint(impersonator)
+	}
+	if len(sessions) > 0 {
+		sessionID, err := extractSessionID(claims)
+		if err != nil {
+			handleAuthError(c, http.StatusUnauthorized, "invalid token claims")
+			return false
+		}
+
+		for _, validator := range sessions {
+			if err := validator.ValidateSession(userID, sessionID); err != nil {
+				handleAuthError(c, http.StatusUnauthorized, "session is no longer valid")
+				return false
+			}
+		}
+
+		id.SessionID = sessionID
+	}
+
+	identity.Set(c, id)
+	return true
+}
+
+// authenticateAPIToken verifies a personal access token with the first validator
+// that checks them, and stores its identity.
+func authenticateAPIToken(c *gin.Context, token string, sessions []SessionValidator) bool {
+	for _, validator := range sessions {
+		tokens, ok := validator.(APITokenValidator)
+		if !ok {
+			continue
+		}
+
+		id, err := tokens.ValidateAPIToken(token)
+		if err != nil {
+			handleAuthError(c, http.StatusUnauthorized, "invalid token")
+			return false
+		}
+
+		identity.Set(c, id)
+		return true
+	}
+
+	handleAuthError(c, http.StatusUnauthorized, "personal access tokens are not accepted here")
+	return false
+}
+
 // extractToken extracts the JWT token from the Authorization header of the request.
 // It expects the token to be in the format "Bearer <token>".
 // Returns the token string if found, or an error if the header is missing or incorrectly formatted.
@@ -97,6 +212,26 @@ func extractUserID(claims jwt.MapClaims) (uint, error) {
 	return uint(userID), nil
 }
 
+// extractSessionID retrieves the session ID from the JWT claims.
+// It expects the session ID to be stored in the "sid" claim as a float64.
+// Returns the session ID as a uint if found, or an error if not present or of incorrect type.
+func extractSessionID(claims jwt.MapClaims) (uint, error) {
+	sessionID, ok := claims["sid"].(float64)
+	if !ok {
+		return 0, errors.New("session ID not found in token claims")
+	}
+	return uint(sessionID), nil
+}
+
+// extractRole retrieves the global role of the user from the "role" claim of the JWT claims.
+// Tokens without a known role are treated as those of regular users.
+func extractRole(claims jwt.MapClaims) models.Role {
+	if role, _ := claims["role"].(string); models.Role(role) == models.RoleAdmin {
+		return models.RoleAdmin
+	}
+	return models.RoleUser
+}
+
 // handleAuthError sends a JSON response with the given error message and status code,
 // then aborts the current request processing.
 // This function is used to handle authentication errors in a consistent manner.
@@ -105,23 +240,6 @@ func handleAuthError(c *gin.Context, status int, message string) {
 	c.Abort()
 }
 
-// getUserId retrieves the user ID from the gin context.
-// It expects the user ID to be stored in the "userID" key as a uint.
-// Returns the user ID if found, or an error if not present or of incorrect type.
-func getUserId(c *gin.Context) (uint, error) {
-	userID, ok := c.Get("userID")
-	if !ok {
-		return 0, errors.New("user ID not found in context")
-	}
-
-	userIDInt, ok := userID.(uint)
-	if !ok {
-		return 0, errors.New("user ID is not a uint")
-	}
-
-	return userIDInt, nil
-}
-
 var (
 	ExportedExtractToken    = extractToken
 	ExportedValidateToken   = validateToken
@@ -0,0 +1,172 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"server/app/handlers"
+	"server/app/identity"
+	"server/app/policy"
+	"server/app/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorizer checks the permissions of users, as services.AuthorizationService does.
+type Authorizer interface {
+	Authorize(userID uint, perm policy.Permission, res policy.Resource) error
+}
+
+// ResourceResolver finds the resource a request acts on.
+type ResourceResolver func(c *gin.Context) (policy.Resource, error)
+
+// ResourceLookup describes the resource with the given ID, as
+// services.AuthorizationService.CourseResource does for courses.
+type ResourceLookup func(id uint) (policy.Resource, error)
+
+// RequirePermission is a middleware that only lets users with a permission on
+// the resource of the request through. It must run after AuthMiddleware, and
+// panics if there is no identity in the context.
+//
+// The resource is found with the resolver; a nil resolver means the request
+// acts on no course, as listing or creating courses does.
+//
+// It aborts with 400 Bad Request if the resource ID in the request is invalid,
+// 404 Not Found if the resource doesn't exist, and 403 Forbidden if the user
+// lacks the permission.
+//
+// Example usage:
+//
+// router.PUT("/courses/:id", RequirePermission(authz, policy.CourseUpdate, ParamResource("id", authz.CourseResource)), handler)
+func RequirePermission(authz Authorizer, perm policy.Permission, resolve ResourceResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, err := hasPermission(c, authz, perm, resolve)
+		if err != nil {
+			handlers.SendError(err, c)
+			c.Abort()
+			return
+		}
+
+		if !allowed {
+			HandleForbiddenWithAbort(c, fmt.Sprintf("You don't have the %s permission", perm))
+			return
+		}
+		c.Next()
+	}
+}
+
+// CheckPermission is a middleware that sets the given context key to whether
+// the user has a permission on the resource of the request, for handlers that
+// answer differently depending on it. Unlike RequirePermission, it lets users
+// without the permission through.
+func CheckPermission(authz Authorizer, perm policy.Permission, resolve ResourceResolver, key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, err := hasPermission(c, authz, perm, resolve)
+		if err != nil {
+			handlers.SendError(err, c)
+			c.Abort()
+			return
+		}
+
+		c.Set(key, allowed)
+		c.Next()
+	}
+}
+
+// ParamResource resolves the resource whose ID is in a URL parameter.
+func ParamResource(key string, lookup ResourceLookup) ResourceResolver {
+	return func(c *gin.Context) (policy.Resource, error) {
+		return lookupID(c.Param(key), key, lookup)
+	}
+}
+
+// QueryResource resolves the resource whose ID is in a query parameter.
+func QueryResource(key string, lookup ResourceLookup) ResourceResolver {
+	return func(c *gin.Context) (policy.Resource, error) {
+		return lookupID(c.Query(key), key, lookup)
+	}
+}
+
+// FormResource resolves the resource whose ID is in a form field.
+func FormResource(field string, lookup ResourceLookup) ResourceResolver {
+	return func(c *gin.Context) (policy.Resource, error) {
+		return lookupID(c.PostForm(field), field, lookup)
+	}
+}
+
+// JSONResource resolves the resource whose ID is in a field of the JSON body.
+// The body is put back for the handler to bind.
+func JSONResource(field string, lookup ResourceLookup) ResourceResolver {
+	return func(c *gin.Context) (policy.Resource, error) {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return policy.Resource{}, services.InvalidInput(err)
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+
+		var body map[string]json.RawMessage
+		var id uint
+		if err := json.Unmarshal(data, &body); err != nil || json.Unmarshal(body[field], &id) != nil || id == 0 {
+			return policy.Resource{}, services.InvalidInput(fmt.Errorf("%s must be a valid ID", field))
+		}
+		return lookup(id)
+	}
+}
+
+// SelfResource resolves to the user making the request, for requests acting
+// on the user's own data outside of any course.
+func SelfResource() ResourceResolver {
+	return func(c *gin.Context) (policy.Resource, error) {
+		return policy.Resource{OwnerID: identity.MustGet(c).UserID}, nil
+	}
+}
+
+// UserParamResource resolves to the user whose ID is in a URL parameter, for
+// requests acting on the data of a user outside of any course.
+func UserParamResource(key string) ResourceResolver {
+	return func(c *gin.Context) (policy.Resource, error) {
+		return lookupID(c.Param(key), key, func(id uint) (policy.Resource, error) {
+			return policy.Resource{OwnerID: id}, nil
+		})
+	}
+}
+
+// hasPermission checks the permission of the user of the request.
+// Requests made with a personal access token also need the permission in the scope of the token.
+func hasPermission(c *gin.Context, authz Authorizer, perm policy.Permission, resolve ResourceResolver) (bool, error) {
+	id := identity.MustGet(c)
+
+	var res policy.Resource
+	if resolve != nil {
+		var err error
+		if res, err = resolve(c); err != nil {
+			return false, err
+		}
+	}
+
+	if id.Scope != nil && !id.Scope.Allows(perm, res) {
+		return false, nil
+	}
+
+	err := authz.Authorize(id.UserID, perm, res)
+	var denied services.PermissionDeniedError
+	if errors.As(err, &denied) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// lookupID parses an ID and describes the resource it identifies.
+func lookupID(value, name string, lookup ResourceLookup) (policy.Resource, error) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		return policy.Resource{}, services.InvalidInput(fmt.Errorf("%s must be a valid ID", name))
+	}
+	return lookup(uint(id))
+}
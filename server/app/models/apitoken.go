@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// APIToken is a personal access token a user creates for scripts and
+// integrations. It can only be used for the permissions in Scopes, and only in
+// one course if CourseID is set. Only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	UserID     uint       `json:"-" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"` // Start of the token, to tell tokens apart
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     []string   `json:"scopes" gorm:"type:text;serializer:json;not null"` // Permissions, such as "grade:read"
+	CourseID   *uint      `json:"courseId" gorm:"index"`
+	ExpiresAt  time.Time  `json:"expiresAt" gorm:"not null"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
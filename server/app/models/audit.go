@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AuditAction is the kind of event an audit entry records.
+type AuditAction string
+
+const (
+	AuditLoginLockout      AuditAction = "login.lockout"
+	AuditAccountUnlock     AuditAction = "account.unlock"
+	AuditUserDeactivate    AuditAction = "user.deactivate"
+	AuditUserReactivate    AuditAction = "user.reactivate"
+	AuditUserRoleChange    AuditAction = "user.role_change"
+	AuditUserPasswordReset AuditAction = "user.password_reset"
+	AuditUserImpersonate   AuditAction = "user.impersonate"
+	AuditUserProvision     AuditAction = "user.provision"
+	AuditUserErasure       AuditAction = "user.erasure"
+	AuditUserErase         AuditAction = "user.erase"
+)
+
+// AuditLog is an entry of the audit trail of security-relevant events, such as
+// lockouts after repeated failed logins. Entries are only ever added; erasing
+// a user only clears the details and IP address of the entries about them.
+type AuditLog struct {
+	ID        uint        `json:"id" gorm:"primarykey"`
+	Action    AuditAction `json:"action" gorm:"type:varchar(64);index;not null"`
+	ActorID   *uint       `json:"actorId" gorm:"index"` // User who acted, nil for the system
+	UserID    *uint       `json:"userId" gorm:"index"`  // User acted upon, if known
+	IP        string      `json:"ip"`
+	Details   string      `json:"details"`
+	CreatedAt time.Time   `json:"createdAt" gorm:"index"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Course struct {
 	gorm.Model
@@ -8,16 +12,41 @@ type Course struct {
 	Description    string       `json:"description"`
 	CreatorID      uint         `json:"creator_id" gorm:"not null"`
 	Creator        User         `json:"creator" gorm:"foreignKey:CreatorID"`
-	StartDate      string       `json:"start_date"`
+	StartDate      string       `json:"start_date" gorm:"index"`
 	EndDate        string       `json:"end_date"`
 	MaxStudents    int          `json:"max_students"`
-	Difficulty     string       `json:"difficulty"`
-	Category       string       `json:"category"`
-	IsActive       bool         `json:"is_active"`
-	InvitationCode string       `json:"invitation_code" gorm:"unique"`
+	Difficulty     string       `json:"difficulty" gorm:"index"`
+	Category       string       `json:"category" gorm:"index"`
+	IsActive       bool         `json:"is_active" gorm:"index"`
+	InvitationCode string       `json:"invitation_code" gorm:"unique"` // Code of the student invitation
+	ArchivedAt     *time.Time   `json:"archived_at"`                   // Set while the course is archived, read-only for all but global admins
+	ClonedFromID   *uint        `json:"cloned_from_id"`                // Course the content was copied from, set once the copy is done
 	Enrollments    []Enrollment `json:"enrollments" gorm:"foreignKey:CourseID"`
+	// Invitations are only loaded for the admins of the course, as the teacher code must stay with them.
+	Invitations []CourseInvitation `json:"invitations,omitempty" gorm:"foreignKey:CourseID"`
+
+	// SearchVector is the full-text search document of the course, its name
+	// weighing more than its description. PostgreSQL keeps it up to date, and it
+	// is never read into the struct.
+	SearchVector string `json:"-" gorm:"type:tsvector GENERATED ALWAYS AS (setweight(to_tsvector('english', coalesce(name, '')), 'A') || setweight(to_tsvector('english', coalesce(description, '')), 'B')) STORED;index:idx_courses_search,type:gin;->:false"`
 }
 
 func (c *Course) TableName() string {
 	return CoursesTable
 }
+
+// CourseInvitation is the invitation code of a course for a role. Each course
+// has one per role; users joining with it get its role, until it expires, is
+// used up or is disabled.
+type CourseInvitation struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	CourseID   uint       `json:"course_id" gorm:"not null;uniqueIndex:idx_course_invitations_role"`
+	Role       Role       `json:"role" gorm:"not null;uniqueIndex:idx_course_invitations_role"`
+	Code       string     `json:"code" gorm:"not null;uniqueIndex"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	MaxUses    int        `json:"max_uses"` // 0 for no limit
+	Uses       int        `json:"uses"`     // Joins since the code was issued
+	DisabledAt *time.Time `json:"disabled_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
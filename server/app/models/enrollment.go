@@ -2,12 +2,15 @@ package models
 
 import "gorm.io/gorm"
 
+// Role is the role of a user in a course. RoleUser and RoleAdmin are also the
+// global roles of users, a global admin having every permission in every course.
 type Role string
 
 const (
 	RoleStudent Role = "student"
 	RoleTeacher Role = "teacher"
 	RoleAdmin   Role = "admin"
+	RoleUser    Role = "user"
 )
 
 func (r Role) String() string {
@@ -31,7 +34,7 @@ type Enrollment struct {
 	gorm.Model
 	UserID   uint             `json:"userId" gorm:"not null"`
 	User     User             `json:"-" gorm:"foreignkey:UserID"`
-	CourseID uint             `json:"courseId" gorm:"not null"`
+	CourseID uint             `json:"courseId" gorm:"not null;index"`
 	Course   Course           `json:"-" gorm:"foreignkey:CourseID"`
 	Role     Role             `json:"role" gorm:"not null"`
 	Status   EnrollmentStatus `json:"status" gorm:"not null;default:'pending'"`
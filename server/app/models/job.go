@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed" // Gave up after its last attempt
+)
+
+// Job is a unit of background work, such as erasing the data of a user. Jobs
+// are queued in the database, so they survive restarts, and are retried a few
+// times when they fail.
+type Job struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	Kind       string     `json:"kind" gorm:"type:varchar(64);index;not null"`
+	Payload    string     `json:"-" gorm:"type:text;not null"` // JSON arguments of the job
+	Status     JobStatus  `json:"status" gorm:"type:varchar(16);index;not null;default:'pending'"`
+	Attempts   int        `json:"attempts" gorm:"not null;default:0"`
+	LastError  string     `json:"lastError,omitempty" gorm:"type:text"`
+	Result     string     `json:"result,omitempty" gorm:"type:text"`  // What the job reports when it succeeds
+	Progress   int        `json:"progress" gorm:"not null;default:0"` // Percentage done, as the job reports it
+	CourseID   *uint      `json:"courseId,omitempty" gorm:"index"`    // Course the job works on, if any
+	RunAt      time.Time  `json:"runAt" gorm:"index;not null"`        // When the job is due, later after a failure
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an OpenID Connect provider,
+// identified by the provider and the subject of its ID tokens.
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"userId" gorm:"index;not null"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_user_identities_subject;not null"`
+	Subject   string    `json:"-" gorm:"uniqueIndex:idx_user_identities_subject;not null"`
+	Email     string    `json:"email"` // Email address at the provider when the identity was linked
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OIDCLogin is a sign-in with an OpenID Connect provider in progress, from
+// sending the user to the provider until it sends them back. Only the SHA-256
+// hash of the state is stored; the nonce and PKCE verifier never leave the server.
+type OIDCLogin struct {
+	ID        uint      `gorm:"primarykey"`
+	Provider  string    `gorm:"not null"`
+	StateHash string    `gorm:"uniqueIndex;not null"`
+	Nonce     string    `gorm:"not null"`
+	Verifier  string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
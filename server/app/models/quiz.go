@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,18 +9,81 @@ import (
 
 type Quiz struct {
 	gorm.Model
-	Title            string     `json:"title" gorm:"not null"`
-	Description      string     `json:"description" gorm:"type:text"`
-	CourseID         uint       `json:"courseId" gorm:"not null"`
-	Course           Course     `json:"-" gorm:"foreignkey:CourseID"`
-	StartTime        time.Time  `json:"startTime" gorm:"not null"`
-	EndTime          time.Time  `json:"endTime" gorm:"not null"`
-	Duration         int        `json:"duration" gorm:"not null"` // In minutes
-	ShuffleQuestions bool       `json:"shuffleQuestions" gorm:"default:false"`
-	ShowResults      bool       `json:"showResults" gorm:"default:true"`
-	Questions        []Question `json:"questions" gorm:"foreignKey:QuizID"`
-	CreatorID        uint       `json:"creatorId" gorm:"not null"`
-	Creator          User       `json:"-" gorm:"foreignkey:CreatorID"`
+	Title            string         `json:"title" gorm:"not null"`
+	Description      string         `json:"description" gorm:"type:text"`
+	CourseID         uint           `json:"courseId" gorm:"not null"`
+	Course           Course         `json:"-" gorm:"foreignkey:CourseID"`
+	StartTime        time.Time      `json:"startTime" gorm:"not null"`
+	EndTime          time.Time      `json:"endTime" gorm:"not null"`
+	Duration         int            `json:"duration" gorm:"not null"` // In minutes
+	ShuffleQuestions bool           `json:"shuffleQuestions" gorm:"default:false"`
+	ShuffleOptions   bool           `json:"shuffleOptions" gorm:"default:false"`
+	ShowResults      bool           `json:"showResults" gorm:"default:true"`
+	ScoringPolicy    ScoringPolicy  `json:"scoringPolicy" gorm:"not null;default:'all_or_nothing'"`
+	MaxAttempts      int            `json:"maxAttempts" gorm:"not null;default:1"`
+	AttemptScoring   AttemptScoring `json:"attemptScoring" gorm:"not null;default:'highest'"`
+	Questions        []Question     `json:"questions" gorm:"foreignKey:QuizID"`
+	DrawRules        []QuizDrawRule `json:"drawRules" gorm:"foreignKey:QuizID"`
+	CreatorID        uint           `json:"creatorId" gorm:"not null"`
+	Creator          User           `json:"-" gorm:"foreignkey:CreatorID"`
+}
+
+// ScoringPolicy decides how partially correct answers to MultiCorrect
+// questions are scored. SingleCorrect questions are always all-or-nothing.
+type ScoringPolicy string
+
+const (
+	// ScoringAllOrNothing awards the points only if exactly the correct options are selected.
+	ScoringAllOrNothing ScoringPolicy = "all_or_nothing"
+	// ScoringPartialCredit awards a share of the points for each correct option
+	// selected, and nothing if any wrong option is selected.
+	ScoringPartialCredit ScoringPolicy = "partial_credit"
+	// ScoringNegativeMarking awards a share of the points for each correct option
+	// selected and takes a share away for each wrong one, never going below zero.
+	ScoringNegativeMarking ScoringPolicy = "negative_marking"
+)
+
+func (p ScoringPolicy) IsValid() bool {
+	switch p {
+	case ScoringAllOrNothing, ScoringPartialCredit, ScoringNegativeMarking:
+		return true
+	default:
+		return false
+	}
+}
+
+// AttemptScoring decides which score counts for a student who took a quiz
+// several times.
+type AttemptScoring string
+
+const (
+	AttemptScoringHighest AttemptScoring = "highest"
+	AttemptScoringLatest  AttemptScoring = "latest"
+	AttemptScoringAverage AttemptScoring = "average"
+)
+
+func (a AttemptScoring) IsValid() bool {
+	switch a {
+	case AttemptScoringHighest, AttemptScoringLatest, AttemptScoringAverage:
+		return true
+	default:
+		return false
+	}
+}
+
+// QuizAccommodation overrides the schedule and attempts of a quiz for a single
+// enrollment, e.g. extra time or a later window for a make-up.
+type QuizAccommodation struct {
+	gorm.Model
+	QuizID             uint       `json:"quizId" gorm:"not null;uniqueIndex:idx_quiz_enrollment"`
+	Quiz               Quiz       `json:"-" gorm:"foreignkey:QuizID"`
+	EnrollmentID       uint       `json:"enrollmentId" gorm:"not null;uniqueIndex:idx_quiz_enrollment"`
+	Enrollment         Enrollment `json:"-" gorm:"foreignkey:EnrollmentID"`
+	DurationMultiplier float64    `json:"durationMultiplier" gorm:"not null;default:1"`
+	ExtraMinutes       int        `json:"extraMinutes" gorm:"not null;default:0"`
+	StartTime          *time.Time `json:"startTime"` // Replaces Quiz.StartTime when set
+	EndTime            *time.Time `json:"endTime"`   // Replaces Quiz.EndTime when set
+	ExtraAttempts      int        `json:"extraAttempts" gorm:"not null;default:0"`
 }
 
 type QuestionType int
@@ -27,17 +91,81 @@ type QuestionType int
 const (
 	SingleCorrect QuestionType = iota
 	MultiCorrect
+	TrueFalse
+	Numeric
+	ShortText
+	Ordering
+	Essay
 )
 
+func (t QuestionType) IsValid() bool {
+	return t >= SingleCorrect && t <= Essay
+}
+
+// NeedsManualGrading reports whether answers to the question are graded by a
+// teacher rather than scored automatically.
+func (t QuestionType) NeedsManualGrading() bool {
+	return t == Essay
+}
+
+// UsesOptions reports whether answers to the question are given by selecting options.
+func (t QuestionType) UsesOptions() bool {
+	switch t {
+	case SingleCorrect, MultiCorrect, TrueFalse, Ordering:
+		return true
+	default:
+		return false
+	}
+}
+
+// Question is either part of a quiz, with QuizID set, or part of the question
+// bank of a course, with CourseID set. Bank questions are drawn into quizzes
+// through QuizDrawRule.
 type Question struct {
 	gorm.Model
-	QuizID      uint         `json:"quizId" gorm:"not null"`
-	Quiz        Quiz         `json:"-" gorm:"foreignkey:QuizID"`
-	Title       string       `json:"title" gorm:"not null"`
-	Description string       `json:"description"`
-	Type        QuestionType `json:"type" gorm:"not null"`
-	Points      int          `json:"points" gorm:"not null"`
-	Options     []Option     `json:"options" gorm:"foreignKey:QuestionID"`
+	QuizID      *uint         `json:"quizId,omitempty" gorm:"index"`
+	Quiz        Quiz          `json:"-" gorm:"foreignkey:QuizID"`
+	CourseID    *uint         `json:"courseId,omitempty" gorm:"index"`
+	Course      Course        `json:"-" gorm:"foreignkey:CourseID"`
+	Tags        []QuestionTag `json:"tags" gorm:"foreignKey:QuestionID"`
+	Title       string        `json:"title" gorm:"not null"`
+	Description string        `json:"description"`
+	Type        QuestionType  `json:"type" gorm:"not null"`
+	Points      int           `json:"points" gorm:"not null"`
+	Options     []Option      `json:"options" gorm:"foreignKey:QuestionID"`
+
+	// NumericAnswer and Tolerance are the answer key of Numeric questions.
+	NumericAnswer *float64 `json:"numericAnswer,omitempty"`
+	Tolerance     float64  `json:"tolerance"`
+
+	// AcceptedAnswers is the answer key of ShortText questions.
+	AcceptedAnswers     []string `json:"acceptedAnswers,omitempty" gorm:"-"` // This will be stored as JSON in the database
+	AcceptedAnswersJSON string   `json:"-" gorm:"column:accepted_answers;type:text"`
+}
+
+// QuestionTag labels a bank question so that quizzes can draw from it.
+// It is represented in JSON as the plain tag name.
+type QuestionTag struct {
+	ID         uint   `gorm:"primarykey"`
+	QuestionID uint   `gorm:"not null;index"`
+	Name       string `gorm:"not null;index"`
+}
+
+func (t QuestionTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Name)
+}
+
+func (t *QuestionTag) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &t.Name)
+}
+
+// QuizDrawRule makes a quiz draw Count questions tagged Tag from the question
+// bank of its course. Every attempt draws its own questions.
+type QuizDrawRule struct {
+	gorm.Model
+	QuizID uint   `json:"quizId" gorm:"not null;index"`
+	Tag    string `json:"tag" gorm:"not null"`
+	Count  int    `json:"count" gorm:"not null"`
 }
 
 type Option struct {
@@ -46,18 +174,56 @@ type Option struct {
 	Question   Question `json:"-" gorm:"foreignkey:QuestionID"`
 	Text       string   `json:"text" gorm:"not null"`
 	IsCorrect  bool     `json:"isCorrect" gorm:"not null"`
+	Position   int      `json:"position"` // Correct place of the option in Ordering questions
 }
 
+type AttemptStatus string
+
+const (
+	AttemptStatusInProgress AttemptStatus = "in_progress"
+	AttemptStatusSubmitted  AttemptStatus = "submitted"
+	// AttemptStatusPendingReview marks a submitted attempt with answers that
+	// still have to be graded by a teacher.
+	AttemptStatusPendingReview AttemptStatus = "pending_review"
+)
+
+func (a AttemptStatus) String() string {
+	return string(a)
+}
+
+// QuizSubmission is a single attempt of a user at a quiz. ExpiresAt is fixed
+// when the attempt starts and is the last moment answers are accepted.
 type QuizSubmission struct {
 	gorm.Model
-	QuizID    uint      `json:"quizId" gorm:"not null"`
-	Quiz      Quiz      `json:"-" gorm:"foreignkey:QuizID"`
-	UserID    uint      `json:"userId" gorm:"not null"`
-	User      User      `json:"-" gorm:"foreignkey:UserID"`
-	StartTime time.Time `json:"startTime" gorm:"not null"`
-	EndTime   time.Time `json:"endTime"`
-	Score     float64   `json:"score"`
-	Answers   []Answer  `json:"answers" gorm:"foreignKey:SubmissionID"`
+	QuizID    uint          `json:"quizId" gorm:"not null"`
+	Quiz      Quiz          `json:"-" gorm:"foreignkey:QuizID"`
+	UserID    uint          `json:"userId" gorm:"not null"`
+	User      User          `json:"-" gorm:"foreignkey:UserID"`
+	Number    int           `json:"number" gorm:"not null;default:1"` // 1 for the first attempt of the user
+	Status    AttemptStatus `json:"status" gorm:"not null;default:'in_progress'"`
+	StartTime time.Time     `json:"startTime" gorm:"not null"`
+	ExpiresAt time.Time     `json:"expiresAt" gorm:"not null"`
+	Seed      int64         `json:"-" gorm:"not null;default:0"` // Fixes the question and option order of the attempt
+	EndTime   time.Time     `json:"endTime"`
+	Score     float64       `json:"score"`
+	MaxScore  float64       `json:"maxScore"`
+	Answers   []Answer      `json:"answers" gorm:"foreignKey:SubmissionID"`
+
+	// Questions are the bank questions drawn for this attempt.
+	Questions []AttemptQuestion `json:"-" gorm:"foreignKey:SubmissionID"`
+
+	// ResultsVisible tells the client whether Score and the per-answer points
+	// may be shown; they are zeroed out until the quiz ends with its results shown.
+	ResultsVisible bool `json:"resultsVisible" gorm:"-"`
+}
+
+// AttemptQuestion records a bank question drawn for an attempt, so the attempt
+// keeps the same questions however the bank changes later.
+type AttemptQuestion struct {
+	ID           uint     `gorm:"primarykey"`
+	SubmissionID uint     `gorm:"not null;uniqueIndex:idx_attempt_question"`
+	QuestionID   uint     `gorm:"not null;uniqueIndex:idx_attempt_question;index"`
+	Question     Question `gorm:"foreignkey:QuestionID"`
 }
 
 type Answer struct {
@@ -68,4 +234,60 @@ type Answer struct {
 	Question            Question       `json:"-" gorm:"foreignkey:QuestionID"`
 	SelectedOptions     []uint         `json:"selectedOptions" gorm:"-"` // This will be stored as JSON in the database
 	SelectedOptionsJSON string         `json:"-" gorm:"column:selected_options"`
+	TextAnswer          string         `json:"textAnswer" gorm:"type:text"`
+	NumericAnswer       *float64       `json:"numericAnswer"`
+	PointsAwarded       float64        `json:"pointsAwarded"`
+	TimeSpentSeconds    int            `json:"timeSpentSeconds" gorm:"not null;default:0"`
+
+	// PendingReview is set on answers waiting to be graded by a teacher.
+	PendingReview bool       `json:"pendingReview" gorm:"not null;default:false;index"`
+	Feedback      string     `json:"feedback" gorm:"type:text"`
+	GradedByID    *uint      `json:"gradedById"`
+	GradedAt      *time.Time `json:"gradedAt"`
+}
+
+// BeforeSave is a GORM hook that stores AcceptedAnswers as JSON in the database
+func (q *Question) BeforeSave(db *gorm.DB) error {
+	if q.AcceptedAnswers == nil {
+		q.AcceptedAnswers = []string{}
+	}
+
+	data, err := json.Marshal(q.AcceptedAnswers)
+	if err != nil {
+		return err
+	}
+	q.AcceptedAnswersJSON = string(data)
+	return nil
+}
+
+// AfterFind is a GORM hook that restores AcceptedAnswers from the stored JSON
+func (q *Question) AfterFind(db *gorm.DB) error {
+	if q.AcceptedAnswersJSON == "" {
+		q.AcceptedAnswers = []string{}
+		return nil
+	}
+	return json.Unmarshal([]byte(q.AcceptedAnswersJSON), &q.AcceptedAnswers)
+}
+
+// BeforeSave is a GORM hook that stores SelectedOptions as JSON in the database
+func (a *Answer) BeforeSave(db *gorm.DB) error {
+	if a.SelectedOptions == nil {
+		a.SelectedOptions = []uint{}
+	}
+
+	data, err := json.Marshal(a.SelectedOptions)
+	if err != nil {
+		return err
+	}
+	a.SelectedOptionsJSON = string(data)
+	return nil
+}
+
+// AfterFind is a GORM hook that restores SelectedOptions from the stored JSON
+func (a *Answer) AfterFind(db *gorm.DB) error {
+	if a.SelectedOptionsJSON == "" {
+		a.SelectedOptions = []uint{}
+		return nil
+	}
+	return json.Unmarshal([]byte(a.SelectedOptionsJSON), &a.SelectedOptions)
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a signed-in device of a user. Access tokens carry the ID of their
+// session, so revoking the session cuts off every access token issued for it.
+type Session struct {
+	gorm.Model
+	UserID     uint       `json:"userId" gorm:"index;not null"`
+	ExpiresAt  time.Time  `json:"expiresAt" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	// ImpersonatorID is the admin who opened the session to act as the user, for support.
+	ImpersonatorID *uint          `json:"impersonatorId" gorm:"index"`
+	Tokens         []RefreshToken `json:"-" gorm:"foreignKey:SessionID"`
+}
+
+// RefreshToken is a single-use refresh token of a session. Only the SHA-256
+// hash of the token is stored. Using a token marks it used and issues the next
+// one, so presenting a used token again means it leaked.
+type RefreshToken struct {
+	ID        uint       `json:"-" gorm:"primarykey"`
+	SessionID uint       `json:"-" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"-"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LoginThrottle counts the recent failed logins of an account or an IP address,
+// identified by Key, such as "account:ada@example.com" or "ip:203.0.113.7".
+// No login for the key is tried before LockedUntil.
+type LoginThrottle struct {
+	ID            uint   `gorm:"primarykey"`
+	Key           string `gorm:"uniqueIndex;not null"`
+	Failures      int    `gorm:"not null;default:0"`
+	LockedUntil   *time.Time
+	LastFailureAt time.Time
+	UpdatedAt     time.Time
+}
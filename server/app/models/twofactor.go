@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// TwoFactor is the TOTP authenticator of a user. It is created when the user
+// starts setting up two-factor authentication and only guards their logins once
+// EnabledAt is set, after they proved their app generates the right codes.
+type TwoFactor struct {
+	ID        uint       `json:"-" gorm:"primarykey"`
+	UserID    uint       `json:"-" gorm:"uniqueIndex;not null"`
+	Secret    string     `json:"-" gorm:"not null"` // Base32 TOTP secret
+	EnabledAt *time.Time `json:"enabledAt"`
+	LastStep  int64      `json:"-"` // Time step of the last accepted code, so a code works only once
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"-"`
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the user
+// has lost their authenticator. Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -9,26 +9,28 @@ import (
 
 type User struct {
 	gorm.Model
-	FirstName   string       `json:"firstName" gorm:"not null"`
-	LastName    string       `json:"lastName" gorm:"not null"`
-	Email       string       `json:"email" gorm:"uniqueIndex;not null"`
-	Password    string       `json:"-" gorm:"not null"` // The "-" ensures this field is not serialized to JSON
-	DateOfBirth time.Time    `json:"dateOfBirth"`
-	ProfilePic  string       `json:"profilePic"`
-	Bio         string       `json:"bio"`
-	Active      bool         `json:"active" gorm:"default:true"`
-	Enrollments []Enrollment `json:"enrollments" gorm:"foreignKey:UserID"`
+	FirstName       string       `json:"firstName" gorm:"not null"`
+	LastName        string       `json:"lastName" gorm:"not null"`
+	Email           string       `json:"email" gorm:"uniqueIndex;not null"`
+	Password        string       `json:"-" gorm:"not null"` // The bcrypt hash, set with SetPassword; "-" keeps it out of JSON
+	DateOfBirth     time.Time    `json:"dateOfBirth"`
+	ProfilePic      string       `json:"profilePic"`
+	Bio             string       `json:"bio"`
+	Active          bool         `json:"active" gorm:"default:true"`
+	Role            Role         `json:"role" gorm:"not null;default:'user'"` // Global role, RoleUser or RoleAdmin
+	EmailVerifiedAt *time.Time   `json:"emailVerifiedAt"`                     // Set once the user follows the link sent to their email
+	ErasedAt        *time.Time   `json:"-"`                                   // Set once the personal data of the user is erased
+	Enrollments     []Enrollment `json:"enrollments" gorm:"foreignKey:UserID"`
 }
 
-// BeforeSave is a GORM hook that hashes the user's password before saving to the database
-func (u *User) BeforeSave(db *gorm.DB) error {
-	if u.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
-		u.Password = string(hashedPassword)
+// SetPassword sets the password of the user, storing its bcrypt hash. Password
+// holds the hash, so it is only ever changed through SetPassword.
+func (u *User) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
 	}
+	u.Password = string(hashedPassword)
 	return nil
 }
 
@@ -37,3 +39,35 @@ func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
 }
+
+type UserTokenPurpose string
+
+const (
+	TokenPurposePasswordReset     UserTokenPurpose = "password_reset"
+	TokenPurposeEmailVerification UserTokenPurpose = "email_verification"
+)
+
+// UserToken is a single-use, expiring token sent to a user by email, to reset
+// their password or verify their email address. Only the SHA-256 hash of the
+// token is stored.
+type UserToken struct {
+	ID        uint             `gorm:"primarykey"`
+	UserID    uint             `gorm:"index;not null"`
+	Purpose   UserTokenPurpose `gorm:"type:varchar(32);not null"`
+	Email     string           `gorm:"not null;default:''"` // The address the token was sent to
+	TokenHash string           `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time        `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// AvatarImage is a square thumbnail of the avatar of a user, one per size.
+// The largest is the profile picture of the user.
+type AvatarImage struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	UserID    uint      `json:"-" gorm:"index;not null"`
+	Size      int       `json:"size" gorm:"not null"` // Width and height, in pixels
+	FileName  string    `json:"-" gorm:"not null"`    // Path of the file in the storage
+	URL       string    `json:"url" gorm:"not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
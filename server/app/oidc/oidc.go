@@ -0,0 +1,273 @@
+// Package oidc signs users in with an OpenID Connect provider, such as the
+// Google or Microsoft accounts of a school: it discovers the endpoints of the
+// provider, builds the authorization code request with PKCE, exchanges the
+// code for tokens and validates the ID token.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the settings of a provider, as registered with it.
+type Config struct {
+	Name         string // Name of the provider in the login routes, such as "google"
+	Issuer       string // Issuer URL, the base of the discovery document
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // Callback URL registered with the provider
+	Scopes       []string // Defaults to openid, email and profile
+}
+
+// Discovery is the part of the discovery document of a provider the login flow uses.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a validated ID token that identify the user.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// Provider signs users in with one OpenID Connect provider. Its discovery
+// document and signing keys are fetched on first use and cached.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      *keySet
+}
+
+// NewProvider creates a provider from its settings.
+//
+// Parameters:
+//   - config: The settings of the provider.
+//   - client: The HTTP client to reach the provider with, or nil for a default one.
+//
+// Returns:
+//   - *Provider: The provider.
+//   - error: An error if a setting is missing, nil otherwise.
+func NewProvider(config Config, client *http.Client) (*Provider, error) {
+	if config.Name == "" || config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC provider name, issuer, client ID and redirect URL are required")
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &Provider{config: config, client: client}, nil
+}
+
+// Name returns the name of the provider.
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL builds the URL of the provider to send the user to for signing in.
+//
+// Parameters:
+//   - ctx: The context of the request, bounding the fetch of the discovery document.
+//   - state: The value the provider sends back to the callback, tying it to this login.
+//   - nonce: The value the ID token must carry, tying it to this login.
+//   - challenge: The PKCE code challenge, from NewPKCE.
+//
+// Returns:
+//   - string: The authorization URL.
+//   - error: An error if the discovery document cannot be fetched, nil otherwise.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades an authorization code for the ID token of the user and validates it.
+//
+// Parameters:
+//   - ctx: The context of the request, bounding the calls to the provider.
+//   - code: The authorization code the provider sent to the callback.
+//   - verifier: The PKCE code verifier of the login.
+//   - nonce: The nonce of the login.
+//
+// Returns:
+//   - *Claims: The claims of the validated ID token.
+//   - error: An error if the exchange fails or the ID token is invalid, nil otherwise.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.do(req, &tokens); err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %v", err)
+	}
+
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no ID token")
+	}
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// discover fetches the discovery document of the provider, once it succeeds.
+func (p *Provider) discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery Discovery
+	if err := p.do(req, &discovery); err != nil {
+		return nil, fmt.Errorf("error fetching OIDC discovery document: %v", err)
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery document is for issuer %q, not %q", discovery.Issuer, p.config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// do sends a request to the provider and decodes its JSON response.
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider answered %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// NewPKCE generates a PKCE code verifier and its S256 code challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns 32 random bytes, base64url encoded, for states, nonces and verifiers.
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// FromEnv builds the providers listed in the OIDC_PROVIDERS environment
+// variable, a comma-separated list of names such as "google,microsoft". Each
+// provider NAME is set up from OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID,
+// OIDC_NAME_CLIENT_SECRET and OIDC_NAME_REDIRECT_URL.
+//
+// Returns:
+//   - []*Provider: The configured providers, none if OIDC_PROVIDERS is unset.
+//   - error: An error if the settings of a provider are incomplete, nil otherwise.
+func FromEnv() ([]*Provider, error) {
+	var providers []*Provider
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider, err := NewProvider(Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC provider %s: %v", name, err)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
@@ -0,0 +1,270 @@
+// Package policy holds the access rules of the platform: which permissions a
+// role grants within a course, and which ones every signed-in user has outside
+// of courses. It only answers questions; looking up the role of a user in a
+// course is left to the caller.
+package policy
+
+import "server/app/models"
+
+// Permission is an action on a kind of resource, such as "course:update".
+type Permission string
+
+const (
+	CourseCreate Permission = "course:create"
+	CourseList   Permission = "course:list"
+	CourseRead   Permission = "course:read"
+	CourseUpdate Permission = "course:update"
+	CourseDelete Permission = "course:delete"
+	// CourseArchive covers archiving a course. Since an archived course is
+	// read-only, only global admins can restore it.
+	CourseArchive Permission = "course:archive"
+	// CourseClone covers copying the content of a course into a new one.
+	CourseClone Permission = "course:clone"
+
+	EnrollmentJoin   Permission = "enrollment:join"
+	EnrollmentManage Permission = "enrollment:manage"
+
+	AssignmentCreate  Permission = "assignment:create"
+	AssignmentRead    Permission = "assignment:read"
+	AssignmentUpdate  Permission = "assignment:update"
+	AssignmentDelete  Permission = "assignment:delete"
+	AssignmentPublish Permission = "assignment:publish"
+
+	SubmissionCreate Permission = "submission:create"
+	SubmissionRead   Permission = "submission:read"
+	SubmissionUpdate Permission = "submission:update"
+	SubmissionDelete Permission = "submission:delete"
+
+	GradeCreate Permission = "grade:create"
+	GradeRead   Permission = "grade:read"
+	GradeUpdate Permission = "grade:update"
+
+	MaterialCreate Permission = "material:create"
+	MaterialRead   Permission = "material:read"
+	MaterialUpdate Permission = "material:update"
+	MaterialDelete Permission = "material:delete"
+
+	QuizCreate  Permission = "quiz:create"
+	QuizRead    Permission = "quiz:read"
+	QuizAttempt Permission = "quiz:attempt"
+	QuizManage  Permission = "quiz:manage"
+
+	QuestionManage Permission = "question:manage"
+
+	// ProfileManage covers the account of the user themselves: their profile,
+	// sign-in settings, avatar and personal data.
+	ProfileManage Permission = "profile:manage"
+	// UserManage covers the accounts of other users. No role grants it, so only
+	// global admins have it.
+	UserManage Permission = "user:manage"
+)
+
+// Scope tells how far a permission reaches.
+type Scope int
+
+const (
+	// ScopeNone grants nothing.
+	ScopeNone Scope = iota
+	// ScopeOwn grants the permission on resources owned by the user only.
+	ScopeOwn
+	// ScopeAll grants the permission on every resource it applies to.
+	ScopeAll
+)
+
+// Resource describes what a request acts on.
+type Resource struct {
+	// CourseID is the course the resource belongs to, or 0 for resources
+	// outside of any course, such as the list of courses.
+	CourseID uint
+	// OwnerID is the user owning the resource, or 0 if no user owns it.
+	OwnerID uint
+}
+
+// courseGrants maps the roles of a course to the permissions they grant in it.
+// The creator of a course is its admin.
+var courseGrants = map[models.Role]map[Permission]Scope{
+	models.RoleStudent: {
+		CourseRead:       ScopeAll,
+		AssignmentRead:   ScopeAll,
+		SubmissionCreate: ScopeAll,
+		SubmissionRead:   ScopeOwn,
+		SubmissionUpdate: ScopeOwn,
+		SubmissionDelete: ScopeOwn,
+		GradeRead:        ScopeOwn,
+		MaterialRead:     ScopeAll,
+		QuizRead:         ScopeAll,
+		QuizAttempt:      ScopeAll,
+	},
+	models.RoleTeacher: {
+		CourseRead:        ScopeAll,
+		AssignmentCreate:  ScopeAll,
+		AssignmentRead:    ScopeAll,
+		AssignmentUpdate:  ScopeAll,
+		AssignmentDelete:  ScopeAll,
+		AssignmentPublish: ScopeAll,
+		SubmissionRead:    ScopeAll,
+		GradeCreate:       ScopeAll,
+		GradeRead:         ScopeAll,
+		GradeUpdate:       ScopeAll,
+		MaterialCreate:    ScopeAll,
+		MaterialRead:      ScopeAll,
+		MaterialUpdate:    ScopeAll,
+		MaterialDelete:    ScopeAll,
+		QuizCreate:        ScopeAll,
+		QuizRead:          ScopeAll,
+		QuizManage:        ScopeOwn,
+		QuestionManage:    ScopeAll,
+	},
+	models.RoleAdmin: {
+		CourseRead:        ScopeAll,
+		CourseUpdate:      ScopeAll,
+		CourseDelete:      ScopeAll,
+		CourseArchive:     ScopeAll,
+		CourseClone:       ScopeAll,
+		EnrollmentManage:  ScopeAll,
+		AssignmentCreate:  ScopeAll,
+		AssignmentRead:    ScopeAll,
+		AssignmentUpdate:  ScopeAll,
+		AssignmentDelete:  ScopeAll,
+		AssignmentPublish: ScopeAll,
+		SubmissionRead:    ScopeAll,
+		SubmissionDelete:  ScopeAll,
+		GradeCreate:       ScopeAll,
+		GradeRead:         ScopeAll,
+		GradeUpdate:       ScopeAll,
+		MaterialCreate:    ScopeAll,
+		MaterialRead:      ScopeAll,
+		MaterialUpdate:    ScopeAll,
+		MaterialDelete:    ScopeAll,
+		QuizCreate:        ScopeAll,
+		QuizRead:          ScopeAll,
+		QuizManage:        ScopeAll,
+		QuestionManage:    ScopeAll,
+	},
+}
+
+// archivedPermissions are the permissions roles still grant in an archived
+// course: reading it, and cloning it into a new term.
+var archivedPermissions = map[Permission]bool{
+	CourseRead: true, CourseClone: true,
+	AssignmentRead: true, SubmissionRead: true, GradeRead: true, MaterialRead: true, QuizRead: true,
+}
+
+// platformGrants are the permissions every signed-in user has on resources
+// outside of any course.
+var platformGrants = map[Permission]Scope{
+	CourseCreate:   ScopeAll,
+	CourseList:     ScopeAll,
+	EnrollmentJoin: ScopeAll,
+	AssignmentRead: ScopeOwn,
+	GradeRead:      ScopeOwn,
+	ProfileManage:  ScopeOwn,
+}
+
+// tokenPermissions are the permissions a personal access token can carry.
+// Tokens are for scripts working with course data, so permissions over the
+// accounts of users are left out.
+var tokenPermissions = map[Permission]bool{
+	CourseCreate: true, CourseList: true, CourseRead: true, CourseUpdate: true, CourseDelete: true,
+	CourseArchive: true, CourseClone: true,
+	EnrollmentJoin: true, EnrollmentManage: true,
+	AssignmentCreate: true, AssignmentRead: true, AssignmentUpdate: true, AssignmentDelete: true, AssignmentPublish: true,
+	SubmissionCreate: true, SubmissionRead: true, SubmissionUpdate: true, SubmissionDelete: true,
+	GradeCreate: true, GradeRead: true, GradeUpdate: true,
+	MaterialCreate: true, MaterialRead: true, MaterialUpdate: true, MaterialDelete: true,
+	QuizCreate: true, QuizRead: true, QuizAttempt: true, QuizManage: true,
+	QuestionManage: true,
+}
+
+// TokenScope limits what a request made with a personal access token may do:
+// only the listed permissions, and only in one course if CourseID is set. The
+// user of the token must still have the permissions themselves.
+type TokenScope struct {
+	Permissions []Permission
+	CourseID    uint // 0 for every course
+}
+
+// Allows reports whether the scope covers a permission on a resource.
+//
+// Parameters:
+//   - perm: The permission needed.
+//   - res: The resource the request acts on.
+//
+// Returns:
+//   - bool: True if the token may be used for the request, false otherwise.
+func (s TokenScope) Allows(perm Permission, res Resource) bool {
+	if s.CourseID != 0 && res.CourseID != s.CourseID {
+		return false
+	}
+
+	for _, granted := range s.Permissions {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenPermission reports whether a personal access token can carry a permission.
+func TokenPermission(perm Permission) bool {
+	return tokenPermissions[perm]
+}
+
+// CourseScope returns how far a role in a course grants a permission.
+//
+// Parameters:
+//   - role: The role of the user in the course.
+//   - perm: The permission to look up.
+//
+// Returns:
+//   - Scope: The scope of the permission, ScopeNone if the role doesn't grant it.
+func CourseScope(role models.Role, perm Permission) Scope {
+	return courseGrants[role][perm]
+}
+
+// ArchivedScope returns how far a role grants a permission in an archived
+// course, where only reading and cloning are left.
+//
+// Parameters:
+//   - role: The role of the user in the course.
+//   - perm: The permission to look up.
+//
+// Returns:
+//   - Scope: The scope of the permission, ScopeNone if the role doesn't grant it or the archive forbids it.
+func ArchivedScope(role models.Role, perm Permission) Scope {
+	if !archivedPermissions[perm] {
+		return ScopeNone
+	}
+	return CourseScope(role, perm)
+}
+
+// PlatformScope returns how far a permission reaches outside of any course.
+//
+// Parameters:
+//   - perm: The permission to look up.
+//
+// Returns:
+//   - Scope: The scope of the permission, ScopeNone if users don't have it.
+func PlatformScope(perm Permission) Scope {
+	return platformGrants[perm]
+}
+
+// Allows reports whether a scope grants a permission on a resource to a user.
+//
+// Parameters:
+//   - scope: The scope the user has the permission with.
+//   - userID: The ID of the user.
+//   - res: The resource the user acts on.
+//
+// Returns:
+//   - bool: True if the user may act on the resource, false otherwise.
+func Allows(scope Scope, userID uint, res Resource) bool {
+	switch scope {
+	case ScopeAll:
+		return true
+	case ScopeOwn:
+		return res.OwnerID != 0 && res.OwnerID == userID
+	default:
+		return false
+	}
+}
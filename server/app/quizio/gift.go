@@ -0,0 +1,504 @@
+package quizio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"server/app/models"
+	"strconv"
+	"strings"
+)
+
+// giftSpecial are the characters that must be escaped in GIFT text.
+const giftSpecial = "~=#{}:\\"
+
+// giftBlank replaces the answer block of missing word questions in the question text.
+const giftBlank = "_____"
+
+// ParseGIFT reads questions in Moodle's GIFT format. Multiple choice, true/false,
+// short answer, numerical and essay questions are supported; matching and
+// description items are reported as issues. Feedback is dropped, and every
+// question is worth one point since GIFT doesn't carry points.
+//
+// Parameters:
+//   - r: The GIFT text.
+//
+// Returns:
+//   - []models.Question: The questions that could be read.
+//   - []Issue: The items that were skipped and why.
+//   - error: An error if the text cannot be read, nil otherwise.
+func ParseGIFT(r io.Reader) ([]models.Question, []Issue, error) {
+	blocks, err := giftBlocks(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var questions []models.Question
+	var issues []Issue
+
+	for i, block := range blocks {
+		question, err := parseGIFTQuestion(block)
+		if err != nil {
+			issues = append(issues, Issue{Item: i + 1, Title: giftIssueTitle(block), Reason: err.Error()})
+			continue
+		}
+		questions = append(questions, question)
+	}
+
+	return questions, issues, nil
+}
+
+// WriteGIFT writes the questions of a quiz in Moodle's GIFT format. Ordering
+// questions have no GIFT equivalent and are reported as issues, as are the
+// draw rules of the quiz.
+//
+// Parameters:
+//   - w: Where to write the GIFT text.
+//   - quiz: The quiz, with its questions and options.
+//
+// Returns:
+//   - []Issue: The items that could not be exported.
+//   - error: An error if writing fails, nil otherwise.
+func WriteGIFT(w io.Writer, quiz *models.Quiz) ([]Issue, error) {
+	var issues []Issue
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "// %s\n", collapseSpace(quiz.Title))
+
+	for i, question := range quiz.Questions {
+		answer, err := giftAnswer(question)
+		if err != nil {
+			issues = append(issues, Issue{Item: i + 1, Title: question.Title, Reason: err.Error()})
+			continue
+		}
+
+		bw.WriteString("\n")
+		if question.Description != "" {
+			fmt.Fprintf(bw, "::%s::%s", giftEscape(question.Title), giftEscape(question.Description))
+		} else {
+			bw.WriteString(giftEscape(question.Title))
+		}
+		fmt.Fprintf(bw, " {%s}\n", answer)
+	}
+
+	for _, rule := range quiz.DrawRules {
+		issues = append(issues, Issue{Title: rule.Tag, Reason: "questions drawn from the question bank cannot be exported"})
+	}
+
+	return issues, bw.Flush()
+}
+
+// giftBlocks splits GIFT text into question blocks, dropping comments and categories.
+func giftBlocks(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var blocks []string
+	var current []string
+
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "$CATEGORY:"):
+			continue
+		default:
+			current = append(current, line)
+		}
+	}
+	flush()
+
+	return blocks, scanner.Err()
+}
+
+// parseGIFTQuestion parses a single GIFT question block.
+func parseGIFTQuestion(block string) (models.Question, error) {
+	text := strings.TrimSpace(block)
+	title := ""
+
+	if strings.HasPrefix(text, "::") {
+		end := indexUnescaped(text[2:], "::")
+		if end < 0 {
+			return models.Question{}, fmt.Errorf("unterminated title")
+		}
+		title = giftUnescape(text[2 : end+2])
+		text = strings.TrimSpace(text[end+4:])
+	}
+
+	open := indexUnescaped(text, "{")
+	if open < 0 {
+		return models.Question{}, fmt.Errorf("description items without an answer are not supported")
+	}
+
+	closing := indexUnescaped(text[open:], "}")
+	if closing < 0 {
+		return models.Question{}, fmt.Errorf("unterminated answer block")
+	}
+	closing += open
+
+	before := strings.TrimSpace(text[:open])
+	after := strings.TrimSpace(text[closing+1:])
+	answer := strings.TrimSpace(text[open+1 : closing])
+
+	stem := stripFormat(before)
+	if after != "" {
+		stem = strings.TrimSpace(stem + " " + giftBlank + " " + after)
+	}
+	stem = collapseSpace(giftUnescape(stem))
+
+	question := models.Question{Title: stem, Points: defaultPoints}
+	if title != "" {
+		question.Title = collapseSpace(title)
+		question.Description = stem
+	}
+
+	if question.Title == "" {
+		return models.Question{}, fmt.Errorf("question has no text")
+	}
+
+	if err := parseGIFTAnswer(&question, answer); err != nil {
+		return models.Question{}, err
+	}
+
+	return question, nil
+}
+
+// parseGIFTAnswer fills in the type and answer key of a question from its GIFT answer block.
+func parseGIFTAnswer(question *models.Question, answer string) error {
+	if answer == "" {
+		question.Type = models.Essay
+		return nil
+	}
+
+	if strings.HasPrefix(answer, "#") {
+		return parseGIFTNumeric(question, answer[1:])
+	}
+
+	if value, ok := giftBool(answer); ok {
+		question.Type = models.TrueFalse
+		question.Options = []models.Option{
+			{Text: "True", IsCorrect: value},
+			{Text: "False", IsCorrect: !value},
+		}
+		return nil
+	}
+
+	if indexUnescaped(answer, "->") >= 0 {
+		return fmt.Errorf("matching questions are not supported")
+	}
+
+	choices := giftChoices(answer)
+	if len(choices) == 0 {
+		return fmt.Errorf("answer block has no answers")
+	}
+
+	shortAnswer := true
+	for _, choice := range choices {
+		if choice.marker != '=' || choice.weighted {
+			shortAnswer = false
+		}
+	}
+
+	if shortAnswer {
+		question.Type = models.ShortText
+		for _, choice := range choices {
+			question.AcceptedAnswers = append(question.AcceptedAnswers, choice.text)
+		}
+		return nil
+	}
+
+	correct := 0
+	for _, choice := range choices {
+		isCorrect := choice.marker == '=' || choice.weight > 0
+		if isCorrect {
+			correct++
+		}
+		question.Options = append(question.Options, models.Option{Text: choice.text, IsCorrect: isCorrect})
+	}
+
+	switch {
+	case correct == 0:
+		return fmt.Errorf("multiple choice question has no correct answer")
+	case correct == 1:
+		question.Type = models.SingleCorrect
+	default:
+		question.Type = models.MultiCorrect
+	}
+
+	return nil
+}
+
+// parseGIFTNumeric parses the answer of a numerical question: "answer",
+// "answer:tolerance" or "min..max". Only the first answer is kept when several are given.
+func parseGIFTNumeric(question *models.Question, answer string) error {
+	answer = strings.TrimSpace(answer)
+	if strings.HasPrefix(answer, "=") {
+		choices := giftChoices(answer)
+		if len(choices) == 0 {
+			return fmt.Errorf("numerical question has no answer")
+		}
+		answer = choices[0].text
+	} else {
+		answer = strings.TrimSpace(giftStripFeedback(answer))
+	}
+
+	value, tolerance := 0.0, 0.0
+	var err error
+
+	switch {
+	case strings.Contains(answer, ".."):
+		bounds := strings.SplitN(answer, "..", 2)
+		low, errLow := strconv.ParseFloat(strings.TrimSpace(bounds[0]), 64)
+		high, errHigh := strconv.ParseFloat(strings.TrimSpace(bounds[1]), 64)
+		if errLow != nil || errHigh != nil || high < low {
+			return fmt.Errorf("invalid numerical range %q", answer)
+		}
+		value, tolerance = (low+high)/2, (high-low)/2
+
+	case strings.Contains(answer, ":"):
+		parts := strings.SplitN(answer, ":", 2)
+		value, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err == nil {
+			tolerance, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid numerical answer %q", answer)
+		}
+
+	default:
+		value, err = strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return fmt.Errorf("invalid numerical answer %q", answer)
+		}
+	}
+
+	question.Type = models.Numeric
+	question.NumericAnswer = &value
+	question.Tolerance = math.Abs(tolerance)
+	return nil
+}
+
+type giftChoice struct {
+	marker   byte
+	weighted bool
+	weight   float64
+	text     string
+}
+
+// giftChoices splits an answer block into its "=" and "~" choices.
+func giftChoices(answer string) []giftChoice {
+	var choices []giftChoice
+	start := -1
+
+	emit := func(end int) {
+		if start < 0 {
+			return
+		}
+		choice := giftChoice{marker: answer[start]}
+		body := strings.TrimSpace(answer[start+1 : end])
+
+		if strings.HasPrefix(body, "%") {
+			if closing := strings.Index(body[1:], "%"); closing >= 0 {
+				if weight, err := strconv.ParseFloat(body[1:closing+1], 64); err == nil {
+					choice.weighted = true
+					choice.weight = weight
+					body = body[closing+2:]
+				}
+			}
+		}
+
+		choice.text = collapseSpace(giftUnescape(giftStripFeedback(body)))
+		if choice.text != "" {
+			choices = append(choices, choice)
+		}
+	}
+
+	for i := 0; i < len(answer); i++ {
+		switch answer[i] {
+		case '\\':
+			i++
+		case '=', '~':
+			emit(i)
+			start = i
+		}
+	}
+	emit(len(answer))
+
+	return choices
+}
+
+// giftBool recognizes the answer block of true/false questions.
+func giftBool(answer string) (bool, bool) {
+	switch strings.ToUpper(strings.TrimSpace(giftStripFeedback(answer))) {
+	case "T", "TRUE":
+		return true, true
+	case "F", "FALSE":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// giftAnswer builds the GIFT answer block of a question.
+func giftAnswer(question models.Question) (string, error) {
+	switch question.Type {
+	case models.Essay:
+		return "", nil
+
+	case models.Numeric:
+		if question.NumericAnswer == nil {
+			return "", fmt.Errorf("numeric question has no answer")
+		}
+		return fmt.Sprintf("#%s:%s", formatNumber(*question.NumericAnswer), formatNumber(question.Tolerance)), nil
+
+	case models.ShortText:
+		parts := make([]string, 0, len(question.AcceptedAnswers))
+		for _, accepted := range question.AcceptedAnswers {
+			parts = append(parts, "="+giftEscape(accepted))
+		}
+		return strings.Join(parts, " "), nil
+
+	case models.TrueFalse:
+		for _, option := range question.Options {
+			if !option.IsCorrect {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(option.Text)) {
+			case "true", "t":
+				return "TRUE", nil
+			case "false", "f":
+				return "FALSE", nil
+			}
+		}
+		return giftChoicesAnswer(question), nil
+
+	case models.SingleCorrect, models.MultiCorrect:
+		return giftChoicesAnswer(question), nil
+
+	default:
+		return "", fmt.Errorf("ordering questions cannot be exported to GIFT")
+	}
+}
+
+// giftChoicesAnswer builds the answer block of a multiple choice question.
+// Questions with several correct options split the credit between them, and
+// take an equal share away for each wrong option.
+func giftChoicesAnswer(question models.Question) string {
+	correct := 0
+	for _, option := range question.Options {
+		if option.IsCorrect {
+			correct++
+		}
+	}
+	wrong := len(question.Options) - correct
+
+	parts := make([]string, 0, len(question.Options))
+	for _, option := range question.Options {
+		text := giftEscape(option.Text)
+
+		switch {
+		case correct <= 1 && option.IsCorrect:
+			parts = append(parts, "="+text)
+		case correct <= 1:
+			parts = append(parts, "~"+text)
+		case option.IsCorrect:
+			parts = append(parts, fmt.Sprintf("~%%%s%%%s", giftWeight(100/float64(correct)), text))
+		default:
+			parts = append(parts, fmt.Sprintf("~%%-%s%%%s", giftWeight(100/float64(wrong)), text))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// giftStripFeedback removes the "#feedback" part of a GIFT answer.
+func giftStripFeedback(text string) string {
+	if i := indexUnescaped(text, "#"); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+// giftIssueTitle picks a short label for a block that could not be parsed.
+func giftIssueTitle(block string) string {
+	text := strings.TrimSpace(block)
+	if strings.HasPrefix(text, "::") {
+		if end := indexUnescaped(text[2:], "::"); end >= 0 {
+			return collapseSpace(giftUnescape(text[2 : end+2]))
+		}
+	}
+
+	text = collapseSpace(text)
+	if len(text) > 40 {
+		text = text[:40] + "..."
+	}
+	return text
+}
+
+// indexUnescaped returns the index of the first occurrence of sub in text that
+// is not preceded by a backslash, or -1.
+func indexUnescaped(text, sub string) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// stripFormat removes the [html], [markdown], [plain] or [moodle] prefix of GIFT text.
+func stripFormat(text string) string {
+	for _, format := range []string{"[html]", "[markdown]", "[plain]", "[moodle]"} {
+		if strings.HasPrefix(text, format) {
+			return strings.TrimSpace(text[len(format):])
+		}
+	}
+	return text
+}
+
+func giftUnescape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && strings.IndexByte(giftSpecial, text[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+func giftEscape(text string) string {
+	var b strings.Builder
+	for _, r := range collapseSpace(text) {
+		if r < 128 && strings.IndexByte(giftSpecial, byte(r)) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// giftWeight rounds an answer weight to the five decimals Moodle expects.
+func giftWeight(weight float64) string {
+	return formatNumber(math.Round(weight*1e5) / 1e5)
+}
+
+// formatNumber prints a number without trailing zeros.
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
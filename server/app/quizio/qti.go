@@ -0,0 +1,624 @@
+package quizio
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"server/app/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const qtiNamespace = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+
+// maxQTIFileSize caps the size of a single file read from a QTI package.
+const maxQTIFileSize = 5 << 20
+
+// ParseQTI reads questions from IMS QTI 2.1, given either a single
+// assessmentItem XML document or a content package ZIP. Items with a choice,
+// order, text entry or extended text interaction are supported; other items,
+// and items with several interactions, are reported as issues.
+//
+// Parameters:
+//   - data: The XML document or ZIP package.
+//
+// Returns:
+//   - []models.Question: The questions that could be read.
+//   - []Issue: The items that were skipped and why.
+//   - error: An error if the data is neither QTI XML nor a readable package, nil otherwise.
+func ParseQTI(data []byte) ([]models.Question, []Issue, error) {
+	documents := [][]byte{data}
+
+	if bytes.HasPrefix(data, []byte("PK")) {
+		var err error
+		documents, err = qtiPackageItems(data)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var questions []models.Question
+	var issues []Issue
+
+	for i, document := range documents {
+		var item qtiItem
+		if err := xml.Unmarshal(document, &item); err != nil {
+			issues = append(issues, Issue{Item: i + 1, Reason: fmt.Sprintf("not a QTI assessment item: %v", err)})
+			continue
+		}
+
+		question, err := item.question()
+		if err != nil {
+			issues = append(issues, Issue{Item: i + 1, Title: item.Title, Reason: err.Error()})
+			continue
+		}
+		questions = append(questions, question)
+	}
+
+	if len(documents) == 1 && len(questions) == 0 && len(issues) == 1 && strings.HasPrefix(issues[0].Reason, "not a QTI") {
+		return nil, nil, errors.New(issues[0].Reason)
+	}
+
+	return questions, issues, nil
+}
+
+// WriteQTI writes the questions of a quiz as an IMS QTI 2.1 content package:
+// a ZIP with a manifest and one assessmentItem per question. The draw rules of
+// the quiz cannot be exported and are reported as issues.
+//
+// Parameters:
+//   - w: Where to write the ZIP package.
+//   - quiz: The quiz, with its questions and options.
+//
+// Returns:
+//   - []Issue: The items that could not be exported.
+//   - error: An error if writing fails, nil otherwise.
+func WriteQTI(w io.Writer, quiz *models.Quiz) ([]Issue, error) {
+	var issues []Issue
+	archive := zip.NewWriter(w)
+
+	var hrefs []string
+	for i, question := range quiz.Questions {
+		identifier := fmt.Sprintf("item-%d", i+1)
+		href := "items/" + identifier + ".xml"
+
+		file, err := archive.Create(href)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := io.WriteString(file, qtiItemXML(identifier, question)); err != nil {
+			return nil, err
+		}
+		hrefs = append(hrefs, href)
+	}
+
+	for _, rule := range quiz.DrawRules {
+		issues = append(issues, Issue{Title: rule.Tag, Reason: "questions drawn from the question bank cannot be exported"})
+	}
+
+	manifest, err := archive.Create("imsmanifest.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(manifest, qtiManifestXML(quiz.Title, hrefs)); err != nil {
+		return nil, err
+	}
+
+	return issues, archive.Close()
+}
+
+type qtiItem struct {
+	XMLName    xml.Name         `xml:"assessmentItem"`
+	Identifier string           `xml:"identifier,attr"`
+	Title      string           `xml:"title,attr"`
+	Responses  []qtiResponse    `xml:"responseDeclaration"`
+	Outcomes   []qtiOutcome     `xml:"outcomeDeclaration"`
+	Body       qtiBody          `xml:"itemBody"`
+	Processing qtiInnerDocument `xml:"responseProcessing"`
+}
+
+type qtiResponse struct {
+	Identifier string   `xml:"identifier,attr"`
+	BaseType   string   `xml:"baseType,attr"`
+	Correct    []string `xml:"correctResponse>value"`
+	Mapping    struct {
+		UpperBound string `xml:"upperBound,attr"`
+		Entries    []struct {
+			Key   string `xml:"mapKey,attr"`
+			Value string `xml:"mappedValue,attr"`
+		} `xml:"mapEntry"`
+	} `xml:"mapping"`
+}
+
+type qtiOutcome struct {
+	Identifier    string   `xml:"identifier,attr"`
+	NormalMaximum string   `xml:"normalMaximum,attr"`
+	Default       []string `xml:"defaultValue>value"`
+}
+
+type qtiInnerDocument struct {
+	Inner string `xml:",innerxml"`
+}
+
+type qtiChoice struct {
+	Identifier string `xml:"identifier,attr"`
+	Inner      string `xml:",innerxml"`
+}
+
+type qtiInteraction struct {
+	Name               string
+	ResponseIdentifier string      `xml:"responseIdentifier,attr"`
+	MaxChoices         string      `xml:"maxChoices,attr"`
+	Prompt             qtiChoice   `xml:"prompt"`
+	Choices            []qtiChoice `xml:"simpleChoice"`
+}
+
+// qtiBody collects the interactions of an item body and the text around them.
+type qtiBody struct {
+	Text         string
+	Interactions []qtiInteraction
+}
+
+func (b *qtiBody) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text strings.Builder
+	blank := -1
+
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !strings.HasSuffix(t.Name.Local, "Interaction") {
+				continue
+			}
+
+			interaction := qtiInteraction{Name: t.Name.Local}
+			if err := d.DecodeElement(&interaction, &t); err != nil {
+				return err
+			}
+			interaction.Name = t.Name.Local
+			b.Interactions = append(b.Interactions, interaction)
+
+			// Text entry interactions sit inline, in place of the missing word.
+			if t.Name.Local == "textEntryInteraction" {
+				blank = text.Len()
+				text.WriteString(" " + giftBlank + " ")
+			}
+
+		case xml.CharData:
+			text.Write(t)
+			text.WriteString(" ")
+
+		case xml.EndElement:
+			if t.Name == start.Name {
+				body := text.String()
+				// A blank with nothing after it is just where the answer goes.
+				if blank >= 0 && strings.TrimSpace(body[blank+len(giftBlank)+2:]) == "" {
+					body = body[:blank]
+				}
+				b.Text = collapseSpace(body)
+				return nil
+			}
+		}
+	}
+}
+
+// question converts the item into a question.
+func (item qtiItem) question() (models.Question, error) {
+	if len(item.Body.Interactions) == 0 {
+		return models.Question{}, errors.New("item has no interaction")
+	}
+	if len(item.Body.Interactions) > 1 {
+		return models.Question{}, errors.New("items with several interactions are not supported")
+	}
+
+	interaction := item.Body.Interactions[0]
+	response := item.response(interaction.ResponseIdentifier)
+	prompt := collapseSpace(xmlText(interaction.Prompt.Inner))
+
+	title := collapseSpace(item.Title)
+	description := collapseSpace(item.Body.Text + " " + prompt)
+	// Bodies usually repeat the title, as ours do so that it shows to students.
+	if title != "" && strings.HasPrefix(description, title) {
+		description = strings.TrimSpace(description[len(title):])
+	}
+
+	question := models.Question{
+		Title:       title,
+		Description: description,
+		Points:      item.points(response),
+	}
+
+	if question.Title == "" {
+		question.Title, question.Description = description, ""
+	}
+
+	correct := make(map[string]int)
+	for i, value := range response.Correct {
+		correct[strings.TrimSpace(value)] = i + 1
+	}
+
+	switch interaction.Name {
+	case "choiceInteraction":
+		question.Type = models.MultiCorrect
+		if interaction.MaxChoices == "1" {
+			question.Type = models.SingleCorrect
+		}
+
+		for _, choice := range interaction.Choices {
+			_, isCorrect := correct[choice.Identifier]
+			question.Options = append(question.Options, models.Option{
+				Text:      collapseSpace(xmlText(choice.Inner)),
+				IsCorrect: isCorrect,
+			})
+		}
+
+	case "orderInteraction":
+		question.Type = models.Ordering
+		for _, choice := range interaction.Choices {
+			question.Options = append(question.Options, models.Option{
+				Text:     collapseSpace(xmlText(choice.Inner)),
+				Position: correct[choice.Identifier],
+			})
+		}
+
+	case "textEntryInteraction":
+		if response.BaseType == "float" || response.BaseType == "integer" {
+			if len(response.Correct) == 0 {
+				return models.Question{}, errors.New("numeric item has no correct response")
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(response.Correct[0]), 64)
+			if err != nil {
+				return models.Question{}, fmt.Errorf("invalid numeric response %q", response.Correct[0])
+			}
+			question.Type = models.Numeric
+			question.NumericAnswer = &value
+			question.Tolerance = qtiTolerance(item.Processing.Inner)
+			break
+		}
+
+		question.Type = models.ShortText
+		seen := make(map[string]bool)
+		for _, value := range response.Correct {
+			if value = collapseSpace(value); value != "" && !seen[value] {
+				seen[value] = true
+				question.AcceptedAnswers = append(question.AcceptedAnswers, value)
+			}
+		}
+		for _, entry := range response.Mapping.Entries {
+			mapped, err := strconv.ParseFloat(entry.Value, 64)
+			if key := collapseSpace(entry.Key); err == nil && mapped > 0 && key != "" && !seen[key] {
+				seen[key] = true
+				question.AcceptedAnswers = append(question.AcceptedAnswers, key)
+			}
+		}
+
+	case "extendedTextInteraction":
+		question.Type = models.Essay
+
+	default:
+		return models.Question{}, fmt.Errorf("%s items are not supported", interaction.Name)
+	}
+
+	return question, nil
+}
+
+// response returns the response declaration with the given identifier.
+func (item qtiItem) response(identifier string) qtiResponse {
+	for _, response := range item.Responses {
+		if response.Identifier == identifier {
+			return response
+		}
+	}
+	return qtiResponse{}
+}
+
+// points reads the points of an item from its MAXSCORE outcome, the maximum of
+// its SCORE outcome or the upper bound of its mapping, in that order.
+func (item qtiItem) points(response qtiResponse) int {
+	candidates := []string{response.Mapping.UpperBound}
+	for _, outcome := range item.Outcomes {
+		switch outcome.Identifier {
+		case "MAXSCORE":
+			candidates = append(outcome.Default, candidates...)
+		case "SCORE":
+			candidates = append(candidates[:len(candidates):len(candidates)], outcome.NormalMaximum)
+		}
+	}
+
+	for _, candidate := range candidates {
+		value, err := strconv.ParseFloat(strings.TrimSpace(candidate), 64)
+		if err == nil && value > 0 {
+			return int(math.Max(1, math.Round(value)))
+		}
+	}
+	return defaultPoints
+}
+
+// qtiTolerance reads the absolute tolerance of the first "equal" comparison
+// in custom response processing.
+func qtiTolerance(processing string) float64 {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + processing + "</root>"))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "equal" {
+			continue
+		}
+
+		for _, attr := range start.Attr {
+			if attr.Name.Local != "tolerance" {
+				continue
+			}
+			fields := strings.Fields(attr.Value)
+			if len(fields) == 0 {
+				return 0
+			}
+			tolerance, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return 0
+			}
+			return math.Abs(tolerance)
+		}
+		return 0
+	}
+}
+
+// qtiPackageItems reads the item documents of a QTI content package, in the
+// order of its manifest, or in name order if it has none.
+func qtiPackageItems(data []byte) ([][]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QTI package: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[path.Clean(file.Name)] = file
+	}
+
+	var hrefs []string
+	if manifest, ok := files["imsmanifest.xml"]; ok {
+		content, err := readZipFile(manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		var parsed struct {
+			Resources []struct {
+				Type string `xml:"type,attr"`
+				Href string `xml:"href,attr"`
+			} `xml:"resources>resource"`
+		}
+		if err := xml.Unmarshal(content, &parsed); err != nil {
+			return nil, fmt.Errorf("invalid QTI manifest: %w", err)
+		}
+
+		for _, resource := range parsed.Resources {
+			if strings.HasPrefix(resource.Type, "imsqti_item") {
+				hrefs = append(hrefs, path.Clean(resource.Href))
+			}
+		}
+	} else {
+		for name := range files {
+			if strings.HasSuffix(strings.ToLower(name), ".xml") {
+				hrefs = append(hrefs, name)
+			}
+		}
+		sort.Strings(hrefs)
+	}
+
+	documents := make([][]byte, 0, len(hrefs))
+	for _, href := range hrefs {
+		file, ok := files[href]
+		if !ok {
+			return nil, fmt.Errorf("QTI package is missing %s", href)
+		}
+
+		content, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, content)
+	}
+
+	return documents, nil
+}
+
+// readZipFile reads a file of a ZIP archive, refusing files over maxQTIFileSize.
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxQTIFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > maxQTIFileSize {
+		return nil, fmt.Errorf("%s is too large", file.Name)
+	}
+	return content, nil
+}
+
+// qtiItemXML builds the assessmentItem document of a question.
+func qtiItemXML(identifier string, question models.Question) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<assessmentItem xmlns="%s" identifier="%s" title="%s" adaptive="false" timeDependent="false">`+"\n",
+		qtiNamespace, identifier, xmlEscape(question.Title))
+
+	choiceID := func(i int) string { return fmt.Sprintf("choice-%d", i+1) }
+
+	switch question.Type {
+	case models.SingleCorrect, models.MultiCorrect, models.TrueFalse:
+		cardinality := "single"
+		if question.Type == models.MultiCorrect {
+			cardinality = "multiple"
+		}
+		fmt.Fprintf(&b, `  <responseDeclaration identifier="RESPONSE" cardinality="%s" baseType="identifier">`+"\n", cardinality)
+		b.WriteString("    <correctResponse>\n")
+		for i, option := range question.Options {
+			if option.IsCorrect {
+				fmt.Fprintf(&b, "      <value>%s</value>\n", choiceID(i))
+			}
+		}
+		b.WriteString("    </correctResponse>\n  </responseDeclaration>\n")
+
+	case models.Ordering:
+		b.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="ordered" baseType="identifier">` + "\n")
+		b.WriteString("    <correctResponse>\n")
+		order := make([]int, len(question.Options))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, c int) bool {
+			return question.Options[order[a]].Position < question.Options[order[c]].Position
+		})
+		for _, i := range order {
+			fmt.Fprintf(&b, "      <value>%s</value>\n", choiceID(i))
+		}
+		b.WriteString("    </correctResponse>\n  </responseDeclaration>\n")
+
+	case models.Numeric:
+		b.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="float">` + "\n")
+		if question.NumericAnswer != nil {
+			fmt.Fprintf(&b, "    <correctResponse>\n      <value>%s</value>\n    </correctResponse>\n", formatNumber(*question.NumericAnswer))
+		}
+		b.WriteString("  </responseDeclaration>\n")
+
+	case models.ShortText:
+		b.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="string">` + "\n")
+		b.WriteString("    <correctResponse>\n")
+		for _, accepted := range question.AcceptedAnswers {
+			fmt.Fprintf(&b, "      <value>%s</value>\n", xmlEscape(accepted))
+		}
+		b.WriteString("    </correctResponse>\n")
+		b.WriteString(`    <mapping defaultValue="0">` + "\n")
+		for _, accepted := range question.AcceptedAnswers {
+			fmt.Fprintf(&b, `      <mapEntry mapKey="%s" mappedValue="%d" caseSensitive="false"/>`+"\n", xmlEscape(accepted), question.Points)
+		}
+		b.WriteString("    </mapping>\n  </responseDeclaration>\n")
+
+	case models.Essay:
+		b.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="string"/>` + "\n")
+	}
+
+	b.WriteString(`  <outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>` + "\n")
+	fmt.Fprintf(&b, `  <outcomeDeclaration identifier="MAXSCORE" cardinality="single" baseType="float">`+"\n"+
+		"    <defaultValue>\n      <value>%d</value>\n    </defaultValue>\n  </outcomeDeclaration>\n", question.Points)
+
+	b.WriteString("  <itemBody>\n")
+	fmt.Fprintf(&b, "    <p>%s</p>\n", xmlEscape(question.Title))
+	if question.Description != "" {
+		fmt.Fprintf(&b, "    <p>%s</p>\n", xmlEscape(question.Description))
+	}
+
+	switch question.Type {
+	case models.SingleCorrect, models.MultiCorrect, models.TrueFalse, models.Ordering:
+		element, maxChoices := "choiceInteraction", ` maxChoices="1"`
+		if question.Type == models.MultiCorrect {
+			maxChoices = ` maxChoices="0"`
+		}
+		if question.Type == models.Ordering {
+			element, maxChoices = "orderInteraction", ""
+		}
+
+		fmt.Fprintf(&b, `    <%s responseIdentifier="RESPONSE" shuffle="false"%s>`+"\n", element, maxChoices)
+		for i, option := range question.Options {
+			fmt.Fprintf(&b, `      <simpleChoice identifier="%s">%s</simpleChoice>`+"\n", choiceID(i), xmlEscape(option.Text))
+		}
+		fmt.Fprintf(&b, "    </%s>\n", element)
+
+	case models.Numeric, models.ShortText:
+		b.WriteString(`    <p><textEntryInteraction responseIdentifier="RESPONSE"/></p>` + "\n")
+
+	case models.Essay:
+		b.WriteString(`    <extendedTextInteraction responseIdentifier="RESPONSE"/>` + "\n")
+	}
+	b.WriteString("  </itemBody>\n")
+
+	switch question.Type {
+	case models.Numeric:
+		fmt.Fprintf(&b, `  <responseProcessing>
+    <responseCondition>
+      <responseIf>
+        <equal toleranceMode="absolute" tolerance="%[1]s %[1]s">
+          <variable identifier="RESPONSE"/>
+          <correct identifier="RESPONSE"/>
+        </equal>
+        <setOutcomeValue identifier="SCORE">
+          <variable identifier="MAXSCORE"/>
+        </setOutcomeValue>
+      </responseIf>
+    </responseCondition>
+  </responseProcessing>
+`, formatNumber(question.Tolerance))
+
+	case models.ShortText:
+		b.WriteString(`  <responseProcessing template="http://www.imsglobal.org/question/qti_v2p1/rptemplates/map_response"/>` + "\n")
+
+	case models.Essay:
+
+	default:
+		b.WriteString(`  <responseProcessing template="http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"/>` + "\n")
+	}
+
+	b.WriteString("</assessmentItem>\n")
+	return b.String()
+}
+
+// qtiManifestXML builds the manifest of a QTI content package.
+func qtiManifestXML(title string, hrefs []string) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="manifest">` + "\n")
+	fmt.Fprintf(&b, "  <metadata>\n    <schema>IMS Content</schema>\n    <schemaversion>1.1</schemaversion>\n  </metadata>\n")
+	fmt.Fprintf(&b, "  <organizations/>\n  <!-- %s -->\n  <resources>\n", strings.ReplaceAll(xmlEscape(title), "--", "- -"))
+	for i, href := range hrefs {
+		fmt.Fprintf(&b, `    <resource identifier="resource-%d" type="imsqti_item_xmlv2p1" href="%s">`+"\n", i+1, href)
+		fmt.Fprintf(&b, `      <file href="%s"/>`+"\n    </resource>\n", href)
+	}
+	b.WriteString("  </resources>\n</manifest>\n")
+	return b.String()
+}
+
+// xmlText extracts the text of an XML fragment, dropping its markup.
+func xmlText(fragment string) string {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + fragment + "</root>"))
+	var b strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return b.String()
+		}
+		if data, ok := token.(xml.CharData); ok {
+			b.Write(data)
+			b.WriteString(" ")
+		}
+	}
+}
+
+func xmlEscape(text string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
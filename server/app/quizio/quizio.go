@@ -0,0 +1,42 @@
+// Package quizio converts quizzes to and from the formats used by other
+// learning management systems: Moodle's GIFT text format and IMS QTI 2.1.
+package quizio
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatGIFT Format = "gift"
+	FormatQTI  Format = "qti"
+)
+
+func (f Format) IsValid() bool {
+	return f == FormatGIFT || f == FormatQTI
+}
+
+// defaultPoints is given to imported questions when the format doesn't carry points.
+const defaultPoints = 1
+
+// Issue reports an item that could not be imported or exported. Item is the
+// 1-based position of the item in the source.
+type Issue struct {
+	Item   int    `json:"item"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+func (i Issue) String() string {
+	if i.Title == "" {
+		return fmt.Sprintf("item %d: %s", i.Item, i.Reason)
+	}
+	return fmt.Sprintf("item %d (%s): %s", i.Item, i.Title, i.Reason)
+}
+
+// collapseSpace trims text and collapses runs of whitespace into single spaces.
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"server/app/handlers"
+	"server/app/mailer"
+	"server/app/middlewares"
+	"server/app/policy"
+	"server/app/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func SetupAdminRoutes(router *gin.Engine, db *gorm.DB, secret []byte, expiration time.Duration, mail mailer.Mailer, appURL string) {
+	accounts := services.NewAccountService(db, mail, appURL)
+	admin := services.NewAdminService(db, services.NewUserService(db, secret, expiration), accounts)
+	provisioning := services.NewProvisioningService(db, services.NewEnrollmentService(db), accounts)
+	adminHandler := handlers.NewAdminHandler(admin, provisioning, services.NewLoginThrottleService(db), services.NewAuditService(db))
+	authz := services.NewAuthorizationService(db)
+
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(
+		middlewares.SessionAuthMiddleware(string(secret), services.NewSessionService(db)),
+		middlewares.RequirePermission(authz, policy.UserManage, nil),
+	)
+	{
+		adminRoutes.GET("/users", adminHandler.ListUsers)
+		adminRoutes.POST("/users/import", adminHandler.ProvisionUsers)
+		adminRoutes.POST("/users/:"+handlers.UserIDKey+"/deactivate", adminHandler.DeactivateUser)
+		adminRoutes.POST("/users/:"+handlers.UserIDKey+"/reactivate", adminHandler.ReactivateUser)
+		adminRoutes.PUT("/users/:"+handlers.UserIDKey+"/role", adminHandler.SetRole)
+		adminRoutes.POST("/users/:"+handlers.UserIDKey+"/password-reset", adminHandler.ForcePasswordReset)
+		adminRoutes.POST("/users/:"+handlers.UserIDKey+"/impersonate", adminHandler.ImpersonateUser)
+		adminRoutes.POST("/users/:"+handlers.UserIDKey+"/unlock", adminHandler.UnlockUser)
+		adminRoutes.GET("/audit-logs", adminHandler.ListAuditLogs)
+	}
+}
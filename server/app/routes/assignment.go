@@ -4,24 +4,33 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 )
 
-func SetupAssignmentRoutes(r *gin.Engine, db *gorm.DB) {
+func SetupAssignmentRoutes(r *gin.Engine, db *gorm.DB, secret string) {
 	assignmentService := services.NewAssignmentService(db)
 	assignmentHandler := handlers.NewAssignmentHandler(assignmentService)
 
+	authz := services.NewAuthorizationService(db)
+	assignment := middlewares.ParamResource("id", authz.AssignmentResource)
+	course := middlewares.ParamResource("courseId", authz.CourseResource)
+	newAssignment := middlewares.JSONResource("courseId", authz.CourseResource)
+	self := middlewares.SelfResource()
+
 	assignments := r.Group("/assignments")
+	assignments.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
 	{
-		assignments.POST("/", assignmentHandler.Create)
-		assignments.GET("/:id", assignmentHandler.Get)
-		assignments.PUT("/:id", assignmentHandler.UpdateAssignment)
-		assignments.DELETE("/:id", assignmentHandler.Delete)
-		assignments.GET("/course/:courseId", assignmentHandler.GetAssignmentsForCourse)
-		assignments.POST("/:id/publish", assignmentHandler.PublishAssignment)
-		assignments.POST("/:id/unpublish", assignmentHandler.UnpublishAssignment)
-		assignments.GET("/upcoming", assignmentHandler.GetUpcomingAssignments)
-		assignments.GET("/overdue", assignmentHandler.GetOverdueAssignments)
-		assignments.GET("/:id/completion", assignmentHandler.GetAssignmentCompletion)
+		assignments.POST("/", middlewares.RequirePermission(authz, policy.AssignmentCreate, newAssignment), assignmentHandler.Create)
+		assignments.GET("/:id", middlewares.RequirePermission(authz, policy.AssignmentRead, assignment), assignmentHandler.Get)
+		assignments.PUT("/:id", middlewares.RequirePermission(authz, policy.AssignmentUpdate, assignment), assignmentHandler.UpdateAssignment)
+		assignments.DELETE("/:id", middlewares.RequirePermission(authz, policy.AssignmentDelete, assignment), assignmentHandler.Delete)
+		assignments.GET("/course/:courseId", middlewares.RequirePermission(authz, policy.AssignmentRead, course), assignmentHandler.GetAssignmentsForCourse)
+		assignments.POST("/:id/publish", middlewares.RequirePermission(authz, policy.AssignmentPublish, assignment), assignmentHandler.PublishAssignment)
+		assignments.POST("/:id/unpublish", middlewares.RequirePermission(authz, policy.AssignmentPublish, assignment), assignmentHandler.UnpublishAssignment)
+		assignments.GET("/upcoming", middlewares.RequirePermission(authz, policy.AssignmentRead, self), assignmentHandler.GetUpcomingAssignments)
+		assignments.GET("/overdue", middlewares.RequirePermission(authz, policy.AssignmentRead, self), assignmentHandler.GetOverdueAssignments)
+		assignments.GET("/:id/completion", middlewares.RequirePermission(authz, policy.SubmissionRead, assignment), assignmentHandler.GetAssignmentCompletion)
 	}
 }
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func SetupAvatarRoutes(r *gin.Engine, db *gorm.DB, store services.FileStore, secret string) {
+	handler := handlers.NewAvatarHandler(services.NewAvatarService(db, store))
+	router := r.Group("/users")
+	router.Use(middlewares.SessionAuthMiddleware(secret, services.NewSessionService(db)), middlewares.RejectImpersonation(),
+		middlewares.RequirePermission(services.NewAuthorizationService(db), policy.ProfileManage, middlewares.SelfResource()))
+	router.POST("/avatar", handler.UploadAvatar)
+	router.DELETE("/avatar", handler.DeleteAvatar)
+}
@@ -2,22 +2,50 @@ package routes
 
 import (
 	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupCourseRoutes(router *gin.Engine, db *gorm.DB) {
+func SetupCourseRoutes(router *gin.Engine, db *gorm.DB, secret string) {
 	courseService := services.NewCourseService(db)
 	courseHandler := handlers.NewCourseHandler(courseService)
+	invitationHandler := handlers.NewInvitationHandler(services.NewInvitationService(db))
+
+	authz := services.NewAuthorizationService(db)
+	course := middlewares.ParamResource(handlers.CourseIDKey, authz.CourseResource)
 
 	courseRoutes := router.Group("/courses")
+	courseRoutes.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
+	{
+		courseRoutes.POST("/", middlewares.RequirePermission(authz, policy.CourseCreate, nil), courseHandler.CreateCourse)
+		courseRoutes.GET("/", middlewares.RequirePermission(authz, policy.CourseList, nil), courseHandler.GetCourses)
+		courseRoutes.GET("/:id", middlewares.RequirePermission(authz, policy.CourseRead, course), courseHandler.GetCourseByID)
+		courseRoutes.PUT("/:id", middlewares.RequirePermission(authz, policy.CourseUpdate, course), courseHandler.UpdateCourse)
+		courseRoutes.DELETE("/:id", middlewares.RequirePermission(authz, policy.CourseDelete, course), courseHandler.DeleteCourse)
+		// Archived courses are read-only, so only global admins have the permission to restore them.
+		courseRoutes.POST("/:id/archive", middlewares.RequirePermission(authz, policy.CourseArchive, course), courseHandler.ArchiveCourse)
+		courseRoutes.POST("/:id/restore", middlewares.RequirePermission(authz, policy.CourseArchive, course), courseHandler.RestoreCourse)
+		// Invitation codes let users in, so only those managing the enrollments see and change them.
+		courseRoutes.GET("/:id/invitations", middlewares.RequirePermission(authz, policy.EnrollmentManage, course), invitationHandler.GetInvitations)
+		courseRoutes.PUT("/:id/invitations/:role", middlewares.RequirePermission(authz, policy.EnrollmentManage, course), invitationHandler.UpdateInvitation)
+		courseRoutes.POST("/:id/invitations/:role/rotate", middlewares.RequirePermission(authz, policy.EnrollmentManage, course), invitationHandler.RotateInvitation)
+	}
+}
+
+func SetupCourseCloneRoutes(router *gin.Engine, db *gorm.DB, store services.FileStore, jobs *services.JobService, secret string) {
+	handler := handlers.NewCourseCloneHandler(services.NewCourseCloneService(db, store, jobs))
+
+	authz := services.NewAuthorizationService(db)
+	course := middlewares.ParamResource(handlers.CourseIDKey, authz.CourseResource)
+
+	cloneRoutes := router.Group("/courses")
+	cloneRoutes.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
 	{
-		courseRoutes.POST("/", courseHandler.CreateCourse)
-		courseRoutes.GET("/", courseHandler.GetCourses)
-		courseRoutes.GET("/:id", courseHandler.GetCourseByID)
-		courseRoutes.PUT("/:id", courseHandler.UpdateCourse)
-		courseRoutes.DELETE("/:id", courseHandler.DeleteCourse)
+		cloneRoutes.POST("/:id/clone", middlewares.RequirePermission(authz, policy.CourseClone, course), handler.CloneCourse)
+		cloneRoutes.GET("/:id/clone", middlewares.RequirePermission(authz, policy.CourseRead, course), handler.GetCloneStatus)
 	}
 }
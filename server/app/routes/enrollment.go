@@ -5,20 +5,25 @@ import (
 	"gorm.io/gorm"
 	"server/app/handlers"
 	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 )
 
-func SetupEnrollmentRoutes(r *gin.Engine, db *gorm.DB) {
+func SetupEnrollmentRoutes(r *gin.Engine, db *gorm.DB, secret string) {
 	enrollmentService := services.NewEnrollmentService(db)
 	enrollmentHandler := handlers.NewEnrollmentHandler(enrollmentService)
 
+	authz := services.NewAuthorizationService(db)
+	enrollment := middlewares.ParamResource("id", authz.EnrollmentResource)
+	course := middlewares.ParamResource("courseId", authz.CourseResource)
+
 	enrollmentRoutes := r.Group("/api/enrollments")
-	enrollmentRoutes.Use(middlewares.AuthMiddleware("hello"))
+	enrollmentRoutes.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
 
 	{
-		enrollmentRoutes.POST("/join", enrollmentHandler.JoinCourseByCode)
-		enrollmentRoutes.PUT("/approve/:id", enrollmentHandler.EnrollToCourse)
-		enrollmentRoutes.PUT("/reject/:id", enrollmentHandler.RejectEnrollment)
-		enrollmentRoutes.GET("/course/:courseId", enrollmentHandler.GetPendingEnrollments)
+		enrollmentRoutes.POST("/join", middlewares.RequirePermission(authz, policy.EnrollmentJoin, nil), enrollmentHandler.JoinCourseByCode)
+		enrollmentRoutes.PUT("/approve/:id", middlewares.RequirePermission(authz, policy.EnrollmentManage, enrollment), enrollmentHandler.EnrollToCourse)
+		enrollmentRoutes.PUT("/reject/:id", middlewares.RequirePermission(authz, policy.EnrollmentManage, enrollment), enrollmentHandler.RejectEnrollment)
+		enrollmentRoutes.GET("/course/:courseId", middlewares.RequirePermission(authz, policy.EnrollmentManage, course), enrollmentHandler.GetPendingEnrollments)
 	}
 }
@@ -4,20 +4,28 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 )
 
-func SetupGradeRoutes(r *gin.Engine, db *gorm.DB) {
+func SetupGradeRoutes(r *gin.Engine, db *gorm.DB, secret string) {
 	gradeService := services.NewGradeService(db)
 	gradeHandler := handlers.NewGradeHandler(gradeService)
 
+	authz := services.NewAuthorizationService(db)
+	grade := middlewares.ParamResource("gradeId", authz.GradeResource)
+	assignment := middlewares.ParamResource("assignmentId", authz.AssignmentResource)
+	submission := middlewares.JSONResource("submissionId", authz.SubmissionResource)
+
 	grades := r.Group("/grades")
+	grades.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
 	{
-		grades.POST("/", gradeHandler.Create)
-		grades.GET("/:gradeId", gradeHandler.GetGrade)
-		grades.PUT("/:gradeId", gradeHandler.UpdateGrade)
-		grades.GET("/assignment/:assignmentId", gradeHandler.GetGradesForAssignment)
-		grades.GET("/user/:userId", gradeHandler.GradesForUser)
-		grades.GET("/statistics/:assignmentId", gradeHandler.GradeStats)
+		grades.POST("/", middlewares.RequirePermission(authz, policy.GradeCreate, submission), gradeHandler.Create)
+		grades.GET("/:gradeId", middlewares.RequirePermission(authz, policy.GradeRead, grade), gradeHandler.GetGrade)
+		grades.PUT("/:gradeId", middlewares.RequirePermission(authz, policy.GradeUpdate, grade), gradeHandler.UpdateGrade)
+		grades.GET("/assignment/:assignmentId", middlewares.RequirePermission(authz, policy.GradeRead, assignment), gradeHandler.GetGradesForAssignment)
+		grades.GET("/user/:userId", middlewares.RequirePermission(authz, policy.GradeRead, middlewares.UserParamResource("userId")), gradeHandler.GradesForUser)
+		grades.GET("/statistics/:assignmentId", middlewares.RequirePermission(authz, policy.GradeRead, assignment), gradeHandler.GradeStats)
 	}
 }
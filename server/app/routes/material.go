@@ -3,21 +3,28 @@ package routes
 import (
 	"server/app/firebase"
 	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupMaterialRoutes(r *gin.Engine, db *gorm.DB, storage *firebase.CloudStorage) {
+func SetupMaterialRoutes(r *gin.Engine, db *gorm.DB, storage *firebase.CloudStorage, secret string) {
 	serv := services.NewMaterialService(db, storage)
 	handler := handlers.NewMaterialHandler(serv)
 
+	authz := services.NewAuthorizationService(db)
+	material := middlewares.ParamResource(handlers.MaterialIDKey, authz.MaterialResource)
+	course := middlewares.FormResource("courseId", authz.CourseResource)
+
 	router := r.Group("/materials")
+	router.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
 	{
-		router.GET("/:id", handler.GetMaterial)
-		router.POST("/", handler.CreateMaterial)
-		router.PUT("/:id", handler.UpdateMaterial)
-		router.DELETE("/:id", handler.DeleteMaterial)
+		router.GET("/:"+handlers.MaterialIDKey, middlewares.RequirePermission(authz, policy.MaterialRead, material), handler.GetMaterial)
+		router.POST("/", middlewares.RequirePermission(authz, policy.MaterialCreate, course), handler.CreateMaterial)
+		router.PUT("/:"+handlers.MaterialIDKey, middlewares.RequirePermission(authz, policy.MaterialUpdate, material), handler.UpdateMaterial)
+		router.DELETE("/:"+handlers.MaterialIDKey, middlewares.RequirePermission(authz, policy.MaterialDelete, material), handler.DeleteMaterial)
 	}
 }
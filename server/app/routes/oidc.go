@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"server/app/handlers"
+	"server/app/oidc"
+	"server/app/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func SetupOIDCRoutes(r *gin.Engine, db *gorm.DB, secret []byte, expiration time.Duration, providers []*oidc.Provider) {
+	users := services.NewUserService(db, secret, expiration)
+	handler := handlers.NewOIDCHandler(services.NewOIDCService(db, users, providers))
+
+	router := r.Group("/users/oidc/:" + handlers.ProviderKey)
+	{
+		router.GET("/login", handler.Login)
+		router.GET("/callback", handler.Callback)
+	}
+}
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func SetupPrivacyRoutes(r *gin.Engine, db *gorm.DB, store services.FileStore, jobs *services.JobService, secret string) {
+	handler := handlers.NewPrivacyHandler(services.NewPrivacyService(db, store, jobs), jobs)
+	authz := services.NewAuthorizationService(db)
+	auth := middlewares.SessionAuthMiddleware(secret, services.NewSessionService(db))
+	{
+		router := r.Group("/users")
+		router.Use(auth, middlewares.RejectImpersonation(),
+			middlewares.RequirePermission(authz, policy.ProfileManage, middlewares.SelfResource()))
+		router.GET("/export", handler.ExportData)
+		router.POST("/erasure", handler.RequestErasure)
+	}
+	{
+		router := r.Group("/admin")
+		router.Use(auth, middlewares.RequirePermission(authz, policy.UserManage, nil))
+		router.POST("/users/:"+handlers.UserIDKey+"/erasure", handler.EraseUser)
+		router.GET("/jobs/:"+handlers.JobIDKey, handler.GetJob)
+	}
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func SetupQuestionBankRoutes(r *gin.Engine, db *gorm.DB, secret string) {
+	handler := handlers.NewQuestionBankHandler(services.NewQuestionBankService(db))
+
+	authz := services.NewAuthorizationService(db)
+	course := middlewares.ParamResource(handlers.CourseIDKey, authz.CourseResource)
+
+	bankRoutes := r.Group("/api/courses/:id/questions")
+	bankRoutes.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)),
+		middlewares.RequirePermission(authz, policy.QuestionManage, course))
+	{
+		bankRoutes.GET("/", handler.ListQuestions)
+		bankRoutes.POST("/", handler.CreateQuestion)
+		bankRoutes.PUT("/:questionId", handler.UpdateQuestion)
+		bankRoutes.DELETE("/:questionId", handler.DeleteQuestion)
+	}
+}
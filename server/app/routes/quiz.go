@@ -3,6 +3,7 @@ package routes
 import (
 	"server/app/handlers"
 	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 
 	"github.com/gin-gonic/gin"
@@ -12,10 +13,42 @@ import (
 func SetupQuizRoutes(r *gin.Engine, db *gorm.DB, secret string) {
 	quizService := services.NewQuizService(db)
 	quizHandler := handlers.NewQuizHandler(quizService)
+	attemptHandler := handlers.NewAttemptHandler(services.NewAttemptService(db))
+	gradingHandler := handlers.NewGradingHandler(services.NewGradingService(db))
+	accommodationHandler := handlers.NewAccommodationHandler(services.NewAccommodationService(db))
+
+	authz := services.NewAuthorizationService(db)
+	quiz := middlewares.ParamResource(handlers.QuizIDKey, authz.QuizResource)
+	read := middlewares.RequirePermission(authz, policy.QuizRead, quiz)
+	attempt := middlewares.RequirePermission(authz, policy.QuizAttempt, quiz)
+	manage := middlewares.RequirePermission(authz, policy.QuizManage, quiz)
+	checkManage := middlewares.CheckPermission(authz, policy.QuizManage, quiz, handlers.CanManageQuizKey)
 
 	quizRoutes := r.Group("/api/quizzes")
-	quizRoutes.Use(middlewares.AuthMiddleware(secret))
+	quizRoutes.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
 	{
-		quizRoutes.POST("/", quizHandler.CreateQuiz)
+		quizRoutes.POST("/", middlewares.RequirePermission(authz, policy.QuizCreate,
+			middlewares.JSONResource(handlers.CourseIDQuery, authz.CourseResource)), quizHandler.CreateQuiz)
+		quizRoutes.GET("/", middlewares.RequirePermission(authz, policy.QuizRead,
+			middlewares.QueryResource(handlers.CourseIDQuery, authz.CourseResource)), quizHandler.ListQuizzes)
+		quizRoutes.POST("/import", middlewares.RequirePermission(authz, policy.QuizCreate,
+			middlewares.FormResource(handlers.CourseIDQuery, authz.CourseResource)), quizHandler.ImportQuiz)
+		quizRoutes.GET("/:id", read, checkManage, quizHandler.GetQuiz)
+		quizRoutes.PUT("/:id", manage, quizHandler.UpdateQuiz)
+		quizRoutes.DELETE("/:id", manage, quizHandler.DeleteQuiz)
+		quizRoutes.GET("/:id/analysis", manage, quizHandler.GetItemAnalysis)
+		quizRoutes.GET("/:id/export", manage, quizHandler.ExportQuiz)
+
+		quizRoutes.POST("/:id/attempts", attempt, attemptHandler.StartAttempt)
+		quizRoutes.GET("/:id/attempts/:attemptId", attempt, attemptHandler.GetAttempt)
+		quizRoutes.PUT("/:id/attempts/:attemptId/answers", attempt, attemptHandler.SaveAnswers)
+		quizRoutes.POST("/:id/attempts/:attemptId/submit", attempt, attemptHandler.SubmitAttempt)
+
+		quizRoutes.GET("/:id/grading", manage, gradingHandler.GetGradingQueue)
+		quizRoutes.PUT("/:id/grading/:answerId", manage, gradingHandler.GradeAnswer)
+
+		quizRoutes.GET("/:id/accommodations", manage, accommodationHandler.ListAccommodations)
+		quizRoutes.PUT("/:id/accommodations/:enrollmentId", manage, accommodationHandler.SetAccommodation)
+		quizRoutes.DELETE("/:id/accommodations/:enrollmentId", manage, accommodationHandler.DeleteAccommodation)
 	}
 }
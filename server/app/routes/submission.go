@@ -3,23 +3,34 @@ package routes
 import (
 	"server/app/firebase"
 	"server/app/handlers"
+	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupSubmissionRoutes(r *gin.Engine, db *gorm.DB, firestore *firebase.CloudStorage) {
+func SetupSubmissionRoutes(r *gin.Engine, db *gorm.DB, firestore *firebase.CloudStorage, secret string) {
 	service := services.NewSubmissionService(db, firestore)
 	handler := handlers.NewSubmissionHandler(service)
+
+	authz := services.NewAuthorizationService(db)
+	submission := middlewares.ParamResource(handlers.SubmissionIDKey, authz.SubmissionResource)
+	assignment := middlewares.ParamResource("assignmentId", authz.AssignmentResource)
 	{
 		router := r.Group("/submissions")
-		router.GET("/:"+handlers.SubmissionIDKey, handler.CheckCanSeeSubmissionMiddleware(),
+		router.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
+		router.GET("/:"+handlers.SubmissionIDKey, middlewares.RequirePermission(authz, policy.SubmissionRead, submission),
 			handler.GetSubmission)
-		router.DELETE("/:"+handlers.SubmissionIDKey, handler.DeleteSubmission)
+		router.DELETE("/:"+handlers.SubmissionIDKey, middlewares.RequirePermission(authz, policy.SubmissionDelete, submission),
+			handler.DeleteSubmission)
+		router.POST("/assignment/:assignmentId", middlewares.RequirePermission(authz, policy.SubmissionCreate, assignment),
+			handler.CreateSubmission)
 		router.GET("/assignment/:assignmentId",
-			handler.CheckCanSeeSubmissionMiddleware(),
+			middlewares.RequirePermission(authz, policy.SubmissionRead, assignment),
 			handler.GetSubmissionsForAssignment)
-		router.PUT("/:id", handler.UpdateSubmission)
+		router.PUT("/:"+handlers.SubmissionIDKey, middlewares.RequirePermission(authz, policy.SubmissionUpdate, submission),
+			handler.UpdateSubmission)
 	}
 }
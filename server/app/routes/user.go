@@ -2,7 +2,9 @@ package routes
 
 import (
 	"server/app/handlers"
+	"server/app/mailer"
 	"server/app/middlewares"
+	"server/app/policy"
 	"server/app/services"
 	"time"
 
@@ -10,24 +12,40 @@ import (
 	"gorm.io/gorm"
 )
 
-func SetUpUserRoutes(r *gin.Engine, db *gorm.DB, secret []byte, expiration time.Duration) {
+func SetUpUserRoutes(r *gin.Engine, db *gorm.DB, secret []byte, expiration time.Duration, mail mailer.Mailer, appURL string) {
 	service := services.NewUserService(db, secret, expiration)
-	handler := handlers.NewUserHandler(service)
+	handler := handlers.NewUserHandler(service, services.NewAccountService(db, mail, appURL), services.NewLoginThrottleService(db))
+	twoFactor := handlers.NewTwoFactorHandler(services.NewTwoFactorService(db))
+	apiTokens := handlers.NewAPITokenHandler(services.NewAPITokenService(db))
 
 	secretString := string(secret)
+	auth := middlewares.SessionAuthMiddleware(secretString, services.NewSessionService(db))
+	profile := middlewares.RequirePermission(services.NewAuthorizationService(db), policy.ProfileManage, middlewares.SelfResource())
+	// Admins impersonating the user cannot change their credentials or profile.
+	own := middlewares.RejectImpersonation()
 	{
 		router := r.Group("/users")
 		router.POST("/login", handler.Login)
 		router.POST("/register", handler.Register)
-		router.GET("/profile",
-			middlewares.AuthMiddleware(secretString),
-			handler.GetProfile)
+		router.POST("/refresh", handler.Refresh)
+		router.POST("/verify-email", handler.VerifyEmail)
+		router.POST("/verify-email/request", auth, profile, handler.RequestVerificationEmail)
+		router.POST("/password-reset/request", handler.RequestPasswordReset)
+		router.POST("/password-reset", handler.ResetPassword)
+		router.POST("/logout", auth, profile, handler.Logout)
+		router.POST("/logout-all", auth, profile, own, handler.LogoutAll)
+		router.GET("/profile", auth, profile, handler.GetProfile)
+		router.PUT("/profile", auth, profile, own, handler.UpdateProfile)
+		router.DELETE("/profile", auth, profile, own, handler.DeleteUser)
 
-		router.PUT("/profile",
-			middlewares.AuthMiddleware(secretString),
-			handler.UpdateProfile)
-		router.DELETE("/profile",
-			middlewares.AuthMiddleware(secretString),
-			handler.DeleteUser)
+		router.POST("/2fa/verify", handler.VerifyTwoFactor)
+		router.POST("/2fa/setup", auth, profile, own, twoFactor.Setup)
+		router.POST("/2fa/enable", auth, profile, own, twoFactor.Enable)
+		router.POST("/2fa/disable", auth, profile, own, twoFactor.Disable)
+		router.POST("/2fa/recovery-codes", auth, profile, own, twoFactor.RegenerateRecoveryCodes)
+
+		router.POST("/tokens", auth, profile, own, apiTokens.Create)
+		router.GET("/tokens", auth, profile, apiTokens.List)
+		router.DELETE("/tokens/:"+handlers.TokenIDKey, auth, profile, own, apiTokens.Revoke)
 	}
 }
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"server/app/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AccommodationService struct {
+	db *gorm.DB
+}
+
+func NewAccommodationService(db *gorm.DB) *AccommodationService {
+	return &AccommodationService{db: db}
+}
+
+// QuizSchedule is the schedule of a quiz for a single student, with their
+// accommodation applied.
+type QuizSchedule struct {
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	Duration    int       `json:"duration"` // In minutes
+	MaxAttempts int       `json:"maxAttempts"`
+}
+
+// ScheduleFor applies an accommodation to the schedule of a quiz. The duration
+// is rounded up to whole minutes. A nil accommodation leaves the schedule unchanged.
+//
+// Parameters:
+//   - quiz: The quiz.
+//   - accommodation: The accommodation of the student, or nil.
+//
+// Returns:
+//   - QuizSchedule: The schedule of the quiz for the student.
+func ScheduleFor(quiz *models.Quiz, accommodation *models.QuizAccommodation) QuizSchedule {
+	schedule := QuizSchedule{
+		StartTime:   quiz.StartTime,
+		EndTime:     quiz.EndTime,
+		Duration:    quiz.Duration,
+		MaxAttempts: quiz.MaxAttempts,
+	}
+
+	if accommodation == nil {
+		return schedule
+	}
+
+	if accommodation.StartTime != nil {
+		schedule.StartTime = *accommodation.StartTime
+	}
+	if accommodation.EndTime != nil {
+		schedule.EndTime = *accommodation.EndTime
+	}
+
+	multiplier := accommodation.DurationMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	schedule.Duration = int(math.Ceil(float64(quiz.Duration)*multiplier)) + accommodation.ExtraMinutes
+
+	schedule.MaxAttempts += accommodation.ExtraAttempts
+
+	return schedule
+}
+
+// FinalScore computes the score that counts for a student from their attempts
+// at a quiz. Only fully graded attempts are taken into account.
+//
+// Parameters:
+//   - scoring: The attempt scoring of the quiz.
+//   - attempts: The attempts of the student.
+//
+// Returns:
+//   - float64: The score that counts.
+//   - bool: False if the student has no graded attempt yet.
+func FinalScore(scoring models.AttemptScoring, attempts []models.QuizSubmission) (float64, bool) {
+	var graded []models.QuizSubmission
+	for _, attempt := range attempts {
+		if attempt.Status == models.AttemptStatusSubmitted {
+			graded = append(graded, attempt)
+		}
+	}
+
+	if len(graded) == 0 {
+		return 0, false
+	}
+
+	switch scoring {
+	case models.AttemptScoringLatest:
+		latest := graded[0]
+		for _, attempt := range graded[1:] {
+			if attempt.Number > latest.Number {
+				latest = attempt
+			}
+		}
+		return latest.Score, true
+
+	case models.AttemptScoringAverage:
+		total := 0.0
+		for _, attempt := range graded {
+			total += attempt.Score
+		}
+		return roundPoints(total / float64(len(graded))), true
+
+	default:
+		highest := graded[0].Score
+		for _, attempt := range graded[1:] {
+			highest = math.Max(highest, attempt.Score)
+		}
+		return highest, true
+	}
+}
+
+// ListAccommodations retrieves the accommodations of a quiz.
+//
+// Parameters:
+//   - quizID: The ID of the quiz.
+//
+// Returns:
+//   - []models.QuizAccommodation: The accommodations of the quiz.
+//   - error: An error if the retrieval fails, nil otherwise.
+func (s *AccommodationService) ListAccommodations(quizID uint) ([]models.QuizAccommodation, error) {
+	var accommodations []models.QuizAccommodation
+	if err := s.db.Where("quiz_id = ?", quizID).
+		Order("id ASC").
+		Find(&accommodations).Error; err != nil {
+		return nil, err
+	}
+	return accommodations, nil
+}
+
+// SetAccommodation creates or replaces the accommodation of a student for a quiz.
+//
+// Parameters:
+//   - quizID: The ID of the quiz.
+//   - enrollmentID: The ID of the student's enrollment in the course of the quiz.
+//   - accommodation: The accommodation to set.
+//
+// Returns:
+//   - *models.QuizAccommodation: The stored accommodation.
+//   - error: An error if the enrollment is not a student of the course or the accommodation is invalid, nil otherwise.
+func (s *AccommodationService) SetAccommodation(quizID, enrollmentID uint, accommodation *models.QuizAccommodation) (*models.QuizAccommodation, error) {
+	if accommodation.DurationMultiplier == 0 {
+		accommodation.DurationMultiplier = 1
+	}
+
+	switch {
+	case accommodation.DurationMultiplier < 1:
+		return nil, InvalidInput(errors.New("duration multiplier cannot shorten the quiz"))
+	case accommodation.ExtraMinutes < 0 || accommodation.ExtraAttempts < 0:
+		return nil, InvalidInput(errors.New("extra minutes and attempts cannot be negative"))
+	}
+
+	var stored models.QuizAccommodation
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var quiz models.Quiz
+		if err := tx.First(&quiz, quizID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return EntityNotFound(err)
+			}
+			return err
+		}
+
+		var enrollment models.Enrollment
+		if err := tx.First(&enrollment, enrollmentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return EntityNotFound(err)
+			}
+			return err
+		}
+
+		if enrollment.CourseID != quiz.CourseID || enrollment.Role != models.RoleStudent {
+			return InvalidInput(errors.New("enrollment is not a student of the course of the quiz"))
+		}
+
+		schedule := ScheduleFor(&quiz, accommodation)
+		if !schedule.EndTime.After(schedule.StartTime) {
+			return InvalidInput(errors.New("accommodated window must end after it starts"))
+		}
+
+		err := tx.Where("quiz_id = ? AND enrollment_id = ?", quizID, enrollmentID).First(&stored).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		stored.QuizID = quizID
+		stored.EnrollmentID = enrollmentID
+		stored.DurationMultiplier = accommodation.DurationMultiplier
+		stored.ExtraMinutes = accommodation.ExtraMinutes
+		stored.StartTime = accommodation.StartTime
+		stored.EndTime = accommodation.EndTime
+		stored.ExtraAttempts = accommodation.ExtraAttempts
+
+		if err := tx.Save(&stored).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// DeleteAccommodation removes the accommodation of a student for a quiz.
+//
+// Parameters:
+//   - quizID: The ID of the quiz.
+//   - enrollmentID: The ID of the student's enrollment.
+//
+// Returns:
+//   - error: An error if the accommodation is not found or the deletion fails, nil otherwise.
+func (s *AccommodationService) DeleteAccommodation(quizID, enrollmentID uint) error {
+	result := s.db.Unscoped().
+		Where("quiz_id = ? AND enrollment_id = ?", quizID, enrollmentID).
+		Delete(&models.QuizAccommodation{})
+	if result.Error != nil {
+		return DeleteEntityFailure(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return EntityNotFound(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// studentSchedule loads the accommodation of an enrollment, if any, and
+// returns the schedule of the quiz for it.
+func studentSchedule(tx *gorm.DB, quiz *models.Quiz, enrollmentID uint) (QuizSchedule, error) {
+	var accommodation models.QuizAccommodation
+	err := tx.Where("quiz_id = ? AND enrollment_id = ?", quiz.ID, enrollmentID).
+		First(&accommodation).Error
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ScheduleFor(quiz, nil), nil
+	case err != nil:
+		return QuizSchedule{}, err
+	}
+
+	return ScheduleFor(quiz, &accommodation), nil
+}
+
+// quizClosesAt returns the moment the quiz closes for every student, taking
+// extended windows into account. Results are held back until then, so that
+// students taking a make-up cannot learn the answers from others.
+func quizClosesAt(tx *gorm.DB, quiz *models.Quiz) (time.Time, error) {
+	var latest sql.NullTime
+	if err := tx.Model(&models.QuizAccommodation{}).
+		Where("quiz_id = ? AND end_time IS NOT NULL", quiz.ID).
+		Select("MAX(end_time)").
+		Scan(&latest).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	if latest.Valid && latest.Time.After(quiz.EndTime) {
+		return latest.Time, nil
+	}
+	return quiz.EndTime, nil
+}
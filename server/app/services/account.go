@@ -0,0 +1,248 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	apperror "server/app/error"
+	"server/app/mailer"
+	"server/app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	passwordResetExpiry     = time.Hour
+	emailVerificationExpiry = 48 * time.Hour
+)
+
+// AccountService handles the account flows that go through email: password
+// resets and email verification.
+type AccountService struct {
+	db     *gorm.DB
+	mailer mailer.Mailer
+	appURL string
+}
+
+// NewAccountService creates a new AccountService instance.
+//
+// Parameters:
+//   - db: The database connection.
+//   - mailer: The mailer used to send the links.
+//   - appURL: The base URL of the web application the links point to.
+func NewAccountService(db *gorm.DB, mailer mailer.Mailer, appURL string) *AccountService {
+	return &AccountService{db: db, mailer: mailer, appURL: strings.TrimSuffix(appURL, "/")}
+}
+
+// SendVerificationEmail emails a user a link to verify their email address.
+// Earlier verification links of the user stop working.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - error: An error if the user is not found, is already verified, or the email cannot be sent, nil otherwise.
+func (s *AccountService) SendVerificationEmail(userID uint) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return EntityNotFound(err)
+		}
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return CannotPerformAction("verify an email address that is already verified")
+	}
+
+	token, err := issueUserToken(s.db, &user, models.TokenPurposeEmailVerification, emailVerificationExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s/verify-email?token=%s\n\nThe link expires in %d hours.\n",
+			greetingName(&user), s.appURL, token, int(emailVerificationExpiry.Hours())),
+	})
+}
+
+// VerifyEmail marks the email address of a user as verified, if it is still
+// the address the verification link was sent to.
+//
+// Parameters:
+//   - token: The token from the verification link.
+//
+// Returns:
+//   - error: An InvalidToken error if the token is unknown, used or expired, or the user
+//     changed their address since, nil otherwise.
+func (s *AccountService) VerifyEmail(token string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, token, models.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, userToken.UserID).Error; err != nil || user.Email != userToken.Email {
+			return apperror.InvalidToken{}
+		}
+
+		if err := tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", user.ID).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		return nil
+	})
+}
+
+// RequestPasswordReset emails a link to reset the password of the user with
+// the given email address. Earlier reset links of the user stop working. To
+// avoid revealing which addresses have an account, unknown and inactive
+// addresses are silently ignored.
+//
+// Parameters:
+//   - email: The email address of the account.
+//
+// Returns:
+//   - error: An error if the email cannot be sent, nil otherwise.
+func (s *AccountService) RequestPasswordReset(email string) error {
+	var user models.User
+	err := s.db.Where("email = ?", strings.TrimSpace(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !user.Active) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.sendPasswordReset(&user, "Someone asked to reset the password of your account.",
+		"If you didn't ask for it, you can ignore this email.")
+}
+
+// sendPasswordReset emails a user a link to reset their password, explaining why with intro and outro.
+func (s *AccountService) sendPasswordReset(user *models.User, intro, outro string) error {
+	token, err := issueUserToken(s.db, user, models.TokenPurposePasswordReset, passwordResetExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n%s To choose a new password, open the link below:\n\n%s/reset-password?token=%s\n\nThe link expires in %d minutes. %s\n",
+			greetingName(user), intro, s.appURL, token, int(passwordResetExpiry.Minutes()), outro),
+	})
+}
+
+// ResetPassword sets a new password for the user a reset token was sent to,
+// and signs the user out of every device. Following the link also proves the
+// user owns the address, so it is marked as verified, unless the user changed
+// their address since the link was sent.
+//
+// Parameters:
+//   - token: The token from the reset link.
+//   - password: The new password.
+//
+// Returns:
+//   - error: An InvalidToken error if the token is unknown, used or expired, nil otherwise.
+func (s *AccountService) ResetPassword(token, password string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, userToken.UserID).Error; err != nil {
+			return apperror.InvalidToken{}
+		}
+
+		if err := user.SetPassword(password); err != nil {
+			return InvalidInput(err)
+		}
+		if user.EmailVerifiedAt == nil && user.Email == userToken.Email {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+
+		if err := tx.Save(&user).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+
+		return revokeUserSessions(tx, user.ID)
+	})
+}
+
+// issueUserToken creates a token for a user, to send to their current email
+// address, voiding their earlier unused tokens with the same purpose.
+func issueUserToken(tx *gorm.DB, user *models.User, purpose models.UserTokenPurpose, expiry time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserToken{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			Email:     user.Email,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(expiry),
+		}).Error
+	})
+
+	if err != nil {
+		return "", CreateEntityFailure(err)
+	}
+	return token, nil
+}
+
+// consumeUserToken marks a token as used and returns it, if it is valid for the purpose.
+func consumeUserToken(tx *gorm.DB, token string, purpose models.UserTokenPurpose) (*models.UserToken, error) {
+	var userToken models.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).
+		First(&userToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.InvalidToken{}
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !userToken.ExpiresAt.After(now) {
+		return nil, apperror.InvalidToken{}
+	}
+
+	// The condition on used_at makes concurrent requests with the same token
+	// consume it only once.
+	result := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", userToken.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, apperror.InvalidToken{}
+	}
+	return &userToken, nil
+}
+
+// greetingName returns the name to greet a user with in emails.
+func greetingName(user *models.User) string {
+	if user.FirstName != "" {
+		return user.FirstName
+	}
+	return user.Email
+}
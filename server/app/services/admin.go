@@ -0,0 +1,305 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"server/app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+	// impersonationExpiry is how long an admin can act as a user before starting over.
+	impersonationExpiry = time.Hour
+)
+
+// AdminService lets global admins manage the accounts of other users. Every
+// change is written to the audit trail, with the admin as its actor.
+type AdminService struct {
+	db       *gorm.DB
+	users    *UserService
+	accounts *AccountService
+}
+
+// NewAdminService creates a new AdminService instance.
+//
+// Parameters:
+//   - db: The database connection.
+//   - users: The user service, signing the tokens of impersonations.
+//   - accounts: The account service, sending forced password reset links.
+func NewAdminService(db *gorm.DB, users *UserService, accounts *AccountService) *AdminService {
+	return &AdminService{db: db, users: users, accounts: accounts}
+}
+
+// UserFilter narrows down the users ListUsers returns. Zero fields match everything.
+type UserFilter struct {
+	Query    string      // Part of the email address or name
+	Role     models.Role // Global role
+	Active   *bool
+	Page     int // From 1
+	PageSize int // 20 by default, at most 100
+}
+
+// UserPage is a page of users, with the number of users matching in all.
+type UserPage struct {
+	Users    []models.User `json:"users"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+}
+
+// ListUsers searches the users, ordered by email address.
+//
+// Parameters:
+//   - filter: The search and the page to return.
+//
+// Returns:
+//   - *UserPage: The page of users.
+//   - error: An error if the query fails, nil otherwise.
+func (s *AdminService) ListUsers(filter UserFilter) (*UserPage, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = defaultUserPageSize
+	}
+	if filter.PageSize > maxUserPageSize {
+		filter.PageSize = maxUserPageSize
+	}
+
+	query := s.db.Model(&models.User{})
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		pattern := "%" + likeEscaper.Replace(q) + "%"
+		query = query.Where("email ILIKE ? OR first_name ILIKE ? OR last_name ILIKE ?", pattern, pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Active != nil {
+		query = query.Where("active = ?", *filter.Active)
+	}
+
+	page := UserPage{Page: filter.Page, PageSize: filter.PageSize}
+	if err := query.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := query.Order("email ASC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&page.Users).Error; err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns in user input.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SetActive deactivates or reactivates a user. Deactivated users cannot log in,
+// and their sessions and personal access tokens stop working at once.
+//
+// Parameters:
+//   - actorID: The ID of the admin.
+//   - userID: The ID of the user.
+//   - active: Whether the user may use the platform.
+//
+// Returns:
+//   - error: An EntityNotFound error if the user doesn't exist, a CannotPerformAction
+//     error if the admin deactivates themselves, nil otherwise.
+func (s *AdminService) SetActive(actorID, userID uint, active bool) error {
+	if actorID == userID && !active {
+		return CannotPerformAction("deactivate your own account")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(user).Update("active", active).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+
+		action, verb := models.AuditUserReactivate, "reactivated"
+		if !active {
+			action, verb = models.AuditUserDeactivate, "deactivated"
+			if err := revokeUserSessions(tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		return recordAudit(tx, models.AuditLog{
+			Action:  action,
+			ActorID: &actorID,
+			UserID:  &user.ID,
+			Details: fmt.Sprintf("account %s %s", user.Email, verb),
+		})
+	})
+}
+
+// SetRole assigns the global role of a user. The role is in the tokens of the
+// user, so their sessions are revoked for it to take effect.
+//
+// Parameters:
+//   - actorID: The ID of the admin.
+//   - userID: The ID of the user.
+//   - role: The new role, RoleUser or RoleAdmin.
+//
+// Returns:
+//   - error: An InvalidInput error if the role is not a global role, an EntityNotFound error if the
+//     user doesn't exist, a CannotPerformAction error if the admin changes their own role, nil otherwise.
+func (s *AdminService) SetRole(actorID, userID uint, role models.Role) error {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return InvalidInput(fmt.Errorf("role must be %q or %q", models.RoleUser, models.RoleAdmin))
+	}
+	if actorID == userID {
+		return CannotPerformAction("change your own role")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.Role == role {
+			return nil
+		}
+
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, models.AuditLog{
+			Action:  models.AuditUserRoleChange,
+			ActorID: &actorID,
+			UserID:  &user.ID,
+			Details: fmt.Sprintf("role of %s changed from %s to %s", user.Email, user.Role, role),
+		})
+	})
+}
+
+// ForcePasswordReset makes a user choose a new password: their current one
+// stops working, they are signed out everywhere and emailed a reset link.
+//
+// Parameters:
+//   - actorID: The ID of the admin.
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - error: An EntityNotFound error if the user doesn't exist, or an error if the email cannot be sent, nil otherwise.
+func (s *AdminService) ForcePasswordReset(actorID, userID uint) error {
+	var user *models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = findUser(tx, userID); err != nil {
+			return err
+		}
+
+		password, err := randomToken()
+		if err != nil {
+			return err
+		}
+		if err := user.SetPassword(password); err != nil {
+			return err
+		}
+		if err := tx.Save(user).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, models.AuditLog{
+			Action:  models.AuditUserPasswordReset,
+			ActorID: &actorID,
+			UserID:  &user.ID,
+			Details: fmt.Sprintf("password reset forced for %s", user.Email),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.accounts.sendPasswordReset(user, "An administrator has reset the password of your account.",
+		"Until you choose a new password, you cannot log in with a password.")
+}
+
+// Impersonate opens a session in which an admin acts as a user, to see the
+// platform as they do for support. The session lasts an hour and cannot be
+// refreshed, and its access token carries the ID of the admin in the "imp" claim.
+//
+// Parameters:
+//   - actorID: The ID of the admin.
+//   - userID: The ID of the user to act as.
+//
+// Returns:
+//   - *TokenPair: The access token of the session, without a refresh token.
+//   - error: An EntityNotFound error if the user doesn't exist, a CannotPerformAction error if
+//     the user is the admin themselves, another admin or inactive, nil otherwise.
+func (s *AdminService) Impersonate(actorID, userID uint) (*TokenPair, error) {
+	if actorID == userID {
+		return nil, CannotPerformAction("impersonate yourself")
+	}
+
+	var tokens *TokenPair
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.Role == models.RoleAdmin {
+			return CannotPerformAction("impersonate another admin")
+		}
+		if !user.Active {
+			return CannotPerformAction("impersonate an inactive user")
+		}
+
+		now := time.Now()
+		session := models.Session{
+			UserID:         user.ID,
+			ExpiresAt:      now.Add(impersonationExpiry),
+			LastUsedAt:     now,
+			ImpersonatorID: &actorID,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+
+		if tokens, err = s.users.tokenPair(user, &session, ""); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, models.AuditLog{
+			Action:  models.AuditUserImpersonate,
+			ActorID: &actorID,
+			UserID:  &user.ID,
+			Details: fmt.Sprintf("impersonation of %s started, session %d", user.Email, session.ID),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// findUser loads a user by ID.
+func findUser(tx *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"math"
+	"server/app/models"
+	"sort"
+	"time"
+)
+
+// discriminationGroup is the share of students in the top and bottom groups
+// compared by the discrimination index.
+const discriminationGroup = 0.27
+
+// histogramBins is the number of bins of the score histogram.
+const histogramBins = 10
+
+// ItemAnalysis is the item analysis report of a quiz.
+type ItemAnalysis struct {
+	QuizID       uint            `json:"quizId"`
+	Attempts     int             `json:"attempts"`
+	AverageScore float64         `json:"averageScore"` // Percentage of the maximum score
+	Histogram    []HistogramBin  `json:"histogram"`
+	KR20         *float64        `json:"kr20"` // Nil when there are too few questions or no variance
+	Questions    []QuestionStats `json:"questions"`
+}
+
+// HistogramBin counts the attempts scoring between From and To percent.
+// The last bin includes its upper bound.
+type HistogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// QuestionStats describes how students fared on a single question.
+type QuestionStats struct {
+	QuestionID         uint                `json:"questionId"`
+	Title              string              `json:"title"`
+	Type               models.QuestionType `json:"type"`
+	Responses          int                 `json:"responses"`
+	PercentCorrect     float64             `json:"percentCorrect"` // Share of students earning full points
+	AverageCredit      float64             `json:"averageCredit"`  // Average share of the points earned, from 0 to 1
+	Discrimination     *float64            `json:"discrimination"` // Nil when too few students got the question
+	AverageTimeSeconds float64             `json:"averageTimeSeconds"`
+	Options            []OptionStats       `json:"options"`
+}
+
+// OptionStats counts how often an option was selected.
+type OptionStats struct {
+	OptionID  uint    `json:"optionId"`
+	Text      string  `json:"text"`
+	IsCorrect bool    `json:"isCorrect"`
+	Count     int     `json:"count"`
+	Percent   float64 `json:"percent"`
+}
+
+// GetItemAnalysis builds the item analysis report of a quiz once it has closed
+// for every student. Each student counts once, with their first fully graded attempt.
+//
+// Parameters:
+//   - quizID: The ID of the quiz.
+//
+// Returns:
+//   - *ItemAnalysis: The item analysis report.
+//   - error: An error if the quiz is not found or is still open, nil otherwise.
+func (q *QuizService) GetItemAnalysis(quizID uint) (*ItemAnalysis, error) {
+	quiz, err := q.GetQuiz(quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	closesAt, err := quizClosesAt(q.db, quiz)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().Before(closesAt) {
+		return nil, CannotPerformAction("analyze a quiz before it closes")
+	}
+
+	var attempts []models.QuizSubmission
+	if err := q.db.Preload("Answers").
+		Where("quiz_id = ? AND status = ?", quizID, models.AttemptStatusSubmitted).
+		Order("number ASC, id ASC").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	counted := make(map[uint]bool)
+	firstAttempts := make([]models.QuizSubmission, 0, len(attempts))
+	for _, attempt := range attempts {
+		if !counted[attempt.UserID] {
+			counted[attempt.UserID] = true
+			firstAttempts = append(firstAttempts, attempt)
+		}
+	}
+
+	// Bank questions drawn for the attempts have an answer row like every other question.
+	questionIDs := make(map[uint]bool)
+	for _, attempt := range firstAttempts {
+		for _, answer := range attempt.Answers {
+			questionIDs[answer.QuestionID] = true
+		}
+	}
+
+	questions := quiz.Questions
+	for _, question := range quiz.Questions {
+		delete(questionIDs, question.ID)
+	}
+
+	if len(questionIDs) > 0 {
+		ids := make([]uint, 0, len(questionIDs))
+		for id := range questionIDs {
+			ids = append(ids, id)
+		}
+
+		// Questions deleted from the bank since still count, with the options
+		// they have now.
+		var drawn []models.Question
+		if err := q.db.Unscoped().
+			Preload("Options", notDeleted, orderByID).
+			Where("id IN ?", ids).
+			Order("id ASC").
+			Find(&drawn).Error; err != nil {
+			return nil, err
+		}
+		questions = append(questions, drawn...)
+	}
+
+	analysis := AnalyzeItems(questions, firstAttempts)
+	analysis.QuizID = quizID
+	return &analysis, nil
+}
+
+// AnalyzeItems computes the item analysis of a set of graded attempts.
+//
+// An answer counts as correct when it earned the full points of its question.
+// The discrimination index of a question is the average credit of the top 27%
+// of students, ranked by their score percentage, minus that of the bottom 27%.
+// KR-20 only considers the questions that every attempt answered, so questions
+// drawn from a bank don't take part in it.
+//
+// Parameters:
+//   - questions: The questions of the attempts, with their options.
+//   - attempts: The graded attempts, with their answers.
+//
+// Returns:
+//   - ItemAnalysis: The item analysis, without its QuizID.
+func AnalyzeItems(questions []models.Question, attempts []models.QuizSubmission) ItemAnalysis {
+	analysis := ItemAnalysis{
+		Attempts:  len(attempts),
+		Histogram: scoreHistogram(attempts),
+		Questions: make([]QuestionStats, 0, len(questions)),
+	}
+
+	ranked := make([]models.QuizSubmission, len(attempts))
+	copy(ranked, attempts)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scorePercent(ranked[i]) > scorePercent(ranked[j])
+	})
+
+	total := 0.0
+	for _, attempt := range attempts {
+		total += scorePercent(attempt)
+	}
+	if len(attempts) > 0 {
+		analysis.AverageScore = roundPoints(total / float64(len(attempts)))
+	}
+
+	groupSize := int(math.Round(discriminationGroup * float64(len(ranked))))
+	if groupSize == 0 && len(ranked) >= 2 {
+		groupSize = 1
+	}
+	top, bottom := ranked[:groupSize], ranked[len(ranked)-groupSize:]
+
+	for _, question := range questions {
+		analysis.Questions = append(analysis.Questions, questionStats(question, attempts, top, bottom))
+	}
+
+	analysis.KR20 = kr20(questions, attempts)
+	return analysis
+}
+
+// questionStats computes the statistics of a single question.
+func questionStats(question models.Question, attempts, top, bottom []models.QuizSubmission) QuestionStats {
+	stats := QuestionStats{
+		QuestionID: question.ID,
+		Title:      question.Title,
+		Type:       question.Type,
+		Options:    []OptionStats{},
+	}
+
+	selections := make(map[uint]int)
+	correct, credit, timeSpent := 0, 0.0, 0
+
+	for _, attempt := range attempts {
+		answer, ok := findAnswer(attempt, question.ID)
+		if !ok {
+			continue
+		}
+
+		stats.Responses++
+		credit += answerCredit(question, answer)
+		timeSpent += answer.TimeSpentSeconds
+		if answerCorrect(question, answer) {
+			correct++
+		}
+		for _, id := range answer.SelectedOptions {
+			selections[id]++
+		}
+	}
+
+	if stats.Responses == 0 {
+		return stats
+	}
+
+	responses := float64(stats.Responses)
+	stats.PercentCorrect = roundPoints(float64(correct) / responses * 100)
+	stats.AverageCredit = roundPoints(credit / responses)
+	stats.AverageTimeSeconds = roundPoints(float64(timeSpent) / responses)
+
+	// The selections of Ordering questions are orders, not choices.
+	if question.Type.UsesOptions() && question.Type != models.Ordering {
+		for _, option := range question.Options {
+			stats.Options = append(stats.Options, OptionStats{
+				OptionID:  option.ID,
+				Text:      option.Text,
+				IsCorrect: option.IsCorrect,
+				Count:     selections[option.ID],
+				Percent:   roundPoints(float64(selections[option.ID]) / responses * 100),
+			})
+		}
+	}
+
+	topCredit, topOK := groupCredit(question, top)
+	bottomCredit, bottomOK := groupCredit(question, bottom)
+	if topOK && bottomOK {
+		discrimination := roundPoints(topCredit - bottomCredit)
+		stats.Discrimination = &discrimination
+	}
+
+	return stats
+}
+
+// groupCredit returns the average credit a group of students earned on a question.
+func groupCredit(question models.Question, group []models.QuizSubmission) (float64, bool) {
+	credit, responses := 0.0, 0
+	for _, attempt := range group {
+		if answer, ok := findAnswer(attempt, question.ID); ok {
+			credit += answerCredit(question, answer)
+			responses++
+		}
+	}
+
+	if responses == 0 {
+		return 0, false
+	}
+	return credit / float64(responses), true
+}
+
+// kr20 computes the Kuder-Richardson 20 reliability of the questions every
+// attempt answered, scoring each of them as right or wrong.
+func kr20(questions []models.Question, attempts []models.QuizSubmission) *float64 {
+	var common []models.Question
+	for _, question := range questions {
+		answeredByAll := true
+		for _, attempt := range attempts {
+			if _, ok := findAnswer(attempt, question.ID); !ok {
+				answeredByAll = false
+				break
+			}
+		}
+		if answeredByAll {
+			common = append(common, question)
+		}
+	}
+
+	k, n := len(common), len(attempts)
+	if k < 2 || n < 2 {
+		return nil
+	}
+
+	totals := make([]float64, n)
+	sumPQ := 0.0
+	for _, question := range common {
+		correct := 0
+		for i, attempt := range attempts {
+			answer, _ := findAnswer(attempt, question.ID)
+			if answerCorrect(question, answer) {
+				correct++
+				totals[i]++
+			}
+		}
+		p := float64(correct) / float64(n)
+		sumPQ += p * (1 - p)
+	}
+
+	mean := 0.0
+	for _, total := range totals {
+		mean += total
+	}
+	mean /= float64(n)
+
+	variance := 0.0
+	for _, total := range totals {
+		variance += (total - mean) * (total - mean)
+	}
+	variance /= float64(n)
+
+	if variance == 0 {
+		return nil
+	}
+
+	reliability := roundPoints(float64(k) / float64(k-1) * (1 - sumPQ/variance))
+	return &reliability
+}
+
+// scoreHistogram counts the attempts per tenth of the maximum score.
+func scoreHistogram(attempts []models.QuizSubmission) []HistogramBin {
+	width := 100.0 / histogramBins
+	bins := make([]HistogramBin, histogramBins)
+	for i := range bins {
+		bins[i] = HistogramBin{From: float64(i) * width, To: float64(i+1) * width}
+	}
+
+	for _, attempt := range attempts {
+		bin := int(scorePercent(attempt) / width)
+		if bin >= histogramBins {
+			bin = histogramBins - 1
+		}
+		bins[bin].Count++
+	}
+
+	return bins
+}
+
+// scorePercent returns the score of an attempt as a percentage of its maximum.
+func scorePercent(attempt models.QuizSubmission) float64 {
+	if attempt.MaxScore <= 0 {
+		return 0
+	}
+	return math.Max(0, math.Min(100, attempt.Score/attempt.MaxScore*100))
+}
+
+// answerCredit returns the share of the points of the question the answer earned.
+func answerCredit(question models.Question, answer models.Answer) float64 {
+	if question.Points <= 0 {
+		return 0
+	}
+	return answer.PointsAwarded / float64(question.Points)
+}
+
+// answerCorrect reports whether the answer earned the full points of the question.
+func answerCorrect(question models.Question, answer models.Answer) bool {
+	return question.Points > 0 && answer.PointsAwarded >= float64(question.Points)
+}
+
+// findAnswer returns the answer of an attempt to a question.
+func findAnswer(attempt models.QuizSubmission, questionID uint) (models.Answer, bool) {
+	for _, answer := range attempt.Answers {
+		if answer.QuestionID == questionID {
+			return answer, true
+		}
+	}
+	return models.Answer{}, false
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	apperror "server/app/error"
+	"server/app/identity"
+	"server/app/models"
+	"server/app/policy"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix starts every personal access token, telling them apart from
+// JWTs and making leaked tokens easy to spot.
+const APITokenPrefix = "glass_pat_"
+
+const (
+	defaultAPITokenLifetime = 30 * 24 * time.Hour
+	maxAPITokenDays         = 365
+	maxAPITokenNameLength   = 100
+	// apiTokenUsageInterval is how often the last use of a token is written, at most.
+	apiTokenUsageInterval = time.Minute
+)
+
+// APITokenService manages the personal access tokens of users.
+type APITokenService struct {
+	db *gorm.DB
+}
+
+func NewAPITokenService(db *gorm.DB) *APITokenService {
+	return &APITokenService{db: db}
+}
+
+// NewAPIToken describes a personal access token to create.
+type NewAPIToken struct {
+	Name          string              `json:"name" binding:"required"`
+	Scopes        []policy.Permission `json:"scopes" binding:"required"`
+	CourseID      *uint               `json:"courseId"`      // Limits the token to one course
+	ExpiresInDays int                 `json:"expiresInDays"` // Defaults to 30, at most 365
+}
+
+// CreatedAPIToken is a new personal access token along with its secret, which
+// is only ever shown at creation.
+type CreatedAPIToken struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+// Create creates a personal access token for a user.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - input: The name, scopes, course and lifetime of the token.
+//
+// Returns:
+//   - *CreatedAPIToken: The token and its secret.
+//   - error: An InvalidInput error if the name, a scope or the lifetime is invalid, an
+//     EntityNotFound error if the course doesn't exist, nil otherwise.
+func (s *APITokenService) Create(userID uint, input NewAPIToken) (*CreatedAPIToken, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > maxAPITokenNameLength {
+		return nil, InvalidInput(fmt.Errorf("name must be 1 to %d characters", maxAPITokenNameLength))
+	}
+
+	if len(input.Scopes) == 0 {
+		return nil, InvalidInput(errors.New("at least one scope is required"))
+	}
+	var scopes []string
+	seen := make(map[policy.Permission]bool)
+	for _, scope := range input.Scopes {
+		if !policy.TokenPermission(scope) {
+			return nil, InvalidInput(fmt.Errorf("unknown scope %q", scope))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, string(scope))
+		}
+	}
+
+	lifetime := defaultAPITokenLifetime
+	if input.ExpiresInDays != 0 {
+		if input.ExpiresInDays < 0 || input.ExpiresInDays > maxAPITokenDays {
+			return nil, InvalidInput(fmt.Errorf("expiresInDays must be 1 to %d", maxAPITokenDays))
+		}
+		lifetime = time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	}
+
+	if input.CourseID != nil {
+		if err := s.db.Select("id").First(&models.Course{}, *input.CourseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, EntityNotFound(err)
+			}
+			return nil, err
+		}
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	token := APITokenPrefix + secret
+
+	apiToken := models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:len(APITokenPrefix)+4],
+		TokenHash: hashToken(token),
+		Scopes:    scopes,
+		CourseID:  input.CourseID,
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+	if err := s.db.Create(&apiToken).Error; err != nil {
+		return nil, CreateEntityFailure(err)
+	}
+
+	return &CreatedAPIToken{APIToken: apiToken, Token: token}, nil
+}
+
+// List returns the personal access tokens of a user that are not revoked, newest first.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - []models.APIToken: The tokens, with when each was last used.
+//   - error: An error if the query fails, nil otherwise.
+func (s *APITokenService) List(userID uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Revoke revokes a personal access token of a user. It stops working at once.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - tokenID: The ID of the token.
+//
+// Returns:
+//   - error: An EntityNotFound error if the user has no such token, nil otherwise.
+func (s *APITokenService) Revoke(userID, tokenID uint) error {
+	result := s.db.Model(&models.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return UpdateEntityFailure(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return EntityNotFound(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// validateAPIToken returns the identity a personal access token acts as, if
+// the token is live and its user still exists and is active.
+func validateAPIToken(db *gorm.DB, token string) (identity.Identity, error) {
+	var apiToken models.APIToken
+	if err := db.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).
+		First(&apiToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return identity.Identity{}, apperror.InvalidToken{}
+		}
+		return identity.Identity{}, err
+	}
+
+	var user models.User
+	if err := db.Select("id", "role", "active").First(&user, apiToken.UserID).Error; err != nil || !user.Active {
+		return identity.Identity{}, apperror.InvalidToken{}
+	}
+
+	now := time.Now()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > apiTokenUsageInterval {
+		if err := db.Model(&apiToken).Update("last_used_at", now).Error; err != nil {
+			return identity.Identity{}, UpdateEntityFailure(err)
+		}
+	}
+
+	scope := &policy.TokenScope{}
+	for _, perm := range apiToken.Scopes {
+		scope.Permissions = append(scope.Permissions, policy.Permission(perm))
+	}
+	if apiToken.CourseID != nil {
+		scope.CourseID = *apiToken.CourseID
+	}
+
+	return identity.Identity{UserID: user.ID, Role: user.Role, TokenID: apiToken.ID, Scope: scope}, nil
+}
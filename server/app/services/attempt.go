@@ -0,0 +1,534 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"server/app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// answerGracePeriod absorbs network latency for autosaves sent right before
+// an attempt expires.
+const answerGracePeriod = 10 * time.Second
+
+type AttemptService struct {
+	db *gorm.DB
+}
+
+func NewAttemptService(db *gorm.DB) *AttemptService {
+	return &AttemptService{db: db}
+}
+
+// maxTextAnswerLength caps the size of text answers stored per question.
+const maxTextAnswerLength = 1000
+
+// maxEssayAnswerLength caps the size of answers to Essay questions.
+const maxEssayAnswerLength = 20000
+
+// AnswerInput is a single answer sent by a student while taking a quiz.
+// SelectedOptions holds the selection of option-based questions, and the
+// student's order of the options for Ordering questions. TimeSpentSeconds is
+// the total time the client has seen the student spend on the question.
+type AnswerInput struct {
+	QuestionID       uint     `json:"questionId" binding:"required"`
+	SelectedOptions  []uint   `json:"selectedOptions"`
+	TextAnswer       string   `json:"textAnswer"`
+	NumericAnswer    *float64 `json:"numericAnswer"`
+	TimeSpentSeconds int      `json:"timeSpentSeconds" binding:"min=0"`
+}
+
+// StartAttempt starts a new attempt at a quiz for a user, or resumes the
+// attempt that is already in progress. Attempts left in progress past their
+// deadline are finalized at it, even if no new attempt may start. The window,
+// duration and number of attempts allowed take the student's accommodation
+// into account.
+//
+// Parameters:
+//   - userID: The ID of the user taking the quiz.
+//   - quizID: The ID of the quiz.
+//
+// Returns:
+//   - *models.QuizSubmission: The started or resumed attempt.
+//   - error: An error if the quiz is not open or the user cannot take it, nil otherwise.
+func (s *AttemptService) StartAttempt(userID, quizID uint) (*models.QuizSubmission, error) {
+	var attempt models.QuizSubmission
+	var refused error
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var quiz models.Quiz
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&quiz, quizID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return EntityNotFound(err)
+			}
+			return err
+		}
+
+		enrollment, err := canTakeQuiz(tx, userID, quiz.CourseID)
+		if err != nil {
+			return err
+		}
+
+		schedule, err := studentSchedule(tx, &quiz, enrollment.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if now.Before(schedule.StartTime) {
+			return CannotPerformAction("start the quiz before it opens")
+		}
+		if !now.Before(schedule.EndTime) {
+			return CannotPerformAction("start the quiz after it has closed")
+		}
+
+		var previous []models.QuizSubmission
+		if err := tx.Where("quiz_id = ? AND user_id = ?", quizID, userID).
+			Find(&previous).Error; err != nil {
+			return err
+		}
+
+		for i := range previous {
+			if previous[i].Status != models.AttemptStatusInProgress {
+				continue
+			}
+
+			if now.Before(previous[i].ExpiresAt) {
+				attempt = previous[i]
+				return nil
+			}
+
+			if err := s.finalize(tx, &previous[i], previous[i].ExpiresAt); err != nil {
+				return err
+			}
+		}
+
+		if len(previous) >= schedule.MaxAttempts {
+			// The expired attempts finalized above stay finalized.
+			refused = CannotPerformAction(fmt.Sprintf("start more than %d attempts at this quiz", schedule.MaxAttempts))
+			return nil
+		}
+
+		expiresAt := now.Add(time.Duration(schedule.Duration) * time.Minute)
+		if expiresAt.After(schedule.EndTime) {
+			expiresAt = schedule.EndTime
+		}
+
+		attempt = models.QuizSubmission{
+			QuizID:    quizID,
+			UserID:    userID,
+			Number:    len(previous) + 1,
+			Status:    models.AttemptStatusInProgress,
+			StartTime: now,
+			ExpiresAt: expiresAt,
+			Seed:      rand.Int63(),
+		}
+
+		if err := tx.Create(&attempt).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+
+		return drawAttemptQuestions(tx, &quiz, &attempt)
+	})
+
+	if err == nil {
+		err = refused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// GetAttempt retrieves an attempt of the user together with the answers saved
+// so far. The score, per-question points and feedback are only included once the
+// attempt is fully graded and the quiz has ended with its results shown.
+//
+// Parameters:
+//   - userID: The ID of the user who owns the attempt.
+//   - quizID: The ID of the quiz the attempt belongs to.
+//   - attemptID: The ID of the attempt.
+//
+// Returns:
+//   - *models.QuizSubmission: The attempt with its answers.
+//   - error: An error if the attempt is not found or belongs to another user, nil otherwise.
+func (s *AttemptService) GetAttempt(userID, quizID, attemptID uint) (*models.QuizSubmission, error) {
+	attempt, err := s.loadAttempt(s.db.Preload("Answers").Preload("Quiz"), userID, quizID, attemptID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := hideUnreleasedResults(s.db, attempt); err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// hideUnreleasedResults sets whether the results of an attempt, loaded with its
+// quiz and answers, are visible to the student, and zeroes them out if not.
+func hideUnreleasedResults(tx *gorm.DB, attempt *models.QuizSubmission) error {
+	closesAt, err := quizClosesAt(tx, &attempt.Quiz)
+	if err != nil {
+		return err
+	}
+
+	attempt.ResultsVisible = attempt.Status == models.AttemptStatusSubmitted &&
+		resultsAvailable(&attempt.Quiz, closesAt, time.Now())
+	if !attempt.ResultsVisible {
+		attempt.Score = 0
+		for i := range attempt.Answers {
+			attempt.Answers[i].PointsAwarded = 0
+			attempt.Answers[i].Feedback = ""
+		}
+	}
+	return nil
+}
+
+// SaveAnswers stores answers for an in-progress attempt. Answers replace any
+// answer previously saved for the same question, so clients can autosave freely.
+//
+// Parameters:
+//   - userID: The ID of the user who owns the attempt.
+//   - quizID: The ID of the quiz the attempt belongs to.
+//   - attemptID: The ID of the attempt.
+//   - answers: The answers to save.
+//
+// Returns:
+//   - *models.QuizSubmission: The attempt with all of its saved answers.
+//   - error: An error if the attempt is closed or an answer is invalid, nil otherwise.
+func (s *AttemptService) SaveAnswers(userID, quizID, attemptID uint, answers []AnswerInput) (*models.QuizSubmission, error) {
+	var attempt *models.QuizSubmission
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		attempt, err = s.loadAttempt(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID, quizID, attemptID)
+		if err != nil {
+			return err
+		}
+
+		if attempt.Status != models.AttemptStatusInProgress {
+			return CannotPerformAction("change answers of a submitted attempt")
+		}
+
+		if time.Now().After(attempt.ExpiresAt.Add(answerGracePeriod)) {
+			return CannotPerformAction("save answers after the attempt deadline")
+		}
+
+		questions, err := attemptQuestions(tx, attempt)
+		if err != nil {
+			return err
+		}
+
+		for _, input := range answers {
+			question, ok := questions[input.QuestionID]
+			if !ok {
+				return InvalidInput(fmt.Errorf("question %d is not part of this attempt", input.QuestionID))
+			}
+
+			if err := validateAnswer(question, input); err != nil {
+				return err
+			}
+
+			if err := saveAnswer(tx, attempt, input); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetAttempt(userID, quizID, attempt.ID)
+}
+
+// SubmitAttempt finalizes an attempt. Attempts submitted after their deadline
+// are closed at the deadline, keeping only the answers saved before it.
+//
+// Parameters:
+//   - userID: The ID of the user who owns the attempt.
+//   - quizID: The ID of the quiz the attempt belongs to.
+//   - attemptID: The ID of the attempt.
+//
+// Returns:
+//   - *models.QuizSubmission: The submitted attempt.
+//   - error: An error if the attempt is not found or already submitted, nil otherwise.
+func (s *AttemptService) SubmitAttempt(userID, quizID, attemptID uint) (*models.QuizSubmission, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		attempt, err := s.loadAttempt(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID, quizID, attemptID)
+		if err != nil {
+			return err
+		}
+
+		if attempt.Status != models.AttemptStatusInProgress {
+			return CannotPerformAction("submit an attempt twice")
+		}
+
+		endTime := time.Now()
+		if endTime.After(attempt.ExpiresAt) {
+			endTime = attempt.ExpiresAt
+		}
+
+		return s.finalize(tx, attempt, endTime)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetAttempt(userID, quizID, attemptID)
+}
+
+// finalize closes an attempt at the given time and scores it. Every question
+// of the quiz gets an answer row, so the stored breakdown also covers the
+// questions the student left unanswered. Non-blank answers to Essay questions
+// are queued for review, and the attempt stays pending until they are graded.
+func (s *AttemptService) finalize(tx *gorm.DB, attempt *models.QuizSubmission, endTime time.Time) error {
+	var quiz models.Quiz
+	if err := tx.First(&quiz, attempt.QuizID).Error; err != nil {
+		return err
+	}
+
+	questions, err := attemptQuestions(tx, attempt)
+	if err != nil {
+		return err
+	}
+
+	var answers []models.Answer
+	if err := tx.Where("submission_id = ?", attempt.ID).Find(&answers).Error; err != nil {
+		return err
+	}
+
+	answered := make(map[uint]bool, len(answers))
+	score, maxScore := 0.0, 0.0
+	pending := false
+
+	for i := range answers {
+		question, ok := questions[answers[i].QuestionID]
+		if !ok {
+			continue
+		}
+		answered[question.ID] = true
+
+		answers[i].PointsAwarded = ScoreAnswer(question, answers[i], quiz.ScoringPolicy)
+		answers[i].PendingReview = question.Type.NeedsManualGrading() && strings.TrimSpace(answers[i].TextAnswer) != ""
+		score += answers[i].PointsAwarded
+		pending = pending || answers[i].PendingReview
+
+		if err := tx.Model(&answers[i]).
+			Select("PointsAwarded", "PendingReview").
+			Updates(&answers[i]).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+	}
+
+	for id, question := range questions {
+		maxScore += float64(question.Points)
+		if answered[id] {
+			continue
+		}
+
+		blank := models.Answer{SubmissionID: attempt.ID, QuestionID: id}
+		if err := tx.Create(&blank).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+	}
+
+	attempt.Status = models.AttemptStatusSubmitted
+	if pending {
+		attempt.Status = models.AttemptStatusPendingReview
+	}
+	attempt.EndTime = endTime
+	attempt.Score = roundPoints(score)
+	attempt.MaxScore = maxScore
+
+	if err := tx.Model(attempt).
+		Select("Status", "EndTime", "Score", "MaxScore").
+		Updates(attempt).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+
+	return nil
+}
+
+// loadAttempt fetches an attempt and makes sure it belongs to the user and quiz.
+func (s *AttemptService) loadAttempt(db *gorm.DB, userID, quizID, attemptID uint) (*models.QuizSubmission, error) {
+	var attempt models.QuizSubmission
+	if err := db.First(&attempt, attemptID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+
+	if attempt.QuizID != quizID {
+		return nil, EntityNotFound(fmt.Errorf("attempt %d does not belong to quiz %d", attemptID, quizID))
+	}
+
+	if attempt.UserID != userID {
+		return nil, PermissionDenied()
+	}
+
+	return &attempt, nil
+}
+
+// canTakeQuiz checks that the user is an approved student of the course and
+// returns their enrollment.
+func canTakeQuiz(tx *gorm.DB, userID, courseID uint) (*models.Enrollment, error) {
+	var enrollment models.Enrollment
+	if err := tx.Where("user_id = ? AND course_id = ?", userID, courseID).
+		First(&enrollment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, PermissionDenied()
+		}
+		return nil, err
+	}
+
+	if enrollment.Role != models.RoleStudent || enrollment.Status != models.EnrollmentStatusApproved {
+		return nil, PermissionDenied()
+	}
+
+	return &enrollment, nil
+}
+
+// attemptQuestions loads the questions of an attempt with their options, keyed
+// by ID: the questions of the quiz and the bank questions drawn for the attempt.
+func attemptQuestions(tx *gorm.DB, attempt *models.QuizSubmission) (map[uint]models.Question, error) {
+	var questions []models.Question
+	if err := tx.Preload("Options").
+		Where("quiz_id = ?", attempt.QuizID).
+		Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	drawn, err := drawnQuestions(tx, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Question, len(questions)+len(drawn))
+	for _, q := range append(questions, drawn...) {
+		byID[q.ID] = q
+	}
+	return byID, nil
+}
+
+// drawnQuestions loads the bank questions drawn for an attempt, in the order
+// they were drawn. Questions deleted from the bank since are still included;
+// deleting a question leaves its options be, so only the live ones are loaded.
+func drawnQuestions(tx *gorm.DB, attemptID uint) ([]models.Question, error) {
+	var drawn []models.AttemptQuestion
+	if err := tx.Preload("Question", unscoped).
+		Preload("Question.Options", notDeleted, orderByID).
+		Where("submission_id = ?", attemptID).
+		Order("id ASC").
+		Find(&drawn).Error; err != nil {
+		return nil, err
+	}
+
+	questions := make([]models.Question, 0, len(drawn))
+	for _, d := range drawn {
+		questions = append(questions, d.Question)
+	}
+	return questions, nil
+}
+
+// validateAnswer checks that an answer has the shape its question expects.
+func validateAnswer(question models.Question, input AnswerInput) error {
+	if !question.Type.UsesOptions() {
+		if len(input.SelectedOptions) > 0 {
+			return InvalidInput(fmt.Errorf("question %d does not take options", question.ID))
+		}
+		maxLength := maxTextAnswerLength
+		if question.Type == models.Essay {
+			maxLength = maxEssayAnswerLength
+		}
+		if len(input.TextAnswer) > maxLength {
+			return InvalidInput(fmt.Errorf("answer to question %d is too long", question.ID))
+		}
+		return nil
+	}
+
+	if input.TextAnswer != "" || input.NumericAnswer != nil {
+		return InvalidInput(fmt.Errorf("question %d is answered by selecting options", question.ID))
+	}
+
+	selected := input.SelectedOptions
+	if (question.Type == models.SingleCorrect || question.Type == models.TrueFalse) && len(selected) > 1 {
+		return InvalidInput(fmt.Errorf("question %d accepts a single option", question.ID))
+	}
+
+	if question.Type == models.Ordering && len(selected) > 0 && len(selected) != len(question.Options) {
+		return InvalidInput(fmt.Errorf("question %d expects all of its options in order", question.ID))
+	}
+
+	valid := make(map[uint]bool, len(question.Options))
+	for _, option := range question.Options {
+		valid[option.ID] = true
+	}
+
+	seen := make(map[uint]bool, len(selected))
+	for _, id := range selected {
+		if !valid[id] {
+			return InvalidInput(fmt.Errorf("option %d is not part of question %d", id, question.ID))
+		}
+		if seen[id] {
+			return InvalidInput(fmt.Errorf("option %d selected twice", id))
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+// saveAnswer creates or replaces the answer of an attempt to a question.
+// The time spent on the question only ever grows, and cannot exceed the time
+// the attempt has been running.
+func saveAnswer(tx *gorm.DB, attempt *models.QuizSubmission, input AnswerInput) error {
+	timeSpent := input.TimeSpentSeconds
+	if elapsed := int(time.Since(attempt.StartTime).Seconds()); timeSpent > elapsed {
+		timeSpent = elapsed
+	}
+
+	var answer models.Answer
+	err := tx.Where("submission_id = ? AND question_id = ?", attempt.ID, input.QuestionID).
+		First(&answer).Error
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		answer = models.Answer{
+			SubmissionID:     attempt.ID,
+			QuestionID:       input.QuestionID,
+			SelectedOptions:  input.SelectedOptions,
+			TextAnswer:       input.TextAnswer,
+			NumericAnswer:    input.NumericAnswer,
+			TimeSpentSeconds: timeSpent,
+		}
+		if err := tx.Create(&answer).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+		return nil
+
+	case err != nil:
+		return err
+	}
+
+	answer.SelectedOptions = input.SelectedOptions
+	answer.TextAnswer = input.TextAnswer
+	answer.NumericAnswer = input.NumericAnswer
+	if timeSpent > answer.TimeSpentSeconds {
+		answer.TimeSpentSeconds = timeSpent
+	}
+	if err := tx.Save(&answer).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"server/app/models"
+
+	"gorm.io/gorm"
+)
+
+// auditPageSize is how many audit entries List returns at most.
+const auditPageSize = 100
+
+// AuditService reads the audit trail. Entries are written by the services
+// whose actions they record, with recordAudit.
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// AuditFilter narrows down the audit entries List returns. Zero fields match everything.
+type AuditFilter struct {
+	Action models.AuditAction
+	UserID uint // Entries about the user, or by them
+	Before uint // Entries older than the entry with this ID, for paging
+}
+
+// List returns the most recent audit entries matching a filter, newest first.
+//
+// Parameters:
+//   - filter: The filter to apply.
+//
+// Returns:
+//   - []models.AuditLog: Up to 100 entries.
+//   - error: An error if the query fails, nil otherwise.
+func (s *AuditService) List(filter AuditFilter) ([]models.AuditLog, error) {
+	query := s.db.Model(&models.AuditLog{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ? OR actor_id = ?", filter.UserID, filter.UserID)
+	}
+	if filter.Before != 0 {
+		query = query.Where("id < ?", filter.Before)
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("id DESC").Limit(auditPageSize).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// recordAudit adds an entry to the audit trail.
+func recordAudit(tx *gorm.DB, entry models.AuditLog) error {
+	if err := tx.Create(&entry).Error; err != nil {
+		return CreateEntityFailure(err)
+	}
+	return nil
+}
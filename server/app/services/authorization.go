@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"server/app/models"
+	"server/app/policy"
+
+	"gorm.io/gorm"
+)
+
+// AuthorizationService applies the rules of the policy package to users,
+// looking up their global role and their role in the course of a resource.
+type AuthorizationService struct {
+	db *gorm.DB
+}
+
+func NewAuthorizationService(db *gorm.DB) *AuthorizationService {
+	return &AuthorizationService{db: db}
+}
+
+// Authorize checks that a user has a permission on a resource. Global admins
+// have every permission. On resources of a course, the permission must be
+// granted by the role of the user in the course; the creator of a course is
+// its admin. Archived courses are read-only. On other resources, it must be
+// one every user has.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - perm: The permission needed.
+//   - res: The resource the user acts on.
+//
+// Returns:
+//   - error: A PermissionDenied error if the user lacks the permission, an
+//     EntityNotFound error if the course doesn't exist, nil otherwise.
+func (s *AuthorizationService) Authorize(userID uint, perm policy.Permission, res policy.Resource) error {
+	return authorize(s.db, userID, perm, res)
+}
+
+// CourseResource describes a course for authorization.
+//
+// Parameters:
+//   - courseID: The ID of the course.
+//
+// Returns:
+//   - policy.Resource: The course.
+//   - error: An EntityNotFound error if the course doesn't exist, nil otherwise.
+func (s *AuthorizationService) CourseResource(courseID uint) (policy.Resource, error) {
+	return lookupResource(s.db.Model(&models.Course{}).
+		Select("courses.id AS course_id").
+		Where("courses.id = ?", courseID))
+}
+
+// AssignmentResource describes an assignment for authorization.
+//
+// Parameters:
+//   - assignmentID: The ID of the assignment.
+//
+// Returns:
+//   - policy.Resource: The assignment, in its course.
+//   - error: An EntityNotFound error if the assignment doesn't exist, nil otherwise.
+func (s *AuthorizationService) AssignmentResource(assignmentID uint) (policy.Resource, error) {
+	return lookupResource(s.db.Model(&models.Assignment{}).
+		Select("assignments.course_id").
+		Where("assignments.id = ?", assignmentID))
+}
+
+// SubmissionResource describes a submission for authorization.
+//
+// Parameters:
+//   - submissionID: The ID of the submission.
+//
+// Returns:
+//   - policy.Resource: The submission, in the course of its assignment and owned by its student.
+//   - error: An EntityNotFound error if the submission doesn't exist, nil otherwise.
+func (s *AuthorizationService) SubmissionResource(submissionID uint) (policy.Resource, error) {
+	return lookupResource(s.db.Model(&models.Submission{}).
+		Select("assignments.course_id, submissions.user_id AS owner_id").
+		Joins("JOIN assignments ON assignments.id = submissions.assignment_id").
+		Where("submissions.id = ?", submissionID))
+}
+
+// GradeResource describes a grade for authorization.
+//
+// Parameters:
+//   - gradeID: The ID of the grade.
+//
+// Returns:
+//   - policy.Resource: The grade, in the course of its assignment and owned by the graded student.
+//   - error: An EntityNotFound error if the grade doesn't exist, nil otherwise.
+func (s *AuthorizationService) GradeResource(gradeID uint) (policy.Resource, error) {
+	return lookupResource(s.db.Model(&models.Grade{}).
+		Select("assignments.course_id, submissions.user_id AS owner_id").
+		Joins("JOIN submissions ON submissions.id = grades.submission_id").
+		Joins("JOIN assignments ON assignments.id = submissions.assignment_id").
+		Where("grades.id = ?", gradeID))
+}
+
+// MaterialResource describes a material for authorization.
+//
+// Parameters:
+//   - materialID: The ID of the material.
+//
+// Returns:
+//   - policy.Resource: The material, in its course.
+//   - error: An EntityNotFound error if the material doesn't exist, nil otherwise.
+func (s *AuthorizationService) MaterialResource(materialID uint) (policy.Resource, error) {
+	return lookupResource(s.db.Model(&models.Material{}).
+		Select("materials.course_id").
+		Where("materials.id = ?", materialID))
+}
+
+// QuizResource describes a quiz for authorization.
+//
+// Parameters:
+//   - quizID: The ID of the quiz.
+//
+// Returns:
+//   - policy.Resource: The quiz, in its course and owned by its creator.
+//   - error: An EntityNotFound error if the quiz doesn't exist, nil otherwise.
+func (s *AuthorizationService) QuizResource(quizID uint) (policy.Resource, error) {
+	return lookupResource(s.db.Model(&models.Quiz{}).
+		Select("quizzes.course_id, quizzes.creator_id AS owner_id").
+		Where("quizzes.id = ?", quizID))
+}
+
+// EnrollmentResource describes an enrollment for authorization.
+//
+// Parameters:
+//   - enrollmentID: The ID of the enrollment.
+//
+// Returns:
+//   - policy.Resource: The enrollment, in its course and owned by the enrolled user.
+//   - error: An EntityNotFound error if the enrollment doesn't exist, nil otherwise.
+func (s *AuthorizationService) EnrollmentResource(enrollmentID uint) (policy.Resource, error) {
+	return lookupResource(s.db.Model(&models.Enrollment{}).
+		Select("enrollments.course_id, enrollments.user_id AS owner_id").
+		Where("enrollments.id = ?", enrollmentID))
+}
+
+// authorize is Authorize within a transaction.
+func authorize(tx *gorm.DB, userID uint, perm policy.Permission, res policy.Resource) error {
+	var user models.User
+	if err := tx.Select("id", "role").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return PermissionDenied()
+		}
+		return err
+	}
+
+	if user.Role == models.RoleAdmin {
+		return nil
+	}
+
+	scope := policy.PlatformScope(perm)
+	if res.CourseID != 0 {
+		role, archived, err := courseRole(tx, userID, res.CourseID)
+		if err != nil {
+			return err
+		}
+		scope = policy.CourseScope(role, perm)
+		if archived {
+			scope = policy.ArchivedScope(role, perm)
+		}
+	}
+
+	if !policy.Allows(scope, userID, res) {
+		return PermissionDenied()
+	}
+	return nil
+}
+
+// courseRole returns the role of a user in a course: admin for its creator,
+// the role of their approved enrollment otherwise, or "" if they have none.
+// It also reports whether the course is archived.
+func courseRole(tx *gorm.DB, userID, courseID uint) (models.Role, bool, error) {
+	var course models.Course
+	if err := tx.Select("id", "creator_id", "archived_at").First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, EntityNotFound(err)
+		}
+		return "", false, err
+	}
+	archived := course.ArchivedAt != nil
+
+	if course.CreatorID == userID {
+		return models.RoleAdmin, archived, nil
+	}
+
+	var enrollment models.Enrollment
+	err := tx.Where("user_id = ? AND course_id = ? AND status = ?", userID, courseID, models.EnrollmentStatusApproved).
+		First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", archived, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return enrollment.Role, archived, nil
+}
+
+// lookupResource runs a query selecting the course_id and owner_id of a resource.
+func lookupResource(query *gorm.DB) (policy.Resource, error) {
+	var res policy.Resource
+	if err := query.Take(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return policy.Resource{}, EntityNotFound(err)
+		}
+		return policy.Resource{}, err
+	}
+	return res, nil
+}
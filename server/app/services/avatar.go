@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"server/app/imaging"
+	"server/app/models"
+
+	"gorm.io/gorm"
+)
+
+// avatarSizes are the sizes of the thumbnails of an avatar, largest first.
+// The largest is the profile picture of the user.
+var avatarSizes = []int{256, 128, 64}
+
+// maxAvatarPixels caps the pixels of an uploaded avatar, as a decoded image
+// takes far more memory than its file.
+const maxAvatarPixels = 25_000_000
+
+// AvatarService stores the avatars of users as square thumbnails in several sizes.
+type AvatarService struct {
+	db    *gorm.DB
+	store FileStore
+}
+
+// NewAvatarService creates a new AvatarService instance.
+//
+// Parameters:
+//   - db: The database connection.
+//   - store: The storage of the thumbnails.
+func NewAvatarService(db *gorm.DB, store FileStore) *AvatarService {
+	return &AvatarService{db: db, store: store}
+}
+
+// Upload replaces the avatar of a user with an image. The image must be a PNG
+// or JPEG, whatever its file name says. It is cropped to a square and stored
+// in each of the avatar sizes, re-encoded without its metadata; the largest
+// becomes the profile picture of the user. The files of the previous avatar are deleted.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - data: The image file.
+//
+// Returns:
+//   - []models.AvatarImage: The thumbnails, largest first.
+//   - error: An InvalidInput error if the image is not a PNG or JPEG, too large or
+//     unreadable, an EntityNotFound error if the user doesn't exist, nil otherwise.
+func (s *AvatarService) Upload(userID uint, data []byte) ([]models.AvatarImage, error) {
+	if _, err := findUser(s.db, userID); err != nil {
+		return nil, err
+	}
+
+	img, format, err := imaging.Decode(data, maxAvatarPixels)
+	if err != nil {
+		return nil, InvalidInput(err)
+	}
+
+	images, err := s.writeThumbnails(userID, img, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []models.AvatarImage
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.AvatarImage{}).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+		if err := tx.Create(&images).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumn("profile_pic", images[0].URL).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.deleteFiles(images)
+		return nil, err
+	}
+
+	s.deleteFiles(previous)
+	return images, nil
+}
+
+// Delete removes the avatar of a user, clearing their profile picture.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - error: An EntityNotFound error if the user doesn't exist, nil otherwise.
+func (s *AvatarService) Delete(userID uint) error {
+	if _, err := findUser(s.db, userID); err != nil {
+		return err
+	}
+
+	var previous []models.AvatarImage
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.AvatarImage{}).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumn("profile_pic", "").Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.deleteFiles(previous)
+	return nil
+}
+
+// writeThumbnails stores the thumbnails of an image in each of the avatar sizes.
+// Each upload gets new file names, so the URLs of the previous avatar don't serve the new one.
+func (s *AvatarService) writeThumbnails(userID uint, img image.Image, format imaging.Format) ([]models.AvatarImage, error) {
+	name, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]models.AvatarImage, 0, len(avatarSizes))
+	for _, size := range avatarSizes {
+		// Each size is scaled from the previous one, which is far cheaper than from a large upload.
+		thumbnail := imaging.Square(img, size)
+		img = thumbnail
+
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, thumbnail, format); err != nil {
+			s.deleteFiles(images)
+			return nil, err
+		}
+
+		path := fmt.Sprintf("avatars/%d/%s_%d%s", userID, name, size, format.Extension())
+		url, err := s.store.WriteFile(path, buf.Bytes(), format.ContentType())
+		if err != nil {
+			s.deleteFiles(images)
+			return nil, err
+		}
+		images = append(images, models.AvatarImage{UserID: userID, Size: size, FileName: path, URL: url})
+	}
+	return images, nil
+}
+
+// deleteFiles deletes the files of thumbnails as best it can. A file left behind
+// is no longer referenced by any user, so it is not worth failing the request for.
+func (s *AvatarService) deleteFiles(images []models.AvatarImage) {
+	for _, thumbnail := range images {
+		_ = deleteStoredFile(s.store, thumbnail.FileName)
+	}
+}
+
+// avatarFiles returns the storage paths of the avatar of a user.
+func avatarFiles(tx *gorm.DB, userID uint) ([]string, error) {
+	var paths []string
+	if err := tx.Model(&models.AvatarImage{}).Where("user_id = ?", userID).Pluck("file_name", &paths).Error; err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"server/app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultCatalogLimit is how many courses a page of the catalog has by default.
+	defaultCatalogLimit = 20
+	// maxCatalogLimit is how many courses a page of the catalog has at most.
+	maxCatalogLimit = 100
+)
+
+// CourseSort is an order of the course catalog.
+type CourseSort string
+
+const (
+	CourseSortRelevance   CourseSort = "relevance" // Best matches of the search first
+	CourseSortName        CourseSort = "name"
+	CourseSortStartDate   CourseSort = "start_date"
+	CourseSortCreated     CourseSort = "created"
+	CourseSortEnrollments CourseSort = "enrollments"
+)
+
+// courseSortKey is how the catalog sorts by an order: the SQL expression of
+// the sort key, its type, and whether it goes down by default.
+type courseSortKey struct {
+	expr       string
+	sqlType    string
+	descending bool
+}
+
+var courseSortKeys = map[CourseSort]courseSortKey{
+	CourseSortRelevance:   {expr: "ts_rank(courses.search_vector, websearch_to_tsquery('english', @query))", sqlType: "real", descending: true},
+	CourseSortName:        {expr: "LOWER(courses.name)", sqlType: "text"},
+	CourseSortStartDate:   {expr: "courses.start_date", sqlType: "text"},
+	CourseSortCreated:     {expr: "courses.created_at", sqlType: "timestamptz", descending: true},
+	CourseSortEnrollments: {expr: "COALESCE(counts.enrollment_count, 0)", sqlType: "bigint", descending: true},
+}
+
+// CourseFilter narrows down and orders the course catalog. Zero fields match everything.
+type CourseFilter struct {
+	Query      string // Words to search in the name and description, as in a web search
+	Category   string
+	Difficulty string
+	Active     *bool
+	Archived   bool // Lists archived courses instead of the others
+
+	// The dates are inclusive, such as 2025-09-01.
+	StartFrom string
+	StartTo   string
+	EndFrom   string
+	EndTo     string
+
+	Sort       CourseSort // Relevance with a search, newest first otherwise
+	Descending *bool      // The natural direction of the sort by default
+	Cursor     string     // NextCursor of the previous page
+	Limit      int        // 20 by default, at most 100
+}
+
+// CatalogCourse is a course of the catalog, with its number of approved enrollments.
+type CatalogCourse struct {
+	models.Course
+	EnrollmentCount int64  `json:"enrollment_count"`
+	SortKey         string `json:"-"`
+}
+
+// CoursePage is a page of the course catalog.
+type CoursePage struct {
+	Courses    []CatalogCourse `json:"courses"`
+	NextCursor string          `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// courseCursor marks where a page of the catalog ends.
+type courseCursor struct {
+	Sort       CourseSort `json:"s"`
+	Descending bool       `json:"d"`
+	Key        string     `json:"k"`
+	ID         uint       `json:"i"`
+}
+
+// SearchCourses searches the course catalog, a page at a time. The search
+// matches the words of the name and description of courses, by their stems,
+// the name weighing more. Pages follow each other with cursors rather than
+// offsets, so that courses added meanwhile don't shift them.
+//
+// Parameters:
+//   - filter: The search, filters, order and page to return.
+//
+// Returns:
+//   - *CoursePage: The page of courses, with the cursor of the next one.
+//   - error: An InvalidInput error if a date, the order or the cursor is invalid, nil otherwise.
+func (s *CourseService) SearchCourses(filter CourseFilter) (*CoursePage, error) {
+	query := strings.TrimSpace(filter.Query)
+	if filter.Sort == "" {
+		filter.Sort = CourseSortCreated
+		if query != "" {
+			filter.Sort = CourseSortRelevance
+		}
+	}
+	key, ok := courseSortKeys[filter.Sort]
+	if !ok {
+		return nil, InvalidInput(errors.New("sort must be one of relevance, name, start_date, created or enrollments"))
+	}
+	if filter.Sort == CourseSortRelevance && query == "" {
+		return nil, InvalidInput(errors.New("sorting by relevance needs a search"))
+	}
+	descending := key.descending
+	if filter.Descending != nil {
+		descending = *filter.Descending
+	}
+
+	limit := filter.Limit
+	if limit < 1 {
+		limit = defaultCatalogLimit
+	}
+	limit = min(limit, maxCatalogLimit)
+
+	counts := s.db.Model(&models.Enrollment{}).
+		Select("course_id, COUNT(*) AS enrollment_count").
+		Where("status = ?", models.EnrollmentStatusApproved).
+		Group("course_id")
+
+	db := s.db.Model(&models.Course{}).
+		Joins("LEFT JOIN (?) AS counts ON counts.course_id = courses.id", counts)
+
+	if query != "" {
+		db = db.Where("courses.search_vector @@ websearch_to_tsquery('english', ?)", query)
+	}
+	if filter.Category != "" {
+		db = db.Where("courses.category = ?", filter.Category)
+	}
+	if filter.Difficulty != "" {
+		db = db.Where("courses.difficulty = ?", filter.Difficulty)
+	}
+	if filter.Active != nil {
+		db = db.Where("courses.is_active = ?", *filter.Active)
+	}
+	if filter.Archived {
+		db = db.Where("courses.archived_at IS NOT NULL")
+	} else {
+		db = db.Where("courses.archived_at IS NULL")
+	}
+
+	// Dates are stored as text such as 2025-09-01, which sorts as the dates do.
+	// Courses without the date are left out of a range on it.
+	for _, bound := range []struct {
+		name, value, condition string
+	}{
+		{"startFrom", filter.StartFrom, "courses.start_date <> '' AND courses.start_date >= ?"},
+		{"startTo", filter.StartTo, "courses.start_date <> '' AND courses.start_date <= ?"},
+		{"endFrom", filter.EndFrom, "courses.end_date <> '' AND courses.end_date >= ?"},
+		{"endTo", filter.EndTo, "courses.end_date <> '' AND courses.end_date <= ?"},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		if _, err := time.Parse(courseDateLayout, bound.value); err != nil {
+			return nil, InvalidInput(fmt.Errorf("%s must be a date such as %s", bound.name, courseDateLayout))
+		}
+		db = db.Where(bound.condition, bound.value)
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+	args := map[string]interface{}{"query": query}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeCourseCursor(filter.Cursor)
+		if err != nil || cursor.Sort != filter.Sort || cursor.Descending != descending {
+			return nil, InvalidInput(errors.New("invalid cursor"))
+		}
+		args["key"], args["id"] = cursor.Key, cursor.ID
+		db = db.Where(fmt.Sprintf("(%s, courses.id) %s (CAST(@key AS %s), @id)", key.expr, comparison, key.sqlType), args)
+	}
+
+	// Only the relevance takes the search as an argument; GORM would add the
+	// arguments of the other sorts as a parameter the SQL never uses.
+	selection := fmt.Sprintf("courses.*, COALESCE(counts.enrollment_count, 0) AS enrollment_count, CAST(%s AS text) AS sort_key", key.expr)
+	if strings.Contains(key.expr, "@") {
+		db = db.Select(selection, args)
+	} else {
+		db = db.Select(selection)
+	}
+
+	order := clause.OrderBy{Expression: clause.NamedExpr{
+		SQL:  fmt.Sprintf("%s %s, courses.id %s", key.expr, direction, direction),
+		Vars: []interface{}{args},
+	}}
+
+	var courses []CatalogCourse
+	if err := db.Order(order).
+		Limit(limit + 1).
+		Scan(&courses).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range courses {
+		// Anyone can search the catalog, but only those who were given the code may join.
+		courses[i].InvitationCode = ""
+	}
+
+	page := &CoursePage{Courses: courses}
+	if len(courses) > limit {
+		page.Courses = courses[:limit]
+		last := page.Courses[limit-1]
+		page.NextCursor = encodeCourseCursor(courseCursor{
+			Sort:       filter.Sort,
+			Descending: descending,
+			Key:        last.SortKey,
+			ID:         last.ID,
+		})
+	}
+	if page.Courses == nil {
+		page.Courses = []CatalogCourse{}
+	}
+	return page, nil
+}
+
+func encodeCourseCursor(cursor courseCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCourseCursor(value string) (courseCursor, error) {
+	var cursor courseCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"errors"
 	"server/app/models"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -21,16 +23,21 @@ func NewCourseService(db *gorm.DB) *CourseService {
 	return &CourseService{db: db}
 }
 
+// CreateCourse creates a course with an invitation code for students and one
+// for teachers, replacing any codes it was given.
+//
+// Parameters:
+//   - c: A pointer to the course to create
+//
+// Returns:
+//   - error: An error if the creation fails, nil otherwise
 func (s *CourseService) CreateCourse(c *models.Course) error {
+	if err := issueInvitations(c); err != nil {
+		return err
+	}
 	return s.db.Create(c).Error
 }
 
-func (s *CourseService) GetCourse() ([]models.Course, error) {
-	var courses []models.Course
-	err := s.db.Find(&courses).Error
-	return courses, err
-}
-
 // GetCourseByID retrieves a course by its ID
 //
 // Returns:
@@ -92,3 +99,59 @@ func (s *CourseService) DeleteCourse(id uint) error {
 
 	return nil
 }
+
+// ArchiveCourse archives a course. An archived course is read-only: its members
+// can still read it, and its admins clone it, but only global admins can change
+// it or restore it. No one can join it.
+//
+// Parameters:
+//   - id: The ID of the course to archive
+//
+// Returns:
+//   - *models.Course: The archived course
+//   - error: An EntityNotFound error if the course doesn't exist, a CannotPerformAction
+//     error if it is already archived, nil otherwise
+func (s *CourseService) ArchiveCourse(id uint) (*models.Course, error) {
+	return s.setArchived(id, true)
+}
+
+// RestoreCourse restores an archived course, making it writable again.
+//
+// Parameters:
+//   - id: The ID of the course to restore
+//
+// Returns:
+//   - *models.Course: The restored course
+//   - error: An EntityNotFound error if the course doesn't exist, a CannotPerformAction
+//     error if it is not archived, nil otherwise
+func (s *CourseService) RestoreCourse(id uint) (*models.Course, error) {
+	return s.setArchived(id, false)
+}
+
+func (s *CourseService) setArchived(id uint, archived bool) (*models.Course, error) {
+	var course models.Course
+	if err := s.db.First(&course, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+
+	if archived == (course.ArchivedAt != nil) {
+		if archived {
+			return nil, CannotPerformAction("archive a course that is already archived")
+		}
+		return nil, CannotPerformAction("restore a course that is not archived")
+	}
+
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now()
+		archivedAt = &now
+	}
+	if err := s.db.Model(&course).Update("archived_at", archivedAt).Error; err != nil {
+		return nil, UpdateEntityFailure(err)
+	}
+	course.ArchivedAt = archivedAt
+	return &course, nil
+}
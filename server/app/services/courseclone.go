@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"server/app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobKindCourseClone is the kind of the jobs copying the content of a course into a new one.
+const JobKindCourseClone = "course.clone"
+
+// courseDateLayout is the layout of the start and end dates of courses.
+const courseDateLayout = "2006-01-02"
+
+// CloneCourseRequest describes the course a clone creates, usually the same
+// course in a new term.
+type CloneCourseRequest struct {
+	Name      string `json:"name" binding:"required"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date"`
+	// ShiftDays is how many days the due dates of assignments and the windows of
+	// quizzes move. By default, it is the gap between the start dates of the courses.
+	ShiftDays *int `json:"shift_days"`
+	// ArchiveSource archives the cloned course once the copy is done, rolling it over to the new term.
+	ArchiveSource bool `json:"archive_source"`
+}
+
+// clonePayload is the payload of a clone job.
+type clonePayload struct {
+	SourceID      uint          `json:"sourceId"`
+	CourseID      uint          `json:"courseId"`
+	ActorID       uint          `json:"actorId"`
+	Shift         time.Duration `json:"shift"`
+	ArchiveSource bool          `json:"archiveSource"`
+}
+
+// CourseCloneService copies courses into new terms: their materials, with
+// copies of their files, their assignments and quizzes, with dates moved to the
+// new term, and their question bank. Enrollments and submissions stay behind.
+type CourseCloneService struct {
+	db    *gorm.DB
+	store FileStore
+}
+
+// NewCourseCloneService creates a new CourseCloneService instance, and sets it
+// up to run the clone jobs of the job service.
+//
+// Parameters:
+//   - db: The database connection.
+//   - store: The storage of the files of materials.
+//   - jobs: The job service running the copies.
+func NewCourseCloneService(db *gorm.DB, store FileStore, jobs *JobService) *CourseCloneService {
+	s := &CourseCloneService{db: db, store: store}
+	jobs.Handle(JobKindCourseClone, s.runClone)
+	return s
+}
+
+// CloneCourse creates a new course from an existing one, and queues the job
+// copying its content. The new course is inactive, with a new invitation code,
+// and its admin is the user cloning it; the copy can be followed with CloneStatus.
+//
+// Parameters:
+//   - actorID: The ID of the user cloning the course.
+//   - sourceID: The ID of the course to clone.
+//   - req: The name and dates of the new course.
+//
+// Returns:
+//   - *models.Course: The new course, still empty.
+//   - *models.Job: The job copying the content.
+//   - error: An EntityNotFound error if the course doesn't exist, an InvalidInput error if
+//     the dates are invalid, nil otherwise.
+func (s *CourseCloneService) CloneCourse(actorID, sourceID uint, req CloneCourseRequest) (*models.Course, *models.Job, error) {
+	var source models.Course
+	if err := s.db.First(&source, sourceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, EntityNotFound(err)
+		}
+		return nil, nil, err
+	}
+
+	shift, err := cloneShift(&source, req)
+	if err != nil {
+		return nil, nil, InvalidInput(err)
+	}
+
+	course := models.Course{
+		Name:        strings.TrimSpace(req.Name),
+		Description: source.Description,
+		CreatorID:   actorID,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		MaxStudents: source.MaxStudents,
+		Difficulty:  source.Difficulty,
+		Category:    source.Category,
+		IsActive:    false,
+	}
+	if err := issueInvitations(&course); err != nil {
+		return nil, nil, err
+	}
+
+	var job *models.Job
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&course).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+
+		job, err = enqueueCourseJob(tx, JobKindCourseClone, clonePayload{
+			SourceID:      source.ID,
+			CourseID:      course.ID,
+			ActorID:       actorID,
+			Shift:         shift,
+			ArchiveSource: req.ArchiveSource,
+		}, &course.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &course, job, nil
+}
+
+// CloneStatus returns the latest job copying content into a course.
+//
+// Parameters:
+//   - courseID: The ID of the new course.
+//
+// Returns:
+//   - *models.Job: The job, with its status and progress.
+//   - error: An EntityNotFound error if no content was ever copied into the course, nil otherwise.
+func (s *CourseCloneService) CloneStatus(courseID uint) (*models.Job, error) {
+	var job models.Job
+	if err := s.db.Where("kind = ? AND course_id = ?", JobKindCourseClone, courseID).
+		Order("id DESC").
+		First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(fmt.Errorf("course %d is not a clone", courseID))
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// cloneShift returns how far the dates of a clone move.
+func cloneShift(source *models.Course, req CloneCourseRequest) (time.Duration, error) {
+	start, err := time.Parse(courseDateLayout, req.StartDate)
+	if err != nil {
+		return 0, fmt.Errorf("start_date must be a date such as %s", courseDateLayout)
+	}
+	if req.EndDate != "" {
+		end, err := time.Parse(courseDateLayout, req.EndDate)
+		if err != nil {
+			return 0, fmt.Errorf("end_date must be a date such as %s", courseDateLayout)
+		}
+		if end.Before(start) {
+			return 0, errors.New("end_date must not be before start_date")
+		}
+	}
+
+	if req.ShiftDays != nil {
+		return time.Duration(*req.ShiftDays) * 24 * time.Hour, nil
+	}
+
+	sourceStart, err := time.Parse(courseDateLayout, source.StartDate)
+	if err != nil {
+		return 0, errors.New("shift_days is required, as the course has no start date to shift from")
+	}
+	return start.Sub(sourceStart), nil
+}
+
+// courseContent is what a clone copies from a course.
+type courseContent struct {
+	materials   []models.Material
+	assignments []models.Assignment
+	quizzes     []models.Quiz
+	bank        []models.Question
+}
+
+// steps returns the number of progress steps of copying the content: one per item, and one per file.
+func (c *courseContent) steps() int {
+	steps := len(c.materials) + len(c.assignments) + len(c.quizzes) + len(c.bank)
+	for _, material := range c.materials {
+		steps += len(material.Files)
+	}
+	return steps
+}
+
+// runClone runs a clone job.
+func (s *CourseCloneService) runClone(ctx context.Context, data []byte) (string, error) {
+	var payload clonePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return "", err
+	}
+
+	var course models.Course
+	if err := s.db.First(&course, payload.CourseID).Error; err != nil {
+		return "", err
+	}
+	// A retry after the copy was committed has nothing left to do.
+	if course.ClonedFromID != nil {
+		return fmt.Sprintf("course %d already copied into course %d", *course.ClonedFromID, course.ID), nil
+	}
+
+	content, err := s.loadContent(payload.SourceID)
+	if err != nil {
+		return "", err
+	}
+
+	total, done := content.steps(), 0
+	step := func() {
+		done++
+		reportProgress(ctx, done, total)
+	}
+
+	// Files are copied first, outside of the transaction; the copies are deleted
+	// if the rows referencing them cannot be saved.
+	files, err := s.copyMaterialFiles(ctx, content.materials, step)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := copyContent(tx, &content, payload, files, step); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&course).UpdateColumn("cloned_from_id", payload.SourceID).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		if payload.ArchiveSource {
+			if err := tx.Model(&models.Course{}).Where("id = ? AND archived_at IS NULL", payload.SourceID).
+				UpdateColumn("archived_at", time.Now()).Error; err != nil {
+				return UpdateEntityFailure(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, file := range files {
+			_ = deleteStoredFile(s.store, file.FileName)
+		}
+		return "", err
+	}
+
+	return fmt.Sprintf("course %d copied into course %d: %d materials, %d assignments, %d quizzes, %d bank questions",
+		payload.SourceID, course.ID, len(content.materials), len(content.assignments), len(content.quizzes),
+		len(content.bank)), nil
+}
+
+// loadContent loads what a clone copies from a course.
+func (s *CourseCloneService) loadContent(courseID uint) (courseContent, error) {
+	var content courseContent
+	if err := s.db.Preload("Files", orderByID).Where("course_id = ?", courseID).
+		Order("id ASC").Find(&content.materials).Error; err != nil {
+		return content, err
+	}
+	if err := s.db.Where("course_id = ?", courseID).Order("id ASC").Find(&content.assignments).Error; err != nil {
+		return content, err
+	}
+	if err := s.db.Preload("Questions", orderByID).Preload("Questions.Options", orderByID).
+		Preload("DrawRules", orderByID).
+		Where("course_id = ?", courseID).Order("id ASC").Find(&content.quizzes).Error; err != nil {
+		return content, err
+	}
+	if err := s.db.Preload("Options", orderByID).Preload("Tags", orderByID).
+		Where("course_id = ? AND quiz_id IS NULL", courseID).Order("id ASC").Find(&content.bank).Error; err != nil {
+		return content, err
+	}
+	return content, nil
+}
+
+// copyMaterialFiles stores a copy of each file of the materials, so that
+// deleting a material of one course leaves the other's files be. It returns the
+// copies by the ID of the file they copy.
+func (s *CourseCloneService) copyMaterialFiles(ctx context.Context, materials []models.Material, step func()) (map[uint]models.BaseFile, error) {
+	copies := make(map[uint]models.BaseFile)
+	for _, material := range materials {
+		for _, file := range material.Files {
+			if err := ctx.Err(); err != nil {
+				s.deleteCopies(copies)
+				return nil, err
+			}
+
+			copied, err := s.copyFile(file.BaseFile)
+			if err != nil {
+				s.deleteCopies(copies)
+				return nil, fmt.Errorf("copying file %s: %w", file.FileName, err)
+			}
+			copies[file.ID] = copied
+			step()
+		}
+	}
+	return copies, nil
+}
+
+// copyFile stores a copy of a file under a new name.
+func (s *CourseCloneService) copyFile(file models.BaseFile) (models.BaseFile, error) {
+	reader, err := s.store.OpenFile(file.FileName)
+	if err != nil {
+		return models.BaseFile{}, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return models.BaseFile{}, err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return models.BaseFile{}, err
+	}
+
+	name := path.Join(path.Dir(file.FileName), token+"_"+path.Base(file.FileName))
+	contentType := mime.TypeByExtension("." + string(file.Extension))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	url, err := s.store.WriteFile(name, data, contentType)
+	if err != nil {
+		return models.BaseFile{}, err
+	}
+	return models.BaseFile{FileName: name, FileUrl: url, Extension: file.Extension, UserFileName: file.UserFileName}, nil
+}
+
+func (s *CourseCloneService) deleteCopies(copies map[uint]models.BaseFile) {
+	for _, file := range copies {
+		_ = deleteStoredFile(s.store, file.FileName)
+	}
+}
+
+// copyContent saves the copies of the content of a course into the new course.
+func copyContent(tx *gorm.DB, content *courseContent, payload clonePayload, files map[uint]models.BaseFile, step func()) error {
+	shift := func(t time.Time) time.Time { return t.Add(payload.Shift) }
+
+	for _, material := range content.materials {
+		copied := models.Material{Title: material.Title, Description: material.Description, CourseId: payload.CourseID}
+		for _, file := range material.Files {
+			copied.Files = append(copied.Files, models.MaterialFile{BaseFile: files[file.ID]})
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+		step()
+	}
+
+	for _, assignment := range content.assignments {
+		copied := models.Assignment{
+			CourseID:              payload.CourseID,
+			Title:                 assignment.Title,
+			DueDate:               shift(assignment.DueDate),
+			Instructions:          assignment.Instructions,
+			AllowedFileExtensions: assignment.AllowedFileExtensions,
+			IsPublished:           assignment.IsPublished,
+		}
+		if assignment.PublishDate != nil {
+			publishDate := shift(*assignment.PublishDate)
+			copied.PublishDate = &publishDate
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+		step()
+	}
+
+	for _, quiz := range content.quizzes {
+		copied := models.Quiz{
+			Title:            quiz.Title,
+			Description:      quiz.Description,
+			CourseID:         payload.CourseID,
+			StartTime:        shift(quiz.StartTime),
+			EndTime:          shift(quiz.EndTime),
+			Duration:         quiz.Duration,
+			ShuffleQuestions: quiz.ShuffleQuestions,
+			ShuffleOptions:   quiz.ShuffleOptions,
+			ShowResults:      quiz.ShowResults,
+			ScoringPolicy:    quiz.ScoringPolicy,
+			MaxAttempts:      quiz.MaxAttempts,
+			AttemptScoring:   quiz.AttemptScoring,
+			CreatorID:        payload.ActorID,
+		}
+		for _, question := range quiz.Questions {
+			copied.Questions = append(copied.Questions, copyQuestion(question, nil))
+		}
+		for _, rule := range quiz.DrawRules {
+			copied.DrawRules = append(copied.DrawRules, models.QuizDrawRule{Tag: rule.Tag, Count: rule.Count})
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+		// GORM leaves out false for a column defaulting to true.
+		if !quiz.ShowResults {
+			if err := tx.Model(&copied).UpdateColumn("show_results", false).Error; err != nil {
+				return UpdateEntityFailure(err)
+			}
+		}
+		step()
+	}
+
+	for _, question := range content.bank {
+		copied := copyQuestion(question, &payload.CourseID)
+		if err := tx.Create(&copied).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+		step()
+	}
+	return nil
+}
+
+// copyQuestion returns a copy of a question with its options and tags, in the
+// question bank of a course if courseID is set, or for a quiz otherwise.
+func copyQuestion(question models.Question, courseID *uint) models.Question {
+	copied := models.Question{
+		CourseID:        courseID,
+		Title:           question.Title,
+		Description:     question.Description,
+		Type:            question.Type,
+		Points:          question.Points,
+		NumericAnswer:   question.NumericAnswer,
+		Tolerance:       question.Tolerance,
+		AcceptedAnswers: question.AcceptedAnswers,
+	}
+	for _, option := range question.Options {
+		copied.Options = append(copied.Options, models.Option{
+			Text:      option.Text,
+			IsCorrect: option.IsCorrect,
+			Position:  option.Position,
+		})
+	}
+	for _, tag := range question.Tags {
+		copied.Tags = append(copied.Tags, models.QuestionTag{Name: tag.Name})
+	}
+	return copied
+}
@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"server/app/models"
+	"server/app/policy"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EnrollmentService struct {
@@ -17,7 +20,10 @@ func NewEnrollmentService(db *gorm.DB) *EnrollmentService {
 }
 
 // JoinCourseByCode enrolls a user in a course using an invitation code.
-// Initially it sets the enrollment status to pending.
+// Initially it sets the enrollment status to pending. The code must be the
+// course's code for the role, neither disabled, expired nor used up, and a
+// course with a maximum number of students takes no more students once it has
+// them, counting the pending ones.
 //
 // Parameters:
 //   - userID: The ID of the user to enroll.
@@ -25,36 +31,123 @@ func NewEnrollmentService(db *gorm.DB) *EnrollmentService {
 //   - role: The role the user will have in the course.
 //
 // Returns:
-//   - error: An error if the enrollment process fails, nil otherwise.
+//   - error: An InvalidInput error if the code is unknown or for another role, a
+//     CannotPerformAction error if it cannot be used, the course is archived or full
+//     or the user is already enrolled, nil otherwise.
 func (s *EnrollmentService) JoinCourseByCode(userID uint, invitationCode string, role models.Role) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		var course models.Course
-		if err := s.db.Where("invitation_code = ?", invitationCode).First(&course).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("invalid invitation code")
-			}
+		// The invitation stays locked until the user is enrolled, so concurrent
+		// joins with the same code count its uses and the students one at a time.
+		invitation, course, err := s.invitationByCode(tx, invitationCode)
+		if err != nil {
+			return err
+		}
+		if invitation.Role != role {
+			return InvalidInput(fmt.Errorf("the invitation code is for the %s role", invitation.Role))
+		}
+		if err := checkUsable(invitation, time.Now()); err != nil {
 			return err
 		}
 
-		enrollment := models.Enrollment{
-			UserID:   userID,
-			CourseID: course.ID,
-			Role:     role,
-			Status:   models.EnrollmentStatusPending,
+		var count int64
+		if err := tx.Model(&models.Enrollment{}).
+			Where("user_id = ? AND course_id = ? AND status <> ?", userID, course.ID, models.EnrollmentStatusRejected).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return CannotPerformAction("join a course you are already enrolled in")
 		}
 
-		if err := s.db.Create(&enrollment).Error; err != nil {
-			return CreateEntityFailure(err)
+		if role == models.RoleStudent && course.MaxStudents > 0 {
+			if err := tx.Model(&models.Enrollment{}).
+				Where("course_id = ? AND role = ? AND status <> ?", course.ID, models.RoleStudent, models.EnrollmentStatusRejected).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(course.MaxStudents) {
+				return CannotPerformAction("join a course that is full")
+			}
 		}
 
+		if _, err := s.enroll(tx, userID, course.ID, role, models.EnrollmentStatusPending); err != nil {
+			return err
+		}
+		if err := tx.Model(invitation).UpdateColumn("uses", gorm.Expr("uses + 1")).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
 		return nil
 	})
 }
 
+// courseByCode finds the course with the given invitation code, whatever the
+// rules of the code. Archived courses take no one in.
+func (s *EnrollmentService) courseByCode(tx *gorm.DB, invitationCode string) (*models.Course, error) {
+	_, course, err := s.invitationByCode(tx, invitationCode)
+	return course, err
+}
+
+// invitationByCode finds and locks the invitation with the given code, and its
+// course. Archived courses take no one in.
+//
+// Courses created before their codes were managed only have the student code on
+// the course; its invitation is issued the first time the code is used.
+func (s *EnrollmentService) invitationByCode(tx *gorm.DB, invitationCode string) (*models.CourseInvitation, *models.Course, error) {
+	errInvalidCode := InvalidInput(errors.New("invalid invitation code"))
+
+	var invitation models.CourseInvitation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", invitationCode).First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var legacy models.Course
+		if err := tx.Where("invitation_code = ?", invitationCode).First(&legacy).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, errInvalidCode
+			}
+			return nil, nil, err
+		}
+
+		invitation = models.CourseInvitation{CourseID: legacy.ID, Role: models.RoleStudent, Code: invitationCode}
+		err = tx.Create(&invitation).Error
+		if err != nil {
+			err = CreateEntityFailure(err)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var course models.Course
+	if err := tx.First(&course, invitation.CourseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errInvalidCode // The course was deleted
+		}
+		return nil, nil, err
+	}
+	if course.ArchivedAt != nil {
+		return nil, nil, CannotPerformAction("join an archived course")
+	}
+	return &invitation, &course, nil
+}
+
+// enroll creates the enrollment of a user in a course.
+func (s *EnrollmentService) enroll(tx *gorm.DB, userID, courseID uint, role models.Role, status models.EnrollmentStatus) (*models.Enrollment, error) {
+	enrollment := models.Enrollment{
+		UserID:   userID,
+		CourseID: courseID,
+		Role:     role,
+		Status:   status,
+	}
+
+	if err := tx.Create(&enrollment).Error; err != nil {
+		return nil, CreateEntityFailure(err)
+	}
+	return &enrollment, nil
+}
+
 // ApproveEnrolment approves a pending enrollment request for a course.
 //
 // Parameters:
-//   - adminId: The ID of the admin (course creator) approving the enrollment.
+//   - adminId: The ID of the admin of the course approving the enrollment.
 //   - enrollmentId: The ID of the enrollment to be approved.
 //
 // Returns:
@@ -66,19 +159,15 @@ func (s *EnrollmentService) ApproveEnrolment(adminId, enrollmentId uint) error {
 
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		var enrollment models.Enrollment
-		if err := tx.Preload(models.CoursesTable).Where("id = ?", enrollmentId).First(&enrollment).Error; err != nil {
+		if err := tx.Where("id = ?", enrollmentId).First(&enrollment).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return EntityNotFound(fmt.Errorf("enrollment with id %d not found", enrollmentId))
 			}
 			return fmt.Errorf("error fetching enrollment: %w", err)
 		}
 
-		if enrollment.Course.ID == 0 {
-			return errors.New("course information not loaded")
-		}
-
-		if adminId != enrollment.Course.CreatorID {
-			return errors.New("only course creator can approve enrollments")
+		if err := authorize(tx, adminId, policy.EnrollmentManage, policy.Resource{CourseID: enrollment.CourseID}); err != nil {
+			return err
 		}
 
 		if enrollment.Status != models.EnrollmentStatusPending {
@@ -97,7 +186,7 @@ func (s *EnrollmentService) ApproveEnrolment(adminId, enrollmentId uint) error {
 // RejectEnrolment rejects a pending enrollment request for a course.
 //
 // Parameters:
-//   - adminId: The ID of the admin (course creator) rejecting the enrollment.
+//   - adminId: The ID of the admin of the course rejecting the enrollment.
 //   - enrollmentId: The ID of the enrollment to be rejected.
 //
 // Returns:
@@ -109,10 +198,7 @@ func (s *EnrollmentService) RejectEnrolment(adminId, enrollmentId uint) error {
 
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		var enrollment models.Enrollment
-		if err := tx.Preload(models.CoursesTable).
-			Where("id = ?", enrollmentId).
-			First(&enrollment).Error; err != nil {
-
+		if err := tx.Where("id = ?", enrollmentId).First(&enrollment).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return EntityNotFound(fmt.Errorf("enrollment with id %d not found", enrollmentId))
 			}
@@ -120,8 +206,7 @@ func (s *EnrollmentService) RejectEnrolment(adminId, enrollmentId uint) error {
 			return fmt.Errorf("error fetching enrollment: %w", err)
 		}
 
-		ok, err := s.IsAdmin(adminId, enrollment.CourseID)
-		if err != nil || !ok {
+		if err := authorize(tx, adminId, policy.EnrollmentManage, policy.Resource{CourseID: enrollment.CourseID}); err != nil {
 			return err
 		}
 
@@ -174,29 +259,21 @@ func (s *EnrollmentService) GetPendingEnrollments(courseID uint) ([]models.Enrol
 	return enrollments, nil
 }
 
-// IsAdmin checks if the given user is the admin (creator) of the specified course.
+// IsAdmin checks if the given user may manage the enrollments of the specified
+// course, which the admins of the course and global admins can.
 //
 // Parameters:
 //   - adminId: The ID of the user to check for admin status.
 //   - courseId: The ID of the course to check against.
 //
 // Returns:
-//   - bool: True if the user is the admin of the course, false otherwise.
+//   - bool: True if the user is an admin of the course, false otherwise.
 //   - error: An error if the check fails, nil otherwise. Possible errors include:
 //   - EntityNotFoundError: If the course is not found.
-//   - PermissionDeniedError: If the user is not the admin of the course.
+//   - PermissionDeniedError: If the user is not an admin of the course.
 func (s *EnrollmentService) IsAdmin(adminId, courseId uint) (bool, error) {
-	var course models.Course
-	if err := s.db.Where("id = ?", courseId).First(&course).Error; err != nil {
-		return false, EntityNotFound(err)
-	}
-
-	if course.ID == 0 {
-		return false, EntityNotFound(fmt.Errorf("course with id %d not found", courseId))
-	}
-
-	if adminId != course.CreatorID {
-		return false, PermissionDenied()
+	if err := authorize(s.db, adminId, policy.EnrollmentManage, policy.Resource{CourseID: courseId}); err != nil {
+		return false, err
 	}
 
 	return true, nil
@@ -1,6 +1,9 @@
 package services
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type EntityNotFoundError struct {
 	err error
@@ -62,6 +65,18 @@ func (e DeleteFileFailureError) Error() string {
 	return fmt.Errorf("error deleting file: %v", e.err).Error()
 }
 
+type InvalidInputError struct {
+	err error
+}
+
+func InvalidInput(err error) InvalidInputError {
+	return InvalidInputError{err: err}
+}
+
+func (e InvalidInputError) Error() string {
+	return fmt.Errorf("invalid input: %v", e.err).Error()
+}
+
 type PermissionDeniedError struct {
 }
 
@@ -84,3 +99,15 @@ func CannotPerformAction(action string) CannotPerformActionError {
 func (e CannotPerformActionError) Error() string {
 	return fmt.Sprintf("cannot perform action: %v", e.action)
 }
+
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func TooManyAttempts(retryAfter time.Duration) TooManyAttemptsError {
+	return TooManyAttemptsError{RetryAfter: retryAfter}
+}
+
+func (e TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many failed attempts, try again in %v", (e.RetryAfter + time.Second - 1).Truncate(time.Second))
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"path/filepath"
 	"server/app/firebase"
@@ -11,6 +12,21 @@ import (
 	"sync"
 )
 
+// FileStore is the storage of uploaded files, as firebase.CloudStorage provides it.
+type FileStore interface {
+	WriteFile(path string, data []byte, contentType string) (string, error)
+	OpenFile(path string) (io.ReadCloser, error)
+	DeleteFile(path string) error
+}
+
+// deleteStoredFile deletes a file from a store. A file that is already gone counts as deleted.
+func deleteStoredFile(store FileStore, path string) error {
+	if err := store.DeleteFile(path); err != nil && !errors.Is(err, firebase.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
 type FileOptions struct {
 	Path              string
 	ExtensionsAllowed []string
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"server/app/models"
+	"server/app/policy"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GradingService struct {
+	db *gorm.DB
+}
+
+func NewGradingService(db *gorm.DB) *GradingService {
+	return &GradingService{db: db}
+}
+
+// GradingItem is an answer waiting in the manual grading queue of a quiz.
+type GradingItem struct {
+	AnswerID      uint      `json:"answerId"`
+	AttemptID     uint      `json:"attemptId"`
+	QuestionID    uint      `json:"questionId"`
+	QuestionTitle string    `json:"questionTitle"`
+	MaxPoints     int       `json:"maxPoints"`
+	StudentID     uint      `json:"studentId"`
+	StudentName   string    `json:"studentName"`
+	TextAnswer    string    `json:"textAnswer"`
+	SubmittedAt   time.Time `json:"submittedAt"`
+}
+
+// GradeInput is the grade a teacher gives to a single answer.
+type GradeInput struct {
+	Points   float64 `json:"points"`
+	Feedback string  `json:"feedback"`
+}
+
+// GetGradingQueue lists the answers of a quiz that still have to be graded,
+// oldest first.
+//
+// Parameters:
+//   - userID: The ID of the user requesting the queue.
+//   - quizID: The ID of the quiz.
+//
+// Returns:
+//   - []GradingItem: The ungraded answers of the quiz.
+//   - error: An error if the quiz is not found or the user may not manage it, nil otherwise.
+func (s *GradingService) GetGradingQueue(userID, quizID uint) ([]GradingItem, error) {
+	if _, err := loadManagedQuiz(s.db, userID, quizID); err != nil {
+		return nil, err
+	}
+
+	var answers []models.Answer
+	if err := s.db.Preload("Question").
+		Preload("Submission.User").
+		Joins("JOIN quiz_submissions ON quiz_submissions.id = answers.submission_id").
+		Where("quiz_submissions.quiz_id = ? AND answers.pending_review = ?", quizID, true).
+		Order("answers.id ASC").
+		Find(&answers).Error; err != nil {
+		return nil, err
+	}
+
+	queue := make([]GradingItem, 0, len(answers))
+	for _, answer := range answers {
+		student := answer.Submission.User
+		queue = append(queue, GradingItem{
+			AnswerID:      answer.ID,
+			AttemptID:     answer.SubmissionID,
+			QuestionID:    answer.QuestionID,
+			QuestionTitle: answer.Question.Title,
+			MaxPoints:     answer.Question.Points,
+			StudentID:     student.ID,
+			StudentName:   student.FirstName + " " + student.LastName,
+			TextAnswer:    answer.TextAnswer,
+			SubmittedAt:   answer.Submission.EndTime,
+		})
+	}
+
+	return queue, nil
+}
+
+// GradeAnswer awards points and feedback to an answer of a manually graded
+// question. Answers that were already graded can be graded again. The score of
+// the attempt is recomputed, and once none of its answers are pending review
+// the attempt is marked as submitted.
+//
+// Parameters:
+//   - userID: The ID of the user grading the answer.
+//   - quizID: The ID of the quiz the answer belongs to.
+//   - answerID: The ID of the answer.
+//   - input: The points and feedback to give.
+//
+// Returns:
+//   - *models.Answer: The graded answer.
+//   - error: An error if the user may not manage the quiz or the answer cannot be graded, nil otherwise.
+func (s *GradingService) GradeAnswer(userID, quizID, answerID uint, input GradeInput) (*models.Answer, error) {
+	var answer models.Answer
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := loadManagedQuiz(tx, userID, quizID); err != nil {
+			return err
+		}
+
+		if err := tx.Preload("Question").First(&answer, answerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return EntityNotFound(err)
+			}
+			return err
+		}
+
+		var attempt models.QuizSubmission
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&attempt, answer.SubmissionID).Error; err != nil {
+			return err
+		}
+
+		if attempt.QuizID != quizID {
+			return EntityNotFound(fmt.Errorf("answer %d does not belong to quiz %d", answerID, quizID))
+		}
+
+		if attempt.Status == models.AttemptStatusInProgress {
+			return CannotPerformAction("grade an attempt that is still in progress")
+		}
+
+		if !answer.Question.Type.NeedsManualGrading() {
+			return CannotPerformAction("grade an automatically scored answer")
+		}
+
+		if input.Points < 0 || input.Points > float64(answer.Question.Points) {
+			return InvalidInput(fmt.Errorf("points must be between 0 and %d", answer.Question.Points))
+		}
+
+		now := time.Now()
+		answer.PointsAwarded = roundPoints(input.Points)
+		answer.Feedback = input.Feedback
+		answer.PendingReview = false
+		answer.GradedByID = &userID
+		answer.GradedAt = &now
+
+		if err := tx.Model(&answer).
+			Select("PointsAwarded", "Feedback", "PendingReview", "GradedByID", "GradedAt").
+			Updates(&answer).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+
+		return rescoreAttempt(tx, &attempt)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &answer, nil
+}
+
+// rescoreAttempt recomputes the score of a submitted attempt from its answers
+// and marks it as submitted once no answer is pending review.
+func rescoreAttempt(tx *gorm.DB, attempt *models.QuizSubmission) error {
+	var answers []models.Answer
+	if err := tx.Where("submission_id = ?", attempt.ID).Find(&answers).Error; err != nil {
+		return err
+	}
+
+	score := 0.0
+	attempt.Status = models.AttemptStatusSubmitted
+	for _, answer := range answers {
+		score += answer.PointsAwarded
+		if answer.PendingReview {
+			attempt.Status = models.AttemptStatusPendingReview
+		}
+	}
+	attempt.Score = roundPoints(score)
+
+	if err := tx.Model(attempt).Select("Status", "Score").Updates(attempt).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+	return nil
+}
+
+// loadManagedQuiz fetches a quiz and makes sure the user may manage it.
+func loadManagedQuiz(db *gorm.DB, userID, quizID uint) (*models.Quiz, error) {
+	var quiz models.Quiz
+	if err := db.First(&quiz, quizID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+
+	res := policy.Resource{CourseID: quiz.CourseID, OwnerID: quiz.CreatorID}
+	if err := authorize(db, userID, policy.QuizManage, res); err != nil {
+		return nil, err
+	}
+
+	return &quiz, nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"server/app/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// invitationRoles are the roles a course has an invitation code for.
+var invitationRoles = []models.Role{models.RoleStudent, models.RoleTeacher}
+
+// InvitationSettings are the rules of an invitation code.
+type InvitationSettings struct {
+	ExpiresAt *time.Time `json:"expires_at"` // Never expires if nil
+	MaxUses   int        `json:"max_uses"`   // 0 for no limit
+	Disabled  bool       `json:"disabled"`
+}
+
+// InvitationService manages the invitation codes with which users join courses.
+type InvitationService struct {
+	db *gorm.DB
+}
+
+// NewInvitationService creates a new InvitationService instance.
+//
+// Parameters:
+//   - db: The database connection.
+func NewInvitationService(db *gorm.DB) *InvitationService {
+	return &InvitationService{db: db}
+}
+
+// ListInvitations retrieves the invitation codes of a course.
+//
+// Parameters:
+//   - courseID: The ID of the course.
+//
+// Returns:
+//   - []models.CourseInvitation: The invitations, the student one first.
+//   - error: An EntityNotFound error if the course doesn't exist, nil otherwise.
+func (s *InvitationService) ListInvitations(courseID uint) ([]models.CourseInvitation, error) {
+	if _, err := findCourse(s.db, courseID); err != nil {
+		return nil, err
+	}
+
+	var invitations []models.CourseInvitation
+	if err := s.db.Where("course_id = ?", courseID).
+		Order("role DESC"). // student before teacher
+		Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// RotateInvitation replaces the invitation code of a course for a role with a
+// new one, issuing it if the course has none. The previous code stops working
+// at once; the new one starts with no uses and enabled, keeping the expiry and
+// maximum number of uses.
+//
+// Parameters:
+//   - courseID: The ID of the course.
+//   - role: The role the code is for, student or teacher.
+//
+// Returns:
+//   - *models.CourseInvitation: The invitation with its new code.
+//   - error: An InvalidInput error if the role is invalid, an EntityNotFound error
+//     if the course doesn't exist, nil otherwise.
+func (s *InvitationService) RotateInvitation(courseID uint, role models.Role) (*models.CourseInvitation, error) {
+	if err := validateInvitationRole(role); err != nil {
+		return nil, err
+	}
+
+	var invitation models.CourseInvitation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := findCourse(tx, courseID); err != nil {
+			return err
+		}
+
+		code, err := newInvitationCode()
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("course_id = ? AND role = ?", courseID, role).First(&invitation).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			invitation = models.CourseInvitation{CourseID: courseID, Role: role, Code: code}
+			if err := tx.Create(&invitation).Error; err != nil {
+				return CreateEntityFailure(err)
+			}
+		case err != nil:
+			return err
+		default:
+			invitation.Code, invitation.Uses, invitation.DisabledAt = code, 0, nil
+			if err := tx.Model(&invitation).Select("code", "uses", "disabled_at").Updates(&invitation).Error; err != nil {
+				return UpdateEntityFailure(err)
+			}
+		}
+
+		if role == models.RoleStudent {
+			if err := tx.Model(&models.Course{}).Where("id = ?", courseID).
+				UpdateColumn("invitation_code", code).Error; err != nil {
+				return UpdateEntityFailure(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// UpdateInvitation sets the rules of the invitation code of a course for a role.
+// A disabled code stays disabled until it is enabled again or rotated.
+//
+// Parameters:
+//   - courseID: The ID of the course.
+//   - role: The role the code is for, student or teacher.
+//   - settings: The expiry, maximum number of uses and whether the code is disabled.
+//
+// Returns:
+//   - *models.CourseInvitation: The updated invitation.
+//   - error: An InvalidInput error if the role or a setting is invalid, an EntityNotFound
+//     error if the course has no code for the role, nil otherwise.
+func (s *InvitationService) UpdateInvitation(courseID uint, role models.Role, settings InvitationSettings) (*models.CourseInvitation, error) {
+	if err := validateInvitationRole(role); err != nil {
+		return nil, err
+	}
+	if settings.MaxUses < 0 {
+		return nil, InvalidInput(errors.New("max_uses cannot be negative"))
+	}
+	if settings.ExpiresAt != nil && !settings.ExpiresAt.After(time.Now()) {
+		return nil, InvalidInput(errors.New("expires_at must be in the future"))
+	}
+
+	var invitation models.CourseInvitation
+	if err := s.db.Where("course_id = ? AND role = ?", courseID, role).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(fmt.Errorf("course %d has no %s invitation code", courseID, role))
+		}
+		return nil, err
+	}
+
+	invitation.ExpiresAt, invitation.MaxUses = settings.ExpiresAt, settings.MaxUses
+	switch {
+	case !settings.Disabled:
+		invitation.DisabledAt = nil
+	case invitation.DisabledAt == nil:
+		now := time.Now()
+		invitation.DisabledAt = &now
+	}
+
+	if err := s.db.Model(&invitation).Select("expires_at", "max_uses", "disabled_at").Updates(&invitation).Error; err != nil {
+		return nil, UpdateEntityFailure(err)
+	}
+	return &invitation, nil
+}
+
+// checkUsable checks that an invitation can still be joined with.
+func checkUsable(invitation *models.CourseInvitation, now time.Time) error {
+	switch {
+	case invitation.DisabledAt != nil:
+		return CannotPerformAction("join with a disabled invitation code")
+	case invitation.ExpiresAt != nil && !now.Before(*invitation.ExpiresAt):
+		return CannotPerformAction("join with an expired invitation code")
+	case invitation.MaxUses > 0 && invitation.Uses >= invitation.MaxUses:
+		return CannotPerformAction("join with an invitation code that is used up")
+	}
+	return nil
+}
+
+// issueInvitations gives a new course an invitation code for each role. It
+// runs before the course is created, which creates the invitations with it.
+func issueInvitations(course *models.Course) error {
+	course.Invitations = make([]models.CourseInvitation, 0, len(invitationRoles))
+	for _, role := range invitationRoles {
+		code, err := newInvitationCode()
+		if err != nil {
+			return err
+		}
+		if role == models.RoleStudent {
+			course.InvitationCode = code
+		}
+		course.Invitations = append(course.Invitations, models.CourseInvitation{Role: role, Code: code})
+	}
+	return nil
+}
+
+func validateInvitationRole(role models.Role) error {
+	for _, r := range invitationRoles {
+		if role == r {
+			return nil
+		}
+	}
+	return InvalidInput(fmt.Errorf("invitation codes are for students or teachers, not %q", role))
+}
+
+// findCourse retrieves a course, or an EntityNotFound error if it doesn't exist.
+func findCourse(tx *gorm.DB, courseID uint) (*models.Course, error) {
+	var course models.Course
+	if err := tx.First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(fmt.Errorf("course with id %d not found", courseID))
+		}
+		return nil, err
+	}
+	return &course, nil
+}
+
+// invitationCodeAlphabet leaves out letters and digits that are easily mistaken for one another.
+const invitationCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newInvitationCode returns a random invitation code such as "K7QM-3XRP".
+func newInvitationCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, 0, len(buf)+1)
+	for i, b := range buf {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		// 256 is a multiple of the 32 letters, so each is equally likely.
+		code = append(code, invitationCodeAlphabet[int(b)%len(invitationCodeAlphabet)])
+	}
+	return string(code), nil
+}
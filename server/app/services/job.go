@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"server/app/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxJobAttempts is how many times a job runs before it is given up.
+	maxJobAttempts = 3
+	// jobRetryDelay is the wait before the second attempt of a failed job, doubling after each attempt.
+	jobRetryDelay = time.Minute
+	// staleJobAfter is how long a job may run before it is taken to have died with its worker.
+	staleJobAfter = time.Hour
+)
+
+// JobHandler runs a job of one kind, with the JSON payload it was queued with.
+// What it returns on success is kept as the result of the job.
+type JobHandler func(ctx context.Context, payload []byte) (string, error)
+
+// JobService runs the background jobs queued in the database. Several workers,
+// in one process or many, can run jobs at once; each job is claimed by one.
+type JobService struct {
+	db       *gorm.DB
+	handlers map[string]JobHandler
+}
+
+// NewJobService creates a new JobService instance.
+//
+// Parameters:
+//   - db: The database connection.
+func NewJobService(db *gorm.DB) *JobService {
+	return &JobService{db: db, handlers: make(map[string]JobHandler)}
+}
+
+// Handle sets the handler running the jobs of a kind. Handlers are set up
+// before the workers start, by the services queuing the jobs.
+//
+// Parameters:
+//   - kind: The kind of job.
+//   - handler: The handler of the jobs.
+func (s *JobService) Handle(kind string, handler JobHandler) {
+	s.handlers[kind] = handler
+}
+
+// Get retrieves a job by ID.
+//
+// Parameters:
+//   - jobID: The ID of the job.
+//
+// Returns:
+//   - *models.Job: The job.
+//   - error: An EntityNotFound error if the job doesn't exist, nil otherwise.
+func (s *JobService) Get(jobID uint) (*models.Job, error) {
+	var job models.Job
+	if err := s.db.First(&job, jobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Run runs due jobs until the context is done, checking for new ones at the
+// given interval when the queue is empty.
+//
+// Parameters:
+//   - ctx: The context stopping the worker.
+//   - interval: How often to check the queue when it is empty.
+func (s *JobService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		ran, err := s.RunNext(ctx)
+		if err != nil {
+			log.Printf("jobs: %v", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunNext claims the next due job and runs it. A job that fails is retried
+// later, until it has run maxJobAttempts times.
+//
+// Parameters:
+//   - ctx: The context of the job.
+//
+// Returns:
+//   - bool: Whether there was a job to run.
+//   - error: An error if the queue cannot be read or updated, nil otherwise. The
+//     failure of the job itself is recorded on the job.
+func (s *JobService) RunNext(ctx context.Context) (bool, error) {
+	job, err := s.claim()
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	var result string
+	handler, ok := s.handlers[job.Kind]
+	if !ok {
+		err = fmt.Errorf("no handler for jobs of kind %q", job.Kind)
+	} else {
+		progress := jobProgress{db: s.db, jobID: job.ID}
+		result, err = runJob(context.WithValue(ctx, jobProgressKey{}, progress), handler, job)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"finished_at": now, "result": result, "last_error": ""}
+	switch {
+	case err == nil:
+		updates["status"] = models.JobStatusSucceeded
+		updates["progress"] = 100
+	case job.Attempts >= maxJobAttempts || !ok:
+		updates["status"] = models.JobStatusFailed
+		updates["last_error"] = err.Error()
+	default:
+		updates["status"] = models.JobStatusPending
+		updates["last_error"] = err.Error()
+		updates["run_at"] = now.Add(jobRetryDelay << (job.Attempts - 1))
+	}
+
+	if err := s.db.Model(job).Updates(updates).Error; err != nil {
+		return true, UpdateEntityFailure(err)
+	}
+	return true, nil
+}
+
+// claim marks the next due job as running and returns it, or nil if none is due.
+func (s *JobService) claim() (*models.Job, error) {
+	var job models.Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// SKIP LOCKED lets concurrent workers each claim a different job.
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND started_at < ?)",
+				models.JobStatusPending, now, models.JobStatusRunning, now.Add(-staleJobAfter)).
+			Order("run_at ASC, id ASC").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.StartedAt = &now
+		return tx.Model(&job).Select("status", "attempts", "started_at").Updates(&job).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// runJob runs a job, turning a panic of its handler into an error.
+func runJob(ctx context.Context, handler JobHandler, job *models.Job) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, []byte(job.Payload))
+}
+
+// jobProgressKey is the context key of the jobProgress of a running job.
+type jobProgressKey struct{}
+
+// jobProgress records the progress of a running job.
+type jobProgress struct {
+	db    *gorm.DB
+	jobID uint
+}
+
+// reportProgress records that the job running with the context has done done
+// steps out of total. The progress is saved at once, outside of any transaction
+// of the job, so it can be followed while the job runs. Outside of a job, it does nothing.
+func reportProgress(ctx context.Context, done, total int) {
+	progress, ok := ctx.Value(jobProgressKey{}).(jobProgress)
+	if !ok || total <= 0 {
+		return
+	}
+
+	percent := min(done*100/total, 99) // 100 once the job has succeeded
+	// The progress is only informative; failing to save it doesn't fail the job.
+	_ = progress.db.Model(&models.Job{}).Where("id = ?", progress.jobID).UpdateColumn("progress", percent).Error
+}
+
+// enqueueJob queues a job of a kind with its payload, due at once.
+func enqueueJob(tx *gorm.DB, kind string, payload interface{}) (*models.Job, error) {
+	return enqueueCourseJob(tx, kind, payload, nil)
+}
+
+// enqueueCourseJob queues a job working on a course, due at once.
+func enqueueCourseJob(tx *gorm.DB, kind string, payload interface{}, courseID *uint) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.Job{
+		Kind:     kind,
+		Payload:  string(data),
+		Status:   models.JobStatusPending,
+		RunAt:    time.Now(),
+		CourseID: courseID,
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return nil, CreateEntityFailure(err)
+	}
+	return &job, nil
+}
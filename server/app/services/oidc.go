@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	apperror "server/app/error"
+	"server/app/models"
+	"server/app/oidc"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// oidcLoginExpiry is how long a user has to sign in at the provider.
+const oidcLoginExpiry = 10 * time.Minute
+
+// OIDCService signs users in with OpenID Connect providers, linking the
+// accounts at the providers to users by email.
+type OIDCService struct {
+	db        *gorm.DB
+	users     *UserService
+	providers map[string]*oidc.Provider
+}
+
+// NewOIDCService creates a new OIDCService instance.
+//
+// Parameters:
+//   - db: The database connection.
+//   - users: The user service issuing the tokens of signed-in users.
+//   - providers: The providers users can sign in with.
+func NewOIDCService(db *gorm.DB, users *UserService, providers []*oidc.Provider) *OIDCService {
+	byName := make(map[string]*oidc.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return &OIDCService{db: db, users: users, providers: byName}
+}
+
+// StartLogin starts signing a user in with a provider.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - providerName: The name of the provider.
+//
+// Returns:
+//   - string: The URL of the provider to send the user to.
+//   - error: An EntityNotFound error if the provider is unknown, or an error if the provider cannot be reached.
+func (s *OIDCService) StartLogin(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", EntityNotFound(fmt.Errorf("OIDC provider %q", providerName))
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", err
+	}
+
+	login := models.OIDCLogin{
+		Provider:  providerName,
+		StateHash: hashToken(state),
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(oidcLoginExpiry),
+	}
+	if err := s.db.Create(&login).Error; err != nil {
+		return "", CreateEntityFailure(err)
+	}
+
+	return authURL, nil
+}
+
+// FinishLogin completes signing a user in when the provider sends them back.
+// The user is found by the identity at the provider, or else by the email of
+// the identity, which is then linked to them. Users without an account get one.
+// Users with two-factor authentication still have to enter a code, as with a password.
+//
+// Parameters:
+//   - ctx: The context of the request.
+//   - providerName: The name of the provider.
+//   - state: The state the provider sent back.
+//   - code: The authorization code the provider sent back.
+//
+// Returns:
+//   - *TokenPair: The tokens of the new session, or the two-factor token.
+//   - error: An InvalidToken error if the state is unknown, used or expired, an
+//     InvalidCredential error if the provider doesn't vouch for the user or
+//     their account is inactive, nil otherwise.
+func (s *OIDCService) FinishLogin(ctx context.Context, providerName, state, code string) (*TokenPair, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, EntityNotFound(fmt.Errorf("OIDC provider %q", providerName))
+	}
+
+	login, err := s.consumeLogin(providerName, state)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := provider.Exchange(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		return nil, apperror.InvalidCredential{}
+	}
+
+	var user models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return linkIdentity(tx, providerName, claims, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Active {
+		return nil, apperror.InvalidCredential{}
+	}
+	return s.users.login(&user)
+}
+
+// consumeLogin deletes the login in progress with the given state and returns it, if it is still valid.
+func (s *OIDCService) consumeLogin(providerName, state string) (*models.OIDCLogin, error) {
+	var login models.OIDCLogin
+	if err := s.db.Where("state_hash = ? AND provider = ?", hashToken(state), providerName).
+		First(&login).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.InvalidToken{}
+		}
+		return nil, err
+	}
+
+	// Deleting the row makes concurrent callbacks with the same state use it only once.
+	result := s.db.Delete(&models.OIDCLogin{}, login.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 || !login.ExpiresAt.After(time.Now()) {
+		return nil, apperror.InvalidToken{}
+	}
+	return &login, nil
+}
+
+// linkIdentity loads the user of an identity at a provider into user, linking
+// the identity to the user with its email address, or to a new user, the first
+// time it signs in. Linking to an account whose email was never verified drops
+// its password, sessions, two-factor authentication and API tokens.
+func linkIdentity(tx *gorm.DB, providerName string, claims *oidc.Claims, user *models.User) error {
+	var identity models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+	if err == nil {
+		if err := tx.First(user, identity.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperror.InvalidCredential{}
+			}
+			return err
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	// Linking by email is only safe if the provider checked the address.
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return apperror.InvalidCredential{}
+	}
+
+	now := time.Now()
+	err = tx.Where("LOWER(email) = ?", normalizeEmail(email)).First(user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		password, err := randomToken()
+		if err != nil {
+			return err
+		}
+
+		*user = models.User{
+			FirstName:       claims.GivenName,
+			LastName:        claims.FamilyName,
+			Email:           email,
+			Role:            models.RoleUser,
+			Active:          true,
+			EmailVerifiedAt: &now,
+		}
+		if err := user.SetPassword(password); err != nil {
+			return err
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+
+	case err != nil:
+		return err
+
+	case user.EmailVerifiedAt == nil:
+		// Anyone could have registered the address before its owner, so
+		// whatever they set up to get back into the account goes.
+		if err := tx.Model(user).UpdateColumns(map[string]interface{}{
+			"password":          "", // Matches no password
+			"email_verified_at": now,
+		}).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.TwoFactor{}, &models.RecoveryCode{}, &models.APIToken{}} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return DeleteEntityFailure(err)
+			}
+		}
+	}
+
+	identity = models.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    email,
+	}
+	if err := tx.Create(&identity).Error; err != nil {
+		return CreateEntityFailure(err)
+	}
+	return nil
+}
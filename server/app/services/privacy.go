@@ -0,0 +1,411 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"server/app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobKindErasure is the kind of the jobs erasing the personal data of a user.
+const JobKindErasure = "user.erasure"
+
+// PrivacyService lets users download their personal data and have it erased.
+// Erasure anonymizes the account rather than deleting it, so that submissions,
+// grades and quiz attempts still count in the statistics of their courses.
+type PrivacyService struct {
+	db    *gorm.DB
+	store FileStore
+}
+
+// NewPrivacyService creates a new PrivacyService instance, and sets it up to
+// run the erasure jobs of the job service.
+//
+// Parameters:
+//   - db: The database connection.
+//   - store: The storage of the uploaded files of users.
+//   - jobs: The job service running the erasures.
+func NewPrivacyService(db *gorm.DB, store FileStore, jobs *JobService) *PrivacyService {
+	s := &PrivacyService{db: db, store: store}
+	jobs.Handle(JobKindErasure, s.runErasure)
+	return s
+}
+
+// ExportEnrollment is an enrollment in a personal data export.
+type ExportEnrollment struct {
+	CourseID   uint                    `json:"courseId"`
+	CourseName string                  `json:"courseName"`
+	Role       models.Role             `json:"role"`
+	Status     models.EnrollmentStatus `json:"status"`
+	EnrolledAt time.Time               `json:"enrolledAt"`
+}
+
+// ExportSubmission is an assignment submission in a personal data export.
+type ExportSubmission struct {
+	ID              uint                    `json:"id"`
+	AssignmentID    uint                    `json:"assignmentId"`
+	AssignmentTitle string                  `json:"assignmentTitle"`
+	SubmittedAt     time.Time               `json:"submittedAt"`
+	Status          models.SubmissionStatus `json:"status"`
+	Files           []string                `json:"files"` // Paths of the files in the export
+}
+
+// ExportGrade is the grade of a submission in a personal data export.
+type ExportGrade struct {
+	SubmissionID    uint      `json:"submissionId"`
+	AssignmentTitle string    `json:"assignmentTitle"`
+	PointsEarned    float64   `json:"pointsEarned"`
+	Feedback        string    `json:"feedback"`
+	GradedAt        time.Time `json:"gradedAt"`
+}
+
+// Export writes a ZIP archive of the personal data of a user: their profile,
+// enrollments, submissions with their files, grades and quiz attempts, as JSON.
+// Quiz scores are left out until the quiz releases its results, as when viewing
+// an attempt. Files that cannot be read from the storage are listed in
+// missing_files.txt rather than failing the export.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - w: The writer of the archive.
+//
+// Returns:
+//   - error: An EntityNotFound error if the user doesn't exist, or an error if the data
+//     cannot be read or written, nil otherwise.
+func (s *PrivacyService) Export(userID uint, w io.Writer) error {
+	user, err := findUser(s.db, userID)
+	if err != nil {
+		return err
+	}
+
+	var enrollments []ExportEnrollment
+	if err := s.db.Table("enrollments").
+		Select("enrollments.course_id, courses.name AS course_name, enrollments.role, enrollments.status, enrollments.created_at AS enrolled_at").
+		Joins("JOIN courses ON courses.id = enrollments.course_id").
+		Where("enrollments.user_id = ? AND enrollments.deleted_at IS NULL", userID).
+		Order("enrollments.id").
+		Scan(&enrollments).Error; err != nil {
+		return err
+	}
+
+	var submissions []models.Submission
+	if err := s.db.Preload("Assignment", unscoped).Preload("Files").Preload("Grade").
+		Where("user_id = ?", userID).Order("id").Find(&submissions).Error; err != nil {
+		return err
+	}
+
+	var attempts []models.QuizSubmission
+	if err := s.db.Preload("Quiz", unscoped).Preload("Answers", orderByID).
+		Where("user_id = ?", userID).Order("id").Find(&attempts).Error; err != nil {
+		return err
+	}
+	for i := range attempts {
+		if err := hideUnreleasedResults(s.db, &attempts[i]); err != nil {
+			return err
+		}
+	}
+
+	exportSubmissions := make([]ExportSubmission, 0, len(submissions))
+	grades := make([]ExportGrade, 0, len(submissions))
+	var files []exportFile
+	for _, submission := range submissions {
+		exported := ExportSubmission{
+			ID:              submission.ID,
+			AssignmentID:    submission.AssignmentID,
+			AssignmentTitle: submission.Assignment.Title,
+			SubmittedAt:     submission.SubmittedAt,
+			Status:          submission.Status,
+			Files:           make([]string, 0, len(submission.Files)),
+		}
+		for _, file := range submission.Files {
+			name := fmt.Sprintf("submissions/%d/%d_%s", submission.ID, file.ID, path.Base(strings.ReplaceAll(file.UserFileName, `\`, "/")))
+			exported.Files = append(exported.Files, name)
+			files = append(files, exportFile{name: name, storagePath: file.FileName})
+		}
+		exportSubmissions = append(exportSubmissions, exported)
+
+		if submission.Grade != nil {
+			grades = append(grades, ExportGrade{
+				SubmissionID:    submission.ID,
+				AssignmentTitle: submission.Assignment.Title,
+				PointsEarned:    submission.Grade.PointsEarned,
+				Feedback:        submission.Grade.Feedback,
+				GradedAt:        submission.Grade.GradedAt,
+			})
+		}
+	}
+
+	archive := zip.NewWriter(w)
+	for _, entry := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", user},
+		{"enrollments.json", enrollments},
+		{"submissions.json", exportSubmissions},
+		{"grades.json", grades},
+		{"quiz_attempts.json", attempts},
+	} {
+		if err := writeJSONEntry(archive, entry.name, entry.data); err != nil {
+			return err
+		}
+	}
+
+	var missing []string
+	for _, file := range files {
+		if err := s.copyFile(archive, file); err != nil {
+			missing = append(missing, fmt.Sprintf("%s: %v", file.name, err))
+		}
+	}
+	if len(missing) > 0 {
+		entry, err := archive.Create("missing_files.txt")
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(entry, strings.Join(missing, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// exportFile is an uploaded file to include in an export.
+type exportFile struct {
+	name        string // Path in the archive
+	storagePath string
+}
+
+// copyFile copies an uploaded file from the storage into the archive.
+func (s *PrivacyService) copyFile(archive *zip.Writer, file exportFile) error {
+	reader, err := s.store.OpenFile(file.storagePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	entry, err := archive.Create(file.name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+// writeJSONEntry writes a value as an indented JSON file of the archive.
+func writeJSONEntry(archive *zip.Writer, name string, v interface{}) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// erasurePayload is the payload of an erasure job.
+type erasurePayload struct {
+	UserID  uint `json:"userId"`
+	ActorID uint `json:"actorId"`
+}
+
+// RequestErasure queues the erasure of the personal data of a user. The user
+// is deactivated and signed out at once; the erasure itself runs in the background.
+//
+// Parameters:
+//   - actorID: The ID of the user asking, the user themselves or an admin.
+//   - userID: The ID of the user to erase.
+//
+// Returns:
+//   - *models.Job: The erasure job, to follow its progress.
+//   - error: An EntityNotFound error if the user doesn't exist, a CannotPerformAction
+//     error if they are already erased, nil otherwise.
+func (s *PrivacyService) RequestErasure(actorID, userID uint) (*models.Job, error) {
+	var job *models.Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx.Unscoped(), userID)
+		if err != nil {
+			return err
+		}
+		if user.ErasedAt != nil {
+			return CannotPerformAction("erase an account that is already erased")
+		}
+
+		if err := tx.Unscoped().Model(user).Update("active", false).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		if job, err = enqueueJob(tx, JobKindErasure, erasurePayload{UserID: user.ID, ActorID: actorID}); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, models.AuditLog{
+			Action:  models.AuditUserErasure,
+			ActorID: &actorID,
+			UserID:  &user.ID,
+			Details: fmt.Sprintf("erasure of account %d requested, job %d", user.ID, job.ID),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// runErasure runs an erasure job.
+func (s *PrivacyService) runErasure(ctx context.Context, data []byte) (string, error) {
+	var payload erasurePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return "", err
+	}
+
+	deleted, err := s.Erase(ctx, payload.ActorID, payload.UserID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("account %d erased, %d files deleted", payload.UserID, deleted), nil
+}
+
+// Erase erases the personal data of a user. Their stored files are deleted,
+// and their account is anonymized and deleted, along with their sign-in
+// methods, their sessions and tokens. Their enrollments, submissions, grades and
+// quiz attempts are kept, anonymous, so that course statistics don't change,
+// but the text they wrote in answers and the feedback they got are cleared, as
+// are the details and IP addresses of the audit entries about them.
+// Erasing a user again does nothing.
+//
+// Parameters:
+//   - ctx: The context of the erasure.
+//   - actorID: The ID of the user who asked for the erasure.
+//   - userID: The ID of the user to erase.
+//
+// Returns:
+//   - int: The number of files deleted.
+//   - error: An EntityNotFound error if the user doesn't exist, or an error if a file cannot
+//     be deleted, in which case nothing else is erased yet, nil otherwise.
+func (s *PrivacyService) Erase(ctx context.Context, actorID, userID uint) (int, error) {
+	user, err := findUser(s.db.Unscoped(), userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.ErasedAt != nil {
+		return 0, nil
+	}
+
+	var paths []string
+	if err := s.db.Unscoped().Model(&models.SubmissionFile{}).
+		Where("submission_id IN (?)", s.db.Unscoped().Model(&models.Submission{}).Select("id").Where("user_id = ?", userID)).
+		Pluck("file_name", &paths).Error; err != nil {
+		return 0, err
+	}
+	avatars, err := avatarFiles(s.db, userID)
+	if err != nil {
+		return 0, err
+	}
+	paths = append(paths, avatars...)
+
+	// Files go first: if one cannot be deleted, the job is retried while the
+	// rows still say which files are left. Files deleted by an earlier try are gone already.
+	deleted := 0
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+		if err := deleteStoredFile(s.store, path); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := eraseUserRows(tx, user); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, models.AuditLog{
+			Action:  models.AuditUserErase,
+			ActorID: &actorID,
+			UserID:  &user.ID,
+			Details: fmt.Sprintf("personal data of account %d erased, %d files deleted", user.ID, deleted),
+		})
+	})
+	if err != nil {
+		return deleted, err
+	}
+	return deleted, nil
+}
+
+// eraseUserRows anonymizes a user and deletes or clears the rows holding their personal data.
+func eraseUserRows(tx *gorm.DB, user *models.User) error {
+	// The login throttle is keyed by email, which is about to change.
+	if err := clearThrottle(tx, "account:"+normalizeEmail(user.Email)); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	anonymous := map[string]interface{}{
+		"first_name":        "Erased",
+		"last_name":         "User",
+		"email":             fmt.Sprintf("erased-%d@erased.invalid", user.ID),
+		"password":          "", // Matches no password
+		"date_of_birth":     time.Time{},
+		"profile_pic":       "",
+		"bio":               "",
+		"active":            false,
+		"email_verified_at": nil,
+		"erased_at":         now,
+		"deleted_at":        now,
+	}
+	if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(anonymous).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+
+	sessions := tx.Unscoped().Model(&models.Session{}).Select("id").Where("user_id = ?", user.ID)
+	if err := tx.Where("session_id IN (?)", sessions).Delete(&models.RefreshToken{}).Error; err != nil {
+		return DeleteEntityFailure(err)
+	}
+
+	for _, model := range []interface{}{
+		&models.Session{}, &models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{},
+		&models.APIToken{}, &models.UserIdentity{}, &models.AvatarImage{},
+	} {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+	}
+
+	submissions := tx.Unscoped().Model(&models.Submission{}).Select("id").Where("user_id = ?", user.ID)
+	if err := tx.Unscoped().Where("submission_id IN (?)", submissions).Delete(&models.SubmissionFile{}).Error; err != nil {
+		return DeleteEntityFailure(err)
+	}
+	if err := tx.Unscoped().Model(&models.Grade{}).Where("submission_id IN (?)", submissions).
+		UpdateColumn("feedback", "").Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+
+	attempts := tx.Unscoped().Model(&models.QuizSubmission{}).Select("id").Where("user_id = ?", user.ID)
+	if err := tx.Unscoped().Model(&models.Answer{}).Where("submission_id IN (?)", attempts).
+		UpdateColumns(map[string]interface{}{"text_answer": "", "feedback": ""}).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+
+	// The audit trail keeps what was done to the user, but not the email and
+	// IP addresses its entries about them hold.
+	if err := tx.Model(&models.AuditLog{}).Where("user_id = ?", user.ID).
+		UpdateColumns(map[string]interface{}{"details": "", "ip": ""}).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+	return nil
+}
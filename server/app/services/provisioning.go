@@ -0,0 +1,361 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"server/app/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxProvisionRows caps the number of users in one provisioning file.
+const maxProvisionRows = 2000
+
+// ProvisionStatus is the outcome of a row of a provisioning file.
+type ProvisionStatus string
+
+const (
+	ProvisionCreated ProvisionStatus = "created"
+	ProvisionUpdated ProvisionStatus = "updated"
+	ProvisionSkipped ProvisionStatus = "skipped" // Nothing to change
+	ProvisionError   ProvisionStatus = "error"
+)
+
+// ProvisionRow is the outcome of a row of a provisioning file.
+type ProvisionRow struct {
+	Line    int             `json:"line"` // Line in the file, the header being line 1
+	Email   string          `json:"email"`
+	Status  ProvisionStatus `json:"status"`
+	UserID  uint            `json:"userId,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// ProvisionReport is the outcome of a provisioning file, row by row.
+type ProvisionReport struct {
+	DryRun  bool           `json:"dryRun"`
+	Created int            `json:"created"`
+	Updated int            `json:"updated"`
+	Skipped int            `json:"skipped"`
+	Errors  int            `json:"errors"`
+	Rows    []ProvisionRow `json:"rows"`
+}
+
+func (r *ProvisionReport) add(row ProvisionRow) {
+	switch row.Status {
+	case ProvisionCreated:
+		r.Created++
+	case ProvisionUpdated:
+		r.Updated++
+	case ProvisionSkipped:
+		r.Skipped++
+	case ProvisionError:
+		r.Errors++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// provisionRecord is a row of a provisioning file.
+type provisionRecord struct {
+	line           int
+	firstName      string
+	lastName       string
+	email          string
+	invitationCode string
+	role           models.Role
+}
+
+// provisionColumns maps the accepted header names, lowercased and without
+// spaces or underscores, to the columns of a provisioning file.
+var provisionColumns = map[string]string{
+	"firstname":      "firstName",
+	"lastname":       "lastName",
+	"email":          "email",
+	"invitationcode": "invitationCode",
+	"code":           "invitationCode",
+	"role":           "role",
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// ProvisioningService creates users in bulk from a CSV file, such as the list
+// of students of a term, optionally enrolling them in courses.
+type ProvisioningService struct {
+	db          *gorm.DB
+	enrollments *EnrollmentService
+	accounts    *AccountService
+}
+
+// NewProvisioningService creates a new ProvisioningService instance.
+//
+// Parameters:
+//   - db: The database connection.
+//   - enrollments: The enrollment service, enrolling users in the courses of their invitation codes.
+//   - accounts: The account service, emailing new users a link to choose their password.
+func NewProvisioningService(db *gorm.DB, enrollments *EnrollmentService, accounts *AccountService) *ProvisioningService {
+	return &ProvisioningService{db: db, enrollments: enrollments, accounts: accounts}
+}
+
+// Provision creates or updates the users of a CSV file. The file starts with a
+// header naming its columns: first_name, last_name and email, and optionally
+// invitation_code and role. Users are matched by email; new users are emailed a
+// link to choose their password, and existing users get the names of the file.
+// Users with an invitation code are enrolled in its course, approved, with the
+// role of the row, student by default.
+//
+// Each row succeeds or fails on its own, and the report tells what happened to
+// each. In a dry run, nothing is saved and no email is sent, but the report is
+// the same as for the real run.
+//
+// Parameters:
+//   - actorID: The ID of the admin.
+//   - file: The CSV file.
+//   - dryRun: Whether to only report what would happen.
+//
+// Returns:
+//   - *ProvisionReport: The outcome of each row.
+//   - error: An InvalidInput error if the file cannot be read, lacks a required column or
+//     has too many rows, nil otherwise.
+func (s *ProvisioningService) Provision(actorID uint, file io.Reader, dryRun bool) (*ProvisionReport, error) {
+	records, err := readProvisionFile(file)
+	if err != nil {
+		return nil, InvalidInput(err)
+	}
+
+	report := &ProvisionReport{DryRun: dryRun, Rows: make([]ProvisionRow, 0, len(records))}
+	var created []models.User
+	seen := make(map[string]int, len(records))
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, record := range records {
+			row := ProvisionRow{Line: record.line, Email: record.email}
+
+			key := strings.ToLower(record.email)
+			if line, ok := seen[key]; ok && key != "" {
+				row.Status, row.Message = ProvisionSkipped, fmt.Sprintf("duplicate of line %d", line)
+				report.add(row)
+				continue
+			}
+			seen[key] = record.line
+
+			var user *models.User
+			// Each row runs in a savepoint, so that a failed row leaves the others be.
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				var err error
+				user, row.Status, err = s.provisionUser(tx, actorID, record)
+				return err
+			})
+			if err != nil {
+				row.Status, row.Message = ProvisionError, err.Error()
+			} else {
+				row.UserID = user.ID
+				if row.Status == ProvisionCreated {
+					created = append(created, *user)
+				}
+			}
+			report.add(row)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	if !dryRun {
+		for i := range created {
+			// The accounts exist; an admin can send the link again with a forced password reset.
+			_ = s.accounts.sendPasswordReset(&created[i], "An account was created for you.",
+				"Once you have chosen a password, you can log in with your email address.")
+		}
+	}
+	return report, nil
+}
+
+// provisionUser creates or updates the user of a row and enrolls them, returning what it did.
+func (s *ProvisioningService) provisionUser(tx *gorm.DB, actorID uint, record provisionRecord) (*models.User, ProvisionStatus, error) {
+	if err := record.validate(); err != nil {
+		return nil, "", err
+	}
+
+	var user models.User
+	status := ProvisionSkipped
+	err := tx.Where("LOWER(email) = LOWER(?)", record.email).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		password, err := randomToken()
+		if err != nil {
+			return nil, "", err
+		}
+
+		user = models.User{
+			FirstName: record.firstName,
+			LastName:  record.lastName,
+			Email:     record.email,
+			Role:      models.RoleUser,
+			Active:    true,
+		}
+		if err := user.SetPassword(password); err != nil {
+			return nil, "", err
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, "", CreateEntityFailure(err)
+		}
+		status = ProvisionCreated
+
+	case err != nil:
+		return nil, "", err
+
+	case user.FirstName != record.firstName || user.LastName != record.lastName:
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"first_name": record.firstName,
+			"last_name":  record.lastName,
+		}).Error; err != nil {
+			return nil, "", UpdateEntityFailure(err)
+		}
+		status = ProvisionUpdated
+	}
+
+	if record.invitationCode != "" {
+		enrolled, err := s.enrollByCode(tx, user.ID, record.invitationCode, record.role)
+		if err != nil {
+			return nil, "", err
+		}
+		if enrolled && status == ProvisionSkipped {
+			status = ProvisionUpdated
+		}
+	}
+
+	if status == ProvisionSkipped {
+		return &user, status, nil
+	}
+
+	return &user, status, recordAudit(tx, models.AuditLog{
+		Action:  models.AuditUserProvision,
+		ActorID: &actorID,
+		UserID:  &user.ID,
+		Details: fmt.Sprintf("account %s %s by provisioning", user.Email, status),
+	})
+}
+
+// enrollByCode enrolls a user, approved, in the course of an invitation code,
+// unless they already are. It reports whether it enrolled them.
+func (s *ProvisioningService) enrollByCode(tx *gorm.DB, userID uint, invitationCode string, role models.Role) (bool, error) {
+	course, err := s.enrollments.courseByCode(tx, invitationCode)
+	if err != nil {
+		return false, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ?", userID, course.ID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if _, err := s.enrollments.enroll(tx, userID, course.ID, role, models.EnrollmentStatusApproved); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// validate checks the fields of a row.
+func (r provisionRecord) validate() error {
+	if r.email == "" {
+		return errors.New("email is required")
+	}
+	if address, err := mail.ParseAddress(r.email); err != nil || address.Address != r.email {
+		return fmt.Errorf("invalid email %q", r.email)
+	}
+	if r.firstName == "" || r.lastName == "" {
+		return errors.New("first and last name are required")
+	}
+	if r.role != models.RoleStudent && r.role != models.RoleTeacher {
+		return fmt.Errorf("role must be %q or %q", models.RoleStudent, models.RoleTeacher)
+	}
+	return nil
+}
+
+// readProvisionFile reads the rows of a provisioning file, by the names of its header.
+func readProvisionFile(file io.Reader) ([]provisionRecord, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.NewReplacer(" ", "", "_", "", "\ufeff", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if column, ok := provisionColumns[name]; ok {
+			columns[column] = i
+		}
+	}
+	for _, required := range []string{"firstName", "lastName", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", required)
+		}
+	}
+
+	field := func(fields []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	var records []provisionRecord
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+		if len(records) == maxProvisionRows {
+			return nil, fmt.Errorf("the file has more than %d rows", maxProvisionRows)
+		}
+
+		role := models.Role(strings.ToLower(field(fields, "role")))
+		if role == "" {
+			role = models.RoleStudent
+		}
+
+		records = append(records, provisionRecord{
+			line:           line,
+			firstName:      field(fields, "firstName"),
+			lastName:       field(fields, "lastName"),
+			email:          field(fields, "email"),
+			invitationCode: field(fields, "invitationCode"),
+			role:           role,
+		})
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("the file has no rows")
+	}
+	return records, nil
+}
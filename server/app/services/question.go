@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"server/app/models"
+	"sort"
+	"strings"
+)
+
+// PrepareQuestion validates a question before it is stored and normalizes its
+// answer key. Ordering questions whose options carry no positions take the
+// order the options were given in as the correct one.
+//
+// Parameters:
+//   - question: The question to validate, modified in place.
+//
+// Returns:
+//   - error: An InvalidInputError describing the first problem found, nil otherwise.
+func PrepareQuestion(question *models.Question) error {
+	if !question.Type.IsValid() {
+		return InvalidInput(errors.New("invalid question type"))
+	}
+
+	if strings.TrimSpace(question.Title) == "" {
+		return InvalidInput(errors.New("question title is required"))
+	}
+
+	if question.Points < 0 {
+		return InvalidInput(fmt.Errorf("question %q cannot have negative points", question.Title))
+	}
+
+	if !question.Type.UsesOptions() && len(question.Options) > 0 {
+		return InvalidInput(fmt.Errorf("question %q does not take options", question.Title))
+	}
+
+	correct := 0
+	for _, option := range question.Options {
+		if option.IsCorrect {
+			correct++
+		}
+	}
+
+	switch question.Type {
+	case models.SingleCorrect:
+		if len(question.Options) < 2 || correct != 1 {
+			return InvalidInput(fmt.Errorf("question %q needs at least two options and exactly one correct option", question.Title))
+		}
+
+	case models.MultiCorrect:
+		if len(question.Options) < 2 || correct < 1 {
+			return InvalidInput(fmt.Errorf("question %q needs at least two options and one correct option", question.Title))
+		}
+
+	case models.TrueFalse:
+		if len(question.Options) != 2 || correct != 1 {
+			return InvalidInput(fmt.Errorf("question %q needs exactly two options, one of them correct", question.Title))
+		}
+
+	case models.Numeric:
+		if question.NumericAnswer == nil {
+			return InvalidInput(fmt.Errorf("question %q needs a numeric answer", question.Title))
+		}
+		if question.Tolerance < 0 {
+			return InvalidInput(fmt.Errorf("question %q cannot have a negative tolerance", question.Title))
+		}
+
+	case models.ShortText:
+		accepted := make([]string, 0, len(question.AcceptedAnswers))
+		for _, answer := range question.AcceptedAnswers {
+			if normalizeText(answer) != "" {
+				accepted = append(accepted, strings.TrimSpace(answer))
+			}
+		}
+		if len(accepted) == 0 {
+			return InvalidInput(fmt.Errorf("question %q needs at least one accepted answer", question.Title))
+		}
+		question.AcceptedAnswers = accepted
+
+	case models.Ordering:
+		return prepareOrdering(question)
+	}
+
+	return nil
+}
+
+// prepareOrdering makes sure every option of an Ordering question has a
+// distinct position.
+func prepareOrdering(question *models.Question) error {
+	if len(question.Options) < 2 {
+		return InvalidInput(fmt.Errorf("question %q needs at least two options to order", question.Title))
+	}
+
+	positioned := false
+	for _, option := range question.Options {
+		if option.Position != 0 {
+			positioned = true
+			break
+		}
+	}
+
+	if !positioned {
+		for i := range question.Options {
+			question.Options[i].Position = i + 1
+		}
+		return nil
+	}
+
+	seen := make(map[int]bool, len(question.Options))
+	for _, option := range question.Options {
+		if seen[option.Position] {
+			return InvalidInput(fmt.Errorf("question %q has options sharing position %d", question.Title, option.Position))
+		}
+		seen[option.Position] = true
+	}
+
+	return nil
+}
+
+// correctOrder returns the option IDs of an Ordering question in their correct order.
+func correctOrder(question models.Question) []uint {
+	options := make([]models.Option, len(question.Options))
+	copy(options, question.Options)
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].Position < options[j].Position
+	})
+
+	order := make([]uint, len(options))
+	for i, option := range options {
+		order[i] = option.ID
+	}
+	return order
+}
+
+// normalizeText makes short text answers comparable regardless of case and whitespace.
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
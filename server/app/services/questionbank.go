@@ -0,0 +1,369 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"server/app/models"
+	"server/app/policy"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type QuestionBankService struct {
+	db *gorm.DB
+}
+
+func NewQuestionBankService(db *gorm.DB) *QuestionBankService {
+	return &QuestionBankService{db: db}
+}
+
+// ListQuestions retrieves the questions in the bank of a course.
+//
+// Parameters:
+//   - userID: The ID of the user requesting the questions.
+//   - courseID: The ID of the course.
+//   - tag: Only return questions with this tag, or all questions if empty.
+//
+// Returns:
+//   - []models.Question: The bank questions with their options and tags.
+//   - error: An error if the user cannot manage the course, nil otherwise.
+func (s *QuestionBankService) ListQuestions(userID, courseID uint, tag string) ([]models.Question, error) {
+	if err := canManageCourse(s.db, userID, courseID); err != nil {
+		return nil, err
+	}
+
+	query := s.db.Preload("Options", orderByID).
+		Preload("Tags", orderByID).
+		Where("course_id = ?", courseID)
+
+	if tag = normalizeTag(tag); tag != "" {
+		query = query.Where("id IN (?)", s.db.Model(&models.QuestionTag{}).Select("question_id").Where("name = ?", tag))
+	}
+
+	var questions []models.Question
+	if err := query.Order("id ASC").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	return questions, nil
+}
+
+// CreateQuestion adds a question to the bank of a course.
+//
+// Parameters:
+//   - userID: The ID of the user creating the question.
+//   - courseID: The ID of the course.
+//   - question: The question to create, with its options and tags.
+//
+// Returns:
+//   - error: An error if the user cannot manage the course or the question is invalid, nil otherwise.
+func (s *QuestionBankService) CreateQuestion(userID, courseID uint, question *models.Question) error {
+	if err := canManageCourse(s.db, userID, courseID); err != nil {
+		return err
+	}
+
+	if err := prepareBankQuestion(question); err != nil {
+		return err
+	}
+
+	question.ID = 0
+	question.QuizID = nil
+	question.CourseID = &courseID
+
+	if err := s.db.Create(question).Error; err != nil {
+		return CreateEntityFailure(err)
+	}
+	return nil
+}
+
+// UpdateQuestion replaces a bank question with its options and tags.
+// Questions that were already drawn for an attempt cannot be changed, so that
+// grading stays consistent with what the students saw.
+//
+// Parameters:
+//   - userID: The ID of the user updating the question.
+//   - courseID: The ID of the course.
+//   - questionID: The ID of the question.
+//   - update: The new question.
+//
+// Returns:
+//   - *models.Question: The updated question.
+//   - error: An error if the question is not found, invalid or already used in an attempt, nil otherwise.
+func (s *QuestionBankService) UpdateQuestion(userID, courseID, questionID uint, update *models.Question) (*models.Question, error) {
+	if err := canManageCourse(s.db, userID, courseID); err != nil {
+		return nil, err
+	}
+
+	if err := prepareBankQuestion(update); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		question, err := bankQuestion(tx, courseID, questionID)
+		if err != nil {
+			return err
+		}
+
+		var used int64
+		if err := tx.Model(&models.AttemptQuestion{}).
+			Where("question_id = ?", questionID).
+			Count(&used).Error; err != nil {
+			return err
+		}
+
+		if used > 0 {
+			return CannotPerformAction("change a question that was already drawn in an attempt")
+		}
+
+		question.Title = update.Title
+		question.Description = update.Description
+		question.Type = update.Type
+		question.Points = update.Points
+		question.NumericAnswer = update.NumericAnswer
+		question.Tolerance = update.Tolerance
+		question.AcceptedAnswers = update.AcceptedAnswers
+
+		if err := tx.Model(question).
+			Select("Title", "Description", "Type", "Points", "NumericAnswer", "Tolerance", "AcceptedAnswersJSON").
+			Updates(question).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+
+		// The replaced options are gone for good: attempts read the options of
+		// drawn questions, and must not find these among them.
+		if err := tx.Unscoped().Where("question_id = ?", questionID).Delete(&models.Option{}).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+
+		if err := tx.Where("question_id = ?", questionID).Delete(&models.QuestionTag{}).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+
+		for i := range update.Options {
+			update.Options[i].ID = 0
+			update.Options[i].QuestionID = questionID
+		}
+		for i := range update.Tags {
+			update.Tags[i].ID = 0
+			update.Tags[i].QuestionID = questionID
+		}
+
+		if len(update.Options) > 0 {
+			if err := tx.Create(&update.Options).Error; err != nil {
+				return CreateEntityFailure(err)
+			}
+		}
+
+		if len(update.Tags) > 0 {
+			if err := tx.Create(&update.Tags).Error; err != nil {
+				return CreateEntityFailure(err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var question models.Question
+	if err := s.db.Preload("Options", orderByID).
+		Preload("Tags", orderByID).
+		First(&question, questionID).Error; err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// DeleteQuestion removes a question from the bank of a course. Attempts that
+// drew the question keep it.
+//
+// Parameters:
+//   - userID: The ID of the user deleting the question.
+//   - courseID: The ID of the course.
+//   - questionID: The ID of the question.
+//
+// Returns:
+//   - error: An error if the question is not found or the user cannot manage the course, nil otherwise.
+func (s *QuestionBankService) DeleteQuestion(userID, courseID, questionID uint) error {
+	if err := canManageCourse(s.db, userID, courseID); err != nil {
+		return err
+	}
+
+	question, err := bankQuestion(s.db, courseID, questionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(question).Error; err != nil {
+		return DeleteEntityFailure(err)
+	}
+	return nil
+}
+
+// DrawQuestions picks the bank questions of an attempt. For each rule, in
+// order, it draws rule.Count questions from the matching pool, skipping
+// questions already drawn by an earlier rule. The same seed always draws the
+// same questions from the same pools.
+//
+// Parameters:
+//   - seed: The seed of the attempt.
+//   - rules: The draw rules of the quiz.
+//   - pools: For each rule, the IDs of the bank questions with its tag.
+//
+// Returns:
+//   - []uint: The IDs of the drawn questions.
+//   - error: An error if a pool has too few questions left for its rule, nil otherwise.
+func DrawQuestions(seed int64, rules []models.QuizDrawRule, pools [][]uint) ([]uint, error) {
+	rng := rand.New(rand.NewSource(seed))
+	drawn := make(map[uint]bool)
+	var ids []uint
+
+	for i, rule := range rules {
+		candidates := make([]uint, 0, len(pools[i]))
+		for _, id := range pools[i] {
+			if !drawn[id] {
+				candidates = append(candidates, id)
+			}
+		}
+
+		if len(candidates) < rule.Count {
+			return nil, fmt.Errorf("tag %q has %d questions left, %d needed", rule.Tag, len(candidates), rule.Count)
+		}
+
+		// Sorting first makes the draw independent of the order the pool was loaded in.
+		sort.Slice(candidates, func(a, b int) bool { return candidates[a] < candidates[b] })
+		rng.Shuffle(len(candidates), func(a, b int) {
+			candidates[a], candidates[b] = candidates[b], candidates[a]
+		})
+
+		for _, id := range candidates[:rule.Count] {
+			drawn[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// drawAttemptQuestions draws the bank questions of a new attempt and records them.
+func drawAttemptQuestions(tx *gorm.DB, quiz *models.Quiz, attempt *models.QuizSubmission) error {
+	var rules []models.QuizDrawRule
+	if err := tx.Where("quiz_id = ?", quiz.ID).Order("id ASC").Find(&rules).Error; err != nil {
+		return err
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	pools := make([][]uint, len(rules))
+	for i, rule := range rules {
+		pool, err := tagPool(tx, quiz.CourseID, rule.Tag)
+		if err != nil {
+			return err
+		}
+		pools[i] = pool
+	}
+
+	ids, err := DrawQuestions(attempt.Seed, rules, pools)
+	if err != nil {
+		return CannotPerformAction(fmt.Sprintf("start the quiz, its question bank is too small: %v", err))
+	}
+
+	drawn := make([]models.AttemptQuestion, len(ids))
+	for i, id := range ids {
+		drawn[i] = models.AttemptQuestion{SubmissionID: attempt.ID, QuestionID: id}
+	}
+
+	if err := tx.Create(&drawn).Error; err != nil {
+		return CreateEntityFailure(err)
+	}
+	return nil
+}
+
+// prepareDrawRules validates the draw rules of a quiz against the question bank of its course.
+func prepareDrawRules(tx *gorm.DB, courseID uint, rules []models.QuizDrawRule) error {
+	for i := range rules {
+		rules[i].Tag = normalizeTag(rules[i].Tag)
+		if rules[i].Tag == "" {
+			return InvalidInput(errors.New("draw rules need a tag"))
+		}
+
+		if rules[i].Count <= 0 {
+			return InvalidInput(fmt.Errorf("draw rule for tag %q must draw at least one question", rules[i].Tag))
+		}
+
+		pool, err := tagPool(tx, courseID, rules[i].Tag)
+		if err != nil {
+			return err
+		}
+
+		if len(pool) < rules[i].Count {
+			return InvalidInput(fmt.Errorf("question bank has %d questions tagged %q, %d needed", len(pool), rules[i].Tag, rules[i].Count))
+		}
+	}
+
+	return nil
+}
+
+// tagPool returns the IDs of the bank questions of a course with the given tag.
+func tagPool(tx *gorm.DB, courseID uint, tag string) ([]uint, error) {
+	var ids []uint
+	if err := tx.Model(&models.Question{}).
+		Joins("JOIN question_tags ON question_tags.question_id = questions.id").
+		Where("questions.course_id = ? AND question_tags.name = ?", courseID, tag).
+		Distinct().
+		Order("questions.id ASC").
+		Pluck("questions.id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// prepareBankQuestion validates a bank question and normalizes its tags.
+func prepareBankQuestion(question *models.Question) error {
+	if err := PrepareQuestion(question); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(question.Tags))
+	tags := make([]models.QuestionTag, 0, len(question.Tags))
+	for _, tag := range question.Tags {
+		name := normalizeTag(tag.Name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, models.QuestionTag{Name: name})
+	}
+	question.Tags = tags
+
+	return nil
+}
+
+// bankQuestion fetches a question from the bank of a course.
+func bankQuestion(tx *gorm.DB, courseID, questionID uint) (*models.Question, error) {
+	var question models.Question
+	if err := tx.Where("course_id = ?", courseID).First(&question, questionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+	return &question, nil
+}
+
+// canManageCourse checks that the user may manage the question bank of the course.
+func canManageCourse(tx *gorm.DB, userID, courseID uint) error {
+	return authorize(tx, userID, policy.QuestionManage, policy.Resource{CourseID: courseID})
+}
+
+// normalizeTag makes tags case-insensitive.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
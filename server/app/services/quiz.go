@@ -3,8 +3,11 @@ package services
 import (
 	"errors"
 	"server/app/models"
+	"server/app/policy"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type QuizService struct {
@@ -28,6 +31,269 @@ func (q *QuizService) IsCreator(userID, quizID uint) (bool, error) {
 	return quiz.CreatorID == userID, nil
 }
 
+// CreateQuiz validates the settings and questions of a quiz and stores it with
+// its questions and options.
+//
+// Parameters:
+//   - quiz: The quiz to create.
+//
+// Returns:
+//   - error: An InvalidInputError if the quiz or a question is invalid, or an error if the creation fails.
 func (q *QuizService) CreateQuiz(quiz *models.Quiz) error {
+	if err := prepareQuiz(quiz); err != nil {
+		return err
+	}
+
+	for i := range quiz.Questions {
+		if err := PrepareQuestion(&quiz.Questions[i]); err != nil {
+			return err
+		}
+
+		// Inline questions belong to the quiz only, never to a question bank.
+		quiz.Questions[i].ID = 0
+		quiz.Questions[i].CourseID = nil
+		for j := range quiz.Questions[i].Options {
+			quiz.Questions[i].Options[j].ID = 0
+			quiz.Questions[i].Options[j].QuestionID = 0
+		}
+	}
+
+	if err := prepareDrawRules(q.db, quiz.CourseID, quiz.DrawRules); err != nil {
+		return err
+	}
+
 	return q.db.Create(quiz).Error
 }
+
+// ListQuizzes retrieves the quizzes of a course, without their questions.
+//
+// Parameters:
+//   - userID: The ID of the user requesting the list.
+//   - courseID: The ID of the course.
+//
+// Returns:
+//   - []models.Quiz: The quizzes of the course, ordered by start time.
+//   - error: An error if the user may not read the quizzes of the course, nil otherwise.
+func (q *QuizService) ListQuizzes(userID, courseID uint) ([]models.Quiz, error) {
+	if err := authorize(q.db, userID, policy.QuizRead, policy.Resource{CourseID: courseID}); err != nil {
+		return nil, err
+	}
+
+	var quizzes []models.Quiz
+	if err := q.db.Where("course_id = ?", courseID).
+		Order("start_time ASC").
+		Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+
+	return quizzes, nil
+}
+
+// UpdateQuiz replaces the settings of a quiz and, if the update carries
+// questions or draw rules, its questions and options or its draw rules.
+//
+// Once anyone has started an attempt, the questions, draw rules and scoring
+// policy are frozen, so that what students answered and how it is scored stay
+// consistent. Updates that carry questions or draw rules, or change the
+// scoring policy, are then refused.
+//
+// Parameters:
+//   - quizID: The ID of the quiz to update.
+//   - update: The new settings, with Questions or DrawRules left nil to keep the current ones,
+//     and ScoringPolicy left empty to keep the current one.
+//
+// Returns:
+//   - *models.Quiz: The updated quiz with its questions and options.
+//   - error: An error if the quiz is not found, the update is invalid or the quiz has attempts, nil otherwise.
+func (q *QuizService) UpdateQuiz(quizID uint, update *models.Quiz) (*models.Quiz, error) {
+	keepScoring := update.ScoringPolicy == ""
+	if err := prepareQuiz(update); err != nil {
+		return nil, err
+	}
+
+	for i := range update.Questions {
+		if err := PrepareQuestion(&update.Questions[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the quiz keeps attempts from starting while questions are replaced.
+		var quiz models.Quiz
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&quiz, quizID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return EntityNotFound(err)
+			}
+			return err
+		}
+
+		if keepScoring {
+			update.ScoringPolicy = quiz.ScoringPolicy
+		}
+
+		locked, err := hasAttempts(tx, quizID)
+		if err != nil {
+			return err
+		}
+
+		if locked && (update.Questions != nil || update.DrawRules != nil || update.ScoringPolicy != quiz.ScoringPolicy) {
+			return CannotPerformAction("change the questions or scoring of a quiz that has attempts")
+		}
+
+		if err := prepareDrawRules(tx, quiz.CourseID, update.DrawRules); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&quiz).
+			Select("Title", "Description", "StartTime", "EndTime", "Duration",
+				"ShuffleQuestions", "ShuffleOptions", "ShowResults", "ScoringPolicy",
+				"MaxAttempts", "AttemptScoring").
+			Updates(update).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+
+		if update.DrawRules != nil {
+			if err := replaceDrawRules(tx, quizID, update.DrawRules); err != nil {
+				return err
+			}
+		}
+
+		if update.Questions == nil {
+			return nil
+		}
+
+		return replaceQuestions(tx, quizID, update.Questions)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return q.GetQuiz(quizID)
+}
+
+// DeleteQuiz deletes a quiz. Attempts at the quiz are kept, so their grades
+// are not lost.
+//
+// Parameters:
+//   - quizID: The ID of the quiz to delete.
+//
+// Returns:
+//   - error: An error if the quiz is not found or the deletion fails, nil otherwise.
+func (q *QuizService) DeleteQuiz(quizID uint) error {
+	result := q.db.Delete(&models.Quiz{}, quizID)
+	if result.Error != nil {
+		return DeleteEntityFailure(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return EntityNotFound(gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
+
+// prepareQuiz validates the settings of a quiz and fills in the defaults:
+// all-or-nothing scoring, a single attempt and keeping the highest score.
+func prepareQuiz(quiz *models.Quiz) error {
+	if strings.TrimSpace(quiz.Title) == "" {
+		return InvalidInput(errors.New("quiz title is required"))
+	}
+
+	if quiz.ScoringPolicy == "" {
+		quiz.ScoringPolicy = models.ScoringAllOrNothing
+	}
+
+	if !quiz.ScoringPolicy.IsValid() {
+		return InvalidInput(errors.New("invalid scoring policy"))
+	}
+
+	if !quiz.EndTime.After(quiz.StartTime) {
+		return InvalidInput(errors.New("quiz must end after it starts"))
+	}
+
+	if quiz.Duration <= 0 {
+		return InvalidInput(errors.New("quiz duration must be positive"))
+	}
+
+	if quiz.MaxAttempts == 0 {
+		quiz.MaxAttempts = 1
+	}
+
+	if quiz.MaxAttempts < 0 {
+		return InvalidInput(errors.New("quiz must allow at least one attempt"))
+	}
+
+	if quiz.AttemptScoring == "" {
+		quiz.AttemptScoring = models.AttemptScoringHighest
+	}
+
+	if !quiz.AttemptScoring.IsValid() {
+		return InvalidInput(errors.New("invalid attempt scoring"))
+	}
+
+	return nil
+}
+
+// hasAttempts reports whether anyone has started an attempt at the quiz.
+func hasAttempts(tx *gorm.DB, quizID uint) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.QuizSubmission{}).
+		Where("quiz_id = ?", quizID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// replaceQuestions deletes the questions and options of a quiz and stores the given ones instead.
+func replaceQuestions(tx *gorm.DB, quizID uint, questions []models.Question) error {
+	if err := tx.Where("question_id IN (?)", tx.Model(&models.Question{}).Select("id").Where("quiz_id = ?", quizID)).
+		Delete(&models.Option{}).Error; err != nil {
+		return DeleteEntityFailure(err)
+	}
+
+	if err := tx.Where("quiz_id = ?", quizID).Delete(&models.Question{}).Error; err != nil {
+		return DeleteEntityFailure(err)
+	}
+
+	if len(questions) == 0 {
+		return nil
+	}
+
+	for i := range questions {
+		questions[i].ID = 0
+		questions[i].QuizID = &quizID
+		questions[i].CourseID = nil
+		for j := range questions[i].Options {
+			questions[i].Options[j].ID = 0
+			questions[i].Options[j].QuestionID = 0
+		}
+	}
+
+	if err := tx.Create(&questions).Error; err != nil {
+		return CreateEntityFailure(err)
+	}
+	return nil
+}
+
+// replaceDrawRules deletes the draw rules of a quiz and stores the given ones instead.
+func replaceDrawRules(tx *gorm.DB, quizID uint, rules []models.QuizDrawRule) error {
+	if err := tx.Where("quiz_id = ?", quizID).Delete(&models.QuizDrawRule{}).Error; err != nil {
+		return DeleteEntityFailure(err)
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	for i := range rules {
+		rules[i].ID = 0
+		rules[i].QuizID = quizID
+	}
+
+	if err := tx.Create(&rules).Error; err != nil {
+		return CreateEntityFailure(err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"server/app/models"
+	"server/app/quizio"
+)
+
+// ImportQuiz creates a quiz from a GIFT or QTI document. Items the format
+// reader cannot handle, and questions that fail validation, are skipped and
+// reported as issues rather than failing the whole import.
+//
+// Parameters:
+//   - quiz: The settings of the quiz to create. Its questions are replaced by the imported ones.
+//   - format: The format of the document.
+//   - data: The document.
+//
+// Returns:
+//   - []quizio.Issue: The items that were skipped and why.
+//   - error: An InvalidInputError if the document cannot be read or holds no valid question, or an error if the creation fails.
+func (q *QuizService) ImportQuiz(quiz *models.Quiz, format quizio.Format, data []byte) ([]quizio.Issue, error) {
+	var questions []models.Question
+	var issues []quizio.Issue
+	var err error
+
+	switch format {
+	case quizio.FormatGIFT:
+		questions, issues, err = quizio.ParseGIFT(bytes.NewReader(data))
+	case quizio.FormatQTI:
+		questions, issues, err = quizio.ParseQTI(data)
+	default:
+		return nil, InvalidInput(fmt.Errorf("unsupported format %q", format))
+	}
+
+	if err != nil {
+		return nil, InvalidInput(err)
+	}
+
+	quiz.Questions = make([]models.Question, 0, len(questions))
+	for i := range questions {
+		if err := PrepareQuestion(&questions[i]); err != nil {
+			issues = append(issues, quizio.Issue{Item: i + 1, Title: questions[i].Title, Reason: err.Error()})
+			continue
+		}
+		quiz.Questions = append(quiz.Questions, questions[i])
+	}
+
+	if len(quiz.Questions) == 0 {
+		return issues, InvalidInput(errors.New("the document has no question that can be imported"))
+	}
+
+	quiz.DrawRules = nil
+	if err := q.CreateQuiz(quiz); err != nil {
+		return issues, err
+	}
+
+	return issues, nil
+}
+
+// ExportQuiz writes the questions of a quiz as a GIFT document or a QTI
+// content package. Questions drawn from the question bank are not part of the
+// export and are reported as issues, as are questions the format cannot express.
+//
+// Parameters:
+//   - quizID: The ID of the quiz.
+//   - format: The format to export to.
+//
+// Returns:
+//   - []byte: The exported document.
+//   - []quizio.Issue: The items that could not be exported.
+//   - error: An error if the quiz is not found or the format is unsupported, nil otherwise.
+func (q *QuizService) ExportQuiz(quizID uint, format quizio.Format) ([]byte, []quizio.Issue, error) {
+	quiz, err := q.GetQuiz(quizID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	var issues []quizio.Issue
+
+	switch format {
+	case quizio.FormatGIFT:
+		issues, err = quizio.WriteGIFT(&buf, quiz)
+	case quizio.FormatQTI:
+		issues, err = quizio.WriteQTI(&buf, quiz)
+	default:
+		return nil, nil, InvalidInput(fmt.Errorf("unsupported format %q", format))
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), issues, nil
+}
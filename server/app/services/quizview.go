@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"math/rand"
+	"server/app/models"
+	"server/app/policy"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuizView is the student-facing representation of a quiz. Unlike models.Quiz
+// it never carries the answer key, unless the quiz is over and shows its results.
+// Its schedule is the student's own, accommodation included.
+type QuizView struct {
+	ID               uint                  `json:"id"`
+	Title            string                `json:"title"`
+	Description      string                `json:"description"`
+	CourseID         uint                  `json:"courseId"`
+	StartTime        time.Time             `json:"startTime"`
+	EndTime          time.Time             `json:"endTime"`
+	Duration         int                   `json:"duration"`
+	MaxAttempts      int                   `json:"maxAttempts"`
+	AttemptScoring   models.AttemptScoring `json:"attemptScoring"`
+	AttemptsUsed     int                   `json:"attemptsUsed"`
+	ShowResults      bool                  `json:"showResults"`
+	ResultsAvailable bool                  `json:"resultsAvailable"`
+	FinalScore       *float64              `json:"finalScore,omitempty"`
+	Questions        []QuestionView        `json:"questions"`
+}
+
+type QuestionView struct {
+	ID          uint                `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Type        models.QuestionType `json:"type"`
+	Points      int                 `json:"points"`
+	Options     []OptionView        `json:"options"`
+	AnswerKey   *AnswerKey          `json:"answerKey,omitempty"`
+}
+
+// AnswerKey reveals the answer of questions that are not answered by picking
+// correct options.
+type AnswerKey struct {
+	NumericAnswer   *float64 `json:"numericAnswer,omitempty"`
+	Tolerance       float64  `json:"tolerance,omitempty"`
+	AcceptedAnswers []string `json:"acceptedAnswers,omitempty"`
+	CorrectOrder    []uint   `json:"correctOrder,omitempty"`
+}
+
+type OptionView struct {
+	ID        uint   `json:"id"`
+	Text      string `json:"text"`
+	IsCorrect *bool  `json:"isCorrect,omitempty"`
+}
+
+// GetQuiz retrieves a quiz with its questions and options, answer key included.
+// It is meant for the creator of the quiz; students should use GetQuizView.
+//
+// Parameters:
+//   - quizID: The ID of the quiz.
+//
+// Returns:
+//   - *models.Quiz: The quiz with its questions and options.
+//   - error: An error if the quiz is not found, nil otherwise.
+func (q *QuizService) GetQuiz(quizID uint) (*models.Quiz, error) {
+	var quiz models.Quiz
+	if err := q.db.Preload("Questions", orderByID).
+		Preload("Questions.Options", orderByID).
+		Preload("DrawRules", orderByID).
+		First(&quiz, quizID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+	return &quiz, nil
+}
+
+// GetQuizView builds the student-facing view of a quiz.
+//
+// Questions are hidden until the student starts an attempt, so reading them
+// always counts against the attempt timer, and follow the student's latest
+// attempt. Bank questions drawn for the attempt follow the questions of the quiz. When the quiz shuffles questions or
+// options, the order is derived from the seed of the student's attempt, so it
+// stays the same across page reloads. Options of Ordering questions are always
+// shuffled, since their stored order is the answer. Correct answers are only
+// revealed after the quiz has ended, and only if it shows its results.
+//
+// Users who may read the quiz without being its students, such as the other
+// teachers of the course, get the questions of the quiz without the answer key.
+//
+// Parameters:
+//   - userID: The ID of the user viewing the quiz.
+//   - quizID: The ID of the quiz.
+//
+// Returns:
+//   - *QuizView: The student-facing view of the quiz.
+//   - error: An error if the quiz is not found or the user may not read it, nil otherwise.
+func (q *QuizService) GetQuizView(userID, quizID uint) (*QuizView, error) {
+	quiz, err := q.GetQuiz(quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	var denied PermissionDeniedError
+	enrollment, err := canTakeQuiz(q.db, userID, quiz.CourseID)
+	if errors.As(err, &denied) {
+		if err := authorize(q.db, userID, policy.QuizRead, policy.Resource{CourseID: quiz.CourseID}); err != nil {
+			return nil, err
+		}
+		return readerView(quiz), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := studentSchedule(q.db, quiz, enrollment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	closesAt, err := quizClosesAt(q.db, quiz)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempts []models.QuizSubmission
+	if err := q.db.Where("quiz_id = ? AND user_id = ?", quizID, userID).
+		Order("number ASC").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	view := &QuizView{
+		ID:               quiz.ID,
+		Title:            quiz.Title,
+		Description:      quiz.Description,
+		CourseID:         quiz.CourseID,
+		StartTime:        schedule.StartTime,
+		EndTime:          schedule.EndTime,
+		Duration:         schedule.Duration,
+		MaxAttempts:      schedule.MaxAttempts,
+		AttemptScoring:   quiz.AttemptScoring,
+		AttemptsUsed:     len(attempts),
+		ShowResults:      quiz.ShowResults,
+		ResultsAvailable: resultsAvailable(quiz, closesAt, time.Now()),
+		Questions:        []QuestionView{},
+	}
+
+	if view.ResultsAvailable {
+		if score, ok := FinalScore(quiz.AttemptScoring, attempts); ok {
+			view.FinalScore = &score
+		}
+	}
+
+	if len(attempts) == 0 {
+		return view, nil
+	}
+	attempt := attempts[len(attempts)-1]
+
+	drawn, err := drawnQuestions(q.db, attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, question := range append(quiz.Questions, drawn...) {
+		view.Questions = append(view.Questions, questionView(question, view.ResultsAvailable))
+	}
+
+	rng := rand.New(rand.NewSource(attempt.Seed))
+	if quiz.ShuffleQuestions {
+		rng.Shuffle(len(view.Questions), func(i, j int) {
+			view.Questions[i], view.Questions[j] = view.Questions[j], view.Questions[i]
+		})
+	}
+
+	for _, question := range view.Questions {
+		if !quiz.ShuffleOptions && question.Type != models.Ordering {
+			continue
+		}
+
+		options := question.Options
+		rng.Shuffle(len(options), func(i, j int) {
+			options[i], options[j] = options[j], options[i]
+		})
+	}
+
+	return view, nil
+}
+
+// readerView is the view of a quiz for those who may read it without taking it,
+// such as the other teachers of the course. It shows the questions of the quiz
+// but never the answer key, so the options of Ordering questions are shuffled
+// as they are for students.
+func readerView(quiz *models.Quiz) *QuizView {
+	view := &QuizView{
+		ID:             quiz.ID,
+		Title:          quiz.Title,
+		Description:    quiz.Description,
+		CourseID:       quiz.CourseID,
+		StartTime:      quiz.StartTime,
+		EndTime:        quiz.EndTime,
+		Duration:       quiz.Duration,
+		MaxAttempts:    quiz.MaxAttempts,
+		AttemptScoring: quiz.AttemptScoring,
+		ShowResults:    quiz.ShowResults,
+		Questions:      make([]QuestionView, 0, len(quiz.Questions)),
+	}
+
+	rng := rand.New(rand.NewSource(int64(quiz.ID)))
+	for _, question := range quiz.Questions {
+		question := questionView(question, false)
+		if question.Type == models.Ordering {
+			rng.Shuffle(len(question.Options), func(i, j int) {
+				question.Options[i], question.Options[j] = question.Options[j], question.Options[i]
+			})
+		}
+		view.Questions = append(view.Questions, question)
+	}
+	return view
+}
+
+// questionView strips the answer key from a question unless it may be revealed.
+func questionView(question models.Question, revealAnswers bool) QuestionView {
+	view := QuestionView{
+		ID:          question.ID,
+		Title:       question.Title,
+		Description: question.Description,
+		Type:        question.Type,
+		Points:      question.Points,
+		Options:     make([]OptionView, 0, len(question.Options)),
+	}
+
+	revealOptions := revealAnswers && question.Type != models.Ordering
+	for _, option := range question.Options {
+		optionView := OptionView{ID: option.ID, Text: option.Text}
+		if revealOptions {
+			isCorrect := option.IsCorrect
+			optionView.IsCorrect = &isCorrect
+		}
+		view.Options = append(view.Options, optionView)
+	}
+
+	if !revealAnswers {
+		return view
+	}
+
+	switch question.Type {
+	case models.Numeric:
+		view.AnswerKey = &AnswerKey{NumericAnswer: question.NumericAnswer, Tolerance: question.Tolerance}
+	case models.ShortText:
+		view.AnswerKey = &AnswerKey{AcceptedAnswers: question.AcceptedAnswers}
+	case models.Ordering:
+		view.AnswerKey = &AnswerKey{CorrectOrder: correctOrder(question)}
+	}
+
+	return view
+}
+
+// resultsAvailable reports whether students may see scores and correct answers.
+// closesAt is the moment the quiz closes for every student, see quizClosesAt.
+func resultsAvailable(quiz *models.Quiz, closesAt, now time.Time) bool {
+	return quiz.ShowResults && !now.Before(closesAt)
+}
+
+// orderByID keeps preloaded questions and options in creation order.
+func orderByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id ASC")
+}
+
+// unscoped makes a preload include soft-deleted records.
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// notDeleted leaves soft-deleted records out of a preload. Preloads of an
+// unscoped query are unscoped too, unless they say otherwise.
+func notDeleted(db *gorm.DB) *gorm.DB {
+	return db.Where("deleted_at IS NULL")
+}
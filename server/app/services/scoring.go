@@ -0,0 +1,152 @@
+package services
+
+import (
+	"math"
+	"server/app/models"
+)
+
+// numericEpsilon keeps exact numeric answers from failing on float rounding.
+const numericEpsilon = 1e-9
+
+// ScoreAnswer computes the points earned by an answer to a question.
+//
+// SingleCorrect, TrueFalse, Numeric and ShortText questions are all-or-nothing.
+// MultiCorrect and Ordering questions are scored according to the given policy,
+// and an empty policy is treated as all-or-nothing. Essay questions are graded
+// by a teacher and always score zero here.
+//
+// Parameters:
+//   - question: The question with its options loaded.
+//   - answer: The answer given by the student.
+//   - policy: The scoring policy of the quiz.
+//
+// Returns:
+//   - float64: The points earned, between zero and question.Points.
+func ScoreAnswer(question models.Question, answer models.Answer, policy models.ScoringPolicy) float64 {
+	var credit float64
+
+	switch question.Type {
+	case models.Numeric:
+		credit = numericCredit(question, answer.NumericAnswer)
+	case models.ShortText:
+		credit = shortTextCredit(question, answer.TextAnswer)
+	case models.Ordering:
+		credit = orderingCredit(question, answer.SelectedOptions, policy)
+	case models.Essay:
+		return 0
+	default:
+		credit = optionsCredit(question, answer.SelectedOptions, policy)
+	}
+
+	return roundPoints(math.Max(credit, 0) * float64(question.Points))
+}
+
+// optionsCredit scores questions answered by selecting options.
+func optionsCredit(question models.Question, selected []uint, policy models.ScoringPolicy) float64 {
+	correct := make(map[uint]bool, len(question.Options))
+	totalCorrect := 0
+	for _, option := range question.Options {
+		correct[option.ID] = option.IsCorrect
+		if option.IsCorrect {
+			totalCorrect++
+		}
+	}
+	totalWrong := len(question.Options) - totalCorrect
+
+	correctSelected, wrongSelected := 0, 0
+	for _, id := range selected {
+		if correct[id] {
+			correctSelected++
+		} else {
+			wrongSelected++
+		}
+	}
+
+	allCorrect := correctSelected == totalCorrect && wrongSelected == 0
+
+	if question.Type != models.MultiCorrect || totalCorrect == 0 {
+		if allCorrect && len(selected) <= 1 {
+			return 1
+		}
+		return 0
+	}
+
+	switch policy {
+	case models.ScoringPartialCredit:
+		if wrongSelected > 0 {
+			return 0
+		}
+		return float64(correctSelected) / float64(totalCorrect)
+
+	case models.ScoringNegativeMarking:
+		credit := float64(correctSelected) / float64(totalCorrect)
+		if totalWrong > 0 {
+			credit -= float64(wrongSelected) / float64(totalWrong)
+		}
+		return credit
+
+	default:
+		if allCorrect {
+			return 1
+		}
+		return 0
+	}
+}
+
+// orderingCredit scores Ordering questions. Under the all-or-nothing policy the
+// whole order must match; otherwise each option in its correct place earns a share.
+func orderingCredit(question models.Question, order []uint, policy models.ScoringPolicy) float64 {
+	expected := correctOrder(question)
+	if len(order) != len(expected) || len(expected) == 0 {
+		return 0
+	}
+
+	inPlace := 0
+	for i := range expected {
+		if order[i] == expected[i] {
+			inPlace++
+		}
+	}
+
+	if policy == models.ScoringPartialCredit || policy == models.ScoringNegativeMarking {
+		return float64(inPlace) / float64(len(expected))
+	}
+
+	if inPlace == len(expected) {
+		return 1
+	}
+	return 0
+}
+
+// numericCredit scores Numeric questions, accepting answers within the tolerance.
+func numericCredit(question models.Question, value *float64) float64 {
+	if value == nil || question.NumericAnswer == nil {
+		return 0
+	}
+
+	if math.Abs(*value-*question.NumericAnswer) <= question.Tolerance+numericEpsilon {
+		return 1
+	}
+	return 0
+}
+
+// shortTextCredit scores ShortText questions, ignoring case and extra whitespace.
+func shortTextCredit(question models.Question, text string) float64 {
+	given := normalizeText(text)
+	if given == "" {
+		return 0
+	}
+
+	for _, accepted := range question.AcceptedAnswers {
+		if normalizeText(accepted) == given {
+			return 1
+		}
+	}
+	return 0
+}
+
+// roundPoints rounds points to two decimal places so that partial credit
+// doesn't accumulate floating point noise in stored scores.
+func roundPoints(points float64) float64 {
+	return math.Round(points*100) / 100
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	apperror "server/app/error"
+	"server/app/identity"
+	"server/app/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// refreshTokenExpiry is how long a session lasts without being refreshed.
+const refreshTokenExpiry = 30 * 24 * time.Hour
+
+// SessionService keeps track of the signed-in sessions of users, and checks
+// their personal access tokens for AuthMiddleware.
+type SessionService struct {
+	db *gorm.DB
+}
+
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// ValidateSession checks that a session is still live: not revoked, not
+// expired, and belonging to a user that still exists and is active. Sessions
+// opened to impersonate the user also need the impersonator to still be an
+// active admin.
+//
+// Parameters:
+//   - userID: The ID of the user the access token was issued to.
+//   - sessionID: The ID of the session the access token was issued for.
+//
+// Returns:
+//   - error: An InvalidToken error if the session is no longer live, nil otherwise.
+func (s *SessionService) ValidateSession(userID, sessionID uint) error {
+	var count int64
+	err := s.db.Model(&models.Session{}).
+		Joins("JOIN users ON users.id = sessions.user_id AND users.deleted_at IS NULL").
+		Joins("LEFT JOIN users AS impersonators ON impersonators.id = sessions.impersonator_id AND impersonators.deleted_at IS NULL").
+		Where("sessions.id = ? AND sessions.user_id = ?", sessionID, userID).
+		Where("sessions.revoked_at IS NULL AND sessions.expires_at > ?", time.Now()).
+		Where("users.active = ?", true).
+		Where("sessions.impersonator_id IS NULL OR (impersonators.active = ? AND impersonators.role = ?)",
+			true, models.RoleAdmin).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return apperror.InvalidToken{}
+	}
+	return nil
+}
+
+// ValidateAPIToken checks a personal access token, returning the identity it acts as.
+//
+// Parameters:
+//   - token: The personal access token.
+//
+// Returns:
+//   - identity.Identity: The user of the token, limited to its scope.
+//   - error: An InvalidToken error if the token is unknown, revoked or expired, or its user is inactive, nil otherwise.
+func (s *SessionService) ValidateAPIToken(token string) (identity.Identity, error) {
+	return validateAPIToken(s.db, token)
+}
+
+// RevokeSession revokes a single session of a user.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - sessionID: The ID of the session to revoke.
+//
+// Returns:
+//   - error: An error if the session is not found or the update fails, nil otherwise.
+func (s *SessionService) RevokeSession(userID, sessionID uint) error {
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return UpdateEntityFailure(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return EntityNotFound(gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// RevokeAllSessions revokes every session of a user, signing them out on all devices.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - error: An error if the update fails, nil otherwise.
+func (s *SessionService) RevokeAllSessions(userID uint) error {
+	return revokeUserSessions(s.db, userID)
+}
+
+// startSession creates a session for a user with its first refresh token.
+func startSession(tx *gorm.DB, userID uint) (*models.Session, string, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     userID,
+		ExpiresAt:  now.Add(refreshTokenExpiry),
+		LastUsedAt: now,
+	}
+
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, "", CreateEntityFailure(err)
+	}
+
+	token, err := issueRefreshToken(tx, session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &session, token, nil
+}
+
+// refreshTokenReused reports that a spent refresh token was presented again.
+// The session must then be revoked, as either the client or an attacker holds
+// a stolen copy. It is returned rather than acted upon so that the revocation
+// survives the rollback of the transaction that found it.
+type refreshTokenReused struct {
+	sessionID uint
+}
+
+func (e refreshTokenReused) Error() string {
+	return apperror.InvalidToken{}.Error()
+}
+
+// rotateRefreshToken spends a refresh token and issues the next one of its
+// session. It fails with refreshTokenReused if the token was already spent.
+func rotateRefreshToken(tx *gorm.DB, refreshToken string) (*models.Session, string, error) {
+	var stored models.RefreshToken
+	if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", apperror.InvalidToken{}
+		}
+		return nil, "", err
+	}
+
+	var session models.Session
+	if err := tx.First(&session, stored.SessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", apperror.InvalidToken{}
+		}
+		return nil, "", err
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return nil, "", apperror.InvalidToken{}
+	}
+
+	// The condition on used_at makes concurrent refreshes with the same token
+	// spend it only once.
+	result := tx.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", stored.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, "", refreshTokenReused{sessionID: session.ID}
+	}
+
+	session.ExpiresAt = now.Add(refreshTokenExpiry)
+	session.LastUsedAt = now
+	if err := tx.Model(&session).Select("expires_at", "last_used_at").Updates(&session).Error; err != nil {
+		return nil, "", err
+	}
+
+	token, err := issueRefreshToken(tx, session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &session, token, nil
+}
+
+// revokeUserSessions revokes every live session of a user, including those
+// they opened as an admin to impersonate others.
+func revokeUserSessions(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.Session{}).
+		Where("(user_id = ? OR impersonator_id = ?) AND revoked_at IS NULL", userID, userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+	return nil
+}
+
+// issueRefreshToken generates a refresh token for a session and stores its hash.
+func issueRefreshToken(tx *gorm.DB, sessionID uint) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Create(&models.RefreshToken{SessionID: sessionID, TokenHash: hashToken(token)}).Error; err != nil {
+		return "", CreateEntityFailure(err)
+	}
+	return token, nil
+}
+
+// randomToken returns 32 random bytes, base64url encoded.
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the hex SHA-256 hash of a token, as stored in the database.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -6,6 +6,7 @@ import (
 	"mime/multipart"
 	"server/app/firebase"
 	"server/app/models"
+	"server/app/policy"
 	"time"
 
 	"gorm.io/gorm"
@@ -265,40 +266,31 @@ func (s *SubmissionService) GradeSubmission(submissionID, gradedBy uint, score f
 //   - bool: True if the user can see the submission, false otherwise.
 //   - error: An error if the database query fails, nil otherwise.
 //
-// The function allows access if:
+// The function allows access if the submission:read permission covers the
+// submission, that is if:
 //  1. The user is the owner of the submission.
 //  2. The user is an admin or teacher for the course associated with the submission.
+//  3. The user is a global admin.
 //
 // Possible errors:
 //   - If the submission is not found in the database.
 //   - If there's an error querying the database for enrollment information.
 func (s *SubmissionService) CanSeeSubmission(userID, submissionID uint) (bool, error) {
-	var submission models.Submission
-	err := s.db.Preload("Assignment.Course").First(&submission, submissionID).Error
+	res, err := NewAuthorizationService(s.db).SubmissionResource(submissionID)
 	if err != nil {
 		return false, err
 	}
 
-	if submission.UserID == userID {
-		return true, nil
-	}
-
-	var count int64
-	var enrollment models.Enrollment
-	roles := []string{
-		models.RoleAdmin.String(),
-		models.RoleTeacher.String(),
+	var denied PermissionDeniedError
+	err = authorize(s.db, userID, policy.SubmissionRead, res)
+	if errors.As(err, &denied) {
+		return false, nil
 	}
-
-	courseId := submission.Assignment.CourseID
-	err = s.db.Model(&enrollment).
-		Where("user_id = ? AND course_id = ? AND role IN ?", userID, courseId, roles).
-		Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 
-	return count > 0, nil
+	return true, nil
 }
 
 // GetSubmissionsForAssignment retrieves all submissions for a given assignment.
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"server/app/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// throttleWindow is how long failed logins count for: a key whose last failure
+// is older starts over.
+const throttleWindow = 24 * time.Hour
+
+// ThrottlePolicy sets how failed logins slow down further attempts. After
+// BackoffAfter failures, each attempt must wait BaseDelay, doubled with every
+// further failure. After LockAfter failures, the key is locked out for Lockout,
+// and every further failure locks it out again.
+type ThrottlePolicy struct {
+	BackoffAfter int
+	BaseDelay    time.Duration
+	LockAfter    int
+	Lockout      time.Duration
+}
+
+var (
+	// AccountThrottle limits guessing the password of one account.
+	AccountThrottle = ThrottlePolicy{BackoffAfter: 3, BaseDelay: time.Second, LockAfter: 10, Lockout: 15 * time.Minute}
+	// IPThrottle limits guessing from one IP address. Schools put many users
+	// behind one address, so it allows far more failures than AccountThrottle.
+	IPThrottle = ThrottlePolicy{BackoffAfter: 20, BaseDelay: time.Second, LockAfter: 100, Lockout: 15 * time.Minute}
+)
+
+// Delay returns how long to wait before the next attempt after a number of failures.
+//
+// Parameters:
+//   - failures: The number of recent failures.
+//
+// Returns:
+//   - time.Duration: The time to wait, 0 if none.
+//   - bool: True if the failures lock the key out.
+func (p ThrottlePolicy) Delay(failures int) (time.Duration, bool) {
+	switch {
+	case failures >= p.LockAfter:
+		return p.Lockout, true
+	case failures >= p.BackoffAfter:
+		delay := p.BaseDelay
+		for i := p.BackoffAfter; i < failures && delay < p.Lockout; i++ {
+			delay *= 2
+		}
+		if delay > p.Lockout {
+			delay = p.Lockout
+		}
+		return delay, false
+	default:
+		return 0, false
+	}
+}
+
+// LoginThrottleService tracks failed logins per account and per IP address, to
+// slow down and then lock out password guessing.
+type LoginThrottleService struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleService(db *gorm.DB) *LoginThrottleService {
+	return &LoginThrottleService{db: db}
+}
+
+// Check tells whether a login for an account from an IP address may be tried now.
+//
+// Parameters:
+//   - email: The email address of the account, or "" to check the IP address only.
+//   - ip: The IP address of the client.
+//
+// Returns:
+//   - error: A TooManyAttempts error with the time left if the account or the IP address is throttled, nil otherwise.
+func (s *LoginThrottleService) Check(email, ip string) error {
+	var throttles []models.LoginThrottle
+	if err := s.db.Where("key IN ? AND locked_until > ?", throttleKeys(email, ip), time.Now()).
+		Find(&throttles).Error; err != nil {
+		return err
+	}
+
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if left := time.Until(*throttle.LockedUntil); left > wait {
+			wait = left
+		}
+	}
+
+	if wait > 0 {
+		return TooManyAttempts(wait)
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against an account and an IP address,
+// throttling them as their policies say. Lockouts are written to the audit trail.
+//
+// Parameters:
+//   - email: The email address of the account, or "" to count against the IP address only.
+//   - ip: The IP address of the client.
+//
+// Returns:
+//   - error: An error if the failure cannot be recorded, nil otherwise.
+func (s *LoginThrottleService) RecordFailure(email, ip string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if email = normalizeEmail(email); email != "" {
+			if err := recordThrottleFailure(tx, "account:"+email, AccountThrottle, func(entry *models.AuditLog) error {
+				var user models.User
+				err := tx.Select("id").Where("LOWER(email) = ?", email).First(&user).Error
+				if err == nil {
+					entry.UserID = &user.ID
+				} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+				entry.IP = ip
+				entry.Details = fmt.Sprintf("account %s locked out after repeated failed logins", email)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		if ip != "" {
+			return recordThrottleFailure(tx, "ip:"+ip, IPThrottle, func(entry *models.AuditLog) error {
+				entry.IP = ip
+				entry.Details = fmt.Sprintf("IP address %s locked out after repeated failed logins", ip)
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// RecordSuccess clears the failed logins of an account after its password was
+// entered correctly. The failures of the IP address still count, so that
+// signing in to an account of one's own doesn't hide guessing at others.
+//
+// Parameters:
+//   - email: The email address of the account.
+//
+// Returns:
+//   - error: An error if the failures cannot be cleared, nil otherwise.
+func (s *LoginThrottleService) RecordSuccess(email string) error {
+	return clearThrottle(s.db, "account:"+normalizeEmail(email))
+}
+
+// Unlock lifts the lockout of an account and clears its failed logins.
+//
+// Parameters:
+//   - actorID: The ID of the admin unlocking the account.
+//   - userID: The ID of the user whose account to unlock.
+//
+// Returns:
+//   - error: An EntityNotFound error if the user doesn't exist, nil otherwise.
+func (s *LoginThrottleService) Unlock(actorID, userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return EntityNotFound(err)
+			}
+			return err
+		}
+
+		if err := clearThrottle(tx, "account:"+normalizeEmail(user.Email)); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, models.AuditLog{
+			Action:  models.AuditAccountUnlock,
+			ActorID: &actorID,
+			UserID:  &user.ID,
+			Details: fmt.Sprintf("account %s unlocked", user.Email),
+		})
+	})
+}
+
+// recordThrottleFailure counts a failure against a key, and writes an audit
+// entry, filled in by describe, when the failure locks the key out.
+func recordThrottleFailure(tx *gorm.DB, key string, policy ThrottlePolicy, describe func(*models.AuditLog) error) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Key: key}).Error; err != nil {
+		return CreateEntityFailure(err)
+	}
+
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("key = ?", key).First(&throttle).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Sub(throttle.LastFailureAt) > throttleWindow {
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	delay, locked := policy.Delay(throttle.Failures)
+	throttle.LockedUntil = nil
+	if delay > 0 {
+		until := now.Add(delay)
+		throttle.LockedUntil = &until
+	}
+
+	if err := tx.Save(&throttle).Error; err != nil {
+		return UpdateEntityFailure(err)
+	}
+
+	if !locked {
+		return nil
+	}
+
+	entry := models.AuditLog{Action: models.AuditLoginLockout}
+	if err := describe(&entry); err != nil {
+		return err
+	}
+	return recordAudit(tx, entry)
+}
+
+// clearThrottle forgets the failed logins of a key.
+func clearThrottle(tx *gorm.DB, key string) error {
+	if err := tx.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error; err != nil {
+		return DeleteEntityFailure(err)
+	}
+	return nil
+}
+
+// throttleKeys returns the keys of an account and an IP address, leaving out empty ones.
+func throttleKeys(email, ip string) []string {
+	var keys []string
+	if email = normalizeEmail(email); email != "" {
+		keys = append(keys, "account:"+email)
+	}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+// normalizeEmail makes the keys of an account match however its address was typed.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
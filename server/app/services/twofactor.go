@@ -0,0 +1,273 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	apperror "server/app/error"
+	"server/app/models"
+	"server/app/totp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// twoFactorIssuer is the name authenticator apps show for the account.
+	twoFactorIssuer = "Glass"
+	// recoveryCodeCount is how many recovery codes a user gets at a time.
+	recoveryCodeCount = 10
+)
+
+// TwoFactorService handles setting up and turning off two-factor authentication with TOTP.
+type TwoFactorService struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorService(db *gorm.DB) *TwoFactorService {
+	return &TwoFactorService{db: db}
+}
+
+// TwoFactorSetup is what a user needs to add their account to an authenticator app.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth:// provisioning URI, usually shown as a QR code
+}
+
+// Setup generates a new TOTP secret for a user. Two-factor authentication is
+// not enabled until the user confirms a code with Enable; calling Setup again
+// before that replaces the secret.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - *TwoFactorSetup: The secret and its provisioning URI.
+//   - error: A CannotPerformAction error if two-factor authentication is already enabled, nil otherwise.
+func (s *TwoFactorService) Setup(userID uint) (*TwoFactorSetup, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return EntityNotFound(err)
+			}
+			return err
+		}
+
+		enabled, err := twoFactorEnabled(tx, userID)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			return CannotPerformAction("set up two-factor authentication that is already enabled")
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactor{}).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+		if err := tx.Create(&models.TwoFactor{UserID: userID, Secret: secret}).Error; err != nil {
+			return CreateEntityFailure(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(twoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// Enable turns on two-factor authentication once the user enters a code from
+// their app for the secret from Setup, and issues their recovery codes.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - code: A code from the authenticator app.
+//
+// Returns:
+//   - []string: The recovery codes. They are not stored and cannot be shown again.
+//   - error: An InvalidCredential error if the code is wrong, a CannotPerformAction error if
+//     Setup was not called or two-factor authentication is already enabled, nil otherwise.
+func (s *TwoFactorService) Enable(userID uint, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var twoFactor models.TwoFactor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).First(&twoFactor).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return CannotPerformAction("enable two-factor authentication before setting it up")
+			}
+			return err
+		}
+		if twoFactor.EnabledAt != nil {
+			return CannotPerformAction("enable two-factor authentication that is already enabled")
+		}
+
+		step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+		if !ok {
+			return apperror.InvalidCredential{}
+		}
+
+		if err := tx.Model(&twoFactor).Updates(map[string]interface{}{
+			"enabled_at": time.Now(),
+			"last_step":  step,
+		}).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns off two-factor authentication, after checking a code from the
+// authenticator app or a recovery code.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - code: A TOTP code or an unused recovery code.
+//
+// Returns:
+//   - error: An InvalidCredential error if the code is wrong, a CannotPerformAction error
+//     if two-factor authentication is not enabled, nil otherwise.
+func (s *TwoFactorService) Disable(userID uint, code string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkSecondFactor(tx, userID, code); err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactor{}).Error; err != nil {
+			return DeleteEntityFailure(err)
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user, after checking
+// a code from the authenticator app. The old recovery codes stop working.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - code: A TOTP code or an unused recovery code.
+//
+// Returns:
+//   - []string: The new recovery codes.
+//   - error: An InvalidCredential error if the code is wrong, a CannotPerformAction error
+//     if two-factor authentication is not enabled, nil otherwise.
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkSecondFactor(tx, userID, code); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// twoFactorEnabled reports whether a user has turned on two-factor authentication.
+func twoFactorEnabled(tx *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.TwoFactor{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// checkSecondFactor checks a TOTP code, or else a recovery code, of a user with
+// two-factor authentication enabled. Accepted codes are spent: a TOTP code by
+// moving past its time step, a recovery code by marking it used.
+func checkSecondFactor(tx *gorm.DB, userID uint, code string) error {
+	var twoFactor models.TwoFactor
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).
+		First(&twoFactor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return CannotPerformAction("use two-factor authentication that is not enabled")
+		}
+		return err
+	}
+
+	if step, ok := totp.Validate(twoFactor.Secret, code, time.Now()); ok {
+		if step <= twoFactor.LastStep {
+			return apperror.InvalidCredential{}
+		}
+		if err := tx.Model(&twoFactor).Update("last_step", step).Error; err != nil {
+			return UpdateEntityFailure(err)
+		}
+		return nil
+	}
+
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return UpdateEntityFailure(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperror.InvalidCredential{}
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and issues new ones.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, DeleteEntityFailure(err)
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(normalizeRecoveryCode(code))}
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, CreateEntityFailure(err)
+	}
+	return codes, nil
+}
+
+// newRecoveryCode returns a random code of 10 base32 characters, such as "k7qm2-x4tpa".
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode makes recovery codes match however the user typed them.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
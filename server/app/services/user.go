@@ -4,12 +4,20 @@ import (
 	"errors"
 	apperror "server/app/error"
 	"server/app/models"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"gorm.io/gorm"
 )
 
+// twoFactorTokenExpiry is how long a user has to enter their second factor after their password.
+const twoFactorTokenExpiry = 5 * time.Minute
+
+// twoFactorPurpose is the "purpose" claim of partial tokens, which only the
+// two-factor verify endpoint accepts.
+const twoFactorPurpose = "2fa"
+
 // UserService handles user-related operations and authentication.
 type UserService struct {
 	db          *gorm.DB
@@ -26,8 +34,11 @@ func NewUserService(db *gorm.DB, jwtSecret []byte, tokenExpiry time.Duration) *U
 	}
 }
 
-// CreateUser creates a new user in the database.
-func (s *UserService) CreateUser(user *models.User) error {
+// CreateUser creates a new user in the database, with the given password.
+func (s *UserService) CreateUser(user *models.User, password string) error {
+	if err := user.SetPassword(password); err != nil {
+		return InvalidInput(err)
+	}
 	return s.db.Create(user).Error
 }
 
@@ -54,33 +65,324 @@ func (s *UserService) UpdateUser(user *models.User) error {
 	return s.db.Save(user).Error
 }
 
-// DeleteUser removes a user from the database by their ID.
+// ProfileUpdate holds the fields of their profile users may edit. Fields left
+// out keep their value.
+type ProfileUpdate struct {
+	FirstName   *string    `json:"firstName"`
+	LastName    *string    `json:"lastName"`
+	Email       *string    `json:"email"`
+	DateOfBirth *time.Time `json:"dateOfBirth"`
+	Bio         *string    `json:"bio"`
+}
+
+// UpdateProfile updates the profile of a user. A new email address has to be
+// verified again.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - update: The fields to change.
+//
+// Returns:
+//   - *models.User: The updated user.
+//   - error: An EntityNotFound error if the user doesn't exist, an InvalidInput error if
+//     the email is empty, nil otherwise.
+func (s *UserService) UpdateProfile(userID uint, update ProfileUpdate) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EntityNotFound(err)
+		}
+		return nil, err
+	}
+
+	if update.FirstName != nil {
+		user.FirstName = *update.FirstName
+	}
+	if update.LastName != nil {
+		user.LastName = *update.LastName
+	}
+	if update.DateOfBirth != nil {
+		user.DateOfBirth = *update.DateOfBirth
+	}
+	if update.Bio != nil {
+		user.Bio = *update.Bio
+	}
+	if update.Email != nil {
+		email := strings.TrimSpace(*update.Email)
+		if email == "" {
+			return nil, InvalidInput(errors.New("email is required"))
+		}
+		if email != user.Email {
+			user.Email = email
+			user.EmailVerifiedAt = nil
+		}
+	}
+
+	if err := s.db.Model(&user).
+		Select("first_name", "last_name", "email", "date_of_birth", "bio", "email_verified_at").
+		Updates(&user).Error; err != nil {
+		return nil, UpdateEntityFailure(err)
+	}
+	return &user, nil
+}
+
+// DeleteUser removes a user from the database by their ID and revokes their
+// sessions, so that their access tokens stop working at once.
 func (s *UserService) DeleteUser(id uint) error {
-	return s.db.Delete(&models.User{}, id).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeUserSessions(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, id).Error
+	})
+}
+
+// TokenPair is what a client gets when signing in or refreshing: a short-lived
+// access token and the single-use refresh token that renews it.
+//
+// Users with two-factor authentication get a TwoFactorToken instead, at the
+// login with their password. It is not an access token: it can only be
+// exchanged, with a code, for the tokens of a session by VerifyTwoFactor.
+type TokenPair struct {
+	AccessToken    string    `json:"token,omitempty"`
+	RefreshToken   string    `json:"refreshToken,omitempty"`
+	TwoFactorToken string    `json:"twoFactorToken,omitempty"`
+	ExpiresAt      time.Time `json:"expiresAt"` // When the access token, or the two-factor token, expires
 }
 
-// AuthenticateUser authenticates a user and returns a JWT token if successful.
-func (s *UserService) AuthenticateUser(email, password string) (string, error) {
+// AuthenticateUser authenticates a user and starts a session, returning its
+// first access and refresh tokens. For users with two-factor authentication it
+// returns a partial token carrying only TwoFactorToken, and no session starts
+// until VerifyTwoFactor.
+//
+// Parameters:
+//   - email: The email address of the user.
+//   - password: The password of the user.
+//
+// Returns:
+//   - *TokenPair: The tokens of the new session, or the two-factor token.
+//   - error: An InvalidCredential error if the credentials don't match, or an error if the session cannot be created.
+func (s *UserService) AuthenticateUser(email, password string) (*TokenPair, error) {
 	user, err := s.GetUserByEmail(email)
 	if err != nil {
-		return "", err
+		return nil, apperror.InvalidCredential{}
+	}
+
+	if !user.Active || !user.CheckPassword(password) {
+		return nil, apperror.InvalidCredential{}
+	}
+
+	return s.login(user)
+}
+
+// login signs in a user who proved who they are with a first factor, or returns
+// the two-factor token if they must still enter a code.
+func (s *UserService) login(user *models.User) (*TokenPair, error) {
+	enabled, err := twoFactorEnabled(s.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return s.twoFactorToken(user)
+	}
+
+	return s.signIn(user)
+}
+
+// VerifyTwoFactor completes the login of a user with two-factor authentication,
+// exchanging the two-factor token from AuthenticateUser and a TOTP or recovery
+// code for the tokens of a new session.
+//
+// Parameters:
+//   - twoFactorToken: The two-factor token from AuthenticateUser.
+//   - code: A code from the authenticator app, or an unused recovery code.
+//
+// Returns:
+//   - *TokenPair: The tokens of the new session.
+//   - error: An InvalidToken error if the two-factor token is invalid or expired, an
+//     InvalidCredential error if the code is wrong, nil otherwise.
+func (s *UserService) VerifyTwoFactor(twoFactorToken, code string) (*TokenPair, error) {
+	user, err := s.TwoFactorTokenUser(twoFactorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return checkSecondFactor(tx, user.ID, code)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.signIn(user)
+}
+
+// TwoFactorTokenUser returns the user a two-factor token was issued to.
+//
+// Parameters:
+//   - twoFactorToken: The two-factor token from AuthenticateUser.
+//
+// Returns:
+//   - *models.User: The user, if still active.
+//   - error: An InvalidToken error if the token is invalid or expired, or the user is gone or inactive, nil otherwise.
+func (s *UserService) TwoFactorTokenUser(twoFactorToken string) (*models.User, error) {
+	token, err := s.VerifyToken(twoFactorToken)
+	if err != nil || !token.Valid {
+		return nil, apperror.InvalidToken{}
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != twoFactorPurpose {
+		return nil, apperror.InvalidToken{}
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, apperror.InvalidToken{}
+	}
+
+	user, err := s.GetUserByID(uint(userID))
+	if err != nil || !user.Active {
+		return nil, apperror.InvalidToken{}
 	}
+	return user, nil
+}
+
+// signIn starts a session for a user who proved who they are, returning its
+// first access and refresh tokens.
+func (s *UserService) signIn(user *models.User) (*TokenPair, error) {
+	var tokens *TokenPair
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		session, refreshToken, err := startSession(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		tokens, err = s.tokenPair(user, session, refreshToken)
+		return err
+	})
 
-	if !user.CheckPassword(password) {
-		return "", apperror.InvalidCredential{}
+	if err != nil {
+		return nil, err
 	}
+	return tokens, nil
+}
 
+// twoFactorToken signs the partial token a user exchanges at VerifyTwoFactor.
+// It has no session and carries a "purpose" claim, so AuthMiddleware refuses it.
+func (s *UserService) twoFactorToken(user *models.User) (*TokenPair, error) {
+	now := time.Now()
+	expiresAt := now.Add(twoFactorTokenExpiry)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": user.ID,
-		"exp":     time.Now().Add(s.tokenExpiry).Unix(),
+		"purpose": twoFactorPurpose,
+		"iat":     now.Unix(),
+		"exp":     expiresAt.Unix(),
 	})
 
 	tokenString, err := token.SignedString(s.jwtSecret)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return &TokenPair{TwoFactorToken: tokenString, ExpiresAt: expiresAt}, nil
+}
+
+// RefreshTokens spends a refresh token and returns a new access token with the
+// next refresh token of the session. Reusing a spent refresh token revokes the
+// session it belongs to.
+//
+// Parameters:
+//   - refreshToken: The refresh token to spend.
+//
+// Returns:
+//   - *TokenPair: The new tokens of the session.
+//   - error: An InvalidToken error if the refresh token is unknown, spent, or its session is no longer live, nil otherwise.
+func (s *UserService) RefreshTokens(refreshToken string) (*TokenPair, error) {
+	var tokens *TokenPair
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		session, next, err := rotateRefreshToken(tx, refreshToken)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, session.UserID).Error; err != nil || !user.Active {
+			return apperror.InvalidToken{}
+		}
+
+		tokens, err = s.tokenPair(&user, session, next)
+		return err
+	})
+
+	var reused refreshTokenReused
+	if errors.As(err, &reused) {
+		if err := s.db.Model(&models.Session{}).
+			Where("id = ?", reused.sessionID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return nil, err
+		}
+		return nil, apperror.InvalidToken{}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout revokes the session an access token was issued for.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//   - sessionID: The ID of the session to revoke.
+//
+// Returns:
+//   - error: An error if the session is not found or the revocation fails, nil otherwise.
+func (s *UserService) Logout(userID, sessionID uint) error {
+	return NewSessionService(s.db).RevokeSession(userID, sessionID)
+}
+
+// LogoutAll revokes every session of a user, signing them out on all devices.
+//
+// Parameters:
+//   - userID: The ID of the user.
+//
+// Returns:
+//   - error: An error if the revocation fails, nil otherwise.
+func (s *UserService) LogoutAll(userID uint) error {
+	return NewSessionService(s.db).RevokeAllSessions(userID)
+}
+
+// tokenPair signs an access token for a session of a user and pairs it with a refresh token.
+func (s *UserService) tokenPair(user *models.User, session *models.Session, refreshToken string) (*TokenPair, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.tokenExpiry)
+	// An access token never outlives its session.
+	if session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+	claims := jwt.MapClaims{
+		"user_id": session.UserID,
+		"sid":     session.ID,
+		"role":    user.Role,
+		"jti":     jti,
+		"iat":     now.Unix(),
+		"exp":     expiresAt.Unix(),
+	}
+	// Tokens of an impersonation say so, and who is behind it.
+	if session.ImpersonatorID != nil {
+		claims["imp"] = *session.ImpersonatorID
 	}
 
-	return tokenString, nil
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: tokenString, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
 }
 
 // VerifyToken verifies the validity of a JWT token.
@@ -114,6 +416,8 @@ func (s *UserService) ChangePassword(userID uint, oldPassword, newPassword strin
 		return errors.New("invalid old password")
 	}
 
-	user.Password = newPassword
+	if err := user.SetPassword(newPassword); err != nil {
+		return err
+	}
 	return s.UpdateUser(user)
 }
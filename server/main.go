@@ -20,21 +20,37 @@ func main() {
 	// db := config.InitDB()
 	// r := gin.Default()
 	// secret := os.Getenv("SECRET_KEY")
-	// expiration := 24 * time.Hour
+	// expiration := 15 * time.Minute
 	// cs, err := firebase.DefaultCloudStorage()
 	// if err != nil {
 	// 	panic(err)
 	// }
+	// mail, err := mailer.FromEnv()
+	// if err != nil {
+	// 	panic(err)
+	// }
+	// providers, err := oidc.FromEnv()
+	// if err != nil {
+	// 	panic(err)
+	// }
 	// r.Use(cors.Default())
 	// r.Use(gin.Logger())
 
-	// routes.SetUpUserRoutes(r, db, []byte(secret), expiration)
-	// routes.SetupCourseRoutes(r, db)
-	// routes.SetupGradeRoutes(r, db)
-	// routes.SetupAssignmentRoutes(r, db)
-	// routes.SetupEnrollmentRoutes(r, db)
-	// routes.SetupSubmissionRoutes(r, db, cs)
-	// routes.SetupMaterialRoutes(r, db, cs)
+	// routes.SetUpUserRoutes(r, db, []byte(secret), expiration, mail, os.Getenv("APP_URL"))
+	// routes.SetupOIDCRoutes(r, db, []byte(secret), expiration, providers)
+	// routes.SetupAdminRoutes(r, db, []byte(secret), expiration, mail, os.Getenv("APP_URL"))
+	// jobs := services.NewJobService(db)
+	// routes.SetupPrivacyRoutes(r, db, cs, jobs, secret)
+	// routes.SetupAvatarRoutes(r, db, cs, secret)
+	// routes.SetupCourseRoutes(r, db, secret)
+	// routes.SetupCourseCloneRoutes(r, db, cs, jobs, secret)
+	// routes.SetupGradeRoutes(r, db, secret)
+	// routes.SetupAssignmentRoutes(r, db, secret)
+	// routes.SetupEnrollmentRoutes(r, db, secret)
+	// routes.SetupSubmissionRoutes(r, db, cs, secret)
+	// routes.SetupMaterialRoutes(r, db, cs, secret)
 	// routes.SetupQuizRoutes(r, db, secret)
+	// routes.SetupQuestionBankRoutes(r, db, secret)
+	// go jobs.Run(ctx, 5*time.Second)
 	// _ = r.Run()
 }
@@ -2,12 +2,16 @@ package tests
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"server/app/identity"
 	"server/app/middlewares"
+	"server/app/models"
+	"server/app/policy"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
@@ -70,12 +74,12 @@ func TestAuthMiddleware(t *testing.T) {
 			router := gin.New()
 			router.Use(middlewares.AuthMiddleware(secretKey))
 			router.GET("/test", func(c *gin.Context) {
-				userID, exists := c.Get("userID")
+				id, exists := identity.Get(c)
 				if !exists {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "userID not set"})
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "identity not set"})
 					return
 				}
-				c.JSON(http.StatusOK, gin.H{"userID": userID})
+				c.JSON(http.StatusOK, gin.H{"userID": id.UserID})
 			})
 
 			w := httptest.NewRecorder()
@@ -241,3 +245,219 @@ func TestExtractUserID(t *testing.T) {
 		})
 	}
 }
+
+type fakeSessions struct {
+	revoked map[uint]bool
+}
+
+func (f fakeSessions) ValidateSession(userID, sessionID uint) error {
+	if f.revoked[sessionID] {
+		return errors.New("session revoked")
+	}
+	return nil
+}
+
+func TestAuthMiddlewareSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secretKey := "test_secret_key"
+
+	router := gin.New()
+	router.Use(middlewares.AuthMiddleware(secretKey, fakeSessions{revoked: map[uint]bool{2: true}}))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"sessionID": identity.MustGet(c).SessionID})
+	})
+
+	sessionToken := func(claims jwt.MapClaims) string {
+		claims["user_id"] = float64(1)
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secretKey))
+		return token
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "Live session", token: sessionToken(jwt.MapClaims{"sid": float64(1)}), expectedStatus: http.StatusOK},
+		{name: "Revoked session", token: sessionToken(jwt.MapClaims{"sid": float64(2)}), expectedStatus: http.StatusUnauthorized},
+		{name: "Token without session", token: createValidToken(secretKey), expectedStatus: http.StatusUnauthorized},
+		{name: "Two-factor token", token: sessionToken(jwt.MapClaims{"sid": float64(1), "purpose": "2fa"}), expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+// fakeTokens accepts the personal access token "glass_pat_valid" as user 7, and every session.
+type fakeTokens struct{}
+
+func (fakeTokens) ValidateSession(userID, sessionID uint) error { return nil }
+
+func (fakeTokens) ValidateAPIToken(token string) (identity.Identity, error) {
+	if token != "glass_pat_valid" {
+		return identity.Identity{}, errors.New("unknown token")
+	}
+	return identity.Identity{UserID: 7, TokenID: 3, Scope: &policy.TokenScope{Permissions: []policy.Permission{policy.GradeRead}}}, nil
+}
+
+func TestAuthMiddlewareAPITokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secretKey := "test_secret_key"
+
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"userID": identity.MustGet(c).UserID}) }
+	router := gin.New()
+	router.GET("/data", middlewares.AuthMiddleware(secretKey, fakeTokens{}), ok)
+	router.GET("/account", middlewares.SessionAuthMiddleware(secretKey, fakeTokens{}), ok)
+	router.GET("/sessions-only", middlewares.AuthMiddleware(secretKey, fakeSessions{}), ok)
+
+	sessionToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": float64(1),
+		"sid":     float64(1),
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(secretKey))
+
+	tests := []struct {
+		name           string
+		path           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "Token accepted", path: "/data", token: "glass_pat_valid", expectedStatus: http.StatusOK},
+		{name: "Unknown token", path: "/data", token: "glass_pat_other", expectedStatus: http.StatusUnauthorized},
+		{name: "Session still accepted", path: "/data", token: sessionToken, expectedStatus: http.StatusOK},
+		{name: "Token on an account route", path: "/account", token: "glass_pat_valid", expectedStatus: http.StatusForbidden},
+		{name: "Session on an account route", path: "/account", token: sessionToken, expectedStatus: http.StatusOK},
+		{name: "Validator without tokens", path: "/sessions-only", token: "glass_pat_valid", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestAuthMiddlewareIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secretKey := "test_secret_key"
+
+	router := gin.New()
+	router.Use(middlewares.AuthMiddleware(secretKey))
+	router.GET("/test", func(c *gin.Context) {
+		id := identity.MustGet(c)
+		c.JSON(http.StatusOK, gin.H{"userID": id.UserID, "role": id.Role})
+	})
+
+	roleToken := func(role interface{}) string {
+		claims := jwt.MapClaims{"user_id": float64(4), "exp": time.Now().Add(time.Hour).Unix()}
+		if role != nil {
+			claims["role"] = role
+		}
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secretKey))
+		return token
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		expectedRole models.Role
+	}{
+		{name: "Admin", token: roleToken("admin"), expectedRole: models.RoleAdmin},
+		{name: "Regular user", token: roleToken("user"), expectedRole: models.RoleUser},
+		{name: "Unknown role", token: roleToken("teacher"), expectedRole: models.RoleUser},
+		{name: "No role", token: roleToken(nil), expectedRole: models.RoleUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, float64(4), response["userID"])
+			assert.Equal(t, string(tt.expectedRole), response["role"])
+		})
+	}
+}
+
+func TestRejectImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secretKey := "test_secret_key"
+
+	router := gin.New()
+	router.Use(middlewares.AuthMiddleware(secretKey))
+	router.GET("/profile", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"impersonatorID": identity.MustGet(c).ImpersonatorID})
+	})
+	router.PUT("/profile", middlewares.RejectImpersonation(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	token := func(impersonator interface{}) string {
+		claims := jwt.MapClaims{"user_id": float64(4), "exp": time.Now().Add(time.Hour).Unix()}
+		if impersonator != nil {
+			claims["imp"] = impersonator
+		}
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secretKey))
+		return token
+	}
+
+	tests := []struct {
+		name                 string
+		token                string
+		expectedImpersonator float64
+		expectedUpdate       int
+	}{
+		{name: "Own session", token: token(nil), expectedImpersonator: 0, expectedUpdate: http.StatusOK},
+		{name: "Impersonated by an admin", token: token(9), expectedImpersonator: 9, expectedUpdate: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/profile", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedImpersonator, response["impersonatorID"])
+
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest("PUT", "/profile", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedUpdate, w.Code)
+		})
+	}
+}
+
+func TestMustGetWithoutIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	_, ok := identity.Get(c)
+	assert.False(t, ok)
+	assert.Panics(t, func() { identity.MustGet(c) })
+}
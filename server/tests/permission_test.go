@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/app/identity"
+	"server/app/middlewares"
+	"server/app/models"
+	"server/app/policy"
+	"server/app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAuthorizer applies the policy with fixed course roles: user 1 teaches
+// course 1, user 2 studies in it and user 9 is a global admin.
+type fakeAuthorizer struct{}
+
+func (fakeAuthorizer) Authorize(userID uint, perm policy.Permission, res policy.Resource) error {
+	if userID == 9 {
+		return nil
+	}
+
+	roles := map[uint]models.Role{1: models.RoleTeacher, 2: models.RoleStudent}
+	scope := policy.PlatformScope(perm)
+	if res.CourseID != 0 {
+		scope = policy.CourseScope(roles[userID], perm)
+	}
+
+	if !policy.Allows(scope, userID, res) {
+		return services.PermissionDenied()
+	}
+	return nil
+}
+
+func submissionResource(id uint) (policy.Resource, error) {
+	if id != 5 {
+		return policy.Resource{}, services.EntityNotFound(nil)
+	}
+	return policy.Resource{CourseID: 1, OwnerID: 2}, nil
+}
+
+func courseResource(id uint) (policy.Resource, error) {
+	return policy.Resource{CourseID: id}, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authz := fakeAuthorizer{}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		var userID uint
+		switch c.GetHeader("X-User") {
+		case "teacher":
+			userID = 1
+		case "student":
+			userID = 2
+		case "outsider":
+			userID = 3
+		case "admin":
+			userID = 9
+		}
+		id := identity.Identity{UserID: userID}
+		switch c.GetHeader("X-Token-Scope") {
+		case "read":
+			id.Scope = &policy.TokenScope{Permissions: []policy.Permission{policy.SubmissionRead}}
+		case "read-course-2":
+			id.Scope = &policy.TokenScope{Permissions: []policy.Permission{policy.SubmissionRead}, CourseID: 2}
+		}
+		identity.Set(c, id)
+	})
+	router.GET("/submissions/:id", middlewares.RequirePermission(authz, policy.SubmissionRead,
+		middlewares.ParamResource("id", submissionResource)), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.DELETE("/submissions/:id", middlewares.RequirePermission(authz, policy.SubmissionDelete,
+		middlewares.ParamResource("id", submissionResource)), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/courses", middlewares.RequirePermission(authz, policy.CourseCreate, nil),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/assignments", middlewares.RequirePermission(authz, policy.AssignmentCreate,
+		middlewares.JSONResource("courseId", courseResource)), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		user           string
+		body           string
+		tokenScope     string
+		expectedStatus int
+	}{
+		{name: "Owner reads own submission", method: "GET", path: "/submissions/5", user: "student", expectedStatus: http.StatusOK},
+		{name: "Teacher reads submission", method: "GET", path: "/submissions/5", user: "teacher", expectedStatus: http.StatusOK},
+		{name: "Outsider cannot read submission", method: "GET", path: "/submissions/5", user: "outsider", expectedStatus: http.StatusForbidden},
+		{name: "Teacher cannot delete submission", method: "DELETE", path: "/submissions/5", user: "teacher", expectedStatus: http.StatusForbidden},
+		{name: "Global admin overrides course roles", method: "DELETE", path: "/submissions/5", user: "admin", expectedStatus: http.StatusOK},
+		{name: "Unknown submission", method: "GET", path: "/submissions/6", user: "teacher", expectedStatus: http.StatusNotFound},
+		{name: "Invalid submission ID", method: "GET", path: "/submissions/abc", user: "teacher", expectedStatus: http.StatusBadRequest},
+		{name: "Anyone creates courses", method: "POST", path: "/courses", user: "outsider", expectedStatus: http.StatusOK},
+		{name: "Teacher creates assignment", method: "POST", path: "/assignments", user: "teacher", body: `{"courseId":1}`, expectedStatus: http.StatusOK},
+		{name: "Student cannot create assignment", method: "POST", path: "/assignments", user: "student", body: `{"courseId":1}`, expectedStatus: http.StatusForbidden},
+		{name: "Missing course in body", method: "POST", path: "/assignments", user: "teacher", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "Token with the permission", method: "GET", path: "/submissions/5", user: "teacher", tokenScope: "read", expectedStatus: http.StatusOK},
+		{name: "Token for another course", method: "GET", path: "/submissions/5", user: "teacher", tokenScope: "read-course-2", expectedStatus: http.StatusForbidden},
+		{name: "Token without the permission", method: "POST", path: "/courses", user: "teacher", tokenScope: "read", expectedStatus: http.StatusForbidden},
+		{name: "Token cannot exceed its user", method: "GET", path: "/submissions/5", user: "outsider", tokenScope: "read", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("X-User", tt.user)
+			req.Header.Set("X-Token-Scope", tt.tokenScope)
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.body != "" && w.Code == http.StatusOK {
+				assert.Equal(t, tt.body, w.Body.String(), "the handler should still read the body")
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/app/services"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminUserManagement(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.APIToken{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	mail := &outbox{}
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, mail, "http://localhost:3000")
+	routes.SetupAdminRoutes(router, db, []byte(secret), time.Hour, mail, "http://localhost:3000")
+
+	studentToken, studentID := signUp(t, router, db, "student@example.com")
+	signUp(t, router, db, "other.student@example.com")
+	adminToken, adminID := signUp(t, router, db, "admin@example.com")
+	require.NoError(t, db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.RoleAdmin).Error)
+
+	userPath := func(userID uint, action string) string {
+		return fmt.Sprintf("/admin/users/%d/%s", userID, action)
+	}
+	login := func(email string) int {
+		return postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": testUserPassword}).Code
+	}
+	auditCount := func(action models.AuditAction) int64 {
+		var count int64
+		require.NoError(t, db.Model(&models.AuditLog{}).Where("action = ? AND actor_id = ?", action, adminID).Count(&count).Error)
+		return count
+	}
+
+	t.Run("Only admins manage users", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "GET", "/admin/users", studentToken, nil).Code)
+	})
+
+	t.Run("Search and paginate", func(t *testing.T) {
+		w := authJSON(router, "GET", "/admin/users?q=STUDENT&pageSize=1", adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var page services.UserPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, int64(2), page.Total)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, "other.student@example.com", page.Users[0].Email)
+
+		w = authJSON(router, "GET", "/admin/users?q=student&pageSize=1&page=2", adminToken, nil)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, "student@example.com", page.Users[0].Email)
+
+		w = authJSON(router, "GET", "/admin/users?role=admin", adminToken, nil)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, int64(1), page.Total)
+
+		assert.Equal(t, http.StatusBadRequest, authJSON(router, "GET", "/admin/users?active=maybe", adminToken, nil).Code)
+	})
+
+	t.Run("Deactivate and reactivate", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "POST", userPath(adminID, "deactivate"), adminToken, nil).Code)
+
+		w := authJSON(router, "POST", userPath(studentID, "deactivate"), adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", "/users/profile", studentToken, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, login("student@example.com"))
+
+		w = authJSON(router, "POST", userPath(studentID, "reactivate"), adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusOK, login("student@example.com"))
+
+		assert.Equal(t, int64(1), auditCount(models.AuditUserDeactivate))
+		assert.Equal(t, int64(1), auditCount(models.AuditUserReactivate))
+	})
+
+	t.Run("Assign a role", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, authJSON(router, "PUT", userPath(studentID, "role"), adminToken,
+			map[string]interface{}{"role": "superuser"}).Code)
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "PUT", userPath(adminID, "role"), adminToken,
+			map[string]interface{}{"role": "user"}).Code)
+
+		w := authJSON(router, "PUT", userPath(studentID, "role"), adminToken, map[string]interface{}{"role": "admin"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = authJSON(router, "PUT", userPath(studentID, "role"), adminToken, map[string]interface{}{"role": "user"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var student models.User
+		require.NoError(t, db.First(&student, studentID).Error)
+		assert.Equal(t, models.RoleUser, student.Role)
+		assert.Equal(t, int64(2), auditCount(models.AuditUserRoleChange))
+	})
+
+	t.Run("Force a password reset", func(t *testing.T) {
+		w := authJSON(router, "POST", userPath(studentID, "password-reset"), adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, login("student@example.com"), "the old password must stop working")
+
+		w = postJSON(router, "/users/password-reset", map[string]interface{}{"token": mail.lastToken(), "password": newPassword})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = postJSON(router, "/users/login", map[string]interface{}{"email": "student@example.com", "password": newPassword})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(1), auditCount(models.AuditUserPasswordReset))
+	})
+
+	t.Run("Impersonate a user", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "POST", userPath(adminID, "impersonate"), adminToken, nil).Code)
+
+		w := authJSON(router, "POST", userPath(studentID, "impersonate"), adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens services.TokenPair
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		assert.Empty(t, tokens.RefreshToken, "impersonation cannot be refreshed")
+		assert.True(t, tokens.ExpiresAt.Before(time.Now().Add(time.Hour+time.Minute)))
+
+		w = authJSON(router, "GET", "/users/profile", tokens.AccessToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "student@example.com")
+
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "PUT", "/users/profile", tokens.AccessToken,
+			map[string]interface{}{"firstName": "Changed"}).Code)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "POST", "/users/tokens", tokens.AccessToken,
+			map[string]interface{}{"name": "ci", "scopes": []string{"grade:read"}}).Code)
+
+		var session models.Session
+		require.NoError(t, db.Where("user_id = ? AND impersonator_id = ?", studentID, adminID).First(&session).Error)
+		assert.Equal(t, int64(1), auditCount(models.AuditUserImpersonate))
+	})
+
+	t.Run("Impersonation ends with the rights of the admin", func(t *testing.T) {
+		impersonate := func(t *testing.T, email string) (string, uint) {
+			token, id := signUp(t, router, db, email)
+			require.NoError(t, db.Model(&models.User{}).Where("id = ?", id).Update("role", models.RoleAdmin).Error)
+
+			w := authJSON(router, "POST", userPath(studentID, "impersonate"), token, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var tokens services.TokenPair
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+			require.Equal(t, http.StatusOK, authJSON(router, "GET", "/users/profile", tokens.AccessToken, nil).Code)
+			return tokens.AccessToken, id
+		}
+
+		impersonation, id := impersonate(t, "demoted.admin@example.com")
+		w := authJSON(router, "PUT", userPath(id, "role"), adminToken, map[string]interface{}{"role": "user"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", "/users/profile", impersonation, nil).Code)
+
+		impersonation, id = impersonate(t, "deactivated.admin@example.com")
+		w = authJSON(router, "POST", userPath(id, "deactivate"), adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", "/users/profile", impersonation, nil).Code)
+
+		impersonation, id = impersonate(t, "former.admin@example.com")
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", id).Update("role", models.RoleUser).Error)
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", "/users/profile", impersonation, nil).Code,
+			"the impersonator must still be an admin, even with the session left live")
+	})
+}
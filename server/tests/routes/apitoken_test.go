@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokenRoutes(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.APIToken{},
+		&models.Course{}, &models.CourseInvitation{}, &models.Enrollment{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupCourseRoutes(router, db, secret)
+
+	sessionToken, _ := signUp(t, router, db, "teacher@example.com")
+
+	createCourse := func(name, code string) uint {
+		w := authJSON(router, "POST", "/courses/", sessionToken, map[string]interface{}{"name": name, "invitation_code": code})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var course models.Course
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &course))
+		return course.ID
+	}
+	algebra := createCourse("Algebra", "ALG-101")
+	geometry := createCourse("Geometry", "GEO-101")
+
+	t.Run("Invalid tokens are refused", func(t *testing.T) {
+		w := authJSON(router, "POST", "/users/tokens", sessionToken, map[string]interface{}{"name": "export", "scopes": []string{"user:manage"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = authJSON(router, "POST", "/users/tokens", sessionToken, map[string]interface{}{
+			"name": "export", "scopes": []string{"course:read"}, "expiresInDays": 1000,
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	var apiToken string
+	var tokenID float64
+	t.Run("Create a token for one course", func(t *testing.T) {
+		w := authJSON(router, "POST", "/users/tokens", sessionToken, map[string]interface{}{
+			"name":     "grade export",
+			"scopes":   []string{"course:read", "grade:read"},
+			"courseId": algebra,
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		apiToken = response["token"].(string)
+		tokenID = response["id"].(float64)
+		assert.Contains(t, apiToken, "glass_pat_")
+		assert.Equal(t, apiToken[:14], response["prefix"])
+
+		var stored models.APIToken
+		require.NoError(t, db.First(&stored, uint(tokenID)).Error)
+		assert.NotContains(t, stored.TokenHash, apiToken[10:], "only the hash is stored")
+	})
+
+	t.Run("Token works within its scope", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, authJSON(router, "GET", fmt.Sprintf("/courses/%d", algebra), apiToken, nil).Code)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "GET", fmt.Sprintf("/courses/%d", geometry), apiToken, nil).Code)
+
+		w := authJSON(router, "PUT", fmt.Sprintf("/courses/%d", algebra), apiToken, map[string]interface{}{"name": "Calculus"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "the token can only read")
+	})
+
+	t.Run("Token cannot manage the account", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, getProfile(router, apiToken).Code)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "GET", "/users/tokens", apiToken, nil).Code)
+	})
+
+	t.Run("List shows the last use", func(t *testing.T) {
+		w := authJSON(router, "GET", "/users/tokens", sessionToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var tokens []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+		require.Len(t, tokens, 1)
+		assert.Equal(t, "grade export", tokens[0]["name"])
+		assert.NotNil(t, tokens[0]["lastUsedAt"])
+		assert.Nil(t, tokens[0]["token"])
+	})
+
+	t.Run("Revoked token stops working", func(t *testing.T) {
+		path := fmt.Sprintf("/users/tokens/%d", int(tokenID))
+		require.Equal(t, http.StatusOK, authJSON(router, "DELETE", path, sessionToken, nil).Code)
+		assert.Equal(t, http.StatusNotFound, authJSON(router, "DELETE", path, sessionToken, nil).Code)
+
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", fmt.Sprintf("/courses/%d", algebra), apiToken, nil).Code)
+	})
+}
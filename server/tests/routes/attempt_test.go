@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"server/app/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttemptLifecycle(t *testing.T) {
+	f := newQuizFixture(t)
+
+	pi := 3.14
+	quiz := f.createQuiz(t, models.Quiz{Title: "Constants", MaxAttempts: 2, Questions: []models.Question{
+		{Title: "Speed of light", Type: models.SingleCorrect, Points: 2,
+			Options: []models.Option{{Text: "c", IsCorrect: true}, {Text: "v"}}},
+		{Title: "Pi", Type: models.Numeric, Points: 1, NumericAnswer: &pi, Tolerance: 0.01},
+	}})
+	light, piQuestion := quiz.Questions[0], quiz.Questions[1]
+	numeric := func(value float64) map[string]interface{} {
+		return map[string]interface{}{"questionId": piQuestion.ID, "numericAnswer": value}
+	}
+	expire := func(t *testing.T, attemptID uint, ago time.Duration) {
+		require.NoError(t, f.db.Model(&models.QuizSubmission{}).Where("id = ?", attemptID).
+			Update("expires_at", time.Now().Add(-ago)).Error)
+	}
+
+	var first models.QuizSubmission
+
+	t.Run("Start and resume", func(t *testing.T) {
+		first = f.startAttempt(t, f.studentToken, quiz.ID)
+		assert.Equal(t, models.AttemptStatusInProgress, first.Status)
+		assert.Equal(t, 1, first.Number)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), first.ExpiresAt, 5*time.Second)
+
+		resumed := f.startAttempt(t, f.studentToken, quiz.ID)
+		assert.Equal(t, first.ID, resumed.ID, "the attempt in progress is resumed")
+		assert.True(t, first.ExpiresAt.Equal(resumed.ExpiresAt), "resuming keeps the deadline")
+	})
+
+	t.Run("Save answers", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, f.saveAnswers(f.studentToken, first, answer(light.ID, light.Options[1].ID)))
+		assert.Equal(t, http.StatusOK, f.saveAnswers(f.studentToken, first,
+			answer(light.ID, light.Options[0].ID), numeric(2)), "answers replace the saved ones")
+		assert.Equal(t, http.StatusBadRequest, f.saveAnswers(f.studentToken, first, answer(piQuestion.ID+100)))
+		assert.Equal(t, http.StatusForbidden, f.saveAnswers(f.teacherToken, first, numeric(2)),
+			"only students take quizzes")
+
+		stored := f.storedAttempt(t, first.ID)
+		assert.Len(t, stored.Answers, 2)
+	})
+
+	t.Run("Grace period after the deadline", func(t *testing.T) {
+		expire(t, first.ID, 5*time.Second)
+		assert.Equal(t, http.StatusOK, f.saveAnswers(f.studentToken, first, numeric(2.5)),
+			"autosaves sent right before the deadline are accepted")
+
+		expire(t, first.ID, 11*time.Second)
+		assert.Equal(t, http.StatusUnauthorized, f.saveAnswers(f.studentToken, first, numeric(pi)))
+	})
+
+	t.Run("Submit after the deadline", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, f.submitAttempt(f.studentToken, first))
+
+		stored := f.storedAttempt(t, first.ID)
+		assert.Equal(t, models.AttemptStatusSubmitted, stored.Status)
+		assert.WithinDuration(t, stored.ExpiresAt, stored.EndTime, time.Millisecond, "closed at the deadline")
+		assert.Equal(t, 2.0, stored.Score, "the answer refused after the grace period doesn't count")
+		assert.Equal(t, 3.0, stored.MaxScore)
+		require.Len(t, stored.Answers, 2)
+		assert.Equal(t, 2.5, *stored.Answers[1].NumericAnswer)
+
+		assert.Equal(t, http.StatusUnauthorized, f.submitAttempt(f.studentToken, first), "submitting twice")
+		assert.Equal(t, http.StatusUnauthorized, f.saveAnswers(f.studentToken, first, numeric(pi)))
+	})
+
+	t.Run("Expired attempts are finalized", func(t *testing.T) {
+		second := f.startAttempt(t, f.studentToken, quiz.ID)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Equal(t, 2, second.Number)
+		assert.Equal(t, http.StatusOK, f.saveAnswers(f.studentToken, second, numeric(pi)))
+		expire(t, second.ID, time.Minute)
+
+		assert.Equal(t, http.StatusUnauthorized, authJSON(f.router, "POST", fmt.Sprintf("/api/quizzes/%d/attempts", quiz.ID),
+			f.studentToken, nil).Code, "both attempts are used")
+
+		stored := f.storedAttempt(t, second.ID)
+		assert.Equal(t, models.AttemptStatusSubmitted, stored.Status, "finalized although no attempt could start")
+		assert.WithinDuration(t, stored.ExpiresAt, stored.EndTime, time.Millisecond)
+		assert.Equal(t, 1.0, stored.Score)
+		assert.Len(t, stored.Answers, 2, "the unanswered question gets a blank answer")
+	})
+
+	t.Run("Deadline within the window", func(t *testing.T) {
+		closing := f.createQuiz(t, models.Quiz{Title: "Closing soon", EndTime: time.Now().Add(10 * time.Minute),
+			Questions: []models.Question{{Title: "Pi", Type: models.Numeric, Points: 1, NumericAnswer: &pi}}})
+
+		attempt := f.startAttempt(t, f.studentToken, closing.ID)
+		assert.WithinDuration(t, closing.EndTime, attempt.ExpiresAt, time.Millisecond,
+			"the attempt ends when the quiz closes")
+	})
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvatar(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.AvatarImage{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	store := &memoryStore{files: map[string][]byte{}}
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupAvatarRoutes(router, db, store, secret)
+
+	token, userID := signUp(t, router, db, "student@example.com")
+
+	picture := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			picture.Set(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var pictureData bytes.Buffer
+	require.NoError(t, png.Encode(&pictureData, picture))
+
+	upload := func(name string, data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", name)
+		_, _ = part.Write(data)
+		_ = writer.Close()
+
+		req, _ := http.NewRequest("POST", "/users/avatar", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	type uploaded struct {
+		ProfilePic string               `json:"profilePic"`
+		Images     []models.AvatarImage `json:"images"`
+	}
+
+	var first uploaded
+	t.Run("Upload", func(t *testing.T) {
+		w := upload("me.jpg", pictureData.Bytes())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+
+		require.Len(t, first.Images, 3)
+		assert.Equal(t, first.Images[0].URL, first.ProfilePic)
+		for i, size := range []int{256, 128, 64} {
+			assert.Equal(t, size, first.Images[i].Size)
+			assert.True(t, strings.HasSuffix(first.Images[i].URL, ".png"), "the content decides the format, not the name")
+		}
+		assert.Len(t, store.files, 3)
+
+		for path, data := range store.files {
+			config, format, err := image.DecodeConfig(bytes.NewReader(data))
+			require.NoError(t, err, path)
+			assert.Equal(t, "png", format)
+			assert.Equal(t, config.Width, config.Height, "thumbnails are square")
+		}
+
+		var user models.User
+		require.NoError(t, db.First(&user, userID).Error)
+		assert.Equal(t, first.ProfilePic, user.ProfilePic)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		w := upload("me.png", pictureData.Bytes())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var second uploaded
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+
+		assert.NotEqual(t, first.ProfilePic, second.ProfilePic)
+		assert.Len(t, store.files, 3, "the previous files are deleted")
+		var count int64
+		require.NoError(t, db.Model(&models.AvatarImage{}).Where("user_id = ?", userID).Count(&count).Error)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("Not an image", func(t *testing.T) {
+		w := upload("me.png", []byte("<svg xmlns='http://www.w3.org/2000/svg'><script>alert(1)</script></svg>"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Len(t, store.files, 3, "nothing is stored")
+	})
+
+	t.Run("Profile update keeps the avatar", func(t *testing.T) {
+		w := authJSON(router, "PUT", "/users/profile", token, map[string]interface{}{
+			"firstName": "Ada", "profilePic": "https://evil.example.com/x.png"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var user models.User
+		require.NoError(t, db.First(&user, userID).Error)
+		assert.Equal(t, "Ada", user.FirstName)
+		assert.NotEqual(t, "https://evil.example.com/x.png", user.ProfilePic)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := authJSON(router, "DELETE", "/users/avatar", token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Empty(t, store.files)
+		var user models.User
+		require.NoError(t, db.First(&user, userID).Error)
+		assert.Empty(t, user.ProfilePic)
+	})
+
+	t.Run("Requires authentication", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "DELETE", "/users/avatar", "", nil).Code)
+	})
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/app/services"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCourseCatalog(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.Course{}, &models.Enrollment{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupCourseRoutes(router, db, secret)
+
+	token, userID := signUp(t, router, db, "student@example.com")
+
+	archived := time.Now()
+	courses := []models.Course{
+		{Name: "Introduction to Biology", Description: "Cells, genetics and evolution", Category: "Science",
+			Difficulty: "Beginner", StartDate: "2025-01-10", EndDate: "2025-05-20", IsActive: true, InvitationCode: "CAT-1"},
+		{Name: "Organic Chemistry", Description: "Reactions of carbon compounds, with a little biology", Category: "Science",
+			Difficulty: "Advanced", StartDate: "2025-09-01", EndDate: "2025-12-19", IsActive: true, InvitationCode: "CAT-2"},
+		{Name: "Painting", Description: "Oil and watercolour", Category: "Arts", Difficulty: "Beginner",
+			StartDate: "2025-02-01", IsActive: false, InvitationCode: "CAT-3"},
+		{Name: "Cell Biology", Description: "The old course", Category: "Science", Difficulty: "Intermediate",
+			StartDate: "2023-01-10", EndDate: "2023-05-20", IsActive: true, ArchivedAt: &archived, InvitationCode: "CAT-4"},
+	}
+	for i := range courses {
+		require.NoError(t, db.Create(&courses[i]).Error)
+	}
+	require.NoError(t, db.Create(&models.Enrollment{UserID: userID, CourseID: courses[1].ID, Role: models.RoleStudent,
+		Status: models.EnrollmentStatusApproved}).Error)
+	_, otherID := signUp(t, router, db, "other@example.com")
+	require.NoError(t, db.Create(&models.Enrollment{UserID: otherID, CourseID: courses[1].ID, Role: models.RoleStudent,
+		Status: models.EnrollmentStatusApproved}).Error)
+	require.NoError(t, db.Create(&models.Enrollment{UserID: otherID, CourseID: courses[0].ID, Role: models.RoleStudent,
+		Status: models.EnrollmentStatusPending}).Error)
+
+	search := func(t *testing.T, query url.Values) services.CoursePage {
+		w := authJSON(router, "GET", "/courses/?"+query.Encode(), token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page services.CoursePage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+	names := func(page services.CoursePage) []string {
+		names := make([]string, 0, len(page.Courses))
+		for _, course := range page.Courses {
+			names = append(names, course.Name)
+		}
+		return names
+	}
+
+	t.Run("Full-text search ranks names first", func(t *testing.T) {
+		page := search(t, url.Values{"q": {"biology"}})
+		assert.Equal(t, []string{"Introduction to Biology", "Organic Chemistry"}, names(page), "archived courses are left out")
+
+		page = search(t, url.Values{"q": {"genetic"}})
+		assert.Equal(t, []string{"Introduction to Biology"}, names(page), "words match by their stems")
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		assert.Equal(t, []string{"Painting"}, names(search(t, url.Values{"active": {"false"}})))
+		assert.Equal(t, []string{"Organic Chemistry"}, names(search(t, url.Values{"difficulty": {"Advanced"}})))
+		assert.Equal(t, []string{"Cell Biology"}, names(search(t, url.Values{"archived": {"true"}})))
+		assert.Equal(t, []string{"Introduction to Biology", "Organic Chemistry"},
+			names(search(t, url.Values{"category": {"Science"}, "sort": {"start_date"}})))
+		assert.Equal(t, []string{"Introduction to Biology", "Painting"},
+			names(search(t, url.Values{"startFrom": {"2025-01-01"}, "startTo": {"2025-06-30"}, "sort": {"name"}})))
+		assert.Equal(t, []string{"Introduction to Biology"},
+			names(search(t, url.Values{"endTo": {"2025-06-30"}})), "courses without an end date are left out")
+	})
+
+	t.Run("Enrollment counts", func(t *testing.T) {
+		page := search(t, url.Values{"sort": {"enrollments"}})
+		require.Len(t, page.Courses, 3)
+		assert.Equal(t, "Organic Chemistry", page.Courses[0].Name)
+		assert.EqualValues(t, 2, page.Courses[0].EnrollmentCount)
+		for _, course := range page.Courses[1:] {
+			assert.Zero(t, course.EnrollmentCount, "pending enrollments don't count")
+		}
+	})
+
+	t.Run("Cursor pagination", func(t *testing.T) {
+		var seen []string
+		query := url.Values{"sort": {"name"}, "order": {"desc"}, "limit": {"1"}}
+		for {
+			page := search(t, query)
+			seen = append(seen, names(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Set("cursor", page.NextCursor)
+		}
+		assert.Equal(t, []string{"Painting", "Organic Chemistry", "Introduction to Biology"}, seen)
+
+		query.Set("order", "asc")
+		assert.Equal(t, http.StatusBadRequest, authJSON(router, "GET", "/courses/?"+query.Encode(), token, nil).Code,
+			"a cursor only goes with its own order")
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []url.Values{
+			{"sort": {"popularity"}},
+			{"sort": {"relevance"}},
+			{"order": {"sideways"}},
+			{"startFrom": {"last spring"}},
+			{"limit": {"0"}},
+			{"active": {"maybe"}},
+			{"cursor": {"not a cursor"}},
+		} {
+			assert.Equal(t, http.StatusBadRequest, authJSON(router, "GET", "/courses/?"+query.Encode(), token, nil).Code,
+				query.Encode())
+		}
+	})
+}
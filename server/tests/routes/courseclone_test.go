@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/app/services"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCourseLifecycle(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.Course{}, &models.CourseInvitation{}, &models.Enrollment{}, &models.Material{}, &models.MaterialFile{}, &models.Assignment{},
+		&models.Submission{}, &models.Quiz{}, &models.Question{}, &models.QuestionTag{}, &models.Option{},
+		&models.QuizDrawRule{}, &models.Job{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	store := &memoryStore{files: map[string][]byte{"materials/syllabus.pdf": []byte("syllabus")}}
+	jobs := services.NewJobService(db)
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupCourseRoutes(router, db, secret)
+	routes.SetupCourseCloneRoutes(router, db, store, jobs, secret)
+
+	teacherToken, teacherID := signUp(t, router, db, "teacher@example.com")
+	studentToken, studentID := signUp(t, router, db, "student@example.com")
+	adminToken, adminID := signUp(t, router, db, "admin@example.com")
+	require.NoError(t, db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.RoleAdmin).Error)
+
+	course := models.Course{Name: "Biology", CreatorID: teacherID, InvitationCode: "BIO-F24", StartDate: "2024-09-02",
+		EndDate: "2024-12-20", IsActive: true}
+	require.NoError(t, db.Create(&course).Error)
+	require.NoError(t, db.Create(&models.Enrollment{UserID: studentID, CourseID: course.ID, Role: models.RoleStudent,
+		Status: models.EnrollmentStatusApproved}).Error)
+
+	require.NoError(t, db.Create(&models.Material{Title: "Syllabus", CourseId: course.ID, Files: []models.MaterialFile{{
+		BaseFile: models.BaseFile{FileName: "materials/syllabus.pdf", FileUrl: "https://example.com/syllabus.pdf",
+			Extension: models.FileExtensionPDF, UserFileName: "syllabus.pdf"}}}}).Error)
+	due := time.Date(2024, 10, 1, 23, 59, 0, 0, time.UTC)
+	assignment := models.Assignment{CourseID: course.ID, Title: "Cells", DueDate: due, IsPublished: true}
+	require.NoError(t, db.Create(&assignment).Error)
+	require.NoError(t, db.Create(&models.Submission{AssignmentID: assignment.ID, UserID: studentID, SubmittedAt: due}).Error)
+	quiz := models.Quiz{Title: "Midterm", CourseID: course.ID, CreatorID: teacherID, StartTime: due, EndTime: due.Add(time.Hour),
+		Duration: 30, ShowResults: false, Questions: []models.Question{{Title: "Mitosis?", Type: models.TrueFalse, Points: 1,
+			Options: []models.Option{{Text: "True", IsCorrect: true}, {Text: "False"}}}},
+		DrawRules: []models.QuizDrawRule{{Tag: "genetics", Count: 1}}}
+	require.NoError(t, db.Create(&quiz).Error)
+	require.NoError(t, db.Model(&quiz).UpdateColumn("show_results", false).Error)
+	require.NoError(t, db.Create(&models.Question{CourseID: &course.ID, Title: "DNA?", Type: models.TrueFalse, Points: 1,
+		Tags: []models.QuestionTag{{Name: "genetics"}}, Options: []models.Option{{Text: "True", IsCorrect: true}}}).Error)
+
+	coursePath := fmt.Sprintf("/courses/%d", course.ID)
+
+	var clone models.Course
+	t.Run("Clone", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "POST", coursePath+"/clone", studentToken,
+			map[string]interface{}{"name": "Biology", "start_date": "2025-09-01"}).Code)
+		assert.Equal(t, http.StatusBadRequest, authJSON(router, "POST", coursePath+"/clone", teacherToken,
+			map[string]interface{}{"name": "Biology", "start_date": "next fall"}).Code)
+
+		w := authJSON(router, "POST", coursePath+"/clone", teacherToken, map[string]interface{}{
+			"name": "Biology (Fall 2025)", "start_date": "2025-09-01", "end_date": "2025-12-19", "archive_source": true})
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+		var response struct {
+			Course models.Course `json:"course"`
+			Job    models.Job    `json:"job"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		clone = response.Course
+		assert.False(t, clone.IsActive)
+		assert.NotEmpty(t, clone.InvitationCode)
+		assert.NotEqual(t, course.InvitationCode, clone.InvitationCode)
+		assert.Equal(t, models.JobStatusPending, response.Job.Status)
+
+		ran, err := jobs.RunNext(context.Background())
+		require.NoError(t, err)
+		require.True(t, ran)
+
+		w = authJSON(router, "GET", fmt.Sprintf("/courses/%d/clone", clone.ID), teacherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var job models.Job
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		require.Equal(t, models.JobStatusSucceeded, job.Status, job.LastError)
+		assert.Equal(t, 100, job.Progress)
+
+		require.NoError(t, db.First(&clone, clone.ID).Error)
+		require.NotNil(t, clone.ClonedFromID)
+		assert.Equal(t, course.ID, *clone.ClonedFromID)
+
+		// 2024-09-02 to 2025-09-01 is 364 days.
+		var assignments []models.Assignment
+		require.NoError(t, db.Where("course_id = ?", clone.ID).Find(&assignments).Error)
+		require.Len(t, assignments, 1)
+		assert.True(t, due.AddDate(0, 0, 364).Equal(assignments[0].DueDate))
+
+		var count int64
+		require.NoError(t, db.Model(&models.Submission{}).Where("assignment_id = ?", assignments[0].ID).Count(&count).Error)
+		assert.Zero(t, count, "submissions stay behind")
+		require.NoError(t, db.Model(&models.Enrollment{}).Where("course_id = ?", clone.ID).Count(&count).Error)
+		assert.Zero(t, count, "enrollments stay behind")
+
+		var quizzes []models.Quiz
+		require.NoError(t, db.Preload("Questions.Options").Preload("DrawRules").Where("course_id = ?", clone.ID).
+			Find(&quizzes).Error)
+		require.Len(t, quizzes, 1)
+		assert.True(t, due.AddDate(0, 0, 364).Equal(quizzes[0].StartTime))
+		assert.False(t, quizzes[0].ShowResults)
+		require.Len(t, quizzes[0].Questions, 1)
+		assert.Len(t, quizzes[0].Questions[0].Options, 2)
+		assert.Len(t, quizzes[0].DrawRules, 1)
+
+		var bank []models.Question
+		require.NoError(t, db.Preload("Tags").Where("course_id = ?", clone.ID).Find(&bank).Error)
+		require.Len(t, bank, 1)
+		require.Len(t, bank[0].Tags, 1)
+		assert.Equal(t, "genetics", bank[0].Tags[0].Name)
+
+		var materials []models.Material
+		require.NoError(t, db.Preload("Files").Where("course_id = ?", clone.ID).Find(&materials).Error)
+		require.Len(t, materials, 1)
+		require.Len(t, materials[0].Files, 1)
+		copied := materials[0].Files[0].FileName
+		assert.NotEqual(t, "materials/syllabus.pdf", copied, "the file is copied, not shared")
+		assert.True(t, strings.HasPrefix(copied, "materials/"))
+		assert.Equal(t, []byte("syllabus"), store.files[copied])
+
+		require.NoError(t, db.First(&course, course.ID).Error)
+		assert.NotNil(t, course.ArchivedAt, "the source is rolled over")
+	})
+
+	t.Run("Archived course is read-only", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, authJSON(router, "GET", coursePath, studentToken, nil).Code)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "PUT", coursePath, teacherToken,
+			map[string]interface{}{"name": "Renamed"}).Code)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "POST", coursePath+"/restore", teacherToken, nil).Code)
+		assert.Equal(t, http.StatusOK, authJSON(router, "PUT", coursePath, adminToken,
+			map[string]interface{}{"name": "Biology (Fall 2024)"}).Code, "global admins can still change it")
+
+		enrollments := services.NewEnrollmentService(db)
+		_, otherID := signUp(t, router, db, "late@example.com")
+		assert.Error(t, enrollments.JoinCourseByCode(otherID, course.InvitationCode, models.RoleStudent))
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		w := authJSON(router, "POST", coursePath+"/restore", adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusOK, authJSON(router, "PUT", coursePath, teacherToken,
+			map[string]interface{}{"name": "Biology"}).Code)
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "POST", coursePath+"/restore", adminToken, nil).Code,
+			"the course is not archived")
+
+		w = authJSON(router, "POST", coursePath+"/archive", teacherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, db.First(&course, course.ID).Error)
+		assert.NotNil(t, course.ArchivedAt)
+	})
+
+	t.Run("Clone status of a course that is not a clone", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, authJSON(router, "GET", coursePath+"/clone", teacherToken, nil).Code)
+	})
+}
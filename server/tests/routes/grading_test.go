@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualGrading(t *testing.T) {
+	f := newQuizFixture(t)
+
+	quiz := f.createQuiz(t, models.Quiz{Title: "Mechanics", Questions: []models.Question{
+		{Title: "Explain inertia", Type: models.Essay, Points: 5},
+		{Title: "Unit of force", Type: models.SingleCorrect, Points: 2,
+			Options: []models.Option{{Text: "Newton", IsCorrect: true}, {Text: "Joule"}}},
+	}})
+	essay, choice := quiz.Questions[0], quiz.Questions[1]
+	essayAnswer := func(text string) map[string]interface{} {
+		return map[string]interface{}{"questionId": essay.ID, "textAnswer": text}
+	}
+
+	students := []string{f.studentToken}
+	for _, email := range []string{"second@example.com", "third@example.com"} {
+		token, id := signUp(t, f.router, f.db, email)
+		f.enroll(t, id, models.RoleStudent)
+		students = append(students, token)
+	}
+
+	// The first two students write an essay, in turn; the third leaves it blank.
+	attempts := make([]models.QuizSubmission, len(students))
+	for i, token := range students {
+		attempts[i] = f.startAttempt(t, token, quiz.ID)
+		answers := []map[string]interface{}{answer(choice.ID, choice.Options[0].ID)}
+		if i < 2 {
+			answers = append(answers, essayAnswer(fmt.Sprintf("Essay %d", i+1)))
+		}
+		require.Equal(t, http.StatusOK, f.saveAnswers(token, attempts[i], answers...))
+		require.Equal(t, http.StatusOK, f.submitAttempt(token, attempts[i]))
+	}
+
+	gradingPath := fmt.Sprintf("/api/quizzes/%d/grading", quiz.ID)
+	queue := func(t *testing.T) []services.GradingItem {
+		w := authJSON(f.router, "GET", gradingPath, f.teacherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Answers []services.GradingItem `json:"answers"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Answers
+	}
+	grade := func(answerID uint, body map[string]interface{}) int {
+		return authJSON(f.router, "PUT", fmt.Sprintf("%s/%d", gradingPath, answerID), f.teacherToken, body).Code
+	}
+	essayAnswerID := func(t *testing.T, attemptID uint) uint {
+		for _, answer := range f.storedAttempt(t, attemptID).Answers {
+			if answer.QuestionID == essay.ID {
+				return answer.ID
+			}
+		}
+		t.Fatalf("attempt %d has no answer to the essay", attemptID)
+		return 0
+	}
+
+	t.Run("Queue", func(t *testing.T) {
+		items := queue(t)
+		require.Len(t, items, 2, "blank essays are not queued")
+		for i, item := range items {
+			assert.Equal(t, attempts[i].ID, item.AttemptID, "oldest first")
+			assert.Equal(t, essay.ID, item.QuestionID)
+			assert.Equal(t, "Explain inertia", item.QuestionTitle)
+			assert.Equal(t, 5, item.MaxPoints)
+			assert.Equal(t, fmt.Sprintf("Essay %d", i+1), item.TextAnswer)
+		}
+
+		assert.Equal(t, models.AttemptStatusPendingReview, f.storedAttempt(t, attempts[0].ID).Status)
+		assert.Equal(t, models.AttemptStatusSubmitted, f.storedAttempt(t, attempts[2].ID).Status)
+		assert.Equal(t, http.StatusForbidden, authJSON(f.router, "GET", gradingPath, f.studentToken, nil).Code)
+	})
+
+	t.Run("Invalid grades", func(t *testing.T) {
+		answerID := queue(t)[0].AnswerID
+		assert.Equal(t, http.StatusBadRequest, grade(answerID, map[string]interface{}{"points": 6}),
+			"more than the points of the question")
+		assert.Equal(t, http.StatusBadRequest, grade(answerID, map[string]interface{}{"points": -1}))
+		assert.Equal(t, http.StatusBadRequest, grade(answerID, map[string]interface{}{"feedback": "Good"}))
+
+		var choiceAnswer models.Answer
+		require.NoError(t, f.db.Where("submission_id = ? AND question_id = ?", attempts[0].ID, choice.ID).
+			First(&choiceAnswer).Error)
+		assert.Equal(t, http.StatusUnauthorized, grade(choiceAnswer.ID, map[string]interface{}{"points": 1}),
+			"automatically scored answers are not graded")
+
+		assert.Len(t, queue(t), 2)
+	})
+
+	t.Run("Grading completes the attempt", func(t *testing.T) {
+		answerID := essayAnswerID(t, attempts[0].ID)
+		require.Equal(t, http.StatusOK, grade(answerID, map[string]interface{}{"points": 4, "feedback": "Good"}))
+
+		graded := f.storedAttempt(t, attempts[0].ID)
+		assert.Equal(t, models.AttemptStatusSubmitted, graded.Status)
+		assert.Equal(t, 6.0, graded.Score)
+		assert.Equal(t, 7.0, graded.MaxScore)
+
+		items := queue(t)
+		require.Len(t, items, 1)
+		assert.Equal(t, attempts[1].ID, items[0].AttemptID)
+
+		require.Equal(t, http.StatusOK, grade(answerID, map[string]interface{}{"points": 2.5}), "grading again")
+		assert.Equal(t, 4.5, f.storedAttempt(t, attempts[0].ID).Score)
+		assert.Len(t, queue(t), 1)
+	})
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestIdentityChain goes from a signed-in user's token, through AuthMiddleware
+// and RequirePermission, to the handlers, checking they act as that user.
+func TestIdentityChain(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.Course{}, &models.CourseInvitation{}, &models.Enrollment{}, &models.TwoFactor{},
+		&models.RecoveryCode{}, &models.LoginThrottle{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupCourseRoutes(router, db, secret)
+	routes.SetupEnrollmentRoutes(router, db, secret)
+
+	teacherToken, teacherID := signUp(t, router, db, "teacher@example.com")
+	studentToken, studentID := signUp(t, router, db, "student@example.com")
+
+	var courseID uint
+	var invitationCode string
+	t.Run("Handler sees the user of the token", func(t *testing.T) {
+		w := authJSON(router, "POST", "/courses/", teacherToken, map[string]interface{}{
+			"name":            "Algebra",
+			"invitation_code": "ALG-101",
+			"creator_id":      studentID,
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var course models.Course
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &course))
+		assert.Equal(t, teacherID, course.CreatorID, "the creator comes from the token, not the body")
+		courseID = course.ID
+		invitationCode = course.InvitationCode
+		assert.NotEqual(t, "ALG-101", invitationCode, "the server generates the invitation codes")
+
+		w = getProfile(router, studentToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "student@example.com")
+	})
+
+	coursePath := fmt.Sprintf("/courses/%d", courseID)
+
+	t.Run("Missing or invalid token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", coursePath, "", nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", coursePath, "not-a-token", nil).Code)
+	})
+
+	t.Run("Outsider cannot read the course", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "GET", coursePath, studentToken, nil).Code)
+	})
+
+	t.Run("Creator approves an enrollment", func(t *testing.T) {
+		w := authJSON(router, "POST", "/api/enrollments/join", studentToken, map[string]interface{}{
+			"code": invitationCode,
+			"role": "student",
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var enrollment models.Enrollment
+		require.NoError(t, db.Where("user_id = ? AND course_id = ?", studentID, courseID).First(&enrollment).Error)
+
+		approvePath := fmt.Sprintf("/api/enrollments/approve/%d", enrollment.ID)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "PUT", approvePath, studentToken, nil).Code)
+		assert.Equal(t, http.StatusOK, authJSON(router, "PUT", approvePath, teacherToken, nil).Code)
+	})
+
+	t.Run("Enrolled student reads but cannot update", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, authJSON(router, "GET", coursePath, studentToken, nil).Code)
+		w := authJSON(router, "PUT", coursePath, studentToken, map[string]interface{}{"name": "Geometry"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Global admin overrides course roles", func(t *testing.T) {
+		adminToken, adminID := signUp(t, router, db, "admin@example.com")
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.RoleAdmin).Error)
+
+		w := authJSON(router, "PUT", coursePath, adminToken, map[string]interface{}{"name": "Geometry"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
+
+// signUp registers a user and signs them in, returning their access token and ID.
+func signUp(t *testing.T, router *gin.Engine, db *gorm.DB, email string) (string, uint) {
+	w := postJSON(router, "/users/register", map[string]interface{}{"email": email, "password": testUserPassword})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": testUserPassword})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	var user models.User
+	require.NoError(t, db.Where("email = ?", email).First(&user).Error)
+	return response["token"].(string), user.ID
+}
+
+// authJSON sends a request with a JSON body, authenticated with the token if it isn't empty.
+func authJSON(router *gin.Engine, method, path, token string, data map[string]interface{}) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	if data != nil {
+		_ = json.NewEncoder(&body).Encode(data)
+	}
+
+	req, _ := http.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvitationCodes(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.Course{}, &models.CourseInvitation{}, &models.Enrollment{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupCourseRoutes(router, db, secret)
+	routes.SetupEnrollmentRoutes(router, db, secret)
+
+	teacherToken, _ := signUp(t, router, db, "teacher@example.com")
+	users := make([]string, 4)
+	for i := range users {
+		users[i], _ = signUp(t, router, db, fmt.Sprintf("user%d@example.com", i))
+	}
+
+	w := authJSON(router, "POST", "/courses/", teacherToken, map[string]interface{}{
+		"name": "Algebra", "invitation_code": "ALG-101", "max_students": 2})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var course models.Course
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &course))
+	invitationsPath := fmt.Sprintf("/courses/%d/invitations", course.ID)
+
+	join := func(token, code, role string) int {
+		return authJSON(router, "POST", "/api/enrollments/join", token,
+			map[string]interface{}{"code": code, "role": role}).Code
+	}
+	invitations := func(t *testing.T) map[models.Role]models.CourseInvitation {
+		w := authJSON(router, "GET", invitationsPath, teacherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Invitations []models.CourseInvitation `json:"invitations"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		byRole := make(map[models.Role]models.CourseInvitation, len(response.Invitations))
+		for _, invitation := range response.Invitations {
+			byRole[invitation.Role] = invitation
+		}
+		return byRole
+	}
+
+	t.Run("Codes are generated on creation", func(t *testing.T) {
+		codes := invitations(t)
+		require.Len(t, codes, 2)
+		format := regexp.MustCompile(`^[A-HJ-NP-Z2-9]{4}-[A-HJ-NP-Z2-9]{4}$`)
+		assert.Regexp(t, format, codes[models.RoleStudent].Code)
+		assert.Regexp(t, format, codes[models.RoleTeacher].Code)
+		assert.NotEqual(t, codes[models.RoleStudent].Code, codes[models.RoleTeacher].Code)
+		assert.Equal(t, codes[models.RoleStudent].Code, course.InvitationCode)
+
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "GET", invitationsPath, users[0], nil).Code,
+			"only those managing the enrollments see the codes")
+	})
+
+	t.Run("Each code is for its role", func(t *testing.T) {
+		codes := invitations(t)
+		assert.Equal(t, http.StatusBadRequest, join(users[0], codes[models.RoleTeacher].Code, "student"))
+		assert.Equal(t, http.StatusBadRequest, join(users[0], codes[models.RoleStudent].Code, "teacher"))
+		assert.Equal(t, http.StatusBadRequest, join(users[0], "ALG-101", "student"), "the server chose the code")
+
+		assert.Equal(t, http.StatusOK, join(users[0], codes[models.RoleTeacher].Code, "teacher"))
+		assert.Equal(t, http.StatusUnauthorized, join(users[0], codes[models.RoleTeacher].Code, "teacher"),
+			"joining twice")
+		assert.Equal(t, 1, invitations(t)[models.RoleTeacher].Uses)
+	})
+
+	t.Run("Maximum number of students", func(t *testing.T) {
+		code := invitations(t)[models.RoleStudent].Code
+		assert.Equal(t, http.StatusOK, join(users[1], code, "student"))
+		assert.Equal(t, http.StatusOK, join(users[2], code, "student"))
+		assert.Equal(t, http.StatusUnauthorized, join(users[3], code, "student"), "the course is full")
+
+		require.NoError(t, db.Model(&models.Course{}).Where("id = ?", course.ID).Update("max_students", 0).Error)
+	})
+
+	t.Run("Disable, expiry and maximum uses", func(t *testing.T) {
+		path := invitationsPath + "/student"
+		code := invitations(t)[models.RoleStudent].Code
+
+		w := authJSON(router, "PUT", path, teacherToken, map[string]interface{}{"disabled": true})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, join(users[3], code, "student"))
+
+		w = authJSON(router, "PUT", path, teacherToken, map[string]interface{}{"max_uses": 2})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, join(users[3], code, "student"), "two students joined already")
+
+		assert.Equal(t, http.StatusBadRequest, authJSON(router, "PUT", path, teacherToken,
+			map[string]interface{}{"expires_at": time.Now().Add(-time.Hour)}).Code)
+		assert.Equal(t, http.StatusBadRequest, authJSON(router, "PUT", path, teacherToken,
+			map[string]interface{}{"max_uses": -1}).Code)
+		assert.Equal(t, http.StatusBadRequest, authJSON(router, "PUT", invitationsPath+"/admin", teacherToken,
+			map[string]interface{}{}).Code)
+
+		w = authJSON(router, "PUT", path, teacherToken, map[string]interface{}{"expires_at": time.Now().Add(time.Hour)})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, db.Model(&models.CourseInvitation{}).Where("code = ?", code).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+		assert.Equal(t, http.StatusUnauthorized, join(users[3], code, "student"), "the code expired")
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		previous := invitations(t)[models.RoleStudent]
+
+		w := authJSON(router, "POST", invitationsPath+"/student/rotate", teacherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rotated models.CourseInvitation
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+		assert.NotEqual(t, previous.Code, rotated.Code)
+		assert.Zero(t, rotated.Uses)
+		assert.NotNil(t, rotated.ExpiresAt, "the rules are kept")
+
+		require.NoError(t, db.First(&course, course.ID).Error)
+		assert.Equal(t, rotated.Code, course.InvitationCode)
+
+		assert.Equal(t, http.StatusBadRequest, join(users[3], previous.Code, "student"))
+		require.NoError(t, db.Model(&rotated).Update("expires_at", nil).Error)
+		assert.Equal(t, http.StatusOK, join(users[3], rotated.Code, "student"))
+	})
+
+	t.Run("Courses from before managed codes", func(t *testing.T) {
+		legacy := models.Course{Name: "Geometry", CreatorID: course.CreatorID, InvitationCode: "GEO-101"}
+		require.NoError(t, db.Create(&legacy).Error)
+
+		assert.Equal(t, http.StatusOK, join(users[0], "GEO-101", "student"))
+		var invitation models.CourseInvitation
+		require.NoError(t, db.Where("course_id = ?", legacy.ID).First(&invitation).Error)
+		assert.Equal(t, models.RoleStudent, invitation.Role)
+		assert.Equal(t, 1, invitation.Uses)
+	})
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/oidc"
+	"server/app/routes"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCRoutes(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.UserIdentity{}, &models.OIDCLogin{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{},
+		&models.APIToken{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	mock, err := setup.NewMockOIDCProvider("glass")
+	require.NoError(t, err)
+	defer mock.Close()
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Name:        "mock",
+		Issuer:      mock.Issuer(),
+		ClientID:    "glass",
+		RedirectURL: "http://localhost:8080/users/oidc/mock/callback",
+	}, nil)
+	require.NoError(t, err)
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupOIDCRoutes(router, db, []byte(secret), time.Hour, []*oidc.Provider{provider})
+
+	_, userID := signUp(t, router, db, "ada@example.com")
+
+	// signIn goes to the login route, signs in at the provider and returns the callback path.
+	signIn := func(t *testing.T) string {
+		w := authJSON(router, "GET", "/users/oidc/mock/login", "", nil)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		redirect, err := mock.Authorize(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return redirect.RequestURI()
+	}
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		w := authJSON(router, "GET", "/users/oidc/other/login", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Links the identity to the user with its email", func(t *testing.T) {
+		mock.SignInAs(setup.MockOIDCUser{Subject: "ada", Email: "ada@example.com", EmailVerified: true})
+		callback := signIn(t)
+
+		w := authJSON(router, "GET", callback, "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response["refreshToken"])
+
+		w = getProfile(router, response["token"].(string))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "ada@example.com")
+
+		var identity models.UserIdentity
+		require.NoError(t, db.Where("provider = ? AND subject = ?", "mock", "ada").First(&identity).Error)
+		assert.Equal(t, userID, identity.UserID)
+
+		// The state of a login can only be used once.
+		w = authJSON(router, "GET", callback, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Signs in by the linked identity after the email changes", func(t *testing.T) {
+		mock.SignInAs(setup.MockOIDCUser{Subject: "ada", Email: "ada@school.example.com", EmailVerified: true})
+
+		w := authJSON(router, "GET", signIn(t), "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var count int64
+		db.Model(&models.User{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Creates a user on first sign in", func(t *testing.T) {
+		mock.SignInAs(setup.MockOIDCUser{Subject: "grace", Email: "grace@example.com", EmailVerified: true, GivenName: "Grace"})
+
+		w := authJSON(router, "GET", signIn(t), "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var user models.User
+		require.NoError(t, db.Where("email = ?", "grace@example.com").First(&user).Error)
+		assert.Equal(t, "Grace", user.FirstName)
+		assert.Equal(t, models.RoleUser, user.Role)
+		assert.NotNil(t, user.EmailVerifiedAt)
+	})
+
+	t.Run("Linking takes over an account with an unverified email", func(t *testing.T) {
+		// Someone registers the address before its owner signs in with the provider.
+		w := postJSON(router, "/users/register", map[string]interface{}{"email": "Linus@Example.com",
+			"password": testUserPassword})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		w = postJSON(router, "/users/login", map[string]interface{}{"email": "Linus@Example.com",
+			"password": testUserPassword})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var session map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+
+		mock.SignInAs(setup.MockOIDCUser{Subject: "linus", Email: "linus@example.com", EmailVerified: true})
+		w = authJSON(router, "GET", signIn(t), "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var user models.User
+		require.NoError(t, db.Where("email = ?", "Linus@Example.com").First(&user).Error)
+		assert.NotNil(t, user.EmailVerifiedAt)
+		var identity models.UserIdentity
+		require.NoError(t, db.Where("provider = ? AND subject = ?", "mock", "linus").First(&identity).Error)
+		assert.Equal(t, user.ID, identity.UserID, "the email is matched regardless of case")
+
+		assert.Equal(t, http.StatusUnauthorized, getProfile(router, session["token"].(string)).Code,
+			"the sessions from before are revoked")
+		w = postJSON(router, "/users/login", map[string]interface{}{"email": "Linus@Example.com",
+			"password": testUserPassword})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the password from before is dropped")
+	})
+
+	t.Run("Unverified email is not linked", func(t *testing.T) {
+		mock.SignInAs(setup.MockOIDCUser{Subject: "mallory", Email: "ada@example.com"})
+
+		w := authJSON(router, "GET", signIn(t), "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Provider reports an error", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/users/oidc/mock/callback?error=access_denied&state=x", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
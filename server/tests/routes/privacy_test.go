@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"server/app/firebase"
+	"server/app/models"
+	"server/app/routes"
+	"server/app/services"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is a file store holding its files in memory.
+type memoryStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (m *memoryStore) WriteFile(path string, data []byte, contentType string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[path] = data
+	return "https://storage.example.com/" + path, nil
+}
+
+func (m *memoryStore) OpenFile(path string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[path]
+	if !ok {
+		return nil, fmt.Errorf("error opening file: %w", firebase.ErrFileNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryStore) DeleteFile(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[path]; !ok {
+		return firebase.NewDeleteFileError(firebase.ErrFileNotFound)
+	}
+	delete(m.files, path)
+	return nil
+}
+
+func TestPersonalData(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.APIToken{}, &models.UserIdentity{}, &models.Course{}, &models.Enrollment{}, &models.Assignment{},
+		&models.Submission{}, &models.SubmissionFile{}, &models.Grade{}, &models.Quiz{}, &models.Question{},
+		&models.QuizAccommodation{}, &models.QuizSubmission{}, &models.Answer{}, &models.Job{}, &models.AvatarImage{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	store := &memoryStore{files: map[string][]byte{
+		"submissions/essay.pdf":   []byte("my essay"),
+		"avatars/student_256.png": []byte("my face"),
+	}}
+	jobs := services.NewJobService(db)
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupAdminRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupPrivacyRoutes(router, db, store, jobs, secret)
+
+	studentToken, studentID := signUp(t, router, db, "student@example.com")
+	_, teacherID := signUp(t, router, db, "teacher@example.com")
+	otherToken, _ := signUp(t, router, db, "other@example.com")
+
+	course := models.Course{Name: "Literature", CreatorID: teacherID, InvitationCode: "LIT-1"}
+	require.NoError(t, db.Create(&course).Error)
+	require.NoError(t, db.Create(&models.Enrollment{UserID: studentID, CourseID: course.ID, Role: models.RoleStudent,
+		Status: models.EnrollmentStatusApproved}).Error)
+
+	assignment := models.Assignment{CourseID: course.ID, Title: "Essay", DueDate: time.Now()}
+	require.NoError(t, db.Create(&assignment).Error)
+	submission := models.Submission{AssignmentID: assignment.ID, UserID: studentID, SubmittedAt: time.Now(),
+		Status: models.SubmissionStatusGraded}
+	require.NoError(t, db.Create(&submission).Error)
+	require.NoError(t, db.Create(&models.SubmissionFile{SubmissionId: submission.ID, BaseFile: models.BaseFile{
+		FileName: "submissions/essay.pdf", FileUrl: "https://example.com/essay.pdf", Extension: models.FileExtensionPDF,
+		UserFileName: "essay.pdf"}}).Error)
+	require.NoError(t, db.Create(&models.AvatarImage{UserID: studentID, Size: 256, FileName: "avatars/student_256.png",
+		URL: "https://example.com/student_256.png"}).Error)
+	require.NoError(t, db.Create(&models.Grade{SubmissionID: submission.ID, GradedBy: teacherID, PointsEarned: 8,
+		Feedback: "Well argued", GradedAt: time.Now()}).Error)
+
+	quiz := models.Quiz{Title: "Poetry", CourseID: course.ID, CreatorID: teacherID, StartTime: time.Now().Add(-2 * time.Hour),
+		EndTime: time.Now().Add(-time.Hour), Duration: 30, ShowResults: true}
+	require.NoError(t, db.Create(&quiz).Error)
+	question := models.Question{QuizID: &quiz.ID, Title: "Favourite poem?", Type: models.ShortText, Points: 2}
+	require.NoError(t, db.Create(&question).Error)
+	attempt := models.QuizSubmission{QuizID: quiz.ID, UserID: studentID, Status: models.AttemptStatusSubmitted,
+		StartTime: quiz.StartTime, ExpiresAt: quiz.EndTime, Score: 2, MaxScore: 2,
+		Answers: []models.Answer{{QuestionID: question.ID, TextAnswer: "The Raven", PointsAwarded: 2}}}
+	require.NoError(t, db.Create(&attempt).Error)
+
+	t.Run("Export", func(t *testing.T) {
+		w := authJSON(router, "GET", "/users/export", studentToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		require.NoError(t, err)
+
+		entries := make(map[string]string)
+		for _, file := range archive.File {
+			reader, err := file.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			entries[file.Name] = string(data)
+		}
+
+		assert.Contains(t, entries["profile.json"], "student@example.com")
+		assert.Contains(t, entries["enrollments.json"], "Literature")
+		assert.Contains(t, entries["grades.json"], "Well argued")
+		assert.Contains(t, entries["quiz_attempts.json"], "The Raven")
+		assert.Equal(t, "my essay", entries[fmt.Sprintf("submissions/%d/1_essay.pdf", submission.ID)])
+		assert.NotContains(t, entries, "missing_files.txt")
+
+		var submissions []services.ExportSubmission
+		require.NoError(t, json.Unmarshal([]byte(entries["submissions.json"]), &submissions))
+		require.Len(t, submissions, 1)
+		assert.Equal(t, "Essay", submissions[0].AssignmentTitle)
+	})
+
+	t.Run("Only the user's own data", func(t *testing.T) {
+		w := authJSON(router, "GET", "/users/export", otherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, strings.Contains(w.Body.String(), "my essay"))
+	})
+
+	t.Run("Erasure", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.AuditLog{Action: models.AuditLoginLockout, UserID: &studentID,
+			IP: "203.0.113.7", Details: "account student@example.com locked out after repeated failed logins"}).Error)
+
+		w := authJSON(router, "POST", "/users/erasure", studentToken, nil)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+		var job models.Job
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		assert.Equal(t, models.JobStatusPending, job.Status)
+
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "GET", "/users/profile", studentToken, nil).Code,
+			"the user is signed out at once")
+
+		ran, err := jobs.RunNext(context.Background())
+		require.NoError(t, err)
+		require.True(t, ran)
+		require.NoError(t, db.First(&job, job.ID).Error)
+		require.Equal(t, models.JobStatusSucceeded, job.Status, job.LastError)
+
+		var user models.User
+		require.NoError(t, db.Unscoped().First(&user, studentID).Error)
+		assert.NotEqual(t, "student@example.com", user.Email)
+		assert.NotNil(t, user.ErasedAt)
+		assert.True(t, user.DeletedAt.Valid)
+		assert.Empty(t, store.files, "the stored files are deleted")
+
+		var count int64
+		require.NoError(t, db.Model(&models.SubmissionFile{}).Unscoped().Count(&count).Error)
+		assert.Zero(t, count)
+		require.NoError(t, db.Model(&models.Session{}).Unscoped().Where("user_id = ?", studentID).Count(&count).Error)
+		assert.Zero(t, count)
+		require.NoError(t, db.Model(&models.AvatarImage{}).Where("user_id = ?", studentID).Count(&count).Error)
+		assert.Zero(t, count)
+
+		// The grades and scores still count in the course statistics.
+		var grade models.Grade
+		require.NoError(t, db.Where("submission_id = ?", submission.ID).First(&grade).Error)
+		assert.Equal(t, 8.0, grade.PointsEarned)
+		assert.Empty(t, grade.Feedback)
+		var answer models.Answer
+		require.NoError(t, db.Where("submission_id = ?", attempt.ID).First(&answer).Error)
+		assert.Equal(t, 2.0, answer.PointsAwarded)
+		assert.Empty(t, answer.TextAnswer)
+
+		var entry models.AuditLog
+		require.NoError(t, db.Where("action = ? AND user_id = ?", models.AuditUserErase, studentID).First(&entry).Error)
+		assert.Equal(t, studentID, *entry.ActorID)
+
+		var lockout models.AuditLog
+		require.NoError(t, db.Where("action = ? AND user_id = ?", models.AuditLoginLockout, studentID).
+			First(&lockout).Error)
+		assert.Empty(t, lockout.IP)
+		assert.Empty(t, lockout.Details)
+		require.NoError(t, db.Model(&models.AuditLog{}).Where("details LIKE ?", "%student@example.com%").
+			Count(&count).Error)
+		assert.Zero(t, count, "the audit trail forgets the email address")
+
+		assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/users/login",
+			map[string]interface{}{"email": "student@example.com", "password": testUserPassword}).Code)
+	})
+
+	t.Run("Admin erasure", func(t *testing.T) {
+		adminToken, adminID := signUp(t, router, db, "admin@example.com")
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.RoleAdmin).Error)
+
+		path := fmt.Sprintf("/admin/users/%d/erasure", studentID)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "POST", path, otherToken, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, authJSON(router, "POST", path, adminToken, nil).Code,
+			"the account is already erased")
+
+		w := authJSON(router, "POST", fmt.Sprintf("/admin/users/%d/erasure", teacherID), adminToken, nil)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+		var job models.Job
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		_, err := jobs.RunNext(context.Background())
+		require.NoError(t, err)
+
+		w = authJSON(router, "GET", fmt.Sprintf("/admin/jobs/%d", job.ID), adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		assert.Equal(t, models.JobStatusSucceeded, job.Status)
+	})
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/app/services"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionUsers(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.Course{}, &models.CourseInvitation{}, &models.Enrollment{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	mail := &outbox{}
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, mail, "http://localhost:3000")
+	routes.SetupAdminRoutes(router, db, []byte(secret), time.Hour, mail, "http://localhost:3000")
+
+	studentToken, _ := signUp(t, router, db, "existing@example.com")
+	adminToken, adminID := signUp(t, router, db, "admin@example.com")
+	require.NoError(t, db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.RoleAdmin).Error)
+
+	course := models.Course{Name: "Algebra", CreatorID: adminID, InvitationCode: "ALG-101"}
+	require.NoError(t, db.Create(&course).Error)
+
+	const file = "First Name,Last Name,Email,Invitation Code,Role\n" +
+		"Ada,Lovelace,ada@example.com,ALG-101,\n" +
+		"Alan,Turing,alan@example.com,,\n" +
+		"Existing,User,existing@example.com,ALG-101,teacher\n" +
+		"Ada,Lovelace,ADA@example.com,,\n" +
+		"Grace,Hopper,not-an-email,,\n" +
+		"Edsger,Dijkstra,edsger@example.com,NOPE,\n"
+
+	provision := func(token, query string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", "students.csv")
+		_, _ = part.Write([]byte(file))
+		_ = writer.Close()
+
+		req, _ := http.NewRequest("POST", "/admin/users/import"+query, &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) services.ProvisionReport {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var report services.ProvisionReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return report
+	}
+	statuses := func(report services.ProvisionReport) []services.ProvisionStatus {
+		var result []services.ProvisionStatus
+		for _, row := range report.Rows {
+			result = append(result, row.Status)
+		}
+		return result
+	}
+	userCount := func() int64 {
+		var count int64
+		require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+		return count
+	}
+	expected := []services.ProvisionStatus{
+		services.ProvisionCreated, services.ProvisionCreated, services.ProvisionUpdated,
+		services.ProvisionSkipped, services.ProvisionError, services.ProvisionError,
+	}
+
+	t.Run("Only admins provision users", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, provision(studentToken, "").Code)
+	})
+
+	t.Run("Dry run saves nothing", func(t *testing.T) {
+		sent := len(mail.messages)
+		report := decode(provision(adminToken, "?dryRun=true"))
+
+		assert.True(t, report.DryRun)
+		assert.Equal(t, expected, statuses(report))
+		assert.Equal(t, 2, report.Created)
+		assert.Equal(t, 2, report.Errors)
+		assert.Equal(t, 2, report.Rows[0].Line)
+		assert.Equal(t, int64(2), userCount())
+		assert.Equal(t, sent, len(mail.messages), "a dry run sends no email")
+	})
+
+	t.Run("Provision", func(t *testing.T) {
+		sent := len(mail.messages)
+		report := decode(provision(adminToken, ""))
+
+		assert.False(t, report.DryRun)
+		assert.Equal(t, expected, statuses(report))
+		assert.Equal(t, int64(4), userCount())
+		assert.Equal(t, sent+2, len(mail.messages), "new users are emailed a link to choose their password")
+
+		var enrollments []models.Enrollment
+		require.NoError(t, db.Where("course_id = ?", course.ID).Order("user_id").Find(&enrollments).Error)
+		require.Len(t, enrollments, 2)
+		assert.Equal(t, models.EnrollmentStatusApproved, enrollments[0].Status)
+		roles := []models.Role{enrollments[0].Role, enrollments[1].Role}
+		assert.ElementsMatch(t, []models.Role{models.RoleStudent, models.RoleTeacher}, roles)
+
+		var existing models.User
+		require.NoError(t, db.Where("email = ?", "existing@example.com").First(&existing).Error)
+		assert.Equal(t, "Existing", existing.FirstName)
+	})
+
+	t.Run("Provisioning again changes nothing", func(t *testing.T) {
+		report := decode(provision(adminToken, ""))
+		assert.Equal(t, 0, report.Created)
+		assert.Equal(t, 0, report.Updated)
+		assert.Equal(t, 4, report.Skipped)
+		assert.Equal(t, int64(4), userCount())
+	})
+}
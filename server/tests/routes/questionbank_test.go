@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"server/app/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuestionBankEdits(t *testing.T) {
+	f := newQuizFixture(t)
+	bankPath := fmt.Sprintf("/api/courses/%d/questions", f.course.ID)
+
+	bankQuestion := func(t *testing.T, method, path string, options ...string) models.Question {
+		choices := make([]map[string]interface{}, len(options))
+		for i, text := range options {
+			choices[i] = map[string]interface{}{"text": text, "isCorrect": i == 0}
+		}
+		w := authJSON(f.router, method, path, f.teacherToken, map[string]interface{}{
+			"title": "Unit of force", "type": models.SingleCorrect, "points": 2,
+			"tags": []string{"units"}, "options": choices})
+		require.Contains(t, []int{http.StatusOK, http.StatusCreated}, w.Code, w.Body.String())
+		var response struct {
+			Question models.Question `json:"question"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Question
+	}
+
+	question := bankQuestion(t, "POST", bankPath+"/", "Newton", "Joule")
+	question = bankQuestion(t, "PUT", fmt.Sprintf("%s/%d", bankPath, question.ID), "N", "J", "W")
+	require.Len(t, question.Options, 3)
+	correct := question.Options[0].ID
+
+	var stored int64
+	require.NoError(t, f.db.Unscoped().Model(&models.Option{}).Where("question_id = ?", question.ID).
+		Count(&stored).Error)
+	assert.EqualValues(t, 3, stored, "the replaced options are deleted for good")
+
+	quiz := f.createQuiz(t, models.Quiz{Title: "Units", DrawRules: []models.QuizDrawRule{{Tag: "units", Count: 1}}})
+	attempt := f.startAttempt(t, f.studentToken, quiz.ID)
+
+	view := f.quizView(t, f.studentToken, quiz.ID)
+	require.Len(t, view.Questions, 1)
+	texts := make([]string, 0, len(view.Questions[0].Options))
+	for _, option := range view.Questions[0].Options {
+		texts = append(texts, option.Text)
+	}
+	assert.Equal(t, []string{"N", "J", "W"}, texts, "only the options after the edit are shown")
+
+	assert.Equal(t, http.StatusOK, f.saveAnswers(f.studentToken, attempt, answer(question.ID, correct)))
+	require.Equal(t, http.StatusOK, f.submitAttempt(f.studentToken, attempt))
+
+	scored := f.storedAttempt(t, attempt.ID)
+	assert.Equal(t, models.AttemptStatusSubmitted, scored.Status)
+	assert.Equal(t, 2.0, scored.Score)
+	assert.Equal(t, 2.0, scored.MaxScore)
+
+	assert.Equal(t, http.StatusUnauthorized, authJSON(f.router, "PUT", fmt.Sprintf("%s/%d", bankPath, question.ID),
+		f.teacherToken, map[string]interface{}{"title": "Unit of work", "type": models.SingleCorrect, "points": 2,
+			"options": []map[string]interface{}{{"text": "J", "isCorrect": true}, {"text": "N"}}}).Code,
+		"a drawn question cannot change")
+}
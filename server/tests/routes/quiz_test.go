@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/app/services"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestQuizzes(t *testing.T) {
+	f := newQuizFixture(t)
+
+	now := time.Now()
+	later := f.createQuiz(t, models.Quiz{Title: "Final", StartTime: now.Add(24 * time.Hour),
+		EndTime: now.Add(25 * time.Hour)})
+	quiz := f.createQuiz(t, models.Quiz{Title: "Midterm", ScoringPolicy: models.ScoringPartialCredit,
+		Questions: []models.Question{{Title: "Unit of force", Type: models.SingleCorrect, Points: 1,
+			Options: []models.Option{{Text: "Newton", IsCorrect: true}, {Text: "Joule"}}}}})
+	quizPath := fmt.Sprintf("/api/quizzes/%d", quiz.ID)
+
+	settings := func(title string) map[string]interface{} {
+		return map[string]interface{}{"title": title, "startTime": quiz.StartTime, "endTime": quiz.EndTime,
+			"duration": 45, "maxAttempts": 2}
+	}
+	stored := func(t *testing.T) models.Quiz {
+		w := authJSON(f.router, "GET", quizPath, f.teacherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Quiz models.Quiz `json:"quiz"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Quiz
+	}
+
+	t.Run("List", func(t *testing.T) {
+		listPath := fmt.Sprintf("/api/quizzes/?courseId=%d", f.course.ID)
+		w := authJSON(f.router, "GET", listPath, f.studentToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Quizzes []models.Quiz `json:"quizzes"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Quizzes, 2)
+		assert.Equal(t, quiz.ID, response.Quizzes[0].ID, "ordered by start time")
+		assert.Equal(t, later.ID, response.Quizzes[1].ID)
+		assert.Empty(t, response.Quizzes[0].Questions)
+
+		strangerToken, _ := signUp(t, f.router, f.db, "stranger@example.com")
+		assert.Equal(t, http.StatusForbidden, authJSON(f.router, "GET", listPath, strangerToken, nil).Code)
+	})
+
+	t.Run("Inline questions stay out of question banks", func(t *testing.T) {
+		other := models.Course{Name: "Chemistry", CreatorID: f.teacherID, InvitationCode: "CHE-101"}
+		require.NoError(t, f.db.Create(&other).Error)
+
+		w := authJSON(f.router, "POST", "/api/quizzes/", f.teacherToken, map[string]interface{}{
+			"title": "Pop quiz", "courseId": other.ID, "startTime": quiz.StartTime, "endTime": quiz.EndTime,
+			"duration": 10, "questions": []map[string]interface{}{{"title": "Unit of force", "courseId": f.course.ID,
+				"type": models.SingleCorrect, "points": 1, "tags": []string{"units"},
+				"options": []map[string]interface{}{{"text": "Newton", "isCorrect": true}, {"text": "Joule"}}}}})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var created models.Quiz
+		require.NoError(t, f.db.Preload("Questions").Where("title = ?", "Pop quiz").First(&created).Error)
+		require.Len(t, created.Questions, 1)
+		assert.Nil(t, created.Questions[0].CourseID, "the courseId of an inline question is ignored")
+
+		var banked int64
+		require.NoError(t, f.db.Model(&models.Question{}).Where("course_id = ?", f.course.ID).Count(&banked).Error)
+		assert.Zero(t, banked)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		update := settings("Midterm exam")
+		update["questions"] = []map[string]interface{}{{"title": "Unit of work", "type": models.SingleCorrect,
+			"points": 2, "options": []map[string]interface{}{{"text": "Joule", "isCorrect": true}, {"text": "Watt"}}}}
+		w := authJSON(f.router, "PUT", quizPath, f.teacherToken, update)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		updated := stored(t)
+		assert.Equal(t, "Midterm exam", updated.Title)
+		assert.Equal(t, 45, updated.Duration)
+		assert.Equal(t, models.ScoringPartialCredit, updated.ScoringPolicy, "left out, the scoring policy is kept")
+		require.Len(t, updated.Questions, 1)
+		assert.Equal(t, "Unit of work", updated.Questions[0].Title)
+
+		invalid := settings("Midterm exam")
+		invalid["endTime"] = quiz.StartTime.Add(-time.Minute)
+		assert.Equal(t, http.StatusBadRequest, authJSON(f.router, "PUT", quizPath, f.teacherToken, invalid).Code)
+		assert.Equal(t, http.StatusForbidden, authJSON(f.router, "PUT", quizPath, f.studentToken,
+			settings("Mine now")).Code)
+	})
+
+	t.Run("Attempts lock the questions and scoring", func(t *testing.T) {
+		f.startAttempt(t, f.studentToken, quiz.ID)
+
+		assert.Equal(t, http.StatusOK, authJSON(f.router, "PUT", quizPath, f.teacherToken,
+			settings("Midterm, part one")).Code, "settings other than the questions and scoring may change")
+		assert.Equal(t, "Midterm, part one", stored(t).Title)
+
+		same := settings("Midterm, part one")
+		same["scoringPolicy"] = models.ScoringPartialCredit
+		assert.Equal(t, http.StatusOK, authJSON(f.router, "PUT", quizPath, f.teacherToken, same).Code)
+
+		changed := settings("Midterm, part one")
+		changed["scoringPolicy"] = models.ScoringAllOrNothing
+		assert.Equal(t, http.StatusUnauthorized, authJSON(f.router, "PUT", quizPath, f.teacherToken, changed).Code)
+
+		withQuestions := settings("Midterm, part one")
+		withQuestions["questions"] = []map[string]interface{}{}
+		assert.Equal(t, http.StatusUnauthorized, authJSON(f.router, "PUT", quizPath, f.teacherToken,
+			withQuestions).Code)
+
+		locked := stored(t)
+		assert.Equal(t, models.ScoringPartialCredit, locked.ScoringPolicy)
+		assert.Len(t, locked.Questions, 1)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, authJSON(f.router, "DELETE", quizPath, f.studentToken, nil).Code)
+		assert.Equal(t, http.StatusNoContent, authJSON(f.router, "DELETE", quizPath, f.teacherToken, nil).Code)
+		assert.Equal(t, http.StatusNotFound, authJSON(f.router, "GET", quizPath, f.teacherToken, nil).Code)
+		assert.Equal(t, http.StatusNotFound, authJSON(f.router, "DELETE", quizPath, f.teacherToken, nil).Code)
+
+		var attempts int64
+		require.NoError(t, f.db.Model(&models.QuizSubmission{}).Where("quiz_id = ?", quiz.ID).Count(&attempts).Error)
+		assert.EqualValues(t, 1, attempts, "the attempts are kept")
+	})
+}
+
+// quizFixture is a course created by a teacher, with an approved student, and
+// the routes of its quizzes and question bank.
+type quizFixture struct {
+	db           *gorm.DB
+	router       *gin.Engine
+	course       models.Course
+	teacherID    uint
+	teacherToken string
+	studentID    uint
+	studentToken string
+}
+
+func newQuizFixture(t *testing.T) *quizFixture {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{},
+		&models.Course{}, &models.CourseInvitation{}, &models.Enrollment{}, &models.Quiz{}, &models.Question{},
+		&models.QuestionTag{}, &models.Option{}, &models.QuizDrawRule{}, &models.QuizSubmission{}, &models.Answer{},
+		&models.AttemptQuestion{}, &models.QuizAccommodation{})
+	require.NoError(t, err, "Failed to set up test database")
+	t.Cleanup(func() { setup.CleanupTestDB(context.Background()) })
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupQuizRoutes(router, db, secret)
+	routes.SetupQuestionBankRoutes(router, db, secret)
+
+	f := &quizFixture{db: db, router: router}
+	f.teacherToken, f.teacherID = signUp(t, router, db, "teacher@example.com")
+	f.studentToken, f.studentID = signUp(t, router, db, "student@example.com")
+
+	f.course = models.Course{Name: "Physics", CreatorID: f.teacherID, InvitationCode: "PHY-101"}
+	require.NoError(t, db.Create(&f.course).Error)
+	f.enroll(t, f.studentID, models.RoleStudent)
+	return f
+}
+
+// enroll adds an approved member to the course of the fixture.
+func (f *quizFixture) enroll(t *testing.T, userID uint, role models.Role) {
+	require.NoError(t, f.db.Create(&models.Enrollment{UserID: userID, CourseID: f.course.ID, Role: role,
+		Status: models.EnrollmentStatusApproved}).Error)
+}
+
+// createQuiz stores a quiz of the course by the teacher. Unless the quiz says
+// otherwise, it opened a minute ago, closes in an hour and lasts 30 minutes.
+func (f *quizFixture) createQuiz(t *testing.T, quiz models.Quiz) models.Quiz {
+	quiz.CourseID, quiz.CreatorID = f.course.ID, f.teacherID
+	if quiz.StartTime.IsZero() {
+		quiz.StartTime = time.Now().Add(-time.Minute)
+	}
+	if quiz.EndTime.IsZero() {
+		quiz.EndTime = time.Now().Add(time.Hour)
+	}
+	if quiz.Duration == 0 {
+		quiz.Duration = 30
+	}
+	require.NoError(t, services.NewQuizService(f.db).CreateQuiz(&quiz))
+	return quiz
+}
+
+// startAttempt starts or resumes an attempt of the user at a quiz.
+func (f *quizFixture) startAttempt(t *testing.T, token string, quizID uint) models.QuizSubmission {
+	w := authJSON(f.router, "POST", fmt.Sprintf("/api/quizzes/%d/attempts", quizID), token, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	return decodeAttempt(t, w.Body.Bytes())
+}
+
+// saveAnswers sends answers for an attempt and returns the status code.
+func (f *quizFixture) saveAnswers(token string, attempt models.QuizSubmission, answers ...map[string]interface{}) int {
+	path := fmt.Sprintf("/api/quizzes/%d/attempts/%d/answers", attempt.QuizID, attempt.ID)
+	return authJSON(f.router, "PUT", path, token, map[string]interface{}{"answers": answers}).Code
+}
+
+// submitAttempt submits an attempt and returns the status code.
+func (f *quizFixture) submitAttempt(token string, attempt models.QuizSubmission) int {
+	path := fmt.Sprintf("/api/quizzes/%d/attempts/%d/submit", attempt.QuizID, attempt.ID)
+	return authJSON(f.router, "POST", path, token, nil).Code
+}
+
+// quizView fetches a quiz as the user sees it.
+func (f *quizFixture) quizView(t *testing.T, token string, quizID uint) services.QuizView {
+	w := authJSON(f.router, "GET", fmt.Sprintf("/api/quizzes/%d", quizID), token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Quiz services.QuizView `json:"quiz"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Quiz
+}
+
+// storedAttempt reads an attempt from the database, with its answers and
+// its results, which the routes hide from students until the quiz closes.
+func (f *quizFixture) storedAttempt(t *testing.T, attemptID uint) models.QuizSubmission {
+	var attempt models.QuizSubmission
+	require.NoError(t, f.db.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("question_id ASC")
+	}).First(&attempt, attemptID).Error)
+	return attempt
+}
+
+func decodeAttempt(t *testing.T, body []byte) models.QuizSubmission {
+	var response struct {
+		Attempt models.QuizSubmission `json:"attempt"`
+	}
+	require.NoError(t, json.Unmarshal(body, &response))
+	return response.Attempt
+}
+
+// answer is the body of an answer to save.
+func answer(questionID uint, options ...uint) map[string]interface{} {
+	return map[string]interface{}{"questionId": questionID, "selectedOptions": options}
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStudentQuizView(t *testing.T) {
+	f := newQuizFixture(t)
+
+	options := make([]models.Option, 6)
+	for i := range options {
+		options[i] = models.Option{Text: fmt.Sprintf("Planet %d", i+1), IsCorrect: i == 2}
+	}
+	pi := 3.14
+	quiz := f.createQuiz(t, models.Quiz{Title: "Solar system", ShuffleQuestions: true, ShuffleOptions: true,
+		ShowResults: true, Questions: []models.Question{
+			{Title: "Which one is Earth?", Type: models.SingleCorrect, Points: 1, Options: options},
+			{Title: "Unit of force", Type: models.ShortText, Points: 1, AcceptedAnswers: []string{"newton"}},
+			{Title: "Pi", Type: models.Numeric, Points: 1, NumericAnswer: &pi, Tolerance: 0.01},
+		}})
+	path := fmt.Sprintf("/api/quizzes/%d", quiz.ID)
+
+	order := func(view services.QuizView) (questions []uint, options []uint) {
+		for _, question := range view.Questions {
+			questions = append(questions, question.ID)
+			if question.Type == models.SingleCorrect {
+				for _, option := range question.Options {
+					options = append(options, option.ID)
+				}
+			}
+		}
+		return questions, options
+	}
+
+	t.Run("Questions are hidden until an attempt starts", func(t *testing.T) {
+		assert.Empty(t, f.quizView(t, f.studentToken, quiz.ID).Questions)
+	})
+
+	t.Run("The answer key is stripped", func(t *testing.T) {
+		f.startAttempt(t, f.studentToken, quiz.ID)
+
+		w := authJSON(f.router, "GET", path, f.studentToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		for _, key := range []string{"isCorrect", "answerKey", "acceptedAnswers", "numericAnswer", "newton"} {
+			assert.NotContains(t, w.Body.String(), key)
+		}
+
+		view := f.quizView(t, f.studentToken, quiz.ID)
+		require.Len(t, view.Questions, 3)
+		for _, question := range view.Questions {
+			assert.Nil(t, question.AnswerKey)
+			for _, option := range question.Options {
+				assert.Nil(t, option.IsCorrect)
+			}
+		}
+	})
+
+	t.Run("The shuffle is stable across reloads", func(t *testing.T) {
+		questions, shuffled := order(f.quizView(t, f.studentToken, quiz.ID))
+		for i := 0; i < 3; i++ {
+			reloadedQuestions, reloaded := order(f.quizView(t, f.studentToken, quiz.ID))
+			assert.Equal(t, questions, reloadedQuestions)
+			assert.Equal(t, shuffled, reloaded)
+		}
+
+		stored := make([]uint, len(quiz.Questions[0].Options))
+		for i, option := range quiz.Questions[0].Options {
+			stored[i] = option.ID
+		}
+		assert.ElementsMatch(t, stored, shuffled, "every option is shown once")
+	})
+
+	t.Run("The answer key is revealed once the quiz closes", func(t *testing.T) {
+		require.NoError(t, f.db.Model(&models.Quiz{}).Where("id = ?", quiz.ID).
+			Updates(map[string]interface{}{"start_time": time.Now().Add(-2 * time.Hour),
+				"end_time": time.Now().Add(-time.Hour)}).Error)
+
+		view := f.quizView(t, f.studentToken, quiz.ID)
+		require.True(t, view.ResultsAvailable)
+		for _, question := range view.Questions {
+			switch question.Type {
+			case models.SingleCorrect:
+				for _, option := range question.Options {
+					require.NotNil(t, option.IsCorrect)
+					assert.Equal(t, option.Text == "Planet 3", *option.IsCorrect)
+				}
+			case models.ShortText:
+				require.NotNil(t, question.AnswerKey)
+				assert.Equal(t, []string{"newton"}, question.AnswerKey.AcceptedAnswers)
+			case models.Numeric:
+				require.NotNil(t, question.AnswerKey)
+				assert.Equal(t, pi, *question.AnswerKey.NumericAnswer)
+			}
+		}
+	})
+}
+
+func TestReaderQuizView(t *testing.T) {
+	f := newQuizFixture(t)
+
+	pi := 3.14
+	quiz := f.createQuiz(t, models.Quiz{Title: "Mechanics", Questions: []models.Question{
+		{Title: "Unit of force", Type: models.SingleCorrect, Points: 1,
+			Options: []models.Option{{Text: "Newton", IsCorrect: true}, {Text: "Joule"}}},
+		{Title: "Pi", Type: models.Numeric, Points: 1, NumericAnswer: &pi, Tolerance: 0.01},
+	}})
+	path := fmt.Sprintf("/api/quizzes/%d", quiz.ID)
+
+	colleagueToken, colleagueID := signUp(t, f.router, f.db, "colleague@example.com")
+	f.enroll(t, colleagueID, models.RoleTeacher)
+
+	w := authJSON(f.router, "GET", path, colleagueToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, "teachers who didn't create the quiz read it: %s", w.Body.String())
+	for _, key := range []string{"isCorrect", "answerKey", "numericAnswer"} {
+		assert.NotContains(t, w.Body.String(), key)
+	}
+
+	view := f.quizView(t, colleagueToken, quiz.ID)
+	require.Len(t, view.Questions, 2, "without an attempt to start")
+	assert.Equal(t, "Unit of force", view.Questions[0].Title)
+	assert.Len(t, view.Questions[0].Options, 2)
+	assert.Zero(t, view.AttemptsUsed)
+
+	strangerToken, _ := signUp(t, f.router, f.db, "stranger@example.com")
+	assert.Equal(t, http.StatusForbidden, authJSON(f.router, "GET", path, strangerToken, nil).Code)
+}
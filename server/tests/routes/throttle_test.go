@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.AuditLog{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+	routes.SetupAdminRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+
+	const email = "student@example.com"
+	studentToken, studentID := signUp(t, router, db, email)
+
+	login := func(password string) int {
+		return postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": password}).Code
+	}
+	// skipBackoff lets the wait after a failure pass, without waiting.
+	skipBackoff := func() {
+		require.NoError(t, db.Model(&models.LoginThrottle{}).Where("key = ?", "account:"+email).
+			Update("locked_until", nil).Error)
+	}
+
+	t.Run("Failures back off", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, login("wrong-password"))
+		}
+
+		w := postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": testUserPassword})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "even the right password must wait")
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		skipBackoff()
+		assert.Equal(t, http.StatusOK, login(testUserPassword), "a successful login clears the failures")
+		assert.Equal(t, http.StatusUnauthorized, login("wrong-password"))
+	})
+
+	t.Run("Lockout after repeated failures", func(t *testing.T) {
+		for i := 1; i < 10; i++ {
+			skipBackoff()
+			assert.Equal(t, http.StatusUnauthorized, login("wrong-password"))
+		}
+
+		w := postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": testUserPassword})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "900", w.Header().Get("Retry-After"))
+
+		var entry models.AuditLog
+		require.NoError(t, db.Where("action = ?", models.AuditLoginLockout).First(&entry).Error)
+		require.NotNil(t, entry.UserID)
+		assert.Equal(t, studentID, *entry.UserID)
+	})
+
+	t.Run("Admin unlocks the account", func(t *testing.T) {
+		unlockPath := fmt.Sprintf("/admin/users/%d/unlock", studentID)
+		assert.Equal(t, http.StatusForbidden, authJSON(router, "POST", unlockPath, studentToken, nil).Code)
+
+		adminToken, adminID := signUp(t, router, db, "admin@example.com")
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.RoleAdmin).Error)
+
+		w := authJSON(router, "POST", unlockPath, adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusOK, login(testUserPassword))
+
+		w = authJSON(router, "GET", "/admin/audit-logs?action=account.unlock", adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var entries []models.AuditLog
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		require.Len(t, entries, 1)
+		assert.Equal(t, adminID, *entries[0].ActorID)
+		assert.Equal(t, studentID, *entries[0].UserID)
+	})
+
+	t.Run("Forwarded addresses are not trusted", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"email": "nobody@example.com", "password": "wrong-password"})
+		req, _ := http.NewRequest("POST", "/users/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		req.RemoteAddr = "203.0.113.7:41000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+
+		var keys []string
+		require.NoError(t, db.Model(&models.LoginThrottle{}).Where("key LIKE ?", "ip:%").Pluck("key", &keys).Error)
+		assert.Equal(t, []string{"ip:203.0.113.7"}, keys)
+	})
+
+	t.Run("Inactive account cannot log in", func(t *testing.T) {
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", studentID).Update("active", false).Error)
+		assert.Equal(t, http.StatusUnauthorized, login(testUserPassword))
+	})
+}
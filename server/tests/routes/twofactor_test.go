@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/routes"
+	"server/app/totp"
+	"server/tests/setup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorRoutes(t *testing.T) {
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.UserToken{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{})
+	require.NoError(t, err, "Failed to set up test database")
+	defer setup.CleanupTestDB(context.Background())
+
+	router := gin.Default()
+	routes.SetUpUserRoutes(router, db, []byte(secret), time.Hour, &outbox{}, "http://localhost:3000")
+
+	const email = "teacher@example.com"
+	token, _ := signUp(t, router, db, email)
+
+	decode := func(t *testing.T, w interface{ Bytes() []byte }) map[string]interface{} {
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Bytes(), &response))
+		return response
+	}
+	codeAt := func(t *testing.T, secret string, step int64) string {
+		code, err := totp.Code(secret, step)
+		require.NoError(t, err)
+		return code
+	}
+	login := func(t *testing.T) string {
+		w := postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": testUserPassword})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		response := decode(t, w.Body)
+		assert.Nil(t, response["token"], "no access token before the second factor")
+		return response["twoFactorToken"].(string)
+	}
+
+	var totpSecret string
+	var enabledStep int64
+	var recoveryCodes []interface{}
+	t.Run("Enrollment", func(t *testing.T) {
+		w := authJSON(router, "POST", "/users/2fa/enable", token, map[string]interface{}{"code": "123456"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "enabling needs a setup first")
+
+		w = authJSON(router, "POST", "/users/2fa/setup", token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		response := decode(t, w.Body)
+		totpSecret = response["secret"].(string)
+		assert.Contains(t, response["uri"], "otpauth://totp/Glass:teacher@example.com")
+
+		// Until the setup is confirmed, the login needs no second factor.
+		w = postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": testUserPassword})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, decode(t, w.Body)["token"])
+
+		w = authJSON(router, "POST", "/users/2fa/enable", token, map[string]interface{}{"code": "000000"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		enabledStep = totp.Step(time.Now())
+		w = authJSON(router, "POST", "/users/2fa/enable", token, map[string]interface{}{
+			"code": codeAt(t, totpSecret, enabledStep),
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		recoveryCodes = decode(t, w.Body)["recoveryCodes"].([]interface{})
+		assert.Len(t, recoveryCodes, 10)
+
+		var stored []models.RecoveryCode
+		require.NoError(t, db.Find(&stored).Error)
+		for _, code := range stored {
+			assert.NotContains(t, recoveryCodes, code.CodeHash, "only hashes are stored")
+		}
+	})
+
+	t.Run("Two-factor token is not an access token", func(t *testing.T) {
+		partial := login(t)
+		assert.Equal(t, http.StatusUnauthorized, getProfile(router, partial).Code)
+	})
+
+	t.Run("Verify with a TOTP code", func(t *testing.T) {
+		partial := login(t)
+
+		// The code that enabled two-factor authentication cannot be used again.
+		w := postJSON(router, "/users/2fa/verify", map[string]interface{}{
+			"twoFactorToken": partial,
+			"code":           codeAt(t, totpSecret, enabledStep),
+		})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(router, "/users/2fa/verify", map[string]interface{}{
+			"twoFactorToken": partial,
+			"code":           codeAt(t, totpSecret, enabledStep+1),
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusOK, getProfile(router, decode(t, w.Body)["token"].(string)).Code)
+	})
+
+	t.Run("Verify with a recovery code", func(t *testing.T) {
+		recoveryCode := recoveryCodes[0].(string)
+
+		w := postJSON(router, "/users/2fa/verify", map[string]interface{}{"twoFactorToken": login(t), "code": recoveryCode})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = postJSON(router, "/users/2fa/verify", map[string]interface{}{"twoFactorToken": login(t), "code": recoveryCode})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "recovery codes work once")
+
+		w = postJSON(router, "/users/2fa/verify", map[string]interface{}{"twoFactorToken": token, "code": recoveryCodes[1]})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "access tokens are not two-factor tokens")
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		w := authJSON(router, "POST", "/users/2fa/disable", token, map[string]interface{}{"code": "not-a-code"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = authJSON(router, "POST", "/users/2fa/disable", token, map[string]interface{}{"code": recoveryCodes[2]})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = postJSON(router, "/users/login", map[string]interface{}{"email": email, "password": testUserPassword})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, decode(t, w.Body)["token"])
+	})
+}
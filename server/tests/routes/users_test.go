@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"server/app/handlers"
+	"server/app/mailer"
 	"server/app/middlewares"
 	"server/app/models"
 	"server/tests/setup"
@@ -20,6 +22,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -30,19 +33,47 @@ const (
 	newPassword      = "newpassword123"
 )
 
-func setupUserTestRouter(db *gorm.DB) (*gin.Engine, *services.UserService) {
+// outbox is a mailer that keeps the emails it is asked to send.
+type outbox struct {
+	messages []mailer.Message
+}
+
+func (o *outbox) Send(msg mailer.Message) error {
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// lastToken returns the token of the link in the last email sent.
+func (o *outbox) lastToken() string {
+	if len(o.messages) == 0 {
+		return ""
+	}
+	match := regexp.MustCompile(`token=([A-Za-z0-9_-]+)`).FindStringSubmatch(o.messages[len(o.messages)-1].Body)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func setupUserTestRouter(db *gorm.DB, mail mailer.Mailer) (*gin.Engine, *services.UserService) {
 	r := gin.Default()
 	expiration := time.Hour * 24
 
 	userService := services.NewUserService(db, []byte(secret), expiration)
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, services.NewAccountService(db, mail, "http://localhost:3000"), services.NewLoginThrottleService(db))
 
 	r.POST("/users/register", userHandler.Register)
 	r.POST("/users/login", userHandler.Login)
+	r.POST("/users/refresh", userHandler.Refresh)
+	r.POST("/users/verify-email", userHandler.VerifyEmail)
+	r.POST("/users/password-reset/request", userHandler.RequestPasswordReset)
+	r.POST("/users/password-reset", userHandler.ResetPassword)
 
 	authorized := r.Group("/users")
-	authorized.Use(middlewares.AuthMiddleware(secret))
+	authorized.Use(middlewares.AuthMiddleware(secret, services.NewSessionService(db)))
 	{
+		authorized.POST("/logout", userHandler.Logout)
+		authorized.POST("/logout-all", userHandler.LogoutAll)
 		authorized.GET("/profile", userHandler.GetProfile)
 		authorized.PUT("/profile", userHandler.UpdateProfile)
 		authorized.DELETE("/profile", userHandler.DeleteUser)
@@ -53,11 +84,13 @@ func setupUserTestRouter(db *gorm.DB) (*gin.Engine, *services.UserService) {
 }
 
 func TestUserRoutes(t *testing.T) {
-	db, err := setup.SetupTestDB(context.Background(), &models.User{})
+	db, err := setup.SetupTestDB(context.Background(), &models.User{}, &models.Session{}, &models.RefreshToken{}, &models.UserToken{},
+		&models.TwoFactor{}, &models.RecoveryCode{}, &models.LoginThrottle{})
 	require.NoError(t, err, "Failed to set up test database")
 	defer setup.CleanupTestDB(context.Background())
 
-	router, userService := setupUserTestRouter(db)
+	mail := &outbox{}
+	router, userService := setupUserTestRouter(db, mail)
 
 	t.Run("RegisterUser", func(t *testing.T) {
 		userData := map[string]interface{}{
@@ -73,6 +106,40 @@ func TestUserRoutes(t *testing.T) {
 
 		assert.Equal(t, http.StatusCreated, w.Code)
 		assert.Contains(t, w.Body.String(), "User created successfully")
+		require.Len(t, mail.messages, 1)
+		assert.Equal(t, testUserEmail, mail.messages[0].To)
+	})
+
+	t.Run("VerifyEmail", func(t *testing.T) {
+		token := mail.lastToken()
+		require.NotEmpty(t, token)
+
+		w := postJSON(router, "/users/verify-email", map[string]interface{}{"token": token})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		user, err := userService.GetUserByEmail(testUserEmail)
+		require.NoError(t, err)
+		assert.NotNil(t, user.EmailVerifiedAt)
+
+		// Verification tokens are single-use.
+		w = postJSON(router, "/users/verify-email", map[string]interface{}{"token": token})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Passwords that look like a hash are hashed", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte(testUserPassword), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		w := postJSON(router, "/users/register", map[string]interface{}{"email": "hashlike@example.com", "password": string(hash)})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		user, err := userService.GetUserByEmail("hashlike@example.com")
+		require.NoError(t, err)
+		assert.NotEqual(t, string(hash), user.Password)
+		_, err = loginUser(userService, "hashlike@example.com", string(hash))
+		assert.NoError(t, err)
+		_, err = loginUser(userService, "hashlike@example.com", testUserPassword)
+		assert.Error(t, err)
 	})
 
 	t.Run("LoginUser", func(t *testing.T) {
@@ -132,6 +199,86 @@ func TestUserRoutes(t *testing.T) {
 		assert.Equal(t, "Doe", response["lastName"])
 	})
 
+	t.Run("ChangeEmail", func(t *testing.T) {
+		token, userID := signUp(t, router, db, "mover@example.com")
+		oldLink := mail.lastToken()
+		other, err := userService.GetUserByEmail(testUserEmail)
+		require.NoError(t, err)
+
+		w := authJSON(router, "PUT", "/users/profile", token, map[string]interface{}{"email": "moved@example.com",
+			"id": other.ID, "active": false, "role": models.RoleAdmin, "emailVerifiedAt": time.Now()})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var user models.User
+		require.NoError(t, db.First(&user, userID).Error)
+		assert.Equal(t, "moved@example.com", user.Email)
+		assert.Nil(t, user.EmailVerifiedAt, "the new address is unverified")
+		assert.True(t, user.Active)
+		assert.Equal(t, models.RoleUser, user.Role)
+		unchanged, err := userService.GetUserByEmail(testUserEmail)
+		require.NoError(t, err)
+		assert.Equal(t, other.ID, unchanged.ID, "the id in the body is ignored")
+
+		require.Equal(t, "moved@example.com", mail.messages[len(mail.messages)-1].To)
+		newLink := mail.lastToken()
+
+		w = postJSON(router, "/users/verify-email", map[string]interface{}{"token": oldLink})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the link sent to the old address doesn't verify the new one")
+
+		// Links only verify the address they were sent to.
+		require.NoError(t, db.Model(&user).UpdateColumn("email", "elsewhere@example.com").Error)
+		w = postJSON(router, "/users/verify-email", map[string]interface{}{"token": newLink})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		require.NoError(t, db.Model(&user).UpdateColumn("email", "moved@example.com").Error)
+
+		w = postJSON(router, "/users/verify-email", map[string]interface{}{"token": newLink})
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, db.First(&user, userID).Error)
+		assert.NotNil(t, user.EmailVerifiedAt)
+	})
+
+	t.Run("RefreshToken", func(t *testing.T) {
+		tokens, err := userService.AuthenticateUser(testUserEmail, testUserPassword)
+		require.NoError(t, err)
+
+		w := postJSON(router, "/users/refresh", map[string]interface{}{"refreshToken": tokens.RefreshToken})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response["token"])
+		assert.NotEqual(t, tokens.RefreshToken, response["refreshToken"])
+
+		// Reusing a spent refresh token revokes the whole session.
+		w = postJSON(router, "/users/refresh", map[string]interface{}{"refreshToken": tokens.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = postJSON(router, "/users/refresh", map[string]interface{}{"refreshToken": response["refreshToken"]})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		token, _ := loginUser(userService, testUserEmail, testUserPassword)
+		other, _ := loginUser(userService, testUserEmail, testUserPassword)
+
+		req, _ := http.NewRequest("POST", "/users/logout", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, getProfile(router, token).Code)
+		assert.Equal(t, http.StatusOK, getProfile(router, other).Code)
+
+		req, _ = http.NewRequest("POST", "/users/logout-all", nil)
+		req.Header.Set("Authorization", "Bearer "+other)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, getProfile(router, other).Code)
+	})
+
 	t.Run("ChangePassword", func(t *testing.T) {
 		token, _ := loginUser(userService, testUserEmail, testUserPassword)
 		changePasswordData := map[string]interface{}{
@@ -154,6 +301,43 @@ func TestUserRoutes(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("ResetPassword", func(t *testing.T) {
+		token, _ := loginUser(userService, testUserEmail, newPassword)
+		sent := len(mail.messages)
+
+		w := postJSON(router, "/users/password-reset/request", map[string]interface{}{"email": "nobody@example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, mail.messages, sent)
+
+		w = postJSON(router, "/users/password-reset/request", map[string]interface{}{"email": testUserEmail})
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.Len(t, mail.messages, sent+1)
+		resetToken := mail.lastToken()
+
+		w = postJSON(router, "/users/password-reset", map[string]interface{}{"token": resetToken, "password": "short"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(router, "/users/password-reset", map[string]interface{}{"token": resetToken, "password": "resetpassword123"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = postJSON(router, "/users/password-reset", map[string]interface{}{"token": resetToken, "password": "otherpassword123"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// Resetting the password signs the user out everywhere.
+		assert.Equal(t, http.StatusUnauthorized, getProfile(router, token).Code)
+
+		_, err := loginUser(userService, testUserEmail, newPassword)
+		assert.Error(t, err)
+		_, err = loginUser(userService, testUserEmail, "resetpassword123")
+		assert.NoError(t, err)
+
+		// Set the password back for the tests that follow.
+		user, err := userService.GetUserByEmail(testUserEmail)
+		require.NoError(t, err)
+		require.NoError(t, user.SetPassword(newPassword))
+		require.NoError(t, userService.UpdateUser(user))
+	})
+
 	t.Run("DeleteUser", func(t *testing.T) {
 		token, _ := loginUser(userService, testUserEmail, newPassword)
 		req, _ := http.NewRequest("DELETE", "/users/profile", nil)
@@ -172,9 +356,28 @@ func TestUserRoutes(t *testing.T) {
 }
 
 func loginUser(userService *services.UserService, email, password string) (string, error) {
-	token, err := userService.AuthenticateUser(email, password)
+	tokens, err := userService.AuthenticateUser(email, password)
 	if err != nil {
 		return "", fmt.Errorf("failed to authenticate user: %v", err)
 	}
-	return token, nil
+	return tokens.AccessToken, nil
+}
+
+func postJSON(router *gin.Engine, path string, data map[string]interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(data)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func getProfile(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/users/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
 }
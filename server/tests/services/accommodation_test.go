@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleFor(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	quiz := &models.Quiz{StartTime: start, EndTime: start.Add(2 * time.Hour), Duration: 45, MaxAttempts: 2}
+
+	t.Run("No accommodation", func(t *testing.T) {
+		schedule := services.ScheduleFor(quiz, nil)
+		assert.Equal(t, quiz.StartTime, schedule.StartTime)
+		assert.Equal(t, quiz.EndTime, schedule.EndTime)
+		assert.Equal(t, 45, schedule.Duration)
+		assert.Equal(t, 2, schedule.MaxAttempts)
+	})
+
+	t.Run("Extra time rounds up", func(t *testing.T) {
+		schedule := services.ScheduleFor(quiz, &models.QuizAccommodation{DurationMultiplier: 1.5, ExtraMinutes: 5})
+		assert.Equal(t, 73, schedule.Duration)
+	})
+
+	t.Run("Make-up window and extra attempts", func(t *testing.T) {
+		makeUpStart := start.Add(48 * time.Hour)
+		makeUpEnd := makeUpStart.Add(time.Hour)
+		schedule := services.ScheduleFor(quiz, &models.QuizAccommodation{
+			StartTime:     &makeUpStart,
+			EndTime:       &makeUpEnd,
+			ExtraAttempts: 1,
+		})
+
+		assert.Equal(t, makeUpStart, schedule.StartTime)
+		assert.Equal(t, makeUpEnd, schedule.EndTime)
+		assert.Equal(t, 45, schedule.Duration)
+		assert.Equal(t, 3, schedule.MaxAttempts)
+	})
+}
+
+func TestFinalScore(t *testing.T) {
+	attempts := []models.QuizSubmission{
+		{Number: 1, Status: models.AttemptStatusSubmitted, Score: 6},
+		{Number: 3, Status: models.AttemptStatusSubmitted, Score: 4},
+		{Number: 2, Status: models.AttemptStatusSubmitted, Score: 9},
+		{Number: 4, Status: models.AttemptStatusPendingReview, Score: 10},
+	}
+
+	tests := []struct {
+		scoring  models.AttemptScoring
+		expected float64
+	}{
+		{scoring: models.AttemptScoringHighest, expected: 9},
+		{scoring: models.AttemptScoringLatest, expected: 4},
+		{scoring: models.AttemptScoringAverage, expected: 6.33},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scoring), func(t *testing.T) {
+			score, ok := services.FinalScore(tt.scoring, attempts)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expected, score)
+		})
+	}
+
+	t.Run("Nothing graded yet", func(t *testing.T) {
+		_, ok := services.FinalScore(models.AttemptScoringHighest, attempts[3:])
+		assert.False(t, ok)
+	})
+}
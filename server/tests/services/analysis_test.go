@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"testing"
+
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// graded builds a graded attempt from the points awarded per question, with
+// each question worth one point.
+func graded(points ...float64) models.QuizSubmission {
+	attempt := models.QuizSubmission{Status: models.AttemptStatusSubmitted, MaxScore: float64(len(points))}
+	for i, p := range points {
+		attempt.Score += p
+		attempt.Answers = append(attempt.Answers, models.Answer{QuestionID: uint(i + 1), PointsAwarded: p, TimeSpentSeconds: 30})
+	}
+	return attempt
+}
+
+func TestAnalyzeItems(t *testing.T) {
+	questions := []models.Question{
+		{Model: gorm.Model{ID: 1}, Type: models.SingleCorrect, Points: 1, Options: []models.Option{option(11, true), option(12, false)}},
+		{Model: gorm.Model{ID: 2}, Type: models.SingleCorrect, Points: 1},
+		{Model: gorm.Model{ID: 3}, Type: models.SingleCorrect, Points: 1},
+	}
+
+	attempts := []models.QuizSubmission{
+		graded(1, 1, 1),
+		graded(1, 1, 0),
+		graded(1, 0, 0),
+		graded(0, 0, 0),
+	}
+	attempts[0].Answers[0].SelectedOptions = []uint{11}
+	attempts[1].Answers[0].SelectedOptions = []uint{11}
+	attempts[2].Answers[0].SelectedOptions = []uint{11}
+	attempts[3].Answers[0].SelectedOptions = []uint{12}
+
+	analysis := services.AnalyzeItems(questions, attempts)
+
+	assert.Equal(t, 4, analysis.Attempts)
+	assert.Equal(t, 50.0, analysis.AverageScore)
+
+	first := analysis.Questions[0]
+	assert.Equal(t, 4, first.Responses)
+	assert.Equal(t, 75.0, first.PercentCorrect)
+	assert.Equal(t, 0.75, first.AverageCredit)
+	assert.Equal(t, 30.0, first.AverageTimeSeconds)
+	assert.Equal(t, 3, first.Options[0].Count)
+	assert.Equal(t, 25.0, first.Options[1].Percent)
+
+	// With four students the top and bottom groups hold one student each.
+	assert.Equal(t, 1.0, *first.Discrimination)
+	assert.Equal(t, 1.0, *analysis.Questions[2].Discrimination)
+
+	counts := make([]int, len(analysis.Histogram))
+	for i, bin := range analysis.Histogram {
+		counts[i] = bin.Count
+	}
+	assert.Equal(t, []int{1, 0, 0, 1, 0, 0, 1, 0, 0, 1}, counts)
+
+	// k/(k-1) * (1 - sum(pq) / variance) = 3/2 * (1 - 0.625/1.25)
+	assert.NotNil(t, analysis.KR20)
+	assert.Equal(t, 0.75, *analysis.KR20)
+}
+
+func TestAnalyzeItemsWithoutVariance(t *testing.T) {
+	questions := []models.Question{
+		{Model: gorm.Model{ID: 1}, Points: 1},
+		{Model: gorm.Model{ID: 2}, Points: 1},
+	}
+
+	analysis := services.AnalyzeItems(questions, []models.QuizSubmission{graded(1, 1), graded(1, 1)})
+	assert.Nil(t, analysis.KR20)
+
+	analysis = services.AnalyzeItems(questions, nil)
+	assert.Equal(t, 0, analysis.Attempts)
+	assert.Nil(t, analysis.Questions[0].Discrimination)
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"server/app/imaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func filledImage(w, h int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// jpegWithOrientation encodes a JPEG with an EXIF segment holding an orientation tag.
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	data := buf.Bytes()
+
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	_ = binary.Write(&tiff, binary.LittleEndian, uint32(8))      // Offset of the first IFD
+	_ = binary.Write(&tiff, binary.LittleEndian, uint16(1))      // One entry
+	_ = binary.Write(&tiff, binary.LittleEndian, uint16(0x0112)) // Orientation
+	_ = binary.Write(&tiff, binary.LittleEndian, uint16(3))      // SHORT
+	_ = binary.Write(&tiff, binary.LittleEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.LittleEndian, orientation)
+	_ = binary.Write(&tiff, binary.LittleEndian, uint16(0))
+	_ = binary.Write(&tiff, binary.LittleEndian, uint32(0)) // No next IFD
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2]) // Start of image
+	out.Write([]byte{0xff, 0xe1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func TestDecodeChecksContent(t *testing.T) {
+	red := filledImage(4, 2, color.NRGBA{R: 255, A: 255})
+
+	_, format, err := imaging.Decode(encodePNG(t, red), 100)
+	require.NoError(t, err)
+	assert.Equal(t, imaging.FormatPNG, format)
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, red, nil))
+	_, format, err = imaging.Decode(buf.Bytes(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, imaging.FormatJPEG, format)
+
+	_, _, err = imaging.Decode([]byte("GIF89a not really an image"), 100)
+	assert.ErrorIs(t, err, imaging.ErrUnsupportedFormat)
+	_, _, err = imaging.Decode([]byte("<html><script>alert(1)</script></html>"), 100)
+	assert.ErrorIs(t, err, imaging.ErrUnsupportedFormat)
+
+	truncated := encodePNG(t, red)
+	_, _, err = imaging.Decode(truncated[:len(truncated)/2], 100)
+	assert.Error(t, err, "a truncated image is rejected")
+
+	_, _, err = imaging.Decode(encodePNG(t, red), 7)
+	assert.Error(t, err, "an image of more pixels than allowed is rejected")
+}
+
+func TestDecodeAppliesOrientation(t *testing.T) {
+	img := filledImage(8, 4, color.NRGBA{G: 255, A: 255})
+
+	decoded, _, err := imaging.Decode(jpegWithOrientation(t, img, 6), 100)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 4, 8), decoded.Bounds(), "rotated a quarter turn")
+
+	decoded, _, err = imaging.Decode(jpegWithOrientation(t, img, 3), 100)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 8, 4), decoded.Bounds())
+
+	var buf bytes.Buffer
+	require.NoError(t, imaging.Encode(&buf, decoded, imaging.FormatJPEG))
+	assert.NotContains(t, buf.String(), "Exif", "the metadata is stripped")
+}
+
+func TestSquare(t *testing.T) {
+	// Blue on the left and right, red in the middle square.
+	img := filledImage(12, 6, color.NRGBA{B: 255, A: 255})
+	for y := 0; y < 6; y++ {
+		for x := 3; x < 9; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	square := imaging.Square(img, 3)
+	assert.Equal(t, image.Rect(0, 0, 3, 3), square.Bounds())
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			assert.Equal(t, color.NRGBA{R: 255, A: 255}, square.NRGBAAt(x, y), "only the middle square is kept")
+		}
+	}
+
+	// Black and white stripes average to gray.
+	stripes := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				stripes.Set(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+			} else {
+				stripes.Set(x, y, color.NRGBA{A: 255})
+			}
+		}
+	}
+	gray := imaging.Square(stripes, 1).NRGBAAt(0, 0)
+	assert.InDelta(t, 127, int(gray.R), 1)
+	assert.Equal(t, uint8(255), gray.A)
+
+	// Transparent pixels don't darken their neighbours.
+	half := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	half.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	half.Set(1, 1, color.NRGBA{R: 255, A: 255})
+	pixel := imaging.Square(half, 1).NRGBAAt(0, 0)
+	assert.Equal(t, uint8(255), pixel.R)
+	assert.InDelta(t, 127, int(pixel.A), 1)
+
+	assert.Equal(t, image.Rect(0, 0, 64, 64), imaging.Square(img, 64).Bounds(), "small images are scaled up")
+}
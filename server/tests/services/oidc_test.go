@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"server/app/oidc"
+	"server/tests/setup"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockProvider(t *testing.T) (*setup.MockOIDCProvider, *oidc.Provider) {
+	mock, err := setup.NewMockOIDCProvider("glass")
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Name:        "mock",
+		Issuer:      mock.Issuer(),
+		ClientID:    "glass",
+		RedirectURL: "http://localhost:8080/users/oidc/mock/callback",
+	}, nil)
+	require.NoError(t, err)
+	return mock, provider
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	mock, provider := newMockProvider(t)
+	mock.SignInAs(setup.MockOIDCUser{Subject: "user-1", Email: "ada@example.com", EmailVerified: true, GivenName: "Ada"})
+
+	login := func(t *testing.T, nonce string) (code, verifier string) {
+		verifier, challenge, err := oidc.NewPKCE()
+		require.NoError(t, err)
+
+		authURL, err := provider.AuthCodeURL(ctx, "state-1", nonce, challenge)
+		require.NoError(t, err)
+
+		redirect, err := mock.Authorize(authURL)
+		require.NoError(t, err)
+		assert.Equal(t, "state-1", redirect.Query().Get("state"))
+		return redirect.Query().Get("code"), verifier
+	}
+
+	t.Run("Valid exchange", func(t *testing.T) {
+		code, verifier := login(t, "nonce-1")
+
+		claims, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, &oidc.Claims{Subject: "user-1", Email: "ada@example.com", EmailVerified: true, GivenName: "Ada"}, claims)
+
+		_, err = provider.Exchange(ctx, code, verifier, "nonce-1")
+		assert.Error(t, err, "a code can only be used once")
+	})
+
+	t.Run("Wrong PKCE verifier", func(t *testing.T) {
+		code, _ := login(t, "nonce-1")
+		_, err := provider.Exchange(ctx, code, "not-the-verifier", "nonce-1")
+		assert.Error(t, err)
+	})
+
+	t.Run("Wrong nonce", func(t *testing.T) {
+		code, verifier := login(t, "nonce-1")
+		_, err := provider.Exchange(ctx, code, verifier, "nonce-2")
+		assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken))
+	})
+
+	t.Run("Token for another client", func(t *testing.T) {
+		mock.Audience = "other-client"
+		defer func() { mock.Audience = "" }()
+
+		code, verifier := login(t, "nonce-1")
+		_, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+		assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken))
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ctx := context.Background()
+	mock, provider := newMockProvider(t)
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            mock.Issuer(),
+			"aud":            []interface{}{"other-client", "glass"},
+			"sub":            "user-1",
+			"email":          "ada@example.com",
+			"email_verified": "true",
+			"nonce":          "nonce-1",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		valid  bool
+	}{
+		{name: "Valid token", modify: func(jwt.MapClaims) {}, valid: true},
+		{name: "Other issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "Other audience", modify: func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{name: "Expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "No expiry", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "No nonce", modify: func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{name: "No subject", modify: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+
+			raw, err := mock.SignIDToken(claims)
+			require.NoError(t, err)
+
+			result, err := provider.VerifyIDToken(ctx, raw, "nonce-1")
+			if tt.valid {
+				require.NoError(t, err)
+				assert.True(t, result.EmailVerified)
+			} else {
+				assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken), "got %v", err)
+			}
+		})
+	}
+
+	t.Run("Signed with an unknown key", func(t *testing.T) {
+		other, err := setup.NewMockOIDCProvider("glass")
+		require.NoError(t, err)
+		defer other.Close()
+
+		raw, err := other.SignIDToken(valid())
+		require.NoError(t, err)
+
+		_, err = provider.VerifyIDToken(ctx, raw, "nonce-1")
+		assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken))
+	})
+}
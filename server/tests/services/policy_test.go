@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"testing"
+
+	"server/app/models"
+	"server/app/policy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCourseScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     models.Role
+		perm     policy.Permission
+		expected policy.Scope
+	}{
+		{name: "Student reads own submissions", role: models.RoleStudent, perm: policy.SubmissionRead, expected: policy.ScopeOwn},
+		{name: "Teacher reads every submission", role: models.RoleTeacher, perm: policy.SubmissionRead, expected: policy.ScopeAll},
+		{name: "Student cannot grade", role: models.RoleStudent, perm: policy.GradeCreate, expected: policy.ScopeNone},
+		{name: "Teacher grades", role: models.RoleTeacher, perm: policy.GradeCreate, expected: policy.ScopeAll},
+		{name: "Teacher cannot update the course", role: models.RoleTeacher, perm: policy.CourseUpdate, expected: policy.ScopeNone},
+		{name: "Admin updates the course", role: models.RoleAdmin, perm: policy.CourseUpdate, expected: policy.ScopeAll},
+		{name: "Teacher manages own quizzes", role: models.RoleTeacher, perm: policy.QuizManage, expected: policy.ScopeOwn},
+		{name: "Admin manages every quiz", role: models.RoleAdmin, perm: policy.QuizManage, expected: policy.ScopeAll},
+		{name: "No role grants nothing", role: "", perm: policy.CourseRead, expected: policy.ScopeNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, policy.CourseScope(tt.role, tt.perm))
+		})
+	}
+}
+
+func TestArchivedScope(t *testing.T) {
+	assert.Equal(t, policy.ScopeAll, policy.ArchivedScope(models.RoleStudent, policy.MaterialRead))
+	assert.Equal(t, policy.ScopeOwn, policy.ArchivedScope(models.RoleStudent, policy.SubmissionRead))
+	assert.Equal(t, policy.ScopeAll, policy.ArchivedScope(models.RoleAdmin, policy.CourseClone))
+	assert.Equal(t, policy.ScopeNone, policy.ArchivedScope(models.RoleStudent, policy.SubmissionCreate), "nothing can be submitted")
+	assert.Equal(t, policy.ScopeNone, policy.ArchivedScope(models.RoleTeacher, policy.GradeUpdate))
+	assert.Equal(t, policy.ScopeNone, policy.ArchivedScope(models.RoleAdmin, policy.CourseUpdate))
+	assert.Equal(t, policy.ScopeNone, policy.ArchivedScope(models.RoleAdmin, policy.CourseArchive), "only global admins restore")
+	assert.Equal(t, policy.ScopeNone, policy.ArchivedScope(models.RoleStudent, policy.CourseClone))
+}
+
+func TestPlatformScope(t *testing.T) {
+	assert.Equal(t, policy.ScopeAll, policy.PlatformScope(policy.CourseCreate))
+	assert.Equal(t, policy.ScopeOwn, policy.PlatformScope(policy.GradeRead))
+	assert.Equal(t, policy.ScopeOwn, policy.PlatformScope(policy.ProfileManage), "users manage their own account only")
+	assert.Equal(t, policy.ScopeNone, policy.PlatformScope(policy.CourseUpdate))
+}
+
+func TestAllows(t *testing.T) {
+	own := policy.Resource{CourseID: 1, OwnerID: 7}
+	unowned := policy.Resource{CourseID: 1}
+
+	assert.True(t, policy.Allows(policy.ScopeAll, 3, own))
+	assert.True(t, policy.Allows(policy.ScopeOwn, 7, own))
+	assert.False(t, policy.Allows(policy.ScopeOwn, 3, own))
+	assert.False(t, policy.Allows(policy.ScopeOwn, 0, unowned))
+	assert.False(t, policy.Allows(policy.ScopeNone, 7, own))
+}
+
+func TestTokenScope(t *testing.T) {
+	scope := policy.TokenScope{Permissions: []policy.Permission{policy.GradeRead, policy.SubmissionRead}}
+	assert.True(t, scope.Allows(policy.GradeRead, policy.Resource{CourseID: 4}))
+	assert.True(t, scope.Allows(policy.GradeRead, policy.Resource{OwnerID: 2}))
+	assert.False(t, scope.Allows(policy.GradeUpdate, policy.Resource{CourseID: 4}))
+
+	scope.CourseID = 4
+	assert.True(t, scope.Allows(policy.SubmissionRead, policy.Resource{CourseID: 4}))
+	assert.False(t, scope.Allows(policy.SubmissionRead, policy.Resource{CourseID: 5}), "tokens for one course stay in it")
+	assert.False(t, scope.Allows(policy.GradeRead, policy.Resource{OwnerID: 2}), "nor act outside of courses")
+
+	assert.True(t, policy.TokenPermission(policy.GradeRead))
+	assert.False(t, policy.TokenPermission(policy.UserManage))
+	assert.False(t, policy.TokenPermission(policy.ProfileManage))
+	assert.False(t, policy.TokenPermission("grades:write"))
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionRejectsUnreadableFiles(t *testing.T) {
+	// The file is read before the database is touched, so none is needed.
+	provisioning := services.NewProvisioningService(nil, nil, nil)
+
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "Empty file", file: ""},
+		{name: "Header only", file: "first_name,last_name,email\n"},
+		{name: "Missing email column", file: "first_name,last_name\nAda,Lovelace\n"},
+		{name: "Unterminated quote", file: "first_name,last_name,email\n\"Ada,Lovelace,ada@example.com\n"},
+		{name: "Too many rows", file: "first_name,last_name,email\n" + strings.Repeat("Ada,Lovelace,ada@example.com\n", 2001)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provisioning.Provision(1, strings.NewReader(tt.file), true)
+
+			var invalid services.InvalidInputError
+			assert.True(t, errors.As(err, &invalid), "got %v", err)
+		})
+	}
+}
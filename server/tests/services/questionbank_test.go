@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"testing"
+
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrawQuestions(t *testing.T) {
+	rules := []models.QuizDrawRule{{Tag: "algebra", Count: 2}, {Tag: "geometry", Count: 2}}
+	pools := [][]uint{{1, 2, 3, 4, 5}, {4, 5, 6, 7}}
+
+	t.Run("Same seed, same draw", func(t *testing.T) {
+		first, err := services.DrawQuestions(42, rules, pools)
+		assert.NoError(t, err)
+
+		// The order the pool is loaded in must not matter.
+		second, err := services.DrawQuestions(42, rules, [][]uint{{5, 4, 3, 2, 1}, {7, 6, 5, 4}})
+		assert.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		assert.Len(t, first, 4)
+	})
+
+	t.Run("No question is drawn twice", func(t *testing.T) {
+		for seed := int64(0); seed < 100; seed++ {
+			ids, err := services.DrawQuestions(seed, rules, pools)
+			assert.NoError(t, err)
+
+			seen := make(map[uint]bool)
+			for _, id := range ids {
+				assert.False(t, seen[id], "question %d drawn twice with seed %d", id, seed)
+				seen[id] = true
+			}
+		}
+	})
+
+	t.Run("Different seeds give different draws", func(t *testing.T) {
+		draws := make(map[[4]uint]bool)
+		for seed := int64(0); seed < 20; seed++ {
+			ids, err := services.DrawQuestions(seed, rules, pools)
+			assert.NoError(t, err)
+			draws[[4]uint{ids[0], ids[1], ids[2], ids[3]}] = true
+		}
+		assert.Greater(t, len(draws), 1)
+	})
+
+	t.Run("Pool too small", func(t *testing.T) {
+		_, err := services.DrawQuestions(1, []models.QuizDrawRule{{Tag: "algebra", Count: 3}}, [][]uint{{1, 2}})
+		assert.Error(t, err)
+	})
+}
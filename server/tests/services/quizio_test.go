@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"server/app/models"
+	"server/app/quizio"
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const giftSample = `// Sample quiz
+$CATEGORY: geography
+
+::Capital::What is the capital of France? {
+	=Paris
+	~London
+	~Berlin # Wrong country
+}
+
+::Primes::Which of these are prime? {
+	~%50%2
+	~%50%3
+	~%-100%4
+}
+
+The sun rises in the east. {T}
+
+::Pi::Give pi to two decimals. {#3.14:0.01}
+
+Two plus two is {=four =4}.
+
+::Reflection::Describe your approach. {}
+
+::Pairs::Match the capitals. {
+	=France -> Paris
+	=Spain -> Madrid
+}
+`
+
+func TestParseGIFT(t *testing.T) {
+	questions, issues, err := quizio.ParseGIFT(strings.NewReader(giftSample))
+	require.NoError(t, err)
+	require.Len(t, questions, 6)
+
+	assert.Equal(t, models.SingleCorrect, questions[0].Type)
+	assert.Equal(t, "Capital", questions[0].Title)
+	assert.Equal(t, "What is the capital of France?", questions[0].Description)
+	require.Len(t, questions[0].Options, 3)
+	assert.True(t, questions[0].Options[0].IsCorrect)
+	assert.Equal(t, "Berlin", questions[0].Options[2].Text)
+
+	assert.Equal(t, models.MultiCorrect, questions[1].Type)
+	assert.True(t, questions[1].Options[0].IsCorrect)
+	assert.True(t, questions[1].Options[1].IsCorrect)
+	assert.False(t, questions[1].Options[2].IsCorrect)
+
+	assert.Equal(t, models.TrueFalse, questions[2].Type)
+
+	assert.Equal(t, models.Numeric, questions[3].Type)
+	require.NotNil(t, questions[3].NumericAnswer)
+	assert.InDelta(t, 3.14, *questions[3].NumericAnswer, 1e-9)
+	assert.InDelta(t, 0.01, questions[3].Tolerance, 1e-9)
+
+	assert.Equal(t, models.ShortText, questions[4].Type)
+	assert.Equal(t, []string{"four", "4"}, questions[4].AcceptedAnswers)
+
+	assert.Equal(t, models.Essay, questions[5].Type)
+
+	require.Len(t, issues, 1)
+	assert.Equal(t, 7, issues[0].Item)
+	assert.Equal(t, "Pairs", issues[0].Title)
+
+	for i := range questions {
+		assert.NoError(t, services.PrepareQuestion(&questions[i]), questions[i].Title)
+	}
+}
+
+func TestGIFTRoundTrip(t *testing.T) {
+	questions, _, err := quizio.ParseGIFT(strings.NewReader(giftSample))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	issues, err := quizio.WriteGIFT(&buf, &models.Quiz{Title: "Sample", Questions: questions})
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	again, issues, err := quizio.ParseGIFT(&buf)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+	assertSameQuestions(t, questions, again)
+}
+
+func TestGIFTExportReportsOrdering(t *testing.T) {
+	quiz := &models.Quiz{
+		Title: "Ordering",
+		Questions: []models.Question{{
+			Title:   "Sort the numbers",
+			Type:    models.Ordering,
+			Options: []models.Option{{Text: "1", Position: 1}, {Text: "2", Position: 2}},
+		}},
+		DrawRules: []models.QuizDrawRule{{Tag: "algebra", Count: 2}},
+	}
+
+	var buf bytes.Buffer
+	issues, err := quizio.WriteGIFT(&buf, quiz)
+	require.NoError(t, err)
+	assert.Len(t, issues, 2)
+	assert.NotContains(t, buf.String(), "Sort the numbers")
+}
+
+func TestQTIRoundTrip(t *testing.T) {
+	pi, answer := 3.14, 42.0
+	quiz := &models.Quiz{
+		Title: "Everything",
+		Questions: []models.Question{
+			{Title: "Capital", Description: "Capital of France?", Type: models.SingleCorrect, Points: 2,
+				Options: []models.Option{{Text: "Paris", IsCorrect: true}, {Text: "Rome & Milan"}}},
+			{Title: "Primes", Type: models.MultiCorrect, Points: 1,
+				Options: []models.Option{{Text: "2", IsCorrect: true}, {Text: "3", IsCorrect: true}, {Text: "4"}}},
+			{Title: "Order", Type: models.Ordering, Points: 3,
+				Options: []models.Option{{Text: "c", Position: 3}, {Text: "a", Position: 1}, {Text: "b", Position: 2}}},
+			{Title: "Pi", Type: models.Numeric, Points: 1, NumericAnswer: &pi, Tolerance: 0.01},
+			{Title: "Answer", Type: models.Numeric, Points: 1, NumericAnswer: &answer},
+			{Title: "Word", Type: models.ShortText, Points: 1, AcceptedAnswers: []string{"four", "4"}},
+			{Title: "Essay", Type: models.Essay, Points: 5},
+		},
+	}
+
+	var buf bytes.Buffer
+	issues, err := quizio.WriteQTI(&buf, quiz)
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	questions, issues, err := quizio.ParseQTI(buf.Bytes())
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+	assertSameQuestions(t, quiz.Questions, questions)
+
+	for i := range questions {
+		assert.Equal(t, quiz.Questions[i].Points, questions[i].Points, questions[i].Title)
+	}
+}
+
+func TestParseQTIReportsUnsupportedItems(t *testing.T) {
+	item := `<?xml version="1.0" encoding="UTF-8"?>
+<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="match" title="Match">
+  <responseDeclaration identifier="RESPONSE" cardinality="multiple" baseType="directedPair"/>
+  <itemBody>
+    <matchInteraction responseIdentifier="RESPONSE"/>
+  </itemBody>
+</assessmentItem>`
+
+	questions, issues, err := quizio.ParseQTI([]byte(item))
+	require.NoError(t, err)
+	assert.Empty(t, questions)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "Match", issues[0].Title)
+	assert.Contains(t, issues[0].Reason, "matchInteraction")
+
+	_, _, err = quizio.ParseQTI([]byte("not xml at all"))
+	assert.Error(t, err)
+}
+
+// assertSameQuestions compares the parts of questions the exchange formats carry.
+func assertSameQuestions(t *testing.T, want, got []models.Question) {
+	t.Helper()
+	require.Len(t, got, len(want))
+
+	for i := range want {
+		assert.Equal(t, want[i].Title, got[i].Title)
+		assert.Equal(t, want[i].Description, got[i].Description)
+		assert.Equal(t, want[i].Type, got[i].Type)
+		assert.Equal(t, want[i].AcceptedAnswers, got[i].AcceptedAnswers, want[i].Title)
+		assert.InDelta(t, want[i].Tolerance, got[i].Tolerance, 1e-9, want[i].Title)
+
+		if want[i].NumericAnswer != nil {
+			require.NotNil(t, got[i].NumericAnswer, want[i].Title)
+			assert.InDelta(t, *want[i].NumericAnswer, *got[i].NumericAnswer, 1e-9)
+		}
+
+		require.Len(t, got[i].Options, len(want[i].Options), want[i].Title)
+		for j := range want[i].Options {
+			assert.Equal(t, want[i].Options[j].Text, got[i].Options[j].Text)
+			assert.Equal(t, want[i].Options[j].IsCorrect, got[i].Options[j].IsCorrect)
+			assert.Equal(t, want[i].Options[j].Position, got[i].Options[j].Position)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"testing"
+
+	"server/app/models"
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func option(id uint, correct bool) models.Option {
+	return models.Option{Model: gorm.Model{ID: id}, IsCorrect: correct}
+}
+
+func selection(ids []uint) models.Answer {
+	return models.Answer{SelectedOptions: ids}
+}
+
+func TestScoreSingleCorrect(t *testing.T) {
+	question := models.Question{
+		Type:    models.SingleCorrect,
+		Points:  4,
+		Options: []models.Option{option(1, true), option(2, false), option(3, false)},
+	}
+
+	tests := []struct {
+		name     string
+		selected []uint
+		expected float64
+	}{
+		{name: "Correct option", selected: []uint{1}, expected: 4},
+		{name: "Wrong option", selected: []uint{2}, expected: 0},
+		{name: "Nothing selected", selected: nil, expected: 0},
+		{name: "Several options", selected: []uint{1, 2}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Policies only apply to MultiCorrect questions.
+			for _, policy := range []models.ScoringPolicy{models.ScoringAllOrNothing, models.ScoringPartialCredit, models.ScoringNegativeMarking} {
+				assert.Equal(t, tt.expected, services.ScoreAnswer(question, selection(tt.selected), policy))
+			}
+		})
+	}
+}
+
+func TestScoreMultiCorrect(t *testing.T) {
+	question := models.Question{
+		Type:   models.MultiCorrect,
+		Points: 6,
+		Options: []models.Option{
+			option(1, true), option(2, true), option(3, true),
+			option(4, false), option(5, false),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		policy   models.ScoringPolicy
+		selected []uint
+		expected float64
+	}{
+		{name: "All or nothing, exact", policy: models.ScoringAllOrNothing, selected: []uint{1, 2, 3}, expected: 6},
+		{name: "All or nothing, missing one", policy: models.ScoringAllOrNothing, selected: []uint{1, 2}, expected: 0},
+		{name: "All or nothing, extra wrong", policy: models.ScoringAllOrNothing, selected: []uint{1, 2, 3, 4}, expected: 0},
+		{name: "Empty policy defaults to all or nothing", policy: "", selected: []uint{1, 2}, expected: 0},
+		{name: "Partial, two of three", policy: models.ScoringPartialCredit, selected: []uint{1, 2}, expected: 4},
+		{name: "Partial, wrong option voids", policy: models.ScoringPartialCredit, selected: []uint{1, 2, 4}, expected: 0},
+		{name: "Partial, exact", policy: models.ScoringPartialCredit, selected: []uint{3, 2, 1}, expected: 6},
+		{name: "Negative, two correct one wrong", policy: models.ScoringNegativeMarking, selected: []uint{1, 2, 4}, expected: 1},
+		{name: "Negative, everything selected", policy: models.ScoringNegativeMarking, selected: []uint{1, 2, 3, 4, 5}, expected: 0},
+		{name: "Negative, never below zero", policy: models.ScoringNegativeMarking, selected: []uint{4, 5}, expected: 0},
+		{name: "Negative, one correct", policy: models.ScoringNegativeMarking, selected: []uint{3}, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, services.ScoreAnswer(question, selection(tt.selected), tt.policy))
+		})
+	}
+}
+
+func TestScoreTrueFalse(t *testing.T) {
+	question := models.Question{
+		Type:    models.TrueFalse,
+		Points:  1,
+		Options: []models.Option{option(1, false), option(2, true)},
+	}
+
+	assert.Equal(t, float64(1), services.ScoreAnswer(question, selection([]uint{2}), models.ScoringPartialCredit))
+	assert.Equal(t, float64(0), services.ScoreAnswer(question, selection([]uint{1}), models.ScoringPartialCredit))
+	assert.Equal(t, float64(0), services.ScoreAnswer(question, selection([]uint{1, 2}), models.ScoringPartialCredit))
+}
+
+func TestScoreNumeric(t *testing.T) {
+	key := 3.14
+	question := models.Question{Type: models.Numeric, Points: 2, NumericAnswer: &key, Tolerance: 0.01}
+
+	tests := []struct {
+		name     string
+		value    *float64
+		expected float64
+	}{
+		{name: "Exact", value: ptr(3.14), expected: 2},
+		{name: "Within tolerance", value: ptr(3.15), expected: 2},
+		{name: "Outside tolerance", value: ptr(3.16), expected: 0},
+		{name: "Unanswered", value: nil, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := models.Answer{NumericAnswer: tt.value}
+			assert.Equal(t, tt.expected, services.ScoreAnswer(question, answer, models.ScoringAllOrNothing))
+		})
+	}
+}
+
+func TestScoreShortText(t *testing.T) {
+	question := models.Question{
+		Type:            models.ShortText,
+		Points:          3,
+		AcceptedAnswers: []string{"Ada Lovelace", "Lovelace"},
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		expected float64
+	}{
+		{name: "Exact", text: "Ada Lovelace", expected: 3},
+		{name: "Case and whitespace", text: "  ada   LOVELACE ", expected: 3},
+		{name: "Alternative", text: "lovelace", expected: 3},
+		{name: "Wrong", text: "Babbage", expected: 0},
+		{name: "Empty", text: "   ", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := models.Answer{TextAnswer: tt.text}
+			assert.Equal(t, tt.expected, services.ScoreAnswer(question, answer, models.ScoringAllOrNothing))
+		})
+	}
+}
+
+func TestScoreOrdering(t *testing.T) {
+	question := models.Question{
+		Type:   models.Ordering,
+		Points: 4,
+		Options: []models.Option{
+			{Model: gorm.Model{ID: 1}, Position: 3},
+			{Model: gorm.Model{ID: 2}, Position: 1},
+			{Model: gorm.Model{ID: 3}, Position: 4},
+			{Model: gorm.Model{ID: 4}, Position: 2},
+		},
+	}
+
+	correct := []uint{2, 4, 1, 3}
+	swapped := []uint{2, 4, 3, 1}
+
+	assert.Equal(t, float64(4), services.ScoreAnswer(question, selection(correct), models.ScoringAllOrNothing))
+	assert.Equal(t, float64(0), services.ScoreAnswer(question, selection(swapped), models.ScoringAllOrNothing))
+	assert.Equal(t, float64(2), services.ScoreAnswer(question, selection(swapped), models.ScoringPartialCredit))
+	assert.Equal(t, float64(0), services.ScoreAnswer(question, selection([]uint{2, 4}), models.ScoringPartialCredit))
+}
+
+func TestScoreEssay(t *testing.T) {
+	question := models.Question{Type: models.Essay, Points: 10}
+	answer := models.Answer{TextAnswer: "A long and thoughtful answer"}
+
+	// Essays are graded by hand, never automatically.
+	assert.Equal(t, float64(0), services.ScoreAnswer(question, answer, models.ScoringPartialCredit))
+}
+
+func TestPrepareQuestion(t *testing.T) {
+	t.Run("Ordering without positions takes the given order", func(t *testing.T) {
+		question := models.Question{
+			Title:   "Order these",
+			Type:    models.Ordering,
+			Options: []models.Option{{Text: "a"}, {Text: "b"}, {Text: "c"}},
+		}
+
+		assert.NoError(t, services.PrepareQuestion(&question))
+		for i, option := range question.Options {
+			assert.Equal(t, i+1, option.Position)
+		}
+	})
+
+	t.Run("Numeric without answer", func(t *testing.T) {
+		question := models.Question{Title: "Pi", Type: models.Numeric}
+		assert.Error(t, services.PrepareQuestion(&question))
+	})
+
+	t.Run("Short text drops blank answers", func(t *testing.T) {
+		question := models.Question{Title: "Name", Type: models.ShortText, AcceptedAnswers: []string{" ", " Ada "}}
+		assert.NoError(t, services.PrepareQuestion(&question))
+		assert.Equal(t, []string{"Ada"}, question.AcceptedAnswers)
+	})
+
+	t.Run("Single correct with two correct options", func(t *testing.T) {
+		question := models.Question{
+			Title:   "Pick one",
+			Type:    models.SingleCorrect,
+			Options: []models.Option{option(1, true), option(2, true)},
+		}
+		assert.Error(t, services.PrepareQuestion(&question))
+	})
+
+	t.Run("Essay with options", func(t *testing.T) {
+		question := models.Question{Title: "Discuss", Type: models.Essay, Options: []models.Option{option(1, true)}}
+		assert.Error(t, services.PrepareQuestion(&question))
+	})
+
+	t.Run("Unknown type", func(t *testing.T) {
+		question := models.Question{Title: "Mystery", Type: models.QuestionType(42)}
+		assert.Error(t, services.PrepareQuestion(&question))
+	})
+}
+
+func ptr(value float64) *float64 {
+	return &value
+}
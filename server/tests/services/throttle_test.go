@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"server/app/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottlePolicyDelay(t *testing.T) {
+	policy := services.ThrottlePolicy{BackoffAfter: 3, BaseDelay: time.Second, LockAfter: 10, Lockout: 15 * time.Minute}
+
+	tests := []struct {
+		failures      int
+		expectedDelay time.Duration
+		expectedLock  bool
+	}{
+		{failures: 0},
+		{failures: 2},
+		{failures: 3, expectedDelay: time.Second},
+		{failures: 4, expectedDelay: 2 * time.Second},
+		{failures: 9, expectedDelay: 64 * time.Second},
+		{failures: 10, expectedDelay: 15 * time.Minute, expectedLock: true},
+		{failures: 25, expectedDelay: 15 * time.Minute, expectedLock: true},
+	}
+
+	for _, tt := range tests {
+		delay, locked := policy.Delay(tt.failures)
+		assert.Equal(t, tt.expectedDelay, delay, "after %d failures", tt.failures)
+		assert.Equal(t, tt.expectedLock, locked, "after %d failures", tt.failures)
+	}
+
+	capped := services.ThrottlePolicy{BackoffAfter: 1, BaseDelay: time.Minute, LockAfter: 50, Lockout: 10 * time.Minute}
+	delay, locked := capped.Delay(40)
+	assert.Equal(t, 10*time.Minute, delay, "backoff never exceeds the lockout")
+	assert.False(t, locked)
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"server/app/totp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The last six digits of the eight-digit codes in RFC 6238, appendix B.
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1111111111, expected: "050471"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, code, "at %d", tt.unix)
+	}
+
+	_, err := totp.Code("not base32!", 1)
+	assert.Error(t, err)
+}
+
+func TestTOTPValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := totp.Step(now)
+
+	codeAt := func(step int64) string {
+		code, err := totp.Code(rfcSecret, step)
+		require.NoError(t, err)
+		return code
+	}
+
+	tests := []struct {
+		name  string
+		code  string
+		valid bool
+	}{
+		{name: "Current step", code: codeAt(step), valid: true},
+		{name: "Previous step", code: codeAt(step - 1), valid: true},
+		{name: "Next step", code: codeAt(step + 1), valid: true},
+		{name: "Two steps old", code: codeAt(step - 2)},
+		{name: "Spaces are ignored", code: codeAt(step)[:3] + " " + codeAt(step)[3:], valid: true},
+		{name: "Too short", code: codeAt(step)[:5]},
+		{name: "Empty", code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := totp.Validate(rfcSecret, tt.code, now)
+			assert.Equal(t, tt.valid, ok)
+		})
+	}
+
+	matched, ok := totp.Validate(rfcSecret, codeAt(step-1), now)
+	require.True(t, ok)
+	assert.Equal(t, step-1, matched, "Validate reports the step the code matched")
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	u, err := url.Parse(totp.ProvisioningURI("Glass", "ada@example.com", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Glass:ada@example.com", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "Glass", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
@@ -0,0 +1,231 @@
+package setup
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// MockOIDCUser is the account that signs in at the mock provider.
+type MockOIDCUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// MockOIDCProvider is a local OpenID Connect provider for tests. It serves the
+// discovery document, the signing keys and the token endpoint, checking PKCE,
+// and signs ID tokens for whichever user is set to sign in.
+type MockOIDCProvider struct {
+	Server   *httptest.Server
+	ClientID string
+
+	mu    sync.Mutex
+	key   *rsa.PrivateKey
+	user  MockOIDCUser
+	codes map[string]mockAuthorization
+	// Audience, when set, replaces the client ID as the audience of ID tokens.
+	Audience string
+}
+
+type mockAuthorization struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+	user        MockOIDCUser
+}
+
+// NewMockOIDCProvider starts a mock provider for the given client ID. Close it when done.
+func NewMockOIDCProvider(clientID string) (*MockOIDCProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MockOIDCProvider{ClientID: clientID, key: key, codes: make(map[string]mockAuthorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	return m, nil
+}
+
+// Issuer returns the issuer URL of the provider.
+func (m *MockOIDCProvider) Issuer() string {
+	return m.Server.URL
+}
+
+// Close shuts the provider down.
+func (m *MockOIDCProvider) Close() {
+	m.Server.Close()
+}
+
+// SignInAs sets the user that signs in at the provider next.
+func (m *MockOIDCProvider) SignInAs(user MockOIDCUser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.user = user
+}
+
+// Authorize plays the part of the user signing in at the provider: it checks
+// the authorization URL the app sent the user to and returns the URL the
+// provider sends the user back to, with the code and state.
+func (m *MockOIDCProvider) Authorize(authURL string) (*url.URL, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	switch {
+	case query.Get("response_type") != "code":
+		return nil, errors.New("response_type must be code")
+	case query.Get("client_id") != m.ClientID:
+		return nil, errors.New("unknown client_id")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return nil, errors.New("PKCE with S256 is required")
+	case query.Get("state") == "" || query.Get("nonce") == "":
+		return nil, errors.New("state and nonce are required")
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		redirectURI: query.Get("redirect_uri"),
+		user:        m.user,
+	}
+	m.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		return nil, err
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	return redirect, nil
+}
+
+// SignIDToken signs an ID token with the key of the provider, for tests of
+// token validation.
+func (m *MockOIDCProvider) SignIDToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock-key"
+	return token.SignedString(m.key)
+}
+
+func (m *MockOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 m.Issuer(),
+		"authorization_endpoint": m.Issuer() + "/authorize",
+		"token_endpoint":         m.Issuer() + "/token",
+		"jwks_uri":               m.Issuer() + "/jwks",
+	})
+}
+
+func (m *MockOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := m.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *MockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code", !ok:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case r.PostForm.Get("client_id") != m.ClientID:
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	case r.PostForm.Get("redirect_uri") != auth.redirectURI,
+		base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	audience := m.Audience
+	if audience == "" {
+		audience = m.ClientID
+	}
+
+	now := time.Now()
+	idToken, err := m.SignIDToken(jwt.MapClaims{
+		"iss":            m.Issuer(),
+		"aud":            audience,
+		"sub":            auth.user.Subject,
+		"email":          auth.user.Email,
+		"email_verified": auth.user.EmailVerified,
+		"given_name":     auth.user.GivenName,
+		"family_name":    auth.user.FamilyName,
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating code: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}